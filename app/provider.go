@@ -3,19 +3,31 @@ package app
 import (
 	"go.uber.org/dig"
 	"timer/app/migrator"
+	"timer/app/retention"
 	"timer/app/scheduler"
+	"timer/app/standalone"
 	"timer/app/webserver"
 	"timer/common/conf"
+	"timer/dao/apikey"
+	"timer/dao/bucket"
+	"timer/dao/calendar"
+	"timer/dao/maintenance"
+	migratorDao "timer/dao/migrator"
 	"timer/dao/task"
-	mysqlDao "timer/dao/timer"
+	timerDao "timer/dao/timer"
 	"timer/pkg/bloom"
 	"timer/pkg/cron"
+	"timer/pkg/database"
 	"timer/pkg/hash"
-	"timer/pkg/mysql"
+	"timer/pkg/membership"
+	"timer/pkg/ratelimit"
 	"timer/pkg/redis"
+	"timer/pkg/schema"
+	standalonepkg "timer/pkg/standalone"
 	"timer/pkg/xhttp"
 	executorservice "timer/service/executor"
 	migratorservice "timer/service/migrator"
+	retentionservice "timer/service/retention"
 	schedulerservice "timer/service/scheduler"
 	triggerservice "timer/service/trigger"
 	"timer/service/webservice"
@@ -39,45 +51,78 @@ func provideConf() {
 	contain.Provide(conf.GetDefaultTriggerAppConfig)
 	contain.Provide(conf.GetDefaultRedisConfig)
 	contain.Provide(conf.GetDefaultMySQLConfig)
+	contain.Provide(conf.GetDefaultDatabaseConfig)
 	contain.Provide(conf.GetDefaultWebServerAppConfig)
+	contain.Provide(conf.GetDefaultAuthConfig)
+	contain.Provide(conf.GetDefaultQuotaConfig)
+	contain.Provide(conf.GetDefaultScheduleConfig)
+	contain.Provide(conf.GetDefaultRetentionConfig)
+	contain.Provide(conf.GetDefaultStandaloneConfig)
+	contain.Provide(conf.GetDefaultMaintenanceConfig)
 }
 
 func providePKG() {
 	contain.Provide(bloom.NewFilter)
 	contain.Provide(hash.NewMurmur3Encryptor)
 	contain.Provide(hash.NewSHA1Encryptor)
-	contain.Provide(mysql.GetClient)
+	contain.Provide(database.GetClient)
 	contain.Provide(redis.GetClient)
 	contain.Provide(cron.NewCronParser)
 	contain.Provide(xhttp.NewJSONClient)
+	contain.Provide(ratelimit.NewLimiter)
+	contain.Provide(schema.NewMigrator)
+	contain.Provide(membership.NewRegistry)
+	contain.Provide(standalonepkg.NewServer)
 }
 
 func provideDao() {
-	contain.Provide(mysqlDao.NewTimerDao)
-	contain.Provide(task.NewTaskDao)
+	contain.Provide(timerDao.NewRepository)
+	contain.Provide(task.NewRepository)
 	contain.Provide(task.NewTaskCache)
+	contain.Provide(bucket.NewBucketDao)
+	contain.Provide(apikey.NewAPIKeyDao)
+	contain.Provide(calendar.NewCalendarDao)
+	contain.Provide(maintenance.NewMaintenanceDao)
+	contain.Provide(migratorDao.NewStateDao)
 }
 
 func provideServer() {
 	contain.Provide(migratorservice.NewWorker)
 	contain.Provide(webservice.NewTimerServer)
+	contain.Provide(webservice.NewHealthServer)
+	contain.Provide(webservice.NewAuthServer)
+	contain.Provide(webservice.NewCronServer)
+	contain.Provide(webservice.NewBucketServer)
+	contain.Provide(webservice.NewCalendarServer)
+	contain.Provide(webservice.NewMaintenanceServer)
+	contain.Provide(webservice.NewTaskServer)
 	contain.Provide(executorservice.NewTimerService)
 	contain.Provide(executorservice.NewWorker)
 	contain.Provide(triggerservice.NewWorker)
 	contain.Provide(triggerservice.NewTaskService)
 	contain.Provide(schedulerservice.NewWorker)
+	contain.Provide(retentionservice.NewPartitionManager)
+	contain.Provide(retentionservice.NewWorker)
 }
 
 func provideHandler() {
 	contain.Provide(webserver.NewTimerHandler)
 	contain.Provide(webserver.NewTaskHandler)
+	contain.Provide(webserver.NewHealthHandler)
+	contain.Provide(webserver.NewAuthHandler)
+	contain.Provide(webserver.NewCronHandler)
+	contain.Provide(webserver.NewBucketHandler)
+	contain.Provide(webserver.NewCalendarHandler)
+	contain.Provide(webserver.NewMaintenanceHandler)
 }
 
 func provideApp() {
 	contain.Provide(migrator.NewMigratorApp)
 	contain.Provide(webserver.NewServer)
 	contain.Provide(scheduler.NewWorkerApp)
-	contain.Provide(scheduler.NewWorkerApp)
+	contain.Provide(scheduler.NewRegistryApp)
+	contain.Provide(retention.NewRetentionApp)
+	contain.Provide(standalone.NewStandaloneApp)
 }
 
 func GetWebApp() *webserver.Server {
@@ -100,6 +145,36 @@ func GetSchedulerApp() *scheduler.WorkerApp {
 	return app
 }
 
+func GetRegistryApp() *scheduler.RegistryApp {
+	var app *scheduler.RegistryApp
+	if err := contain.Invoke(func(registryApp *scheduler.RegistryApp) {
+		app = registryApp
+	}); err != nil {
+		panic(err)
+	}
+	return app
+}
+
+func GetSchemaMigrator() *schema.Migrator {
+	var migrator *schema.Migrator
+	if err := contain.Invoke(func(_m *schema.Migrator) {
+		migrator = _m
+	}); err != nil {
+		panic(err)
+	}
+	return migrator
+}
+
+func GetTaskCache() *task.TaskCache {
+	var cache *task.TaskCache
+	if err := contain.Invoke(func(_c *task.TaskCache) {
+		cache = _c
+	}); err != nil {
+		panic(err)
+	}
+	return cache
+}
+
 func GetMigratorApp() *migrator.MigratorApp {
 	var migratorApp *migrator.MigratorApp
 	if err := contain.Invoke(func(_m *migrator.MigratorApp) {
@@ -109,3 +184,23 @@ func GetMigratorApp() *migrator.MigratorApp {
 	}
 	return migratorApp
 }
+
+func GetRetentionApp() *retention.RetentionApp {
+	var retentionApp *retention.RetentionApp
+	if err := contain.Invoke(func(_r *retention.RetentionApp) {
+		retentionApp = _r
+	}); err != nil {
+		panic(err)
+	}
+	return retentionApp
+}
+
+func GetStandaloneApp() *standalone.StandaloneApp {
+	var standaloneApp *standalone.StandaloneApp
+	if err := contain.Invoke(func(_s *standalone.StandaloneApp) {
+		standaloneApp = _s
+	}); err != nil {
+		panic(err)
+	}
+	return standaloneApp
+}
@@ -0,0 +1,40 @@
+package retention
+
+import (
+	"context"
+	"sync"
+	"timer/pkg/logger"
+	service "timer/service/retention"
+)
+
+// RetentionApp 定期归档并清理过期的 task 流水
+type RetentionApp struct {
+	sync.Once
+	ctx    context.Context
+	stop   func()
+	worker *service.Worker
+}
+
+func NewRetentionApp(worker *service.Worker) *RetentionApp {
+	r := RetentionApp{
+		worker: worker,
+	}
+
+	r.ctx, r.stop = context.WithCancel(context.Background())
+	return &r
+}
+
+func (r *RetentionApp) Start() {
+	r.Do(func() {
+		logger.InfoContext(r.ctx, "retention is starting")
+		go func() {
+			if err := r.worker.Start(r.ctx); err != nil {
+				logger.ErrorContextf(r.ctx, "start worker failed, err: %v", err)
+			}
+		}()
+	})
+}
+
+func (r *RetentionApp) Stop() {
+	r.stop()
+}
@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"timer/pkg/logger"
+	"timer/pkg/membership"
+)
+
+// RegistryApp 上报当前节点的心跳，存活节点之间按 rendezvous hashing 分配分桶
+// 调度器和健康检查都依赖成员表，独立于调度器启动
+type RegistryApp struct {
+	once     sync.Once
+	ctx      context.Context
+	stop     func()
+	done     chan struct{}
+	registry *membership.Registry
+}
+
+func NewRegistryApp(registry *membership.Registry) *RegistryApp {
+	r := RegistryApp{
+		registry: registry,
+		done:     make(chan struct{}),
+	}
+
+	r.ctx, r.stop = context.WithCancel(context.Background())
+	return &r
+}
+
+func (r *RegistryApp) Start() {
+	r.once.Do(func() {
+		logger.InfoContext(r.ctx, "registry is starting")
+		go func() {
+			defer close(r.done)
+			r.registry.Run(r.ctx)
+		}()
+	})
+}
+
+// Stop 主动下线并等待下线完成，其他节点无需等待心跳过期即可接管分桶
+func (r *RegistryApp) Stop() {
+	r.stop()
+	// 没有启动过时直接返回
+	r.once.Do(func() {
+		close(r.done)
+	})
+	<-r.done
+}
@@ -0,0 +1,44 @@
+package standalone
+
+import (
+	"context"
+	"sync"
+	"timer/pkg/logger"
+	"timer/pkg/standalone"
+)
+
+// StandaloneApp 单机模式下内嵌存储的生命周期：恢复快照、推进过期时间、定期及退出时写快照
+type StandaloneApp struct {
+	sync.Once
+	ctx    context.Context
+	stop   func()
+	server *standalone.Server
+}
+
+func NewStandaloneApp(server *standalone.Server) *StandaloneApp {
+	s := StandaloneApp{
+		server: server,
+	}
+
+	s.ctx, s.stop = context.WithCancel(context.Background())
+	return &s
+}
+
+// Start 需要在 schema 迁移和其他模块启动之前调用
+func (s *StandaloneApp) Start() {
+	s.Do(func() {
+		logger.InfoContext(s.ctx, "standalone is starting")
+		if err := s.server.Restore(s.ctx); err != nil {
+			panic(err)
+		}
+		go s.server.Start(s.ctx)
+	})
+}
+
+func (s *StandaloneApp) Stop() {
+	s.stop()
+	if err := s.server.Snapshot(context.Background()); err != nil {
+		logger.Errorf("standalone write snapshot failed, err: %v", err)
+	}
+	s.server.Close()
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"timer/common/conf"
+	"timer/common/consts"
+	"timer/common/model/po"
+	"timer/common/model/vo"
+	"timer/dao/task"
+	"timer/service/webservice"
+)
+
+// TestStandaloneCreateEnableFire 单机模式下按 main 的顺序启动各模块，创建并激活定时器后回调被触发
+func TestStandaloneCreateEnableFire(t *testing.T) {
+	ctx := context.Background()
+	snapshotFile := t.TempDir() + "/snapshot.db"
+	// 替换配置文件中的存储配置，不依赖外部的 MySQL 和 Redis
+	decorators := []interface{}{
+		func(*conf.StandaloneConfig) *conf.StandaloneConfig {
+			return &conf.StandaloneConfig{Enabled: true, RedisAddress: "127.0.0.1:0", SnapshotFile: snapshotFile}
+		},
+		func(*conf.DatabaseConfig) *conf.DatabaseConfig {
+			return &conf.DatabaseConfig{Driver: conf.DriverSQLite, DSN: "file:timer_standalone_test?mode=memory&cache=shared&_busy_timeout=5000"}
+		},
+		// 内嵌 redis 启动时改写 redis 配置，使用副本避免修改全局配置
+		func(config *conf.RedisConfig) *conf.RedisConfig {
+			copied := *config
+			return &copied
+		},
+	}
+	for _, decorator := range decorators {
+		if err := contain.Decorate(decorator); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var fired atomic.Int32
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fired.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"code":0}`))
+	}))
+	defer callback.Close()
+
+	standaloneApp := GetStandaloneApp()
+	standaloneApp.Start()
+	defer standaloneApp.Stop()
+	if _, err := GetSchemaMigrator().Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	registryApp := GetRegistryApp()
+	registryApp.Start()
+	defer registryApp.Stop()
+	GetSchedulerApp().Start()
+
+	var (
+		timerServer *webservice.TimerServer
+		taskDAO     task.Repository
+	)
+	if err := contain.Invoke(func(s *webservice.TimerServer, dao task.Repository) {
+		timerServer, taskDAO = s, dao
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := timerServer.CreateTimer(ctx, &vo.Timer{
+		App:             "standalone",
+		Name:            "every-second",
+		Cron:            "* * * * * * *",
+		JitterSeconds:   po.JitterDisabled,
+		NotifyHTTPParam: &vo.NotifyHTTPParam{Method: http.MethodPost, URL: callback.URL, Body: "{}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := timerServer.EnableTimer(ctx, "standalone", resp.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	// 调度器认领分桶之后开始触发，每秒一次
+	deadline := time.Now().Add(20 * time.Second)
+	for fired.Load() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("callback fired %d times in 20s, want at least 2", fired.Load())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		tasks, err := taskDAO.GetTasks(ctx, task.WithTimerID(resp.Id), task.WithStatus(int32(consts.Successed.ToInt())))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tasks) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no successed task after callback fired")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
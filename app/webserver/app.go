@@ -12,11 +12,20 @@ import (
 type Server struct {
 	engine *gin.Engine
 
-	timerHandler *TimerHandler
-	taskHandler  *TaskHandler
-
-	timerRouter *gin.RouterGroup
-	taskRouter  *gin.RouterGroup
+	timerHandler       *TimerHandler
+	taskHandler        *TaskHandler
+	healthHandler      *HealthHandler
+	authHandler        *AuthHandler
+	cronHandler        *CronHandler
+	bucketHandler      *BucketHandler
+	calendarHandler    *CalendarHandler
+	maintenanceHandler *MaintenanceHandler
+
+	timerRouter    *gin.RouterGroup
+	taskRouter     *gin.RouterGroup
+	adminRouter    *gin.RouterGroup
+	cronRouter     *gin.RouterGroup
+	calendarRouter *gin.RouterGroup
 
 	conf *conf.WebServerAppConfig
 }
@@ -27,22 +36,37 @@ type Server struct {
 // @version         0.0.0
 // @host 127.0.0.1:8080
 // @BasePath /api/dev
-func NewServer(timerHandler *TimerHandler, taskHandler *TaskHandler, conf *conf.WebServerAppConfig) *Server {
+func NewServer(timerHandler *TimerHandler, taskHandler *TaskHandler, healthHandler *HealthHandler,
+	authHandler *AuthHandler, cronHandler *CronHandler, bucketHandler *BucketHandler, calendarHandler *CalendarHandler,
+	maintenanceHandler *MaintenanceHandler, conf *conf.WebServerAppConfig) *Server {
 	server := &Server{
-		engine:       gin.Default(),
-		timerHandler: timerHandler,
-		taskHandler:  taskHandler,
-		conf:         conf,
+		engine:             gin.Default(),
+		timerHandler:       timerHandler,
+		taskHandler:        taskHandler,
+		healthHandler:      healthHandler,
+		authHandler:        authHandler,
+		cronHandler:        cronHandler,
+		bucketHandler:      bucketHandler,
+		calendarHandler:    calendarHandler,
+		maintenanceHandler: maintenanceHandler,
+		conf:               conf,
 	}
 
 	// 跨域和 设置 http header 头选项
-	server.engine.Use(CrosHandler())
+	server.engine.Use(CrosHandler(conf.AllowOrigins))
 
 	baseGroup := server.engine.Group("api/dev")
+	// 业务接口需要 API Key 鉴权
+	baseGroup.Use(authHandler.Authenticate())
 
 	// 设置路由组
 	server.timerRouter = baseGroup.Group("/timer")
 	server.taskRouter = baseGroup.Group("/task")
+	server.cronRouter = baseGroup.Group("/cron")
+	server.calendarRouter = baseGroup.Group("/calendar")
+	// 运维接口不走业务前缀
+	server.adminRouter = server.engine.Group("/admin")
+	server.adminRouter.Use(authHandler.AdminAuthenticate())
 
 	// 注册路由
 	// swagger
@@ -50,6 +74,9 @@ func NewServer(timerHandler *TimerHandler, taskHandler *TaskHandler, conf *conf.
 
 	server.registerTimerRouter()
 	server.registerTaskRouter()
+	server.registerCronRouter()
+	server.registerCalendarRouter()
+	server.registerAdminRouter()
 
 	return server
 }
@@ -65,6 +92,9 @@ func (s *Server) Start() {
 
 func (s *Server) registerBaseRouter() {
 	s.engine.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	// 探针
+	s.engine.GET("/healthz", s.healthHandler.Healthz)
+	s.engine.GET("/readyz", s.healthHandler.Readyz)
 }
 
 func (s *Server) registerTimerRouter() {
@@ -72,8 +102,37 @@ func (s *Server) registerTimerRouter() {
 	s.timerRouter.DELETE("/delete", s.timerHandler.DeleteTimer)
 	s.timerRouter.POST("/enable", s.timerHandler.EnableTimer)
 	s.timerRouter.POST("/unable", s.timerHandler.UnableTimer)
+	s.timerRouter.POST("/successors", s.timerHandler.UpdateSuccessors)
 }
 
 func (s *Server) registerTaskRouter() {
+	s.taskRouter.GET("/list", s.taskHandler.GetTasks)
+	s.taskRouter.GET("/chain", s.taskHandler.GetTaskChain)
+}
+
+func (s *Server) registerCronRouter() {
+	s.cronRouter.GET("/preview", s.cronHandler.Preview)
+}
+
+func (s *Server) registerCalendarRouter() {
+	s.calendarRouter.POST("/create", s.calendarHandler.CreateCalendar)
+	s.calendarRouter.POST("/update", s.calendarHandler.UpdateCalendar)
+	s.calendarRouter.DELETE("/delete", s.calendarHandler.DeleteCalendar)
+	s.calendarRouter.GET("/list", s.calendarHandler.GetCalendars)
+	s.calendarRouter.POST("/import", s.calendarHandler.ImportCalendar)
+}
+
+func (s *Server) registerAdminRouter() {
+	s.adminRouter.GET("/cluster", s.healthHandler.Cluster)
+
+	s.adminRouter.POST("/apikey/create", s.authHandler.CreateAPIKey)
+	s.adminRouter.DELETE("/apikey/delete", s.authHandler.DeleteAPIKey)
+	s.adminRouter.GET("/apikey/list", s.authHandler.GetAPIKeys)
+
+	s.adminRouter.POST("/maintenance/create", s.maintenanceHandler.CreateWindow)
+	s.adminRouter.DELETE("/maintenance/delete", s.maintenanceHandler.DeleteWindow)
+	s.adminRouter.GET("/maintenance/list", s.maintenanceHandler.GetWindows)
 
+	s.adminRouter.GET("/bucket/list", s.bucketHandler.GetLayouts)
+	s.adminRouter.POST("/bucket/set", s.bucketHandler.SetBucketsNum)
 }
@@ -0,0 +1,180 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"timer/common/consts"
+	"timer/common/model/vo"
+	"timer/pkg/logger"
+	"timer/service/webservice"
+)
+
+const (
+	authEnabledCtxKey = "auth_enabled"
+	principalCtxKey   = "principal"
+	authAppCtxKey     = "auth_app"
+)
+
+type AuthHandler struct {
+	authServer authServer
+}
+
+func NewAuthHandler(server *webservice.AuthServer) *AuthHandler {
+	return &AuthHandler{
+		authServer: server,
+	}
+}
+
+// Authenticate 业务接口鉴权中间件，校验 API Key 并记录审计日志
+func (handler *AuthHandler) Authenticate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !handler.authServer.Enabled() {
+			ctx.Next()
+			return
+		}
+		ctx.Set(authEnabledCtxKey, true)
+
+		principal, err := handler.authServer.Authenticate(ctx.Request.Context(), getBearerToken(ctx, "X-API-Key"))
+		if err != nil {
+			if !errors.Is(err, vo.ErrUnauthorized) {
+				logger.Errorf("authenticate failed, err: %v", err)
+			}
+			logger.Warnf("audit: unauthorized request, ip: %s, %s %s", ctx.ClientIP(), ctx.Request.Method, ctx.Request.URL.Path)
+			vo.ResponseWithStatus(ctx, http.StatusUnauthorized, vo.CodeUnauthorized, nil)
+			ctx.Abort()
+			return
+		}
+		ctx.Set(principalCtxKey, principal)
+
+		ctx.Next()
+
+		// 审计日志：谁对哪个 app 做了什么
+		logger.Infof("audit: caller: %s, key id: %d, app: %s, %s %s, status: %d",
+			principal.Name, principal.KeyID, ctx.GetString(authAppCtxKey), ctx.Request.Method, ctx.Request.URL.Path, ctx.Writer.Status())
+	}
+}
+
+// AdminAuthenticate 管理接口鉴权中间件，校验管理员 token
+// 管理接口可以创建 API Key、修改分桶和维护窗口，不受 auth.enabled 控制，没有配置管理员 token 时全部拒绝
+func (handler *AuthHandler) AdminAuthenticate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !handler.authServer.IsAdmin(getBearerToken(ctx, "X-Admin-Token")) {
+			logger.Warnf("audit: unauthorized admin request, ip: %s, %s %s", ctx.ClientIP(), ctx.Request.Method, ctx.Request.URL.Path)
+			vo.ResponseWithStatus(ctx, http.StatusUnauthorized, vo.CodeUnauthorized, nil)
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+
+		logger.Infof("audit: caller: admin, %s %s, status: %d", ctx.Request.Method, ctx.Request.URL.Path, ctx.Writer.Status())
+	}
+}
+
+// CreateAPIKey 创建 API Key
+// @Summary      创建 API Key
+// @Description  创建 API Key，明文 key 只在本次返回
+// @Tags         API Key
+// @Accept       json
+// @Produce      json
+// @Param        apikey body vo.CreateAPIKeyReq  true  "请求参数"
+// @Success      200  {object}  vo.ResponseData{data=vo.CreateAPIKeyRespData}
+// @Router       /admin/apikey/create [post]
+func (handler *AuthHandler) CreateAPIKey(ctx *gin.Context) {
+	var req vo.CreateAPIKeyReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		vo.ResponseError(ctx, vo.CodeInvalidParam)
+		return
+	}
+
+	data, err := handler.authServer.CreateAPIKey(ctx.Request.Context(), &req)
+	if err != nil {
+		logger.Errorf("create api key failed, err: %v", err)
+		vo.ResponseErrorWithMsg(ctx, vo.CodeInvalidParam, err.Error())
+		return
+	}
+
+	vo.ResponseSuccess(ctx, data)
+}
+
+// DeleteAPIKey 删除 API Key
+// @Summary      删除 API Key
+// @Description  删除 API Key
+// @Tags         API Key
+// @Accept       json
+// @Produce      json
+// @Param        apikey body vo.APIKeyReq  true  "请求参数"
+// @Success      200  {object}  vo.ResponseData{data=boolean}
+// @Router       /admin/apikey/delete [delete]
+func (handler *AuthHandler) DeleteAPIKey(ctx *gin.Context) {
+	var req vo.APIKeyReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		vo.ResponseError(ctx, vo.CodeInvalidParam)
+		return
+	}
+
+	if err := handler.authServer.DeleteAPIKey(ctx.Request.Context(), req.ID); err != nil {
+		logger.Errorf("delete api key failed, err: %v", err)
+		vo.ResponseError(ctx, vo.CodeServerBusy)
+		return
+	}
+
+	vo.ResponseSuccess(ctx, true)
+}
+
+// GetAPIKeys 查看全部 API Key
+// @Summary      查看全部 API Key
+// @Description  查看全部 API Key，不包含 key 本身
+// @Tags         API Key
+// @Produce      json
+// @Success      200  {object}  vo.ResponseData{data=[]vo.APIKey}
+// @Router       /admin/apikey/list [get]
+func (handler *AuthHandler) GetAPIKeys(ctx *gin.Context) {
+	keys, err := handler.authServer.GetAPIKeys(ctx.Request.Context())
+	if err != nil {
+		logger.Errorf("get api keys failed, err: %v", err)
+		vo.ResponseError(ctx, vo.CodeServerBusy)
+		return
+	}
+
+	vo.ResponseSuccess(ctx, keys)
+}
+
+// authorize 校验调用方是否拥有 app 的 scope 权限，没有权限时直接写回响应并返回 false
+func authorize(ctx *gin.Context, app string, scope consts.APIScope) bool {
+	ctx.Set(authAppCtxKey, app)
+	if !ctx.GetBool(authEnabledCtxKey) {
+		return true
+	}
+
+	value, _ := ctx.Get(principalCtxKey)
+	principal, ok := value.(*vo.Principal)
+	if !ok || !principal.Can(app, scope) {
+		vo.ResponseWithStatus(ctx, http.StatusForbidden, vo.CodeForbidden, nil)
+		return false
+	}
+	return true
+}
+
+// getBearerToken 优先从 Authorization: Bearer <token> 中获取，其次从指定 header 中获取
+func getBearerToken(ctx *gin.Context, header string) string {
+	if auth := ctx.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return ctx.GetHeader(header)
+}
+
+// 编译时检查
+var _ authServer = &webservice.AuthServer{}
+
+type authServer interface {
+	Enabled() bool
+	Authenticate(ctx context.Context, rawKey string) (*vo.Principal, error)
+	IsAdmin(token string) bool
+	CreateAPIKey(ctx context.Context, req *vo.CreateAPIKeyReq) (*vo.CreateAPIKeyRespData, error)
+	DeleteAPIKey(ctx context.Context, id uint) error
+	GetAPIKeys(ctx context.Context) ([]*vo.APIKey, error)
+}
@@ -0,0 +1,111 @@
+package webserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"timer/common/conf"
+	"timer/common/consts"
+	"timer/common/model/vo"
+	"timer/dao/apikey"
+	"timer/dao/calendar"
+	"timer/pkg/testenv"
+	"timer/service/webservice"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newAuthEngine 挂载鉴权中间件和日历接口，日历的查询需要 read 权限，创建需要 write 权限
+func newAuthEngine(t *testing.T, enabled bool) (*gin.Engine, *webservice.AuthServer) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db := testenv.NewDB(t)
+	authServer := webservice.NewAuthServer(apikey.NewAPIKeyDao(db), &conf.AuthConfig{Enabled: enabled, AdminToken: "admin"})
+	authHandler := NewAuthHandler(authServer)
+	calendarHandler := NewCalendarHandler(webservice.NewCalendarServer(calendar.NewCalendarDao(db)))
+
+	engine := gin.New()
+	group := engine.Group("api/dev")
+	group.Use(authHandler.Authenticate())
+	group.GET("/calendar/list", calendarHandler.GetCalendars)
+	group.POST("/calendar/create", calendarHandler.CreateCalendar)
+	admin := engine.Group("/admin")
+	admin.Use(authHandler.AdminAuthenticate())
+	admin.GET("/apikey/list", authHandler.GetAPIKeys)
+	return engine, authServer
+}
+
+func createKey(t *testing.T, server *webservice.AuthServer, apps []string, scopes ...consts.APIScope) *vo.CreateAPIKeyRespData {
+	t.Helper()
+	data, err := server.CreateAPIKey(context.Background(), &vo.CreateAPIKeyReq{Name: "caller", Apps: apps, Scopes: scopes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func serve(engine *gin.Engine, method, path, body string, header map[string]string) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestAuthenticate(t *testing.T) {
+	engine, server := newAuthEngine(t, true)
+	reader := createKey(t, server, []string{"app"}, consts.ScopeRead)
+	revoked := createKey(t, server, []string{"app"}, consts.ScopeRead, consts.ScopeWrite)
+	if err := server.DeleteAPIKey(context.Background(), revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	const (
+		list   = "/api/dev/calendar/list?app=app"
+		create = "/api/dev/calendar/create"
+		body   = `{"app":"app","name":"holiday"}`
+	)
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		header map[string]string
+		want   int
+	}{
+		{name: "missing key", method: http.MethodGet, path: list, want: http.StatusUnauthorized},
+		{name: "unknown key", method: http.MethodGet, path: list, header: map[string]string{"X-API-Key": "tk_unknown"}, want: http.StatusUnauthorized},
+		{name: "revoked key", method: http.MethodGet, path: list, header: map[string]string{"X-API-Key": revoked.Key}, want: http.StatusUnauthorized},
+		{name: "wrong app", method: http.MethodGet, path: "/api/dev/calendar/list?app=other", header: map[string]string{"X-API-Key": reader.Key}, want: http.StatusForbidden},
+		{name: "wrong scope", method: http.MethodPost, path: create, body: body, header: map[string]string{"X-API-Key": reader.Key}, want: http.StatusForbidden},
+		{name: "api key header", method: http.MethodGet, path: list, header: map[string]string{"X-API-Key": reader.Key}, want: http.StatusOK},
+		{name: "bearer token", method: http.MethodGet, path: list, header: map[string]string{"Authorization": "Bearer " + reader.Key}, want: http.StatusOK},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if code := serve(engine, c.method, c.path, c.body, c.header); code != c.want {
+				t.Errorf("status %d, want %d", code, c.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateDisabled(t *testing.T) {
+	engine, _ := newAuthEngine(t, false)
+	// 不开启鉴权时业务接口不需要 key
+	if code := serve(engine, http.MethodPost, "/api/dev/calendar/create", `{"app":"app","name":"holiday"}`, nil); code != http.StatusOK {
+		t.Errorf("status %d without auth, want %d", code, http.StatusOK)
+	}
+	// 管理接口不受开关控制
+	if code := serve(engine, http.MethodGet, "/admin/apikey/list", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("admin status %d without token, want %d", code, http.StatusUnauthorized)
+	}
+	if code := serve(engine, http.MethodGet, "/admin/apikey/list", "", map[string]string{"X-Admin-Token": "admin"}); code != http.StatusOK {
+		t.Errorf("admin status %d with token, want %d", code, http.StatusOK)
+	}
+}
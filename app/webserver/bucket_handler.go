@@ -0,0 +1,70 @@
+package webserver
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"timer/common/model/vo"
+	"timer/pkg/logger"
+	"timer/service/webservice"
+)
+
+type BucketHandler struct {
+	bucketServer bucketServer
+}
+
+func NewBucketHandler(server *webservice.BucketServer) *BucketHandler {
+	return &BucketHandler{
+		bucketServer: server,
+	}
+}
+
+// GetLayouts 查看分桶数量
+// @Summary      查看分桶数量
+// @Description  查看当前生效的分桶数量以及全部分桶变更记录
+// @Tags         分桶
+// @Produce      json
+// @Success      200  {object}  vo.ResponseData{data=vo.BucketLayoutsRespData}
+// @Router       /admin/bucket/list [get]
+func (handler *BucketHandler) GetLayouts(ctx *gin.Context) {
+	data, err := handler.bucketServer.GetLayouts(ctx.Request.Context())
+	if err != nil {
+		logger.Errorf("get bucket layouts failed, err: %v", err)
+		vo.ResponseError(ctx, vo.CodeServerBusy)
+		return
+	}
+
+	vo.ResponseSuccess(ctx, data)
+}
+
+// SetBucketsNum 修改分桶数量
+// @Summary      修改分桶数量
+// @Description  修改每分钟的分桶数量，从指定的分钟开始生效，最早为当前时间的下下分钟
+// @Tags         分桶
+// @Accept       json
+// @Produce      json
+// @Param        bucket body vo.SetBucketsReq  true  "请求参数"
+// @Success      200  {object}  vo.ResponseData{data=boolean}
+// @Router       /admin/bucket/set [post]
+func (handler *BucketHandler) SetBucketsNum(ctx *gin.Context) {
+	var req vo.SetBucketsReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		vo.ResponseError(ctx, vo.CodeInvalidParam)
+		return
+	}
+
+	if err := handler.bucketServer.SetBucketsNum(ctx.Request.Context(), &req); err != nil {
+		logger.Errorf("set buckets num failed, err: %v", err)
+		vo.ResponseErrorWithMsg(ctx, vo.CodeInvalidParam, err.Error())
+		return
+	}
+
+	vo.ResponseSuccess(ctx, true)
+}
+
+// 编译时检查
+var _ bucketServer = &webservice.BucketServer{}
+
+type bucketServer interface {
+	GetLayouts(ctx context.Context) (*vo.BucketLayoutsRespData, error)
+	SetBucketsNum(ctx context.Context, req *vo.SetBucketsReq) error
+}
@@ -0,0 +1,210 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"io"
+	"timer/common/consts"
+	"timer/common/model/vo"
+	"timer/pkg/logger"
+	"timer/service/webservice"
+)
+
+// maxICSFileBytes 导入的 .ics 文件大小上限
+const maxICSFileBytes = 4 << 20
+
+type CalendarHandler struct {
+	calendarServer calendarServer
+}
+
+func NewCalendarHandler(server *webservice.CalendarServer) *CalendarHandler {
+	return &CalendarHandler{
+		calendarServer: server,
+	}
+}
+
+// CreateCalendar 创建日历
+// @Summary      创建日历
+// @Description  创建节假日日历，定时器引用后，落在排除日期上的触发时间不执行
+// @Tags         日历
+// @Accept       json
+// @Produce      json
+// @Param        calendar body vo.CreateCalendarReq  true  "请求参数"
+// @Success      200  {object}  vo.ResponseData{data=integer}
+// @Router       /calendar/create [post]
+func (handler *CalendarHandler) CreateCalendar(ctx *gin.Context) {
+	var req vo.CreateCalendarReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		vo.ResponseError(ctx, vo.CodeInvalidParam)
+		return
+	}
+
+	if !authorize(ctx, req.App, consts.ScopeWrite) {
+		return
+	}
+
+	id, err := handler.calendarServer.CreateCalendar(ctx.Request.Context(), &req)
+	if err != nil {
+		logger.Errorf("create calendar failed, err: %v", err)
+		responseCalendarError(ctx, err)
+		return
+	}
+
+	vo.ResponseSuccess(ctx, id)
+}
+
+// UpdateCalendar 更新日历
+// @Summary      更新日历
+// @Description  整体覆盖日历的排除规则，只对之后生成的 task 生效
+// @Tags         日历
+// @Accept       json
+// @Produce      json
+// @Param        calendar body vo.UpdateCalendarReq  true  "请求参数"
+// @Success      200  {object}  vo.ResponseData{data=boolean}
+// @Router       /calendar/update [post]
+func (handler *CalendarHandler) UpdateCalendar(ctx *gin.Context) {
+	var req vo.UpdateCalendarReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		vo.ResponseError(ctx, vo.CodeInvalidParam)
+		return
+	}
+
+	if !authorize(ctx, req.App, consts.ScopeWrite) {
+		return
+	}
+
+	if err := handler.calendarServer.UpdateCalendar(ctx.Request.Context(), &req); err != nil {
+		logger.Errorf("update calendar failed, err: %v", err)
+		responseCalendarError(ctx, err)
+		return
+	}
+
+	vo.ResponseSuccess(ctx, true)
+}
+
+// DeleteCalendar 删除日历
+// @Summary      删除日历
+// @Description  删除日历，引用该日历的定时器之后不再按该日历排除
+// @Tags         日历
+// @Accept       json
+// @Produce      json
+// @Param        calendar body vo.CalendarReq  true  "请求参数"
+// @Success      200  {object}  vo.ResponseData{data=boolean}
+// @Router       /calendar/delete [delete]
+func (handler *CalendarHandler) DeleteCalendar(ctx *gin.Context) {
+	var req vo.CalendarReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		vo.ResponseError(ctx, vo.CodeInvalidParam)
+		return
+	}
+
+	if !authorize(ctx, req.App, consts.ScopeWrite) {
+		return
+	}
+
+	if err := handler.calendarServer.DeleteCalendar(ctx.Request.Context(), req.App, req.ID); err != nil {
+		logger.Errorf("delete calendar failed, err: %v", err)
+		responseCalendarError(ctx, err)
+		return
+	}
+
+	vo.ResponseSuccess(ctx, true)
+}
+
+// GetCalendars 查看 app 下的全部日历
+// @Summary      查看日历
+// @Description  查看 app 下的全部日历
+// @Tags         日历
+// @Produce      json
+// @Param        app  query  string  true  "应用名"
+// @Success      200  {object}  vo.ResponseData{data=[]vo.Calendar}
+// @Router       /calendar/list [get]
+func (handler *CalendarHandler) GetCalendars(ctx *gin.Context) {
+	var req vo.GetCalendarsReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		vo.ResponseError(ctx, vo.CodeInvalidParam)
+		return
+	}
+
+	if !authorize(ctx, req.App, consts.ScopeRead) {
+		return
+	}
+
+	calendars, err := handler.calendarServer.GetCalendars(ctx.Request.Context(), req.App)
+	if err != nil {
+		logger.Errorf("get calendars failed, err: %v", err)
+		vo.ResponseError(ctx, vo.CodeServerBusy)
+		return
+	}
+
+	vo.ResponseSuccess(ctx, calendars)
+}
+
+// ImportCalendar 从 .ics 文件导入排除日期
+// @Summary      导入日历
+// @Description  从 iCalendar(.ics) 文件导入排除日期，每个事件覆盖的日期都会被排除
+// @Tags         日历
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        app      formData  string  true   "应用名"
+// @Param        id       formData  int     true   "日历 id"
+// @Param        replace  formData  bool    false  "覆盖已有的排除日期，默认合并"
+// @Param        file     formData  file    true   ".ics 文件"
+// @Success      200  {object}  vo.ResponseData{data=vo.ImportCalendarRespData}
+// @Router       /calendar/import [post]
+func (handler *CalendarHandler) ImportCalendar(ctx *gin.Context) {
+	var req vo.ImportCalendarReq
+	if err := ctx.ShouldBind(&req); err != nil {
+		vo.ResponseError(ctx, vo.CodeInvalidParam)
+		return
+	}
+
+	if !authorize(ctx, req.App, consts.ScopeWrite) {
+		return
+	}
+
+	header, err := ctx.FormFile("file")
+	if err != nil || header.Size > maxICSFileBytes {
+		vo.ResponseErrorWithMsg(ctx, vo.CodeInvalidParam, "ics file is required and should not be larger than 4MB")
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		vo.ResponseError(ctx, vo.CodeServerBusy)
+		return
+	}
+	defer file.Close()
+
+	data, err := handler.calendarServer.ImportICS(ctx.Request.Context(), &req, io.LimitReader(file, maxICSFileBytes))
+	if err != nil {
+		logger.Errorf("import calendar failed, err: %v", err)
+		responseCalendarError(ctx, err)
+		return
+	}
+
+	vo.ResponseSuccess(ctx, data)
+}
+
+func responseCalendarError(ctx *gin.Context, err error) {
+	if errors.Is(err, vo.ErrForbidden) {
+		vo.ResponseError(ctx, vo.CodeForbidden)
+		return
+	}
+	if errors.Is(err, vo.ErrCalendarUnValid) {
+		vo.ResponseErrorWithMsg(ctx, vo.CodeInvalidParam, err.Error())
+		return
+	}
+	vo.ResponseError(ctx, vo.CodeServerBusy)
+}
+
+// 编译时检查
+var _ calendarServer = &webservice.CalendarServer{}
+
+type calendarServer interface {
+	CreateCalendar(ctx context.Context, req *vo.CreateCalendarReq) (uint, error)
+	UpdateCalendar(ctx context.Context, req *vo.UpdateCalendarReq) error
+	DeleteCalendar(ctx context.Context, app string, id uint) error
+	GetCalendars(ctx context.Context, app string) ([]*vo.Calendar, error)
+	ImportICS(ctx context.Context, req *vo.ImportCalendarReq, r io.Reader) (*vo.ImportCalendarRespData, error)
+}
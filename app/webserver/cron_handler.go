@@ -0,0 +1,54 @@
+package webserver
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"timer/common/model/vo"
+	"timer/service/webservice"
+)
+
+type CronHandler struct {
+	cronServer cronServer
+}
+
+func NewCronHandler(server *webservice.CronServer) *CronHandler {
+	return &CronHandler{
+		cronServer: server,
+	}
+}
+
+// Preview 预览 cron 表达式
+// @Summary      预览 cron 表达式
+// @Description  校验 cron 表达式，返回英文描述和接下来的触发时间；不合法时返回出错字段和位置
+// @Tags         cron 表达式
+// @Produce      json
+// @Param        expr      query  string  true   "cron 表达式"
+// @Param        timezone  query  string  false  "时区，例如 Asia/Shanghai"
+// @Param        start     query  string  false  "开始时间，RFC3339 格式"
+// @Param        num       query  int     false  "预览的触发次数，默认 5，最多 100"
+// @Param        jitterSeconds  query  int  false  "抖动窗口，单位：s"
+// @Param        timerId   query  int     false  "定时器 id，与 jitterSeconds 同时给出时返回实际执行时间"
+// @Success      200  {object}  vo.ResponseData{data=vo.CronPreviewRespData}
+// @Router       /cron/preview [get]
+func (handler *CronHandler) Preview(ctx *gin.Context) {
+	var req vo.CronPreviewReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		vo.ResponseError(ctx, vo.CodeInvalidParam)
+		return
+	}
+
+	data, err := handler.cronServer.Preview(ctx.Request.Context(), &req)
+	if err != nil {
+		vo.ResponseErrorWithMsg(ctx, vo.CodeInvalidParam, err.Error())
+		return
+	}
+
+	vo.ResponseSuccess(ctx, data)
+}
+
+// 编译时检查
+var _ cronServer = &webservice.CronServer{}
+
+type cronServer interface {
+	Preview(ctx context.Context, req *vo.CronPreviewReq) (*vo.CronPreviewRespData, error)
+}
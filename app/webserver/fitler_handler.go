@@ -4,12 +4,23 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func CrosHandler() gin.HandlerFunc {
+func CrosHandler(allowOrigins []string) gin.HandlerFunc {
+	allowed := make(map[string]struct{}, len(allowOrigins))
+	for _, origin := range allowOrigins {
+		allowed[origin] = struct{}{}
+	}
+
 	return func(context *gin.Context) {
-		context.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		context.Header("Access-Control-Allow-Origin", "*") // 设置允许访问所有域
+		// 只对配置中允许的 origin 设置跨域头，"*" 代表允许所有域
+		origin := context.GetHeader("Origin")
+		_, allowAll := allowed["*"]
+		_, ok := allowed[origin]
+		if origin != "" && (allowAll || ok) {
+			context.Header("Access-Control-Allow-Origin", origin)
+			context.Header("Vary", "Origin")
+		}
 		context.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE,UPDATE")
-		context.Header("Access-Control-Allow-Headers", "Authorization, Content-Length, X-CSRF-Token, Token,session,X_Requested_With,Accept, Origin, Host, Connection, Accept-Encoding, Accept-Language,DNT, X-CustomHeader, Keep-Alive, User-Agent, X-Requested-With, If-Modified-Since, Cache-Control, Content-Type, Pragma,token,openid,opentoken")
+		context.Header("Access-Control-Allow-Headers", "Authorization, Content-Length, X-CSRF-Token, Token,session,X_Requested_With,Accept, Origin, Host, Connection, Accept-Encoding, Accept-Language,DNT, X-CustomHeader, Keep-Alive, User-Agent, X-Requested-With, If-Modified-Since, Cache-Control, Content-Type, Pragma,token,openid,opentoken,X-API-Key")
 		context.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers,Cache-Control,Content-Language,Content-Type,Expires,Last-Modified,Pragma,FooBar")
 		context.Header("Access-Control-Max-Age", "172800")
 		context.Header("Access-Control-Allow-Credentials", "false")
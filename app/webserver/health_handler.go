@@ -0,0 +1,79 @@
+package webserver
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"timer/common/model/vo"
+	"timer/pkg/logger"
+	"timer/service/webservice"
+)
+
+type HealthHandler struct {
+	healthServer healthServer
+}
+
+func NewHealthHandler(server *webservice.HealthServer) *HealthHandler {
+	return &HealthHandler{
+		healthServer: server,
+	}
+}
+
+// Healthz 存活检查
+// @Summary      存活检查
+// @Description  检查调度循环和迁移循环是否存活，不存活返回 503
+// @Tags         健康检查
+// @Produce      json
+// @Success      200  {object}  vo.ResponseData{data=vo.HealthRespData}
+// @Failure      503  {object}  vo.ResponseData{data=vo.HealthRespData}
+// @Router       /healthz [get]
+func (handler *HealthHandler) Healthz(ctx *gin.Context) {
+	responseHealth(ctx, handler.healthServer.Liveness(ctx.Request.Context()))
+}
+
+// Readyz 就绪检查
+// @Summary      就绪检查
+// @Description  检查数据库、Redis、调度循环和迁移循环是否可用，不可用返回 503
+// @Tags         健康检查
+// @Produce      json
+// @Success      200  {object}  vo.ResponseData{data=vo.HealthRespData}
+// @Failure      503  {object}  vo.ResponseData{data=vo.HealthRespData}
+// @Router       /readyz [get]
+func (handler *HealthHandler) Readyz(ctx *gin.Context) {
+	responseHealth(ctx, handler.healthServer.Readiness(ctx.Request.Context()))
+}
+
+// Cluster 集群状态
+// @Summary      集群状态
+// @Description  查看存活的调度节点、各分桶的负责节点，以及各节点持有的 time_bucket_lock_* 分布式锁和 migrator_leader 租约及其过期时间
+// @Tags         集群状态
+// @Produce      json
+// @Success      200  {object}  vo.ResponseData{data=vo.ClusterRespData}
+// @Router       /admin/cluster [get]
+func (handler *HealthHandler) Cluster(ctx *gin.Context) {
+	data, err := handler.healthServer.Cluster(ctx.Request.Context())
+	if err != nil {
+		logger.Errorf("get cluster status failed, err: %v", err)
+		vo.ResponseError(ctx, vo.CodeServerBusy)
+		return
+	}
+
+	vo.ResponseSuccess(ctx, data)
+}
+
+func responseHealth(ctx *gin.Context, data *vo.HealthRespData) {
+	if !data.Healthy {
+		vo.ResponseWithStatus(ctx, http.StatusServiceUnavailable, vo.CodeUnhealthy, data)
+		return
+	}
+	vo.ResponseSuccess(ctx, data)
+}
+
+// 编译时检查
+var _ healthServer = &webservice.HealthServer{}
+
+type healthServer interface {
+	Liveness(ctx context.Context) *vo.HealthRespData
+	Readiness(ctx context.Context) *vo.HealthRespData
+	Cluster(ctx context.Context) (*vo.ClusterRespData, error)
+}
@@ -0,0 +1,97 @@
+package webserver
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"timer/common/model/vo"
+	"timer/pkg/logger"
+	"timer/service/webservice"
+)
+
+type MaintenanceHandler struct {
+	maintenanceServer maintenanceServer
+}
+
+func NewMaintenanceHandler(server *webservice.MaintenanceServer) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		maintenanceServer: server,
+	}
+}
+
+// CreateWindow 创建维护窗口
+// @Summary      创建维护窗口
+// @Description  窗口内命中的 app 的回调按策略跳过（skip）或者延后到窗口结束（defer）
+// @Tags         维护窗口
+// @Accept       json
+// @Produce      json
+// @Param        window body vo.CreateMaintenanceWindowReq  true  "请求参数"
+// @Success      200  {object}  vo.ResponseData{data=integer}
+// @Router       /admin/maintenance/create [post]
+func (handler *MaintenanceHandler) CreateWindow(ctx *gin.Context) {
+	var req vo.CreateMaintenanceWindowReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		vo.ResponseError(ctx, vo.CodeInvalidParam)
+		return
+	}
+
+	id, err := handler.maintenanceServer.CreateWindow(ctx.Request.Context(), &req)
+	if err != nil {
+		logger.Errorf("create maintenance window failed, err: %v", err)
+		vo.ResponseErrorWithMsg(ctx, vo.CodeInvalidParam, err.Error())
+		return
+	}
+
+	vo.ResponseSuccess(ctx, id)
+}
+
+// DeleteWindow 删除维护窗口
+// @Summary      删除维护窗口
+// @Description  删除维护窗口，已经暂缓的任务仍然在原定的结束时间释放
+// @Tags         维护窗口
+// @Accept       json
+// @Produce      json
+// @Param        window body vo.MaintenanceWindowReq  true  "请求参数"
+// @Success      200  {object}  vo.ResponseData{data=boolean}
+// @Router       /admin/maintenance/delete [delete]
+func (handler *MaintenanceHandler) DeleteWindow(ctx *gin.Context) {
+	var req vo.MaintenanceWindowReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		vo.ResponseError(ctx, vo.CodeInvalidParam)
+		return
+	}
+
+	if err := handler.maintenanceServer.DeleteWindow(ctx.Request.Context(), req.ID); err != nil {
+		logger.Errorf("delete maintenance window failed, err: %v", err)
+		vo.ResponseError(ctx, vo.CodeServerBusy)
+		return
+	}
+
+	vo.ResponseSuccess(ctx, true)
+}
+
+// GetWindows 查看尚未结束的维护窗口
+// @Summary      查看维护窗口
+// @Description  查看尚未结束的维护窗口
+// @Tags         维护窗口
+// @Produce      json
+// @Success      200  {object}  vo.ResponseData{data=[]vo.MaintenanceWindow}
+// @Router       /admin/maintenance/list [get]
+func (handler *MaintenanceHandler) GetWindows(ctx *gin.Context) {
+	windows, err := handler.maintenanceServer.GetWindows(ctx.Request.Context())
+	if err != nil {
+		logger.Errorf("get maintenance windows failed, err: %v", err)
+		vo.ResponseError(ctx, vo.CodeServerBusy)
+		return
+	}
+
+	vo.ResponseSuccess(ctx, windows)
+}
+
+// 编译时检查
+var _ maintenanceServer = &webservice.MaintenanceServer{}
+
+type maintenanceServer interface {
+	CreateWindow(ctx context.Context, req *vo.CreateMaintenanceWindowReq) (uint, error)
+	DeleteWindow(ctx context.Context, id uint) error
+	GetWindows(ctx context.Context) ([]*vo.MaintenanceWindow, error)
+}
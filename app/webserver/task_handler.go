@@ -1,12 +1,101 @@
 package webserver
 
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"timer/common/consts"
+	"timer/common/model/vo"
+	"timer/pkg/logger"
+	"timer/service/webservice"
+)
+
 type TaskHandler struct {
 	taskServer taskServer
 }
 
-func NewTaskHandler() *TaskHandler {
-	return &TaskHandler{}
+func NewTaskHandler(server *webservice.TaskServer) *TaskHandler {
+	return &TaskHandler{
+		taskServer: server,
+	}
+}
+
+// GetTasks 查询任务
+// @Summary      查询任务
+// @Description  按执行时间倒序查询 app 下的任务，status 为 4 时查询被维护窗口暂缓的任务，5 为被跳过的任务
+// @Tags         任务
+// @Produce      json
+// @Param        app      query  string  true   "应用名"
+// @Param        timerId  query  int     false  "定时器 id"
+// @Param        status   query  int     false  "任务状态，0 未执行，1 执行中，2 成功，3 失败，4 暂缓，5 跳过"
+// @Param        start    query  string  false  "执行时间的开始，RFC3339 格式"
+// @Param        end      query  string  false  "执行时间的结束，RFC3339 格式"
+// @Param        offset   query  int     false  "偏移量"
+// @Param        limit    query  int     false  "每页数量，默认 20，最多 500"
+// @Success      200  {object}  vo.ResponseData{data=[]vo.Task}
+// @Router       /task/list [get]
+func (handler *TaskHandler) GetTasks(ctx *gin.Context) {
+	var req vo.GetTasksReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		vo.ResponseError(ctx, vo.CodeInvalidParam)
+		return
+	}
+
+	if !authorize(ctx, req.App, consts.ScopeRead) {
+		return
+	}
+
+	tasks, err := handler.taskServer.GetTasks(ctx.Request.Context(), &req)
+	if err != nil {
+		logger.Errorf("get tasks failed, err: %v", err)
+		vo.ResponseErrorWithMsg(ctx, vo.CodeInvalidParam, err.Error())
+		return
+	}
+
+	vo.ResponseSuccess(ctx, tasks)
+}
+
+// GetTaskChain 查询执行链
+// @Summary      查询执行链
+// @Description  查询 task 所在的整条执行链，从按时间触发的根 task 开始，按触发顺序列出由后继触发的全部 task，parentId 指向触发它的前驱 task
+// @Tags         任务
+// @Produce      json
+// @Param        app  query  string  true  "应用名"
+// @Param        id   query  int     true  "执行链上任意一个 task 的 id"
+// @Success      200  {object}  vo.ResponseData{data=vo.TaskChain}
+// @Router       /task/chain [get]
+func (handler *TaskHandler) GetTaskChain(ctx *gin.Context) {
+	var req vo.GetTaskChainReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		vo.ResponseError(ctx, vo.CodeInvalidParam)
+		return
+	}
+
+	if !authorize(ctx, req.App, consts.ScopeRead) {
+		return
+	}
+
+	chain, err := handler.taskServer.GetTaskChain(ctx.Request.Context(), req.App, req.ID)
+	if err != nil {
+		logger.Errorf("get task chain failed, err: %v", err)
+		switch {
+		case errors.Is(err, vo.ErrForbidden):
+			vo.ResponseError(ctx, vo.CodeForbidden)
+		case errors.Is(err, vo.ErrTaskNotFound):
+			vo.ResponseErrorWithMsg(ctx, vo.CodeInvalidParam, err.Error())
+		default:
+			vo.ResponseError(ctx, vo.CodeServerBusy)
+		}
+		return
+	}
+
+	vo.ResponseSuccess(ctx, chain)
 }
 
+// 编译时检查
+var _ taskServer = &webservice.TaskServer{}
+
 type taskServer interface {
+	GetTasks(ctx context.Context, req *vo.GetTasksReq) ([]*vo.Task, error)
+	GetTaskChain(ctx context.Context, app string, id uint) (*vo.TaskChain, error)
 }
@@ -2,7 +2,9 @@ package webserver
 
 import (
 	"context"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"timer/common/consts"
 	"timer/common/model/vo"
 	"timer/pkg/logger"
 	"timer/service/webservice"
@@ -36,18 +38,20 @@ func (handler *TimerHandler) CreateTimer(ctx *gin.Context) {
 		return
 	}
 
+	if !authorize(ctx, req.App, consts.ScopeWrite) {
+		return
+	}
+
 	// 业务处理：
 	// 生成 timer 存入数据库中
-	id, err := handler.timerServer.CreateTimer(ctx.Request.Context(), &req.Timer)
+	data, err := handler.timerServer.CreateTimer(ctx.Request.Context(), &req.Timer)
 	if err != nil {
 		logger.Errorf("%s", err)
-		vo.ResponseError(ctx, vo.CodeServerBusy)
+		responseTimerError(ctx, err)
 		return
 	}
 
-	vo.ResponseSuccess(ctx, vo.CreateTimerRespData{
-		Id:      id,
-		Success: true})
+	vo.ResponseSuccess(ctx, data)
 }
 
 // DeleteTimer 删除计时器
@@ -68,9 +72,13 @@ func (handler *TimerHandler) DeleteTimer(ctx *gin.Context) {
 		return
 	}
 
+	if !authorize(ctx, req.App, consts.ScopeWrite) {
+		return
+	}
+
 	// 业务处理：直接删除数据库中的 timer 定义
-	if err = handler.timerServer.DeleteTimer(ctx, req.ID); err != nil {
-		vo.ResponseError(ctx, vo.CodeServerBusy)
+	if err = handler.timerServer.DeleteTimer(ctx, req.App, req.ID); err != nil {
+		responseTimerError(ctx, err)
 		return
 	}
 
@@ -95,11 +103,15 @@ func (handler *TimerHandler) EnableTimer(ctx *gin.Context) {
 		return
 	}
 
+	if !authorize(ctx, req.App, consts.ScopeWrite) {
+		return
+	}
+
 	// 业务处理：
 	// 创建两个一级迁移时间的 task，加入 MySQL 中，再加入 redis zset 中。
-	if err = handler.timerServer.EnableTimer(ctx, req.ID); err != nil {
+	if err = handler.timerServer.EnableTimer(ctx, req.App, req.ID); err != nil {
 		logger.Errorf("%s", err)
-		vo.ResponseError(ctx, vo.CodeServerBusy)
+		responseTimerError(ctx, err)
 		return
 	}
 
@@ -124,21 +136,74 @@ func (handler *TimerHandler) UnableTimer(ctx *gin.Context) {
 		return
 	}
 
+	if !authorize(ctx, req.App, consts.ScopeWrite) {
+		return
+	}
+
 	// 业务处理：直接 update 数据库中 timer 定义的状态
-	if err = handler.timerServer.UnableTimer(ctx, req.ID); err != nil {
-		vo.ResponseError(ctx, vo.CodeServerBusy)
+	if err = handler.timerServer.UnableTimer(ctx, req.App, req.ID); err != nil {
+		responseTimerError(ctx, err)
+		return
+	}
+
+	vo.ResponseSuccess(ctx, true)
+}
+
+// UpdateSuccessors 修改计时器的后继
+// @Summary      修改计时器的后继
+// @Description  整体覆盖计时器执行成功、失败后触发的计时器，后继必须属于同一个 app，且不能形成环
+// @Tags         修改计时器的后继
+// @Accept       json
+// @Produce      json
+// @Param        timer body vo.UpdateSuccessorsReq  true  "请求参数"
+// @Success      200  {object}  vo.ResponseData{data=boolean}
+// @Router       /timer/successors [post]
+func (handler *TimerHandler) UpdateSuccessors(ctx *gin.Context) {
+	var err error
+
+	var req vo.UpdateSuccessorsReq
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		vo.ResponseError(ctx, vo.CodeInvalidParam)
+		return
+	}
+
+	if !authorize(ctx, req.App, consts.ScopeWrite) {
+		return
+	}
+
+	if err = handler.timerServer.UpdateSuccessors(ctx.Request.Context(), &req); err != nil {
+		logger.Errorf("%s", err)
+		responseTimerError(ctx, err)
 		return
 	}
 
 	vo.ResponseSuccess(ctx, true)
 }
 
+func responseTimerError(ctx *gin.Context, err error) {
+	if errors.Is(err, vo.ErrForbidden) {
+		vo.ResponseError(ctx, vo.CodeForbidden)
+		return
+	}
+	if errors.Is(err, vo.ErrCronExprUnValid) || errors.Is(err, vo.ErrScheduleTooDense) || errors.Is(err, vo.ErrJitterUnValid) ||
+		errors.Is(err, vo.ErrCalendarUnValid) || errors.Is(err, vo.ErrSuccessorUnValid) {
+		vo.ResponseErrorWithMsg(ctx, vo.CodeInvalidParam, err.Error())
+		return
+	}
+	if errors.Is(err, vo.ErrQuotaExceeded) {
+		vo.ResponseErrorWithMsg(ctx, vo.CodeQuotaExceeded, err.Error())
+		return
+	}
+	vo.ResponseError(ctx, vo.CodeServerBusy)
+}
+
 // 编译时检查
 var _ timerServer = &webservice.TimerServer{}
 
 type timerServer interface {
-	CreateTimer(context.Context, *vo.Timer) (uint, error)
-	DeleteTimer(ctx context.Context, id uint) error
-	EnableTimer(ctx context.Context, id uint) error
-	UnableTimer(ctx *gin.Context, id uint) error
+	CreateTimer(context.Context, *vo.Timer) (*vo.CreateTimerRespData, error)
+	DeleteTimer(ctx context.Context, app string, id uint) error
+	EnableTimer(ctx context.Context, app string, id uint) error
+	UnableTimer(ctx *gin.Context, app string, id uint) error
+	UpdateSuccessors(ctx context.Context, req *vo.UpdateSuccessorsReq) error
 }
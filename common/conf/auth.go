@@ -0,0 +1,14 @@
+package conf
+
+type AuthConfig struct {
+	// 是否开启 API Key 鉴权
+	Enabled bool `yaml:"enabled"`
+	// 管理员 token，用于调用 /admin 下的管理接口，不受 Enabled 控制，为空则拒绝全部管理请求
+	AdminToken string `yaml:"adminToken"`
+}
+
+var defaultAuthConfig *AuthConfig
+
+func GetDefaultAuthConfig() *AuthConfig {
+	return defaultAuthConfig
+}
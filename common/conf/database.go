@@ -0,0 +1,45 @@
+package conf
+
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// DatabaseConfig 存储后端配置，driver 可选 mysql、postgres、sqlite
+// 没有配置 database 时兼容旧的 mysql 配置
+type DatabaseConfig struct {
+	Driver       string `yaml:"driver"`
+	DSN          string `yaml:"dsn"`
+	MaxOpenConns int    `yaml:"maxOpenConns"`
+	MaxIdleConns int    `yaml:"maxIdleConns"`
+	// 关闭启动时自动执行 schema 迁移，改为通过 migrate 命令手动执行
+	DisableAutoMigrate bool `yaml:"disableAutoMigrate"`
+}
+
+var defaultDatabaseConfig *DatabaseConfig
+
+func GetDefaultDatabaseConfig() *DatabaseConfig {
+	return defaultDatabaseConfig
+}
+
+func newDatabaseConfig(database *DatabaseConfig, mysql *MySQLConfig) *DatabaseConfig {
+	if database != nil && database.DSN != "" {
+		if database.Driver == "" {
+			database.Driver = DriverMySQL
+		}
+		return database
+	}
+
+	// 兼容只配置了 mysql 的旧配置文件
+	config := DatabaseConfig{
+		Driver:       DriverMySQL,
+		DSN:          mysql.DSN,
+		MaxOpenConns: mysql.MaxOpenConns,
+		MaxIdleConns: mysql.MaxIdleConns,
+	}
+	if database != nil {
+		config.DisableAutoMigrate = database.DisableAutoMigrate
+	}
+	return &config
+}
@@ -3,12 +3,18 @@ package conf
 import (
 	"fmt"
 	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
 )
 
 func init() {
 	viper.SetConfigName("config") // name of config file (without extension)
 	viper.SetConfigType("yaml")   // REQUIRED if the config file does not have the extension in the name
 	viper.AddConfigPath(".")      // path to look for the config file in
+	// go test 的工作目录是包所在目录，当前目录找不到时再到模块根目录查找
+	if root := findModuleRoot(); root != "" {
+		viper.AddConfigPath(root)
+	}
 
 	// 读取配置文件
 	err := viper.ReadInConfig() // Find and read the config file
@@ -22,13 +28,28 @@ func init() {
 		panic(fmt.Errorf("fatal error config file: %w", err))
 	}
 
+	// 配置错误时尽早失败，避免写入无法解析的 key
+	if err = gConf.Scheduler.Validate(); err != nil {
+		panic(fmt.Errorf("fatal error config file: %w", err))
+	}
+
 	// 序列化到各包变量中，方便 provide 到 contain 中
 	defaultSchedulerAppConfig = gConf.Scheduler
 	defaultTriggerAppConfig = gConf.Trigger
 	defaultMigratorAppConfig = gConf.Migrator
 	defaultMySQLConfig = gConf.Mysql
+	defaultDatabaseConfig = newDatabaseConfig(gConf.Database, gConf.Mysql)
 	defaultRedisConfig = gConf.Redis
 	defaultWebServerAppConf = gConf.WebServer
+	defaultAuthConfig = gConf.Auth
+	defaultQuotaConfig = gConf.Quota
+	defaultScheduleConfig = gConf.Schedule
+	defaultRetentionConfig = gConf.Retention
+	defaultStandaloneConfig = gConf.Standalone
+	defaultMaintenanceConfig = gConf.Maintenance
+	if defaultStandaloneConfig.Enabled {
+		defaultDatabaseConfig = newStandaloneDatabaseConfig(defaultDatabaseConfig)
+	}
 }
 
 // gConf 兜底配置，即默认配置。后续配置文件会写入覆盖
@@ -42,26 +63,55 @@ var gConf GlobalConf = GlobalConf{
 		TryLockGapMilliSeconds: 100,
 		// 时间片执行成功后，更新的分布式锁时间，单位：s
 		SuccessExpireSeconds: 130,
+		// 下一分钟开始前 2s 提前认领下一分钟的分片，单位：毫秒
+		LookaheadMilliSeconds: 2000,
+		// 分片 key 的命名空间
+		SliceKeyApp: "timer",
+		// 节点每 2s 上报一次心跳，6s 没有心跳视为下线
+		HeartbeatSeconds:  2,
+		NodeExpireSeconds: 6,
 	},
 
 	Trigger: &TriggerAppConfig{
+		// 默认按固定间隔轮询 zset
+		Engine: TriggerEngineZRange,
 		// 触发器轮询定时任务 zset 的时间间隔，单位：s
 		ZRangeGapSeconds: 1,
+		// 毫秒级的轮询间隔，例如 100，不配置时使用 ZRangeGapSeconds
+		ZRangeGapMilliSeconds: 0,
+		// 每个时间片提前 200ms 拉取，单位：毫秒
+		PreloadMilliSeconds: 200,
 		// 并发协程数
 		WorkersNum: 10000,
+		// 以下为延迟队列模式的配置：30s 没有 ack 重新投递，每次最多弹出 1000 个，最长休眠 1s
+		VisibilityTimeoutSeconds: 30,
+		PopBatchSize:             1000,
+		MaxWaitMilliSeconds:      1000,
+		// 时间轮模式：每格 1ms，每层 64 格
+		TimeWheelTickMilliSeconds: 1,
+		TimeWheelSize:             64,
 	},
 
 	Migrator: &MigratorAppConfig{
 		WorkersNum: 1000,
 		// 一级每次迁移数据的时间间隔，单位：min
 		MigrateStepMinutes: 60,
-		// 迁移成功更新的锁过期时间，单位：min
-		MigrateSuccessExpireMinutes: 120,
-		// 迁移器获取锁时，初设的过期时间，单位：min
-		MigrateTryLockMinutes: 20,
 		// 迁移器提前将定时器数据缓存到内存中的保存时间，单位：min
 		// 2 级迁移时间
 		TimerDetailCacheMinutes: 2,
+		// 每页加载 500 个定时器
+		TimerPageSize: 500,
+		// 每批 1000 个 task 写入 redis
+		CacheBatchSize: 1000,
+		// 失败的批次最多重试 3 次
+		CacheRetryTimes: 3,
+		// leader 租约 15s，每 3s 续期一次
+		LeaderLeaseSeconds: 15,
+		LeaderRenewSeconds: 3,
+		// leader 每 30s 检查一次迁移进度
+		MigrateCheckSeconds: 30,
+		// 后继 task 超过执行时间 2min 没有执行则重新投递
+		SuccessorRecoverSeconds: 120,
 	},
 
 	Redis: &RedisConfig{
@@ -79,13 +129,100 @@ var gConf GlobalConf = GlobalConf{
 		MaxOpenConns: 100,
 		MaxIdleConns: 50,
 	},
+	Database: &DatabaseConfig{
+		MaxOpenConns: 100,
+		MaxIdleConns: 50,
+	},
+
+	Auth: &AuthConfig{
+		// 默认不开启，兼容旧的调用方
+		Enabled: false,
+	},
+
+	Quota: &QuotaConfig{
+		// 默认不限制
+		Default: &AppQuota{},
+		// 回调并发槽位租约 30 s
+		ConcurrencyLeaseSeconds: 30,
+		// 并发槽位已满时 200 毫秒后重新投递
+		RetryGapMilliSeconds: 200,
+		// redis 异常时放行，宁可超出配额也不延误回调
+		FailOpen: true,
+	},
+
+	Schedule: &ScheduleConfig{
+		// 默认不限制调度密度
+		MinIntervalSeconds: 0,
+		MaxFiresPerDay:     0,
+		// 默认预览接下来 5 次触发时间
+		PreviewNum: 5,
+		// 抖动窗口最长 5 分钟
+		MaxJitterSeconds: 300,
+	},
+
+	Retention: &RetentionConfig{
+		// 默认不开启，task 流水永久保留
+		Enabled:    false,
+		RetainDays: 30,
+		BatchSize:  1000,
+		// 单次最多处理 1000 批，即 100 万条
+		MaxBatchesPerRun: 1000,
+		IntervalMinutes:  60,
+		LockSeconds:      300,
+		Archive: &ArchiveConfig{
+			Type: ArchiveTypeLocal,
+			Dir:  "./archive",
+			S3:   &S3Config{},
+		},
+		Partition: &PartitionConfig{
+			Enabled:    false,
+			FutureDays: 7,
+		},
+	},
+
+	Standalone: &StandaloneConfig{
+		Enabled:      false,
+		RedisAddress: "127.0.0.1:0",
+		// 每分钟写一次快照
+		SnapshotIntervalSeconds: 60,
+	},
+
+	Maintenance: &MaintenanceConfig{
+		// 每 10s 刷新一次维护窗口
+		RefreshSeconds: 10,
+	},
 }
 
 type GlobalConf struct {
-	Scheduler *SchedulerAppConfig `yaml:"scheduler"`
-	Migrator  *MigratorAppConfig  `yaml:"*migrator"`
-	Mysql     *MySQLConfig        `yaml:"mysql"`
-	Redis     *RedisConfig        `yaml:"redis"`
-	WebServer *WebServerAppConfig `yaml:"webservice"`
-	Trigger   *TriggerAppConfig   `yaml:"trigger"`
+	Scheduler   *SchedulerAppConfig `yaml:"scheduler"`
+	Migrator    *MigratorAppConfig  `yaml:"*migrator"`
+	Mysql       *MySQLConfig        `yaml:"mysql"`
+	Database    *DatabaseConfig     `yaml:"database"`
+	Redis       *RedisConfig        `yaml:"redis"`
+	WebServer   *WebServerAppConfig `yaml:"webservice"`
+	Trigger     *TriggerAppConfig   `yaml:"trigger"`
+	Auth        *AuthConfig         `yaml:"auth"`
+	Quota       *QuotaConfig        `yaml:"quota"`
+	Schedule    *ScheduleConfig     `yaml:"schedule"`
+	Retention   *RetentionConfig    `yaml:"retention"`
+	Standalone  *StandaloneConfig   `yaml:"standalone"`
+	Maintenance *MaintenanceConfig  `yaml:"maintenance"`
+}
+
+// findModuleRoot 从当前目录向上查找 go.mod 所在的目录，找不到时返回空字符串
+func findModuleRoot() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
 }
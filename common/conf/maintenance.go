@@ -0,0 +1,13 @@
+package conf
+
+// MaintenanceConfig 维护窗口的配置
+type MaintenanceConfig struct {
+	// 执行器刷新维护窗口的时间间隔，新建、删除的窗口最多延迟该时间生效，单位：s
+	RefreshSeconds int `yaml:"refreshSeconds"`
+}
+
+var defaultMaintenanceConfig *MaintenanceConfig
+
+func GetDefaultMaintenanceConfig() *MaintenanceConfig {
+	return defaultMaintenanceConfig
+}
@@ -1,11 +1,23 @@
 package conf
 
 type MigratorAppConfig struct {
-	WorkersNum                  int `yaml:"workersNum"`
-	MigrateStepMinutes          int `yaml:"migrateStepMinutes"`
-	MigrateSuccessExpireMinutes int `yaml:"migrateSuccessExpireMinutes"`
-	MigrateTryLockMinutes       int `yaml:"migrateTryLockMinutes"`
-	TimerDetailCacheMinutes     int `yaml:"timerDetailCacheMinutes"`
+	WorkersNum              int `yaml:"workersNum"`
+	MigrateStepMinutes      int `yaml:"migrateStepMinutes"`
+	TimerDetailCacheMinutes int `yaml:"timerDetailCacheMinutes"`
+	// leader 租约时长，leader 宕机后最多经过该时长完成切换
+	LeaderLeaseSeconds int `yaml:"leaderLeaseSeconds"`
+	// 竞选与续期租约的间隔，需要明显小于租约时长
+	LeaderRenewSeconds int `yaml:"leaderRenewSeconds"`
+	// leader 检查迁移进度的间隔
+	MigrateCheckSeconds int `yaml:"migrateCheckSeconds"`
+	// 迁移时每页加载的定时器数量
+	TimerPageSize int `yaml:"timerPageSize"`
+	// 写入 redis 时每个 pipeline 批次的 task 数量
+	CacheBatchSize int `yaml:"cacheBatchSize"`
+	// 写入 redis 失败的批次的最大重试次数
+	CacheRetryTimes int `yaml:"cacheRetryTimes"`
+	// 后继 task 超过执行时间该时长仍然没有执行时重新投递，需要大于回调的最长执行时间
+	SuccessorRecoverSeconds int `yaml:"successorRecoverSeconds"`
 }
 
 var defaultMigratorAppConfig *MigratorAppConfig
@@ -0,0 +1,53 @@
+package conf
+
+import "time"
+
+// AppQuota 单个 app 的配额，0 代表不限制
+type AppQuota struct {
+	App string `yaml:"app"`
+	// 最多可以创建的定时器数量
+	MaxTimers int `yaml:"maxTimers"`
+	// 整个 app 每分钟最多触发的次数
+	MaxFiresPerMinute int `yaml:"maxFiresPerMinute"`
+	// 整个 app 同时执行的回调数量上限
+	MaxConcurrency int `yaml:"maxConcurrency"`
+	// 打散窗口，没有单独配置抖动窗口的定时器在该窗口内按 id 和触发时间打散，避免同一秒集中触发，单位：s
+	SpreadSeconds int `yaml:"spreadSeconds"`
+}
+
+// GetSpread app 的打散窗口
+func (q *AppQuota) GetSpread() time.Duration {
+	return time.Duration(q.SpreadSeconds) * time.Second
+}
+
+type QuotaConfig struct {
+	// 没有单独配置的 app 使用的默认配额
+	Default *AppQuota `yaml:"default"`
+	// 按 app 单独配置的配额
+	Apps []*AppQuota `yaml:"apps"`
+	// 回调并发槽位的租约时间，节点宕机没有释放的槽位在租约到期后自动回收，单位：s
+	ConcurrencyLeaseSeconds int `yaml:"concurrencyLeaseSeconds"`
+	// 并发槽位已满、或者 redis 异常并且不放行时，task 延后重新投递的间隔，单位：毫秒
+	RetryGapMilliSeconds int `yaml:"retryGapMilliSeconds"`
+	// 限流依赖的 redis 异常时是否放行：放行宁可超出配额也不延误回调，不放行则按 RetryGapMilliSeconds 延后
+	FailOpen bool `yaml:"failOpen"`
+}
+
+// GetAppQuota 获取 app 的配额，没有单独配置则返回默认配额
+func (c *QuotaConfig) GetAppQuota(app string) *AppQuota {
+	for _, quota := range c.Apps {
+		if quota.App == app {
+			return quota
+		}
+	}
+	if c.Default != nil {
+		return c.Default
+	}
+	return &AppQuota{}
+}
+
+var defaultQuotaConfig *QuotaConfig
+
+func GetDefaultQuotaConfig() *QuotaConfig {
+	return defaultQuotaConfig
+}
@@ -1,8 +1,23 @@
 package conf
 
+const (
+	RedisModeSingle   = "single"
+	RedisModeSentinel = "sentinel"
+	RedisModeCluster  = "cluster"
+)
+
+// RedisConfig mode 可选 single、sentinel、cluster，默认 single
 type RedisConfig struct {
-	Network            string `yaml:"network"`
-	Address            string `yaml:"address"`
+	Mode    string `yaml:"mode"`
+	Network string `yaml:"network"`
+	// 单机模式的地址
+	Address string `yaml:"address"`
+	// 哨兵模式为哨兵节点的地址，集群模式为启动时用于发现集群拓扑的节点地址
+	Addresses []string `yaml:"addresses"`
+	// 哨兵模式监控的主节点名称
+	MasterName string `yaml:"masterName"`
+	// 哨兵节点的密码，为空则不需要认证
+	SentinelPassword   string `yaml:"sentinelPassword"`
 	Password           string `yaml:"password"`
 	MaxIdle            int    `yaml:"maxIdle"`
 	IdleTimeoutSeconds int    `yaml:"idleTimeout"`
@@ -0,0 +1,57 @@
+package conf
+
+const (
+	ArchiveTypeNone  = "none"
+	ArchiveTypeLocal = "local"
+	ArchiveTypeS3    = "s3"
+)
+
+// RetentionConfig task 流水的保留策略，超过保留天数的已完成 task 归档后分批删除
+type RetentionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// task 流水的保留天数，按 run_timer 计算
+	RetainDays int `yaml:"retainDays"`
+	// 每批归档、删除的 task 数量
+	BatchSize int `yaml:"batchSize"`
+	// 单次执行最多处理的批次数，避免一次清理过多影响线上
+	MaxBatchesPerRun int `yaml:"maxBatchesPerRun"`
+	// 执行间隔，单位：min
+	IntervalMinutes int `yaml:"intervalMinutes"`
+	// 执行时持有的分布式锁过期时间，每批处理完会续期，单位：s
+	LockSeconds int            `yaml:"lockSeconds"`
+	Archive     *ArchiveConfig `yaml:"archive"`
+	// 按 run_timer 管理 MySQL 的 range 分区，需要 task 表已经按 run_timer 分区
+	Partition *PartitionConfig `yaml:"partition"`
+}
+
+// ArchiveConfig 归档存储，type 可选 none、local、s3，none 表示直接删除不归档
+type ArchiveConfig struct {
+	Type string `yaml:"type"`
+	// 本地归档目录
+	Dir string    `yaml:"dir"`
+	S3  *S3Config `yaml:"s3"`
+}
+
+// S3Config 兼容 S3 协议的对象存储，本地可以使用 MinIO 代替
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint"`
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	Prefix    string `yaml:"prefix"`
+	AccessKey string `yaml:"accessKey"`
+	SecretKey string `yaml:"secretKey"`
+	UseSSL    bool   `yaml:"useSSL"`
+}
+
+// PartitionConfig task 表按天分区，分区名为 pYYYYMMDD
+type PartitionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 提前创建未来多少天的分区
+	FutureDays int `yaml:"futureDays"`
+}
+
+var defaultRetentionConfig *RetentionConfig
+
+func GetDefaultRetentionConfig() *RetentionConfig {
+	return defaultRetentionConfig
+}
@@ -0,0 +1,19 @@
+package conf
+
+// ScheduleConfig 创建定时器时对 cron 表达式调度密度的校验配置
+type ScheduleConfig struct {
+	// 相邻两次触发的最小间隔，小于该值的表达式拒绝创建，0 代表不限制，单位：s
+	MinIntervalSeconds int `yaml:"minIntervalSeconds"`
+	// 一天内最多触发的次数，超过该值的表达式拒绝创建，0 代表不限制
+	MaxFiresPerDay int `yaml:"maxFiresPerDay"`
+	// 创建成功后返回的预览触发时间个数
+	PreviewNum int `yaml:"previewNum"`
+	// 定时器触发时间抖动窗口的上限，0 代表不限制，单位：s
+	MaxJitterSeconds int `yaml:"maxJitterSeconds"`
+}
+
+var defaultScheduleConfig *ScheduleConfig
+
+func GetDefaultScheduleConfig() *ScheduleConfig {
+	return defaultScheduleConfig
+}
@@ -1,10 +1,32 @@
 package conf
 
+import (
+	"fmt"
+	"time"
+	"timer/common/utils"
+)
+
 type SchedulerAppConfig struct {
-	BucketsNum             int `yaml:"bucketsNum"`
-	TryLockSeconds         int `yaml:"tryLockSeconds"`
-	TryLockGapMilliSeconds int `yaml:"tryLockGapMilliSeconds"`
-	SuccessExpireSeconds   int `yaml:"successExpireSeconds"`
+	// 分片 key 的命名空间，多个部署共用同一个 redis 时需要配置为不同的值
+	SliceKeyApp            string `yaml:"sliceKeyApp"`
+	BucketsNum             int    `yaml:"bucketsNum"`
+	TryLockSeconds         int    `yaml:"tryLockSeconds"`
+	TryLockGapMilliSeconds int    `yaml:"tryLockGapMilliSeconds"`
+	SuccessExpireSeconds   int    `yaml:"successExpireSeconds"`
+	// 距离下一分钟不足该时长时提前认领下一分钟的分片，0 表示不提前
+	LookaheadMilliSeconds int `yaml:"lookaheadMilliSeconds"`
+	// 节点心跳间隔
+	HeartbeatSeconds int `yaml:"heartbeatSeconds"`
+	// 超过该时长没有心跳的节点视为下线，其负责的分桶由其他节点接管
+	NodeExpireSeconds int `yaml:"nodeExpireSeconds"`
+}
+
+// Validate 校验调度配置，分片 key 的命名空间与 utils.SliceKey 的规则一致，不能包含冒号与花括号
+func (c *SchedulerAppConfig) Validate() error {
+	if err := utils.NewSliceKey(c.SliceKeyApp, time.Now(), 0).Validate(); err != nil {
+		return fmt.Errorf("invalid scheduler.sliceKeyApp: %w", err)
+	}
+	return nil
 }
 
 var defaultSchedulerAppConfig *SchedulerAppConfig
@@ -0,0 +1,18 @@
+package conf
+
+import "testing"
+
+func TestSchedulerAppConfigValidate(t *testing.T) {
+	for app, valid := range map[string]bool{
+		"timer":        true,
+		"billing-prod": true,
+		"":             false,
+		"a:b":          false,
+		"{timer}":      false,
+	} {
+		err := (&SchedulerAppConfig{SliceKeyApp: app}).Validate()
+		if valid != (err == nil) {
+			t.Errorf("validate sliceKeyApp %q: got err %v, want valid: %v", app, err, valid)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package conf
+
+// standaloneDSN 单机模式使用的 SQLite 内存数据库
+const standaloneDSN = "file:timer_standalone?mode=memory&cache=shared&_busy_timeout=5000"
+
+// StandaloneConfig 单机模式，不依赖外部的 MySQL 和 Redis
+// timer、task 保存在进程内的 SQLite 内存库中，zset 分桶、布隆过滤器、分布式锁保存在进程内嵌的 redis 中
+type StandaloneConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 内嵌 redis 的监听地址，端口为 0 时随机分配
+	RedisAddress string `yaml:"redisAddress"`
+	// 快照文件，为空则不持久化，进程重启后数据丢失
+	SnapshotFile string `yaml:"snapshotFile"`
+	// 定期写快照的时间间隔，进程正常退出时也会写一次快照，单位：s
+	SnapshotIntervalSeconds int `yaml:"snapshotIntervalSeconds"`
+}
+
+var defaultStandaloneConfig *StandaloneConfig
+
+func GetDefaultStandaloneConfig() *StandaloneConfig {
+	return defaultStandaloneConfig
+}
+
+// newStandaloneDatabaseConfig 单机模式下替换掉配置文件中的数据库配置
+func newStandaloneDatabaseConfig(database *DatabaseConfig) *DatabaseConfig {
+	return &DatabaseConfig{
+		Driver:             DriverSQLite,
+		DSN:                standaloneDSN,
+		DisableAutoMigrate: database.DisableAutoMigrate,
+	}
+}
@@ -1,8 +1,34 @@
 package conf
 
+import "time"
+
+const (
+	// TriggerEngineZRange 每个轮询间隔 zrange 拉取一次时间片内的 task
+	TriggerEngineZRange = "zrange"
+	// TriggerEngineDelayQueue 原子地弹出到期的 task，执行结果落库后 ack，超时未 ack 的重新投递
+	TriggerEngineDelayQueue = "delayqueue"
+	// TriggerEngineTimeWheel 一次性加载分片的 task 到进程内的时间轮，在执行时间精确触发，执行结果落库后 ack
+	TriggerEngineTimeWheel = "timewheel"
+)
+
+// TriggerAppConfig engine 可选 zrange、delayqueue、timewheel，默认 zrange
 type TriggerAppConfig struct {
-	ZRangeGapSeconds int `yaml:"zrangeGapSeconds"`
-	WorkersNum       int `yaml:"workersNum"`
+	Engine           string `yaml:"engine"`
+	ZRangeGapSeconds int    `yaml:"zrangeGapSeconds"`
+	// 毫秒级的轮询间隔，大于 0 时优先于 ZRangeGapSeconds，用于需要亚秒级精度的场景
+	ZRangeGapMilliSeconds int `yaml:"zrangeGapMilliSeconds"`
+	// 每个时间片提前多久拉取，拉取到的任务按执行时间精确触发
+	PreloadMilliSeconds int `yaml:"preloadMilliSeconds"`
+	WorkersNum          int `yaml:"workersNum"`
+	// 延迟队列模式下，弹出的 task 超过该时长没有 ack 则重新投递
+	VisibilityTimeoutSeconds int `yaml:"visibilityTimeoutSeconds"`
+	// 延迟队列模式下每次最多弹出的 task 数量
+	PopBatchSize int `yaml:"popBatchSize"`
+	// 延迟队列模式下休眠的最长时间，休眠期间新写入的 task 最多延迟该时长被发现
+	MaxWaitMilliSeconds int `yaml:"maxWaitMilliSeconds"`
+	// 时间轮模式下第一层每格的跨度以及每层的格数
+	TimeWheelTickMilliSeconds int `yaml:"timeWheelTickMilliSeconds"`
+	TimeWheelSize             int `yaml:"timeWheelSize"`
 }
 
 var defaultTriggerAppConfig *TriggerAppConfig
@@ -10,3 +36,31 @@ var defaultTriggerAppConfig *TriggerAppConfig
 func GetDefaultTriggerAppConfig() *TriggerAppConfig {
 	return defaultTriggerAppConfig
 }
+
+// GetZRangeGap 触发器轮询 zset 的时间间隔
+func (c *TriggerAppConfig) GetZRangeGap() time.Duration {
+	if c.ZRangeGapMilliSeconds > 0 {
+		return time.Duration(c.ZRangeGapMilliSeconds) * time.Millisecond
+	}
+	return time.Duration(c.ZRangeGapSeconds) * time.Second
+}
+
+// GetUnreadAfter 触发器在 now 时一定还没有读取的最早执行时间，执行时间不早于该时间写入缓存的 task 一定会被触发
+// lookahead 为调度器提前认领分片的时间
+func (c *TriggerAppConfig) GetUnreadAfter(now time.Time, lookahead time.Duration) time.Time {
+	switch c.Engine {
+	case TriggerEngineDelayQueue:
+		// 延迟队列持续弹出分片中新写入的 task
+		return now
+	case TriggerEngineTimeWheel:
+		// 时间轮一次性加载分片，只能写入还没有被认领的分片，留出 1s 的余量
+		next := now.Truncate(time.Minute).Add(time.Minute)
+		if !now.Add(lookahead + time.Second).Before(next) {
+			next = next.Add(time.Minute)
+		}
+		return next
+	default:
+		// zrange 每个时间片提前 preload 拉取，已经拉取过的时间片不会再读取
+		return now.Add(c.GetZRangeGap() + time.Duration(c.PreloadMilliSeconds)*time.Millisecond)
+	}
+}
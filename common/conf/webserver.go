@@ -2,6 +2,8 @@ package conf
 
 type WebServerAppConfig struct {
 	Port int `yaml:"port"`
+	// 允许跨域访问的 origin 列表，"*" 代表允许全部；为空则不允许跨域
+	AllowOrigins []string `yaml:"allowOrigins"`
 }
 
 var defaultWebServerAppConf *WebServerAppConfig
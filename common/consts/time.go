@@ -27,4 +27,28 @@ const (
 	Running   TaskStatus = 1
 	Successed TaskStatus = 2
 	Failed    TaskStatus = 3
+	// Held 维护窗口内被暂缓，窗口结束后重新投递
+	Held TaskStatus = 4
+	// Skipped 维护窗口内被跳过，不再执行
+	Skipped TaskStatus = 5
+)
+
+// MaintenancePolicy 维护窗口内任务的处理策略
+type MaintenancePolicy string
+
+const (
+	// MaintenancePolicySkip 窗口内的任务直接跳过
+	MaintenancePolicySkip MaintenancePolicy = "skip"
+	// MaintenancePolicyDefer 窗口内的任务暂缓，窗口结束后执行
+	MaintenancePolicyDefer MaintenancePolicy = "defer"
+	// MaintenanceAllApps 维护窗口命中全部 app
+	MaintenanceAllApps = "*"
+)
+
+// APIScope API Key 的权限范围
+type APIScope string
+
+const (
+	ScopeRead  APIScope = "read"
+	ScopeWrite APIScope = "write"
 )
@@ -0,0 +1,39 @@
+package po
+
+import (
+	"gorm.io/gorm"
+	"strings"
+)
+
+const APIKeyTable = "api_key"
+
+// APIKey 调用方的 API Key，key 本身只保存 hash
+type APIKey struct {
+	gorm.Model
+	Name    string `gorm:"column:name;NOT NULL"`     // 调用方名称，用于审计日志
+	KeyHash string `gorm:"column:key_hash;NOT NULL"` // key 的 sha256 hex
+	Apps    string `gorm:"column:apps;NOT NULL"`     // 可访问的 app，逗号分隔
+	Scopes  string `gorm:"column:scopes;NOT NULL"`   // 拥有的权限，逗号分隔，read/write
+}
+
+func (k *APIKey) TableName() string {
+	return APIKeyTable
+}
+
+func (k *APIKey) GetApps() []string {
+	return splitComma(k.Apps)
+}
+
+func (k *APIKey) GetScopes() []string {
+	return splitComma(k.Scopes)
+}
+
+func splitComma(str string) []string {
+	var res []string
+	for _, s := range strings.Split(str, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
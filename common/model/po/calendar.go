@@ -0,0 +1,65 @@
+package po
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+	"timer/pkg/calendar"
+)
+
+const CalendarTable = "calendar"
+
+// Calendar 节假日日历，定时器引用日历后，落在排除日期上的触发时间不生成 task
+type Calendar struct {
+	gorm.Model
+	App      string `gorm:"column:app;NOT NULL"`      // 应用名
+	Name     string `gorm:"column:name;NOT NULL"`     // 日历名称
+	Weekdays string `gorm:"column:weekdays;NOT NULL"` // 排除的星期，逗号分隔，0 为周日
+	Dates    string `gorm:"column:dates;NOT NULL"`    // 排除的日期，逗号分隔，区间用 ~ 连接，例如 2026-10-01~2026-10-07
+}
+
+func (c *Calendar) TableName() string {
+	return CalendarTable
+}
+
+// ToCalendar 解析为排除规则
+func (c *Calendar) ToCalendar() (*calendar.Calendar, error) {
+	weekdays, err := calendar.ParseWeekdays(c.Weekdays)
+	if err != nil {
+		return nil, err
+	}
+	ranges, err := calendar.ParseRanges(c.Dates)
+	if err != nil {
+		return nil, err
+	}
+	return &calendar.Calendar{
+		Weekdays: weekdays,
+		Ranges:   ranges,
+	}, nil
+}
+
+// CalendarRules 按日历 id 索引的排除规则
+type CalendarRules map[uint]*calendar.Calendar
+
+func NewCalendarRules(calendars []*Calendar) (CalendarRules, error) {
+	rules := make(CalendarRules, len(calendars))
+	for _, c := range calendars {
+		rule, err := c.ToCalendar()
+		if err != nil {
+			return nil, fmt.Errorf("invalid calendar: %d, err: %w", c.ID, err)
+		}
+		rules[c.ID] = rule
+	}
+	return rules, nil
+}
+
+// Filter 过滤掉定时器引用的日历所排除的触发时间，已经删除的日历不再生效
+func (r CalendarRules) Filter(timer *Timer, times []time.Time) []time.Time {
+	var calendars []*calendar.Calendar
+	for _, id := range timer.GetCalendarIDs() {
+		if rule, ok := r[id]; ok {
+			calendars = append(calendars, rule)
+		}
+	}
+	return calendar.Filter(times, calendars...)
+}
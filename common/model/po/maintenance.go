@@ -0,0 +1,40 @@
+package po
+
+import (
+	"gorm.io/gorm"
+	"time"
+	"timer/common/consts"
+)
+
+const MaintenanceWindowTable = "maintenance_window"
+
+// MaintenanceWindow 维护窗口，窗口内命中的 app 的回调按策略跳过或者延后到窗口结束
+type MaintenanceWindow struct {
+	gorm.Model
+	Apps    string    `gorm:"column:apps;NOT NULL"`     // 命中的 app，逗号分隔，* 表示全部 app
+	StartAt time.Time `gorm:"column:start_at;NOT NULL"` // 窗口开始时间
+	EndAt   time.Time `gorm:"column:end_at;NOT NULL"`   // 窗口结束时间，不包含
+	Policy  string    `gorm:"column:policy;NOT NULL"`   // 窗口内的处理策略，skip/defer
+	Reason  string    `gorm:"column:reason;NOT NULL"`   // 维护原因
+}
+
+func (w *MaintenanceWindow) TableName() string {
+	return MaintenanceWindowTable
+}
+
+func (w *MaintenanceWindow) GetApps() []string {
+	return splitComma(w.Apps)
+}
+
+// Matches 判断 app 在 t 时刻是否处于窗口内
+func (w *MaintenanceWindow) Matches(app string, t time.Time) bool {
+	if t.Before(w.StartAt) || !t.Before(w.EndAt) {
+		return false
+	}
+	for _, a := range w.GetApps() {
+		if a == consts.MaintenanceAllApps || a == app {
+			return true
+		}
+	}
+	return false
+}
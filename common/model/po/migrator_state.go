@@ -0,0 +1,16 @@
+package po
+
+import "time"
+
+const MigratorStateTable = "migrator_state"
+
+// MigratorStateID migrator_state 表只有一行
+const MigratorStateID = 1
+
+// MigratorState 迁移器的状态
+type MigratorState struct {
+	ID           uint       `gorm:"column:id;primaryKey"`
+	FencingToken int64      `gorm:"column:fencing_token;NOT NULL;default:0"` // 最近一次写入的 leader 的 fencing token
+	Watermark    *time.Time `gorm:"column:watermark"`                        // 已经完成迁移的截止时间，为空表示还没有迁移过
+	UpdatedAt    *time.Time `gorm:"column:updated_at"`
+}
@@ -17,6 +17,10 @@ type Task struct {
 	RunTimer time.Time `gorm:"column:run_timer;default:null"` // 执行时间
 	CostTime int       `gorm:"column:cost_time"`              // 执行耗时
 	Status   int       `gorm:"column:status;NOT NULL"`        // 当前状态
+	// 写入执行结果时持有的分片锁的 fencing token
+	FencingToken int64 `gorm:"column:fencing_token;NOT NULL;default:0"`
+	// 由前驱 task 触发时记录前驱 task 的 id，按时间触发的 task 为 0
+	ParentTaskID uint `gorm:"column:parent_task_id;NOT NULL;default:0"`
 }
 
 func (t *Task) TableName() string {
@@ -2,8 +2,11 @@ package po
 
 import (
 	"gorm.io/gorm"
+	"strconv"
+	"strings"
 	"time"
 	"timer/common/consts"
+	"timer/common/utils"
 )
 
 const TimerTable = "timer"
@@ -15,17 +18,110 @@ type Timer struct {
 	Name            string `gorm:"column:name;NOT NULL" json:"name,omitempty"`                           // 定时器定义名称
 	Status          int    `gorm:"column:status;NOT NULL" json:"status,omitempty"`                       // 定时器定义状态，1:未激活, 2:已激活
 	Cron            string `gorm:"column:cron;NOT NULL" json:"cron,omitempty"`                           // 定时器定时配置
+	JitterSeconds   int    `gorm:"column:jitter_seconds;NOT NULL" json:"jitter_seconds,omitempty"`       // 触发时间抖动窗口，单位：s，0 使用 app 的打散窗口，-1 不抖动
+	CalendarIDs     string `gorm:"column:calendar_ids;NOT NULL" json:"calendar_ids,omitempty"`           // 引用的日历 id，逗号分隔
+	OnSuccessIDs    string `gorm:"column:on_success_ids;NOT NULL" json:"on_success_ids,omitempty"`       // 执行成功后触发的定时器 id，逗号分隔
+	OnFailureIDs    string `gorm:"column:on_failure_ids;NOT NULL" json:"on_failure_ids,omitempty"`       // 执行失败后触发的定时器 id，逗号分隔
+	PassOutput      bool   `gorm:"column:pass_output;NOT NULL" json:"pass_output,omitempty"`             // 作为后继触发时，回调 body 按模板渲染前驱的执行结果
 	NotifyHTTPParam string `gorm:"column:notify_http_param;NOT NULL" json:"notify_http_param,omitempty"` // Http 回调参数
+	// 已经生成 task 的截止时间，为空表示还没有生成过
+	GeneratedThrough *time.Time `gorm:"column:generated_through" json:"generated_through,omitempty"`
 }
 
-func (t *Timer) BatchTasksFromTimer(executeTimes []time.Time) []*Task {
-	tasks := make([]*Task, 0, len(executeTimes))
+// GetCalendarIDs 引用的日历 id
+func (t *Timer) GetCalendarIDs() []uint {
+	return parseIDs(t.CalendarIDs)
+}
+
+// SetCalendarIDs 设置引用的日历 id
+func (t *Timer) SetCalendarIDs(ids []uint) {
+	t.CalendarIDs = formatIDs(ids)
+}
+
+// GetOnSuccessIDs 执行成功后触发的定时器 id
+func (t *Timer) GetOnSuccessIDs() []uint {
+	return parseIDs(t.OnSuccessIDs)
+}
+
+// SetOnSuccessIDs 设置执行成功后触发的定时器 id
+func (t *Timer) SetOnSuccessIDs(ids []uint) {
+	t.OnSuccessIDs = formatIDs(ids)
+}
+
+// GetOnFailureIDs 执行失败后触发的定时器 id
+func (t *Timer) GetOnFailureIDs() []uint {
+	return parseIDs(t.OnFailureIDs)
+}
+
+// SetOnFailureIDs 设置执行失败后触发的定时器 id
+func (t *Timer) SetOnFailureIDs(ids []uint) {
+	t.OnFailureIDs = formatIDs(ids)
+}
+
+// GetSuccessors 按 task 的执行结果返回需要触发的后继定时器 id
+func (t *Timer) GetSuccessors(success bool) []uint {
+	if success {
+		return t.GetOnSuccessIDs()
+	}
+	return t.GetOnFailureIDs()
+}
+
+// IsSuccessorOnly 没有 cron 的定时器不按时间触发，只作为其他定时器的后继执行
+func (t *Timer) IsSuccessorOnly() bool {
+	return t.Cron == ""
+}
+
+func parseIDs(s string) []uint {
+	var ids []uint
+	for _, str := range splitComma(s) {
+		if id, err := strconv.ParseUint(str, 10, 64); err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
+
+func formatIDs(ids []uint) string {
+	strs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		strs = append(strs, strconv.FormatUint(uint64(id), 10))
+	}
+	return strings.Join(strs, ",")
+}
+
+// JitterDisabled 定时器的执行时间不抖动，也不使用 app 的打散窗口
+const JitterDisabled = -1
+
+// JitterWindow 触发时间的抖动窗口，定时器没有单独配置时使用 app 的打散窗口 spread
+func (t *Timer) JitterWindow(spread time.Duration) time.Duration {
+	if t.JitterSeconds == JitterDisabled {
+		return 0
+	}
+	if t.JitterSeconds > 0 {
+		return time.Duration(t.JitterSeconds) * time.Second
+	}
+	return spread
+}
+
+// JitterTimes 触发时间在抖动窗口 window 内偏移后的执行时间
+// 偏移由定时器 id 和触发时间共同决定，同一秒触发的定时器以及同一个定时器的每次触发都会被打散
+func (t *Timer) JitterTimes(executeTimes []time.Time, window time.Duration) []time.Time {
+	runTimers := make([]time.Time, 0, len(executeTimes))
 	for _, executeTime := range executeTimes {
+		runTimers = append(runTimers, executeTime.Add(utils.GetJitterOffset(t.Model.ID, executeTime, window)))
+	}
+	return runTimers
+}
+
+// BatchTasksFromTimer 根据执行时间生成 task
+func (t *Timer) BatchTasksFromTimer(runTimers []time.Time) []*Task {
+	tasks := make([]*Task, 0, len(runTimers))
+	for _, runTimer := range runTimers {
 		tasks = append(tasks, &Task{
 			App:      t.App,
 			TimerID:  t.Model.ID,
 			Status:   consts.NotRunned.ToInt(),
-			RunTimer: executeTime,
+			RunTimer: runTimer,
 		})
 	}
 	return tasks
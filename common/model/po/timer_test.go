@@ -0,0 +1,87 @@
+package po
+
+import (
+	"gorm.io/gorm"
+	"testing"
+	"time"
+	"timer/pkg/calendar"
+)
+
+func TestJitterWindow(t *testing.T) {
+	spread := 30 * time.Second
+	cases := []struct {
+		jitterSeconds int
+		want          time.Duration
+	}{
+		{jitterSeconds: JitterDisabled, want: 0},
+		{jitterSeconds: 0, want: spread},
+		{jitterSeconds: 10, want: 10 * time.Second},
+	}
+	for _, c := range cases {
+		timer := &Timer{JitterSeconds: c.jitterSeconds}
+		if got := timer.JitterWindow(spread); got != c.want {
+			t.Errorf("jitter seconds %d: window %v, want %v", c.jitterSeconds, got, c.want)
+		}
+	}
+}
+
+func TestJitterTimesSpreadsSameSecond(t *testing.T) {
+	window := time.Minute
+	fire := time.Date(2026, 10, 19, 9, 0, 0, 0, time.Local)
+
+	// 1000 个定时器在同一秒触发，执行时间应该分布在整个窗口内
+	seconds := make(map[int64]struct{})
+	for id := uint(1); id <= 1000; id++ {
+		timer := &Timer{Model: gorm.Model{ID: id}}
+		runTimer := timer.JitterTimes([]time.Time{fire}, window)[0]
+		if runTimer.Before(fire) || !runTimer.Before(fire.Add(window)) {
+			t.Fatalf("timer %d run at %v, out of window [%v, %v)", id, runTimer, fire, fire.Add(window))
+		}
+		seconds[runTimer.Unix()] = struct{}{}
+	}
+	if len(seconds) < 55 {
+		t.Errorf("1000 timers run in %d distinct seconds of a 60s window", len(seconds))
+	}
+
+	// 同一个定时器的每次触发偏移不同，重复计算的结果相同
+	timer := &Timer{Model: gorm.Model{ID: 1}}
+	fires := []time.Time{fire, fire.Add(time.Hour), fire.Add(2 * time.Hour)}
+	first, second := timer.JitterTimes(fires, window), timer.JitterTimes(fires, window)
+	offsets := make(map[time.Duration]struct{})
+	for i := range fires {
+		if !first[i].Equal(second[i]) {
+			t.Errorf("fire %v jittered to %v and %v", fires[i], first[i], second[i])
+		}
+		offsets[first[i].Sub(fires[i])] = struct{}{}
+	}
+	if len(offsets) == 1 {
+		t.Errorf("all fires of timer 1 have the same offset")
+	}
+
+	if got := timer.JitterTimes(fires, 0); !got[0].Equal(fire) {
+		t.Errorf("zero window moved fire %v to %v", fire, got[0])
+	}
+}
+
+func TestCalendarFilterAfterJitter(t *testing.T) {
+	window := time.Minute
+	fire := time.Date(2026, 10, 19, 23, 59, 30, 0, time.Local)
+	holiday, err := calendar.ParseRange("2026-10-20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := CalendarRules{1: &calendar.Calendar{Ranges: []calendar.DateRange{holiday}}}
+
+	// 找到一个偏移后跨过零点、落在排除日期上的定时器
+	var timer *Timer
+	for id := uint(1); timer == nil; id++ {
+		candidate := &Timer{Model: gorm.Model{ID: id}, CalendarIDs: "1"}
+		if candidate.JitterTimes([]time.Time{fire}, window)[0].Day() == 20 {
+			timer = candidate
+		}
+	}
+
+	if got := rules.Filter(timer, timer.JitterTimes([]time.Time{fire}, window)); len(got) != 0 {
+		t.Errorf("run time %v on an excluded date is kept", got[0])
+	}
+}
@@ -0,0 +1,8 @@
+// Package sql 内嵌各存储后端的版本化 schema 迁移文件
+// 文件命名：<driver>/<version>_<name>.up.sql 和 <driver>/<version>_<name>.down.sql
+package sql
+
+import "embed"
+
+//go:embed mysql postgres sqlite
+var Migrations embed.FS
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `api_key`
+(
+    `id`         bigint(20) unsigned NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+    `name`       varchar(255)  NOT NULL COMMENT '调用方名称',
+    `key_hash`   char(64)      NOT NULL COMMENT 'key 的 sha256 hex',
+    `apps`       varchar(1024) NOT NULL COMMENT '可访问的 app，逗号分隔',
+    `scopes`     varchar(64)   NOT NULL COMMENT '权限，逗号分隔 read/write',
+    `created_at` datetime      NOT NULL COMMENT '创建时间',
+    `updated_at` datetime      DEFAULT NULL ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    `deleted_at` datetime      DEFAULT NULL COMMENT '删除时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uni_key_hash` (`key_hash`) USING BTREE COMMENT 'key hash 唯一索引'
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,3 @@
+ALTER TABLE `task` MODIFY COLUMN `output` varchar(256) DEFAULT NULL COMMENT '执行结果';
+ALTER TABLE `timer` CONVERT TO CHARACTER SET utf8;
+ALTER TABLE `timer` MODIFY COLUMN `notify_http_param` json DEFAULT NULL COMMENT 'http 参数';
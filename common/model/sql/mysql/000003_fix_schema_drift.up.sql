@@ -0,0 +1,5 @@
+-- po.Timer.NotifyHTTPParam 是 string，原来的 json 类型会因为 key 顺序、空白被 MySQL 规范化，且与 NOT NULL 的定义不一致
+ALTER TABLE `timer` MODIFY COLUMN `notify_http_param` text NOT NULL COMMENT 'http 参数';
+ALTER TABLE `timer` CONVERT TO CHARACTER SET utf8mb4;
+-- po.Task.Output 保存的是回调的完整响应，256 长度会导致写入失败
+ALTER TABLE `task` MODIFY COLUMN `output` text DEFAULT NULL COMMENT '执行结果';
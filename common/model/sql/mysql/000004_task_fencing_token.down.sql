@@ -0,0 +1,6 @@
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'task' AND column_name = 'fencing_token') > 0,
+    'ALTER TABLE `task` DROP COLUMN `fencing_token`',
+    'SELECT 1');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
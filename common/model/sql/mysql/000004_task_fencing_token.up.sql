@@ -0,0 +1,8 @@
+-- 调度分片锁的 fencing token，只有 token 不小于已写入值的节点才能更新 task，防止锁过期后旧的持有者覆盖执行结果
+-- MySQL 的 DDL 会隐式提交事务，按 information_schema 判断列是否存在，迁移中断后可以重复执行
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'task' AND column_name = 'fencing_token') = 0,
+    'ALTER TABLE `task` ADD COLUMN `fencing_token` bigint(20) NOT NULL DEFAULT 0 COMMENT ''分片锁 fencing token'' AFTER `status`',
+    'SELECT 1');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
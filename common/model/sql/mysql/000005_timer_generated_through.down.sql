@@ -0,0 +1,6 @@
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'timer' AND column_name = 'generated_through') > 0,
+    'ALTER TABLE `timer` DROP COLUMN `generated_through`',
+    'SELECT 1');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
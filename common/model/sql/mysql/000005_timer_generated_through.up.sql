@@ -0,0 +1,8 @@
+-- 定时器已经生成 task 的截止时间，迁移器只为截止时间落后的定时器生成 task
+-- MySQL 的 DDL 会隐式提交事务，按 information_schema 判断列是否存在，迁移中断后可以重复执行
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'timer' AND column_name = 'generated_through') = 0,
+    'ALTER TABLE `timer` ADD COLUMN `generated_through` datetime DEFAULT NULL COMMENT ''已生成 task 的截止时间'' AFTER `notify_http_param`',
+    'SELECT 1');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
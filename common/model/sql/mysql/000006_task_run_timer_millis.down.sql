@@ -0,0 +1 @@
+ALTER TABLE `task` MODIFY COLUMN `run_timer` datetime NOT NULL COMMENT '执行时间';
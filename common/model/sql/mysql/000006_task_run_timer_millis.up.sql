@@ -0,0 +1,2 @@
+-- 执行时间精确到毫秒，支持亚秒级的定时任务
+ALTER TABLE `task` MODIFY COLUMN `run_timer` datetime(3) NOT NULL COMMENT '执行时间';
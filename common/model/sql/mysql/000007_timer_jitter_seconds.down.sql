@@ -0,0 +1,6 @@
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'timer' AND column_name = 'jitter_seconds') > 0,
+    'ALTER TABLE `timer` DROP COLUMN `jitter_seconds`',
+    'SELECT 1');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
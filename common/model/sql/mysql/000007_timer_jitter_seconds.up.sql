@@ -0,0 +1,8 @@
+-- 定时器触发时间的抖动窗口，生成 task 时按定时器 id 确定性地偏移执行时间
+-- MySQL 的 DDL 会隐式提交事务，按 information_schema 判断列是否存在，迁移中断后可以重复执行
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'timer' AND column_name = 'jitter_seconds') = 0,
+    'ALTER TABLE `timer` ADD COLUMN `jitter_seconds` int NOT NULL DEFAULT 0 COMMENT ''触发时间抖动窗口，单位：s'' AFTER `cron`',
+    'SELECT 1');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
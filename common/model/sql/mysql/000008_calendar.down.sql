@@ -0,0 +1,7 @@
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'timer' AND column_name = 'calendar_ids') > 0,
+    'ALTER TABLE `timer` DROP COLUMN `calendar_ids`',
+    'SELECT 1');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+DROP TABLE IF EXISTS `calendar`;
@@ -0,0 +1,22 @@
+-- 节假日日历，定时器引用日历后，落在排除日期上的触发时间不生成 task
+CREATE TABLE IF NOT EXISTS `calendar`
+(
+    `id`         bigint(20) unsigned NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+    `app`        varchar(255) NOT NULL COMMENT '应用名',
+    `name`       varchar(255) NOT NULL COMMENT '日历名称',
+    `weekdays`   varchar(32)  NOT NULL DEFAULT '' COMMENT '排除的星期，逗号分隔，0 为周日',
+    `dates`      mediumtext   NOT NULL COMMENT '排除的日期，逗号分隔，区间用 ~ 连接',
+    `created_at` datetime     NOT NULL COMMENT '创建时间',
+    `updated_at` datetime     DEFAULT NULL ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    `deleted_at` datetime     DEFAULT NULL COMMENT '删除时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_app_name` (`app`,`name`) USING BTREE COMMENT 'app name 索引'
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4;
+
+-- MySQL 的 DDL 会隐式提交事务，按 information_schema 判断列是否存在，迁移中断后可以重复执行
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'timer' AND column_name = 'calendar_ids') = 0,
+    'ALTER TABLE `timer` ADD COLUMN `calendar_ids` varchar(255) NOT NULL DEFAULT '''' COMMENT ''引用的日历 id，逗号分隔'' AFTER `jitter_seconds`',
+    'SELECT 1');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
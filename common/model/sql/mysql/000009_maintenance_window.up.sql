@@ -0,0 +1,15 @@
+-- 维护窗口，窗口内命中的 app 的回调跳过或者延后到窗口结束后执行
+CREATE TABLE IF NOT EXISTS `maintenance_window`
+(
+    `id`         bigint(20) unsigned NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+    `apps`       varchar(1024) NOT NULL COMMENT '命中的 app，逗号分隔，* 表示全部 app',
+    `start_at`   datetime      NOT NULL COMMENT '窗口开始时间',
+    `end_at`     datetime      NOT NULL COMMENT '窗口结束时间',
+    `policy`     varchar(16)   NOT NULL COMMENT '窗口内的处理策略 skip/defer',
+    `reason`     varchar(255)  NOT NULL DEFAULT '' COMMENT '维护原因',
+    `created_at` datetime      NOT NULL COMMENT '创建时间',
+    `updated_at` datetime      DEFAULT NULL ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    `deleted_at` datetime      DEFAULT NULL COMMENT '删除时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_end_at` (`end_at`) USING BTREE COMMENT '结束时间索引'
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4;
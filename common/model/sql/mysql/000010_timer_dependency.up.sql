@@ -0,0 +1,33 @@
+-- 定时器的后继：task 执行成功/失败后触发的定时器，组成串行的工作流
+-- MySQL 的 DDL 会隐式提交事务，按 information_schema 判断列是否存在，迁移中断后可以重复执行
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'timer' AND column_name = 'on_success_ids') = 0,
+    'ALTER TABLE `timer` ADD COLUMN `on_success_ids` varchar(255) NOT NULL DEFAULT '''' COMMENT ''执行成功后触发的定时器 id，逗号分隔'' AFTER `calendar_ids`',
+    'SELECT 1');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'timer' AND column_name = 'on_failure_ids') = 0,
+    'ALTER TABLE `timer` ADD COLUMN `on_failure_ids` varchar(255) NOT NULL DEFAULT '''' COMMENT ''执行失败后触发的定时器 id，逗号分隔'' AFTER `on_success_ids`',
+    'SELECT 1');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'timer' AND column_name = 'pass_output') = 0,
+    'ALTER TABLE `timer` ADD COLUMN `pass_output` tinyint(1) NOT NULL DEFAULT 0 COMMENT ''作为后继触发时，是否把前驱 task 的执行结果渲染进回调 body'' AFTER `on_failure_ids`',
+    'SELECT 1');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+-- 由前驱 task 触发的 task 记录前驱的 id，按 id 串起整条执行链
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'task' AND column_name = 'parent_task_id') = 0,
+    'ALTER TABLE `task` ADD COLUMN `parent_task_id` bigint(20) unsigned NOT NULL DEFAULT 0 COMMENT ''触发该 task 的前驱 task id'' AFTER `timer_id`',
+    'SELECT 1');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = 'task' AND index_name = 'idx_parent_task_id') = 0,
+    'ALTER TABLE `task` ADD KEY `idx_parent_task_id` (`parent_task_id`) COMMENT ''前驱 task 索引''',
+    'SELECT 1');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
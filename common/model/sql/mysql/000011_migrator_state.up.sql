@@ -0,0 +1,12 @@
+-- 迁移器的状态只有一行：已经完成迁移的截止时间，以及最近一次写入的 leader 任期（fencing token）
+-- 迁移器的写入与 fencing token 的校验在同一个事务中，失去 leader 身份的节点无法继续写入
+CREATE TABLE IF NOT EXISTS `migrator_state`
+(
+    `id`            bigint(20) unsigned NOT NULL COMMENT '主键ID，固定为 1',
+    `fencing_token` bigint(20)   NOT NULL DEFAULT 0 COMMENT '最近一次写入的 leader 的 fencing token',
+    `watermark`     datetime     DEFAULT NULL COMMENT '已经完成迁移的截止时间',
+    `updated_at`    datetime     DEFAULT NULL ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+INSERT IGNORE INTO `migrator_state` (`id`, `fencing_token`) VALUES (1, 0);
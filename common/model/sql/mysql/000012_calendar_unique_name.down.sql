@@ -0,0 +1,12 @@
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = 'calendar' AND index_name = 'uniq_app_name_alive') > 0,
+    'ALTER TABLE `calendar` DROP INDEX `uniq_app_name_alive`, ADD INDEX `idx_app_name` (`app`, `name`) USING BTREE COMMENT ''app name 索引''',
+    'SELECT 1');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'calendar' AND column_name = 'alive') > 0,
+    'ALTER TABLE `calendar` DROP COLUMN `alive`',
+    'SELECT 1');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
@@ -0,0 +1,15 @@
+-- 同一个 app 下未删除的日历名称唯一，软删除的日历不占用名称
+-- 唯一索引不约束 NULL，deleted_at 为 NULL 的记录之间不会冲突，用生成列把未删除的记录映射为相同的值 1
+-- MySQL 的 DDL 会隐式提交事务，按 information_schema 判断列、索引是否存在，迁移中断后可以重复执行
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'calendar' AND column_name = 'alive') = 0,
+    'ALTER TABLE `calendar` ADD COLUMN `alive` tinyint GENERATED ALWAYS AS (IF(`deleted_at` IS NULL, 1, NULL)) STORED COMMENT ''未删除时为 1，删除后为 NULL''',
+    'SELECT 1');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = 'calendar' AND index_name = 'uniq_app_name_alive') = 0,
+    'ALTER TABLE `calendar` DROP INDEX `idx_app_name`, ADD UNIQUE INDEX `uniq_app_name_alive` (`app`, `name`, `alive`)',
+    'SELECT 1');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
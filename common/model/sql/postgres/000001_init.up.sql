@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS timer
+(
+    id                bigserial    PRIMARY KEY,
+    app               varchar(255) NOT NULL,
+    name              varchar(255) NOT NULL,
+    status            smallint     NOT NULL,
+    cron              varchar(255) NOT NULL,
+    notify_http_param text         NOT NULL,
+    deleted_at        timestamptz  DEFAULT NULL,
+    created_at        timestamptz  NOT NULL,
+    updated_at        timestamptz  DEFAULT NULL,
+    CONSTRAINT uni_app UNIQUE (app, name)
+);
+COMMENT ON TABLE timer IS '定时器定义';
+
+CREATE TABLE IF NOT EXISTS task
+(
+    id         bigserial    PRIMARY KEY,
+    app        varchar(255) NOT NULL,
+    timer_id   bigint       NOT NULL,
+    output     text         DEFAULT NULL,
+    run_timer  timestamptz  NOT NULL,
+    cost_time  integer      DEFAULT NULL,
+    status     integer      NOT NULL,
+    created_at timestamptz  NOT NULL,
+    updated_at timestamptz  NOT NULL,
+    deleted_at timestamptz  DEFAULT NULL,
+    CONSTRAINT idx_def_timer UNIQUE (timer_id, run_timer)
+);
+CREATE INDEX IF NOT EXISTS idx_run_timer ON task (run_timer);
+COMMENT ON TABLE task IS '定时任务运行流水';
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS api_key
+(
+    id         bigserial     PRIMARY KEY,
+    name       varchar(255)  NOT NULL,
+    key_hash   char(64)      NOT NULL,
+    apps       varchar(1024) NOT NULL,
+    scopes     varchar(64)   NOT NULL,
+    created_at timestamptz   NOT NULL,
+    updated_at timestamptz   DEFAULT NULL,
+    deleted_at timestamptz   DEFAULT NULL,
+    CONSTRAINT uni_key_hash UNIQUE (key_hash)
+);
@@ -0,0 +1 @@
+ALTER TABLE timer DROP COLUMN IF EXISTS generated_through;
@@ -0,0 +1,2 @@
+-- 定时器已经生成 task 的截止时间，迁移器只为截止时间落后的定时器生成 task
+ALTER TABLE timer ADD COLUMN IF NOT EXISTS generated_through timestamptz DEFAULT NULL;
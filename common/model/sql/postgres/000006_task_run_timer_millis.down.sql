@@ -0,0 +1 @@
+ALTER TABLE task ALTER COLUMN run_timer TYPE timestamptz;
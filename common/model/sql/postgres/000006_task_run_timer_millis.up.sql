@@ -0,0 +1,2 @@
+-- 执行时间精确到毫秒，支持亚秒级的定时任务
+ALTER TABLE task ALTER COLUMN run_timer TYPE timestamptz(3);
@@ -0,0 +1 @@
+ALTER TABLE timer DROP COLUMN IF EXISTS jitter_seconds;
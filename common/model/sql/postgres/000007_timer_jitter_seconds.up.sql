@@ -0,0 +1,2 @@
+-- 定时器触发时间的抖动窗口，生成 task 时按定时器 id 确定性地偏移执行时间
+ALTER TABLE timer ADD COLUMN IF NOT EXISTS jitter_seconds integer NOT NULL DEFAULT 0;
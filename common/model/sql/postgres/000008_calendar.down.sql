@@ -0,0 +1,2 @@
+ALTER TABLE timer DROP COLUMN IF EXISTS calendar_ids;
+DROP TABLE IF EXISTS calendar;
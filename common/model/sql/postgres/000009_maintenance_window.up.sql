@@ -0,0 +1,14 @@
+-- 维护窗口，窗口内命中的 app 的回调跳过或者延后到窗口结束后执行
+CREATE TABLE IF NOT EXISTS maintenance_window
+(
+    id         bigserial     PRIMARY KEY,
+    apps       varchar(1024) NOT NULL,
+    start_at   timestamptz   NOT NULL,
+    end_at     timestamptz   NOT NULL,
+    policy     varchar(16)   NOT NULL,
+    reason     varchar(255)  NOT NULL DEFAULT '',
+    created_at timestamptz   NOT NULL,
+    updated_at timestamptz   DEFAULT NULL,
+    deleted_at timestamptz   DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_end_at ON maintenance_window (end_at);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_parent_task_id;
+ALTER TABLE task DROP COLUMN IF EXISTS parent_task_id;
+ALTER TABLE timer DROP COLUMN IF EXISTS pass_output;
+ALTER TABLE timer DROP COLUMN IF EXISTS on_failure_ids;
+ALTER TABLE timer DROP COLUMN IF EXISTS on_success_ids;
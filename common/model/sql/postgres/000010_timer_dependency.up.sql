@@ -0,0 +1,7 @@
+-- 定时器的后继：task 执行成功/失败后触发的定时器，组成串行的工作流
+ALTER TABLE timer ADD COLUMN IF NOT EXISTS on_success_ids varchar(255) NOT NULL DEFAULT '';
+ALTER TABLE timer ADD COLUMN IF NOT EXISTS on_failure_ids varchar(255) NOT NULL DEFAULT '';
+ALTER TABLE timer ADD COLUMN IF NOT EXISTS pass_output boolean NOT NULL DEFAULT false;
+-- 由前驱 task 触发的 task 记录前驱的 id，按 id 串起整条执行链
+ALTER TABLE task ADD COLUMN IF NOT EXISTS parent_task_id bigint NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_parent_task_id ON task (parent_task_id);
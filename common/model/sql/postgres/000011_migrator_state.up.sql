@@ -0,0 +1,11 @@
+-- 迁移器的状态只有一行：已经完成迁移的截止时间，以及最近一次写入的 leader 任期（fencing token）
+-- 迁移器的写入与 fencing token 的校验在同一个事务中，失去 leader 身份的节点无法继续写入
+CREATE TABLE IF NOT EXISTS migrator_state
+(
+    id            bigint      PRIMARY KEY,
+    fencing_token bigint      NOT NULL DEFAULT 0,
+    watermark     timestamptz DEFAULT NULL,
+    updated_at    timestamptz DEFAULT NULL
+);
+
+INSERT INTO migrator_state (id, fencing_token) VALUES (1, 0) ON CONFLICT (id) DO NOTHING;
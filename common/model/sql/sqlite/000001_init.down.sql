@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `task`;
+DROP TABLE IF EXISTS `timer`;
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS `timer`
+(
+    `id`                integer      PRIMARY KEY AUTOINCREMENT,
+    `app`               varchar(255) NOT NULL,
+    `name`              varchar(255) NOT NULL,
+    `status`            smallint     NOT NULL,
+    `cron`              varchar(255) NOT NULL,
+    `notify_http_param` text         NOT NULL,
+    `deleted_at`        datetime     DEFAULT NULL,
+    `created_at`        datetime     NOT NULL,
+    `updated_at`        datetime     DEFAULT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `uni_app` ON `timer` (`app`, `name`);
+
+CREATE TABLE IF NOT EXISTS `task`
+(
+    `id`         integer      PRIMARY KEY AUTOINCREMENT,
+    `app`        varchar(255) NOT NULL,
+    `timer_id`   bigint       NOT NULL,
+    `output`     text         DEFAULT NULL,
+    `run_timer`  datetime     NOT NULL,
+    `cost_time`  integer      DEFAULT NULL,
+    `status`     integer      NOT NULL,
+    `created_at` datetime     NOT NULL,
+    `updated_at` datetime     NOT NULL,
+    `deleted_at` datetime     DEFAULT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_def_timer` ON `task` (`timer_id`, `run_timer`);
+CREATE INDEX IF NOT EXISTS `idx_run_timer` ON `task` (`run_timer`);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS `api_key`
+(
+    `id`         integer       PRIMARY KEY AUTOINCREMENT,
+    `name`       varchar(255)  NOT NULL,
+    `key_hash`   char(64)      NOT NULL,
+    `apps`       varchar(1024) NOT NULL,
+    `scopes`     varchar(64)   NOT NULL,
+    `created_at` datetime      NOT NULL,
+    `updated_at` datetime      DEFAULT NULL,
+    `deleted_at` datetime      DEFAULT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `uni_key_hash` ON `api_key` (`key_hash`);
@@ -0,0 +1 @@
+ALTER TABLE `task` ADD COLUMN `fencing_token` bigint NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE `timer` DROP COLUMN `generated_through`;
@@ -0,0 +1,2 @@
+-- SQLite 的 datetime 没有精度，不需要回退
+SELECT 1;
@@ -0,0 +1,3 @@
+-- 执行时间精确到毫秒，支持亚秒级的定时任务
+-- SQLite 的 datetime 按文本保存完整的时间，已经包含毫秒，不需要变更，这里只记录版本，与其他存储的版本号保持一致
+SELECT 1;
@@ -0,0 +1,15 @@
+-- 节假日日历，定时器引用日历后，落在排除日期上的触发时间不生成 task
+CREATE TABLE IF NOT EXISTS `calendar`
+(
+    `id`         integer      PRIMARY KEY AUTOINCREMENT,
+    `app`        varchar(255) NOT NULL,
+    `name`       varchar(255) NOT NULL,
+    `weekdays`   varchar(32)  NOT NULL DEFAULT '',
+    `dates`      text         NOT NULL,
+    `created_at` datetime     NOT NULL,
+    `updated_at` datetime     DEFAULT NULL,
+    `deleted_at` datetime     DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS `idx_app_name` ON `calendar` (`app`, `name`);
+
+ALTER TABLE `timer` ADD COLUMN `calendar_ids` varchar(255) NOT NULL DEFAULT '';
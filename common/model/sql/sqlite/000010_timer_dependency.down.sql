@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS `idx_parent_task_id`;
+ALTER TABLE `task` DROP COLUMN `parent_task_id`;
+ALTER TABLE `timer` DROP COLUMN `pass_output`;
+ALTER TABLE `timer` DROP COLUMN `on_failure_ids`;
+ALTER TABLE `timer` DROP COLUMN `on_success_ids`;
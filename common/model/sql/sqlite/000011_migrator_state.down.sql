@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `migrator_state`;
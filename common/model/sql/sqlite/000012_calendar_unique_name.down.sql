@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS `uniq_app_name_alive`;
+CREATE INDEX IF NOT EXISTS `idx_app_name` ON `calendar` (`app`, `name`);
@@ -0,0 +1,3 @@
+-- 同一个 app 下未删除的日历名称唯一，软删除的日历不占用名称
+DROP INDEX IF EXISTS `idx_app_name`;
+CREATE UNIQUE INDEX IF NOT EXISTS `uniq_app_name_alive` ON `calendar` (`app`, `name`) WHERE `deleted_at` IS NULL;
@@ -0,0 +1,86 @@
+package vo
+
+import (
+	"time"
+	"timer/common/consts"
+	"timer/common/model/po"
+)
+
+// Principal 通过鉴权的调用方
+type Principal struct {
+	KeyID  uint
+	Name   string
+	Apps   map[string]struct{}
+	Scopes map[consts.APIScope]struct{}
+}
+
+func NewPrincipal(key *po.APIKey) *Principal {
+	principal := Principal{
+		KeyID:  key.ID,
+		Name:   key.Name,
+		Apps:   make(map[string]struct{}),
+		Scopes: make(map[consts.APIScope]struct{}),
+	}
+	for _, app := range key.GetApps() {
+		principal.Apps[app] = struct{}{}
+	}
+	for _, scope := range key.GetScopes() {
+		principal.Scopes[consts.APIScope(scope)] = struct{}{}
+	}
+	return &principal
+}
+
+// Can 判断调用方是否拥有 app 的 scope 权限
+func (p *Principal) Can(app string, scope consts.APIScope) bool {
+	if _, ok := p.Apps[app]; !ok {
+		return false
+	}
+	_, ok := p.Scopes[scope]
+	return ok
+}
+
+type CreateAPIKeyReq struct {
+	Name   string            `json:"name" binding:"required"`         // 调用方名称
+	Apps   []string          `json:"apps" binding:"required,min=1"`   // 可访问的 app
+	Scopes []consts.APIScope `json:"scopes" binding:"required,min=1"` // 权限，read/write
+}
+
+type CreateAPIKeyRespData struct {
+	ID  uint   `json:"id"`
+	Key string `json:"key"` // 明文 key，只在创建时返回一次
+}
+
+type APIKeyReq struct {
+	ID uint `form:"id" json:"id" binding:"required"`
+}
+
+// APIKey 不包含 key 本身
+type APIKey struct {
+	ID        uint              `json:"id"`
+	Name      string            `json:"name"`
+	Apps      []string          `json:"apps"`
+	Scopes    []consts.APIScope `json:"scopes"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+func NewAPIKey(key *po.APIKey) *APIKey {
+	scopes := make([]consts.APIScope, 0)
+	for _, scope := range key.GetScopes() {
+		scopes = append(scopes, consts.APIScope(scope))
+	}
+	return &APIKey{
+		ID:        key.ID,
+		Name:      key.Name,
+		Apps:      key.GetApps(),
+		Scopes:    scopes,
+		CreatedAt: key.CreatedAt,
+	}
+}
+
+func NewAPIKeys(keys []*po.APIKey) []*APIKey {
+	vKeys := make([]*APIKey, 0, len(keys))
+	for _, key := range keys {
+		vKeys = append(vKeys, NewAPIKey(key))
+	}
+	return vKeys
+}
@@ -0,0 +1,18 @@
+package vo
+
+import "time"
+
+type SetBucketsReq struct {
+	BucketsNum    int    `json:"bucketsNum" binding:"required,min=1"` // 每分钟的分桶数量
+	EffectiveFrom string `json:"effectiveFrom" binding:"required"`    // 开始生效的分钟，RFC3339 格式，需要是整分钟
+}
+
+type BucketLayout struct {
+	EffectiveFrom time.Time `json:"effectiveFrom"`
+	BucketsNum    int       `json:"bucketsNum"`
+}
+
+type BucketLayoutsRespData struct {
+	Current int             `json:"current"` // 当前分钟生效的分桶数量
+	Layouts []*BucketLayout `json:"layouts"` // 全部分桶变更记录，按生效时间升序
+}
@@ -0,0 +1,106 @@
+package vo
+
+import (
+	"fmt"
+	"time"
+	"timer/common/model/po"
+	"timer/pkg/calendar"
+)
+
+type CreateCalendarReq struct {
+	App      string   `json:"app" binding:"required"`  // 所属应用的名称
+	Name     string   `json:"name" binding:"required"` // 日历名称
+	Weekdays []int    `json:"weekdays"`                // 排除的星期，0 为周日，6 为周六
+	Dates    []string `json:"dates"`                   // 排除的日期或日期区间，例如 2026-01-01、2026-10-01~2026-10-07
+}
+
+type UpdateCalendarReq struct {
+	App      string   `json:"app" binding:"required"`
+	ID       uint     `json:"id" binding:"required"`
+	Weekdays []int    `json:"weekdays"` // 排除的星期，整体覆盖
+	Dates    []string `json:"dates"`    // 排除的日期或日期区间，整体覆盖
+}
+
+type CalendarReq struct {
+	App string `form:"app" json:"app" binding:"required"`
+	ID  uint   `form:"id" json:"id" binding:"required"`
+}
+
+type GetCalendarsReq struct {
+	App string `form:"app" json:"app" binding:"required"`
+}
+
+type ImportCalendarReq struct {
+	App     string `form:"app" binding:"required"`
+	ID      uint   `form:"id" binding:"required"`
+	Replace bool   `form:"replace"` // 覆盖已有的排除日期，默认与已有的日期合并
+}
+
+type ImportCalendarRespData struct {
+	Imported int      `json:"imported"` // 文件中解析出的日期区间数
+	Dates    []string `json:"dates"`    // 导入后全部的排除日期
+}
+
+type Calendar struct {
+	ID        uint      `json:"id"`
+	App       string    `json:"app"`
+	Name      string    `json:"name"`
+	Weekdays  []int     `json:"weekdays"`
+	Dates     []string  `json:"dates"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func NewCalendar(c *po.Calendar) (*Calendar, error) {
+	rule, err := c.ToCalendar()
+	if err != nil {
+		return nil, err
+	}
+
+	weekdays := make([]int, 0, len(rule.Weekdays))
+	for _, weekday := range rule.Weekdays {
+		weekdays = append(weekdays, int(weekday))
+	}
+	dates := make([]string, 0, len(rule.Ranges))
+	for _, r := range rule.Ranges {
+		dates = append(dates, r.String())
+	}
+	return &Calendar{
+		ID:        c.ID,
+		App:       c.App,
+		Name:      c.Name,
+		Weekdays:  weekdays,
+		Dates:     dates,
+		CreatedAt: c.CreatedAt,
+	}, nil
+}
+
+func NewCalendars(calendars []*po.Calendar) ([]*Calendar, error) {
+	vCalendars := make([]*Calendar, 0, len(calendars))
+	for _, c := range calendars {
+		vCalendar, err := NewCalendar(c)
+		if err != nil {
+			return nil, err
+		}
+		vCalendars = append(vCalendars, vCalendar)
+	}
+	return vCalendars, nil
+}
+
+// NewCalendarRule 校验并转换请求中的排除规则
+func NewCalendarRule(weekdays []int, dates []string) (*calendar.Calendar, error) {
+	var rule calendar.Calendar
+	for _, weekday := range weekdays {
+		if weekday < 0 || weekday > 6 {
+			return nil, fmt.Errorf("%w: weekday %d should be between 0 and 6", ErrCalendarUnValid, weekday)
+		}
+		rule.Weekdays = append(rule.Weekdays, time.Weekday(weekday))
+	}
+	for _, date := range dates {
+		r, err := calendar.ParseRange(date)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCalendarUnValid, err)
+		}
+		rule.Ranges = append(rule.Ranges, r)
+	}
+	return &rule, nil
+}
@@ -6,13 +6,21 @@ const (
 	CodeSuccess ResCode = 1000 + iota
 	CodeServerBusy
 	CodeInvalidParam
+	CodeUnhealthy
+	CodeUnauthorized
+	CodeForbidden
+	CodeQuotaExceeded
 )
 
 var codeMsgMap = map[ResCode]string{
 	CodeSuccess: "success",
 	// 一般不暴露服务器内部错误，对外统一暴露“服务繁忙”
-	CodeServerBusy:   "服务繁忙",
-	CodeInvalidParam: "请求参数错误",
+	CodeServerBusy:    "服务繁忙",
+	CodeInvalidParam:  "请求参数错误",
+	CodeUnhealthy:     "服务不可用",
+	CodeUnauthorized:  "未认证",
+	CodeForbidden:     "没有权限",
+	CodeQuotaExceeded: "超出配额",
 }
 
 func (c ResCode) Msg() string {
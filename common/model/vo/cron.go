@@ -0,0 +1,27 @@
+package vo
+
+import "time"
+
+type CronPreviewReq struct {
+	Expr          string `form:"expr" json:"expr" binding:"required"` // cron 表达式
+	TimeZone      string `form:"timezone" json:"timezone"`            // 时区，例如 Asia/Shanghai，默认服务端本地时区
+	Start         string `form:"start" json:"start"`                  // 开始时间，RFC3339 格式，默认当前时间
+	Num           int    `form:"num" json:"num"`                      // 预览的触发次数，默认 5，最多 100
+	JitterSeconds int    `form:"jitterSeconds" json:"jitterSeconds"`  // 抖动窗口，单位：s，大于 0 时返回实际执行时间
+	TimerID       uint   `form:"timerId" json:"timerId"`              // 定时器 id，抖动偏移由 id 和触发时间决定，不传时按 0 计算
+}
+
+type CronPreviewRespData struct {
+	Valid         bool           `json:"valid"`                   // 表达式是否合法
+	Description   string         `json:"description,omitempty"`   // 表达式的英文描述
+	NextFires     []time.Time    `json:"nextFires,omitempty"`     // 接下来的触发时间
+	JitteredFires []time.Time    `json:"jitteredFires,omitempty"` // 加上抖动偏移后的实际执行时间
+	Error         *CronExprError `json:"error,omitempty"`         // 不合法时的错误信息
+}
+
+// CronExprError cron 表达式的校验错误
+type CronExprError struct {
+	Field    string `json:"field,omitempty"` // 出错的字段，例如 minute
+	Position int    `json:"position"`        // 出错字段在表达式中的起始下标（从 0 开始）
+	Message  string `json:"message"`
+}
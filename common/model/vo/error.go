@@ -3,5 +3,13 @@ package vo
 import "errors"
 
 var (
-	ErrCronExprUnValid = errors.New("cron expression not valid")
+	ErrCronExprUnValid  = errors.New("cron expression not valid")
+	ErrUnauthorized     = errors.New("invalid or missing api key")
+	ErrForbidden        = errors.New("no permission for app")
+	ErrQuotaExceeded    = errors.New("app quota exceeded")
+	ErrScheduleTooDense = errors.New("schedule too dense")
+	ErrJitterUnValid    = errors.New("jitter seconds not valid")
+	ErrCalendarUnValid  = errors.New("calendar not valid")
+	ErrSuccessorUnValid = errors.New("successor not valid")
+	ErrTaskNotFound     = errors.New("task not found")
 )
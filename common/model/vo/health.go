@@ -0,0 +1,36 @@
+package vo
+
+import "time"
+
+// ComponentStatus 单个依赖组件的健康状态
+type ComponentStatus struct {
+	Name    string `json:"name"`            // 组件名称
+	Healthy bool   `json:"healthy"`         // 是否健康
+	Error   string `json:"error,omitempty"` // 不健康的原因
+}
+
+type HealthRespData struct {
+	Healthy    bool               `json:"healthy"`    // 全部组件都健康才为 true
+	Components []*ComponentStatus `json:"components"` // 各组件的检查结果
+}
+
+// ClusterLock 集群中某个分布式锁的持有情况
+type ClusterLock struct {
+	Key      string    `json:"key"`      // 锁的 key，不包含统一前缀
+	Node     string    `json:"node"`     // 持有锁的节点，主机名_进程ID
+	Token    string    `json:"token"`    // 锁的完整 token
+	ExpireAt time.Time `json:"expireAt"` // 锁的过期时间，零值代表没有过期时间
+}
+
+// BucketOwner 分桶由哪个节点负责
+type BucketOwner struct {
+	Bucket int    `json:"bucket"`
+	Node   string `json:"node"`
+}
+
+type ClusterRespData struct {
+	Nodes         []string       `json:"nodes"`         // 存活的调度节点
+	BucketOwners  []*BucketOwner `json:"bucketOwners"`  // 当前分钟每个分桶的负责节点
+	BucketLocks   []*ClusterLock `json:"bucketLocks"`   // time_bucket_lock_* 调度分片锁
+	MigratorLocks []*ClusterLock `json:"migratorLocks"` // migrator_leader 迁移器 leader 租约
+}
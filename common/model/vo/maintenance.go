@@ -0,0 +1,57 @@
+package vo
+
+import (
+	"time"
+	"timer/common/consts"
+	"timer/common/model/po"
+)
+
+type CreateMaintenanceWindowReq struct {
+	Apps    []string                 `json:"apps" binding:"required,min=1"` // 命中的 app，* 表示全部 app
+	StartAt time.Time                `json:"startAt" binding:"required"`    // 窗口开始时间，RFC3339 格式
+	EndAt   time.Time                `json:"endAt" binding:"required"`      // 窗口结束时间，RFC3339 格式
+	Policy  consts.MaintenancePolicy `json:"policy" binding:"required"`     // 窗口内的处理策略，skip/defer
+	Reason  string                   `json:"reason"`                        // 维护原因
+}
+
+type MaintenanceWindowReq struct {
+	ID uint `form:"id" json:"id" binding:"required"`
+}
+
+type MaintenanceWindow struct {
+	ID        uint                     `json:"id"`
+	Apps      []string                 `json:"apps"`
+	StartAt   time.Time                `json:"startAt"`
+	EndAt     time.Time                `json:"endAt"`
+	Policy    consts.MaintenancePolicy `json:"policy"`
+	Reason    string                   `json:"reason"`
+	CreatedAt time.Time                `json:"createdAt"`
+}
+
+func NewMaintenanceWindow(window *po.MaintenanceWindow) *MaintenanceWindow {
+	return &MaintenanceWindow{
+		ID:        window.ID,
+		Apps:      window.GetApps(),
+		StartAt:   window.StartAt,
+		EndAt:     window.EndAt,
+		Policy:    consts.MaintenancePolicy(window.Policy),
+		Reason:    window.Reason,
+		CreatedAt: window.CreatedAt,
+	}
+}
+
+func NewMaintenanceWindows(windows []*po.MaintenanceWindow) []*MaintenanceWindow {
+	vWindows := make([]*MaintenanceWindow, 0, len(windows))
+	for _, window := range windows {
+		vWindows = append(vWindows, NewMaintenanceWindow(window))
+	}
+	return vWindows
+}
+
+// TaskHold 任务被维护窗口暂缓或者跳过的记录，写入 task 的 output
+type TaskHold struct {
+	WindowID  uint                     `json:"maintenanceWindowId"`
+	Policy    consts.MaintenancePolicy `json:"policy"`
+	Reason    string                   `json:"reason,omitempty"`
+	ReleaseAt *time.Time               `json:"releaseAt,omitempty"` // 暂缓的任务重新投递的时间
+}
@@ -42,3 +42,12 @@ func ResponseSuccess(c *gin.Context, data interface{}) {
 		Data: data,
 	})
 }
+
+// ResponseWithStatus 指定 http 状态码返回，用于健康检查等需要依赖 http 状态码的场景
+func ResponseWithStatus(c *gin.Context, httpStatus int, code ResCode, data interface{}) {
+	c.JSON(httpStatus, &ResponseData{
+		Code: code,
+		Msg:  code.Msg(),
+		Data: data,
+	})
+}
@@ -1,31 +1,65 @@
 package vo
 
 import (
+	"encoding/json"
 	"time"
+	"timer/common/consts"
 	"timer/common/model/po"
 )
 
+type GetTasksReq struct {
+	App     string `form:"app" json:"app" binding:"required"` // 所属应用的名称
+	TimerID uint   `form:"timerId" json:"timerId"`            // 定时器 id，不传时查询 app 下全部定时器
+	Status  *int   `form:"status" json:"status"`              // 任务状态，例如 4 查询被维护窗口暂缓的任务
+	Start   string `form:"start" json:"start"`                // 执行时间的开始，RFC3339 格式
+	End     string `form:"end" json:"end"`                    // 执行时间的结束，RFC3339 格式，不包含
+	Offset  int    `form:"offset" json:"offset"`
+	Limit   int    `form:"limit" json:"limit"` // 默认 20，最多 500
+}
+
+// GetTaskChainReq 查询 task 所在的整条执行链
+type GetTaskChainReq struct {
+	App string `form:"app" json:"app" binding:"required"`
+	ID  uint   `form:"id" json:"id" binding:"required"` // 执行链上任意一个 task 的 id
+}
+
+// TaskChain 由后继触发串起来的一次执行，按触发顺序排列，第一个为按时间触发的根 task
+type TaskChain struct {
+	RootID uint    `json:"rootId"`
+	Tasks  []*Task `json:"tasks"`
+}
+
 // Task 运行流水记录
 type Task struct {
-	ID       uint      `json:"id"`       // 任务 ID
-	App      string    `json:"app"`      // 定义ID
-	TimerID  uint      `json:"timerID"`  // 定义ID
-	Output   string    `json:"output"`   // 执行结果
-	RunTimer time.Time `json:"runTimer"` // 执行时间
-	CostTime int       `json:"costTime"` // 执行耗时
-	Status   int       `json:"status"`   // 当前状态
+	ID       uint      `json:"id"`             // 任务 ID
+	App      string    `json:"app"`            // 定义ID
+	TimerID  uint      `json:"timerID"`        // 定义ID
+	ParentID uint      `json:"parentId"`       // 触发该 task 的前驱 task id，按时间触发时为 0
+	Output   string    `json:"output"`         // 执行结果
+	RunTimer time.Time `json:"runTimer"`       // 执行时间
+	CostTime int       `json:"costTime"`       // 执行耗时
+	Status   int       `json:"status"`         // 当前状态
+	Hold     *TaskHold `json:"hold,omitempty"` // 被维护窗口暂缓或者跳过的记录
 }
 
 func NewTask(task *po.Task) *Task {
-	return &Task{
+	vTask := Task{
 		ID:       task.ID,
 		App:      task.App,
 		TimerID:  task.TimerID,
+		ParentID: task.ParentTaskID,
 		Output:   task.Output,
 		RunTimer: task.RunTimer,
 		CostTime: task.CostTime,
 		Status:   task.Status,
 	}
+	if task.Status == consts.Held.ToInt() || task.Status == consts.Skipped.ToInt() {
+		var hold TaskHold
+		if err := json.Unmarshal([]byte(task.Output), &hold); err == nil {
+			vTask.Hold = &hold
+		}
+	}
+	return &vTask
 }
 
 func NewTasks(tasks []*po.Task) []*Task {
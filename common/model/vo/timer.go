@@ -3,6 +3,7 @@ package vo
 import (
 	"encoding/json"
 	"errors"
+	"time"
 	"timer/common/consts"
 	"timer/common/model/po"
 )
@@ -12,8 +13,19 @@ type CreateTimerReq struct {
 }
 
 type CreateTimerRespData struct {
-	Id      uint `json:"id"`
-	Success bool `json:"success"`
+	Id       uint              `json:"id"`
+	Success  bool              `json:"success"`
+	Schedule *ScheduleAnalysis `json:"schedule,omitempty"` // 调度分析结果
+}
+
+// ScheduleAnalysis cron 表达式的调度分析结果
+type ScheduleAnalysis struct {
+	MinIntervalSeconds int64       `json:"minIntervalSeconds"`      // 相邻两次触发的最小间隔，单位：s
+	FiresPerDay        int         `json:"firesPerDay"`             // 任意一天内的最大触发次数
+	NextFires          []time.Time `json:"nextFires"`               // 接下来的触发时间预览
+	Warnings           []string    `json:"warnings,omitempty"`      // 告警信息，例如永远不会触发
+	JitterSeconds      int         `json:"jitterSeconds,omitempty"` // 生效的抖动窗口，单位：s
+	JitteredFires      []time.Time `json:"jitteredFires,omitempty"` // 加上抖动偏移后的实际执行时间
 }
 
 type TimerReq struct {
@@ -21,12 +33,26 @@ type TimerReq struct {
 	ID  uint   `form:"id" json:"id" binding:"required"`
 }
 
+// UpdateSuccessorsReq 整体覆盖定时器的后继
+type UpdateSuccessorsReq struct {
+	App        string `json:"app" binding:"required"`
+	ID         uint   `json:"id" binding:"required"`
+	OnSuccess  []uint `json:"onSuccess"`  // 执行成功后触发的定时器 id
+	OnFailure  []uint `json:"onFailure"`  // 执行失败后触发的定时器 id
+	PassOutput *bool  `json:"passOutput"` // 不传时保持不变
+}
+
 type Timer struct {
 	ID              uint               `json:"id,omitempty"`
 	App             string             `json:"app,omitempty" binding:"required"`             // 所属应用的名称
 	Name            string             `json:"name,omitempty" binding:"required"`            // 定时器定义名称
 	Status          consts.TimerStatus `json:"status"`                                       // 定时器定义状态，1:未激活, 2:已激活
-	Cron            string             `json:"cron,omitempty" binding:"required"`            // 定时器定时配置
+	Cron            string             `json:"cron,omitempty"`                               // 定时器定时配置，为空时只作为其他定时器的后继执行
+	JitterSeconds   int                `json:"jitterSeconds,omitempty"`                      // 触发时间抖动窗口，单位：s，不配置时使用 app 的打散窗口，-1 不抖动
+	CalendarIDs     []uint             `json:"calendarIds,omitempty"`                        // 引用的日历 id，落在任意一个日历排除日期上的触发时间不执行
+	OnSuccess       []uint             `json:"onSuccess,omitempty"`                          // 执行成功后触发的定时器 id
+	OnFailure       []uint             `json:"onFailure,omitempty"`                          // 执行失败后触发的定时器 id
+	PassOutput      bool               `json:"passOutput,omitempty"`                         // 作为后继触发时，body 按 text/template 渲染前驱 task，例如 {{json .Output}}
 	NotifyHTTPParam *NotifyHTTPParam   `json:"notifyHTTPParam,omitempty" binding:"required"` // http 回调参数
 }
 
@@ -61,8 +87,13 @@ func (timer *Timer) ToPo() (*po.Timer, error) {
 		Name:            timer.Name,
 		Status:          timer.Status.ToInt(),
 		Cron:            timer.Cron,
+		JitterSeconds:   timer.JitterSeconds,
+		PassOutput:      timer.PassOutput,
 		NotifyHTTPParam: string(param),
 	}
+	poTimer.SetCalendarIDs(timer.CalendarIDs)
+	poTimer.SetOnSuccessIDs(timer.OnSuccess)
+	poTimer.SetOnFailureIDs(timer.OnFailure)
 
 	return poTimer, nil
 }
@@ -79,6 +110,11 @@ func NewTimer(timer *po.Timer) (*Timer, error) {
 		Name:            timer.Name,
 		Status:          consts.TimerStatus(timer.Status),
 		Cron:            timer.Cron,
+		JitterSeconds:   timer.JitterSeconds,
+		CalendarIDs:     timer.GetCalendarIDs(),
+		OnSuccess:       timer.GetOnSuccessIDs(),
+		OnFailure:       timer.GetOnFailureIDs(),
+		PassOutput:      timer.PassOutput,
 		NotifyHTTPParam: &param,
 	}, nil
 }
@@ -20,6 +20,24 @@ func GetCurrentGoroutineID() string {
 	return strings.TrimSpace(strings.Split(strings.Split(stackInfo, "[running]")[0], "goroutine")[1])
 }
 
+// GetCurrentNodeID 获取当前节点标识：主机名_进程ID，用于区分集群中的不同节点
+func GetCurrentNodeID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s_%s", hostname, GetCurrentProcessID())
+}
+
 func GetProcessAndGoroutineIDStr() string {
-	return fmt.Sprintf("%s_%s", GetCurrentProcessID(), GetCurrentGoroutineID())
+	return fmt.Sprintf("%s_%s", GetCurrentNodeID(), GetCurrentGoroutineID())
+}
+
+// GetNodeIDFromToken 从 主机名_进程ID_协程ID 格式的 token 中提取节点标识
+func GetNodeIDFromToken(token string) string {
+	idx := strings.LastIndex(token, "_")
+	if idx < 0 {
+		return token
+	}
+	return token[:idx]
 }
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"timer/common/consts"
+)
+
+// SliceKeyVersion 分片 key 的格式版本，格式变化时递增，新旧格式的 key 互不冲突
+const SliceKeyVersion = "v1"
+
+// SliceKey 调度分片，即某一分钟的某一个桶
+// 格式为 <app>:<version>:{<minute>_<bucket>}，app 区分共用同一个 redis 的不同部署；
+// 花括号内为 hash tag，同一个分片相关的 key（zset、处理中集合、分片锁）在集群模式下落在同一个 slot
+type SliceKey struct {
+	App     string
+	Version string
+	Minute  time.Time
+	Bucket  int
+}
+
+// NewSliceKey 当前格式版本下 t 所在分钟的分片
+func NewSliceKey(app string, t time.Time, bucket int) SliceKey {
+	return SliceKey{
+		App:     app,
+		Version: SliceKeyVersion,
+		Minute:  t.Truncate(time.Minute),
+		Bucket:  bucket,
+	}
+}
+
+// ParseSliceKey 解析 String 生成的分片 key
+func ParseSliceKey(s string) (SliceKey, error) {
+	start, end := strings.Index(s, "{"), strings.LastIndex(s, "}")
+	if start < 0 || end != len(s)-1 {
+		return SliceKey{}, fmt.Errorf("invalid slice key: %s, hash tag not found", s)
+	}
+
+	namespace := strings.Split(strings.TrimSuffix(s[:start], ":"), ":")
+	if len(namespace) != 2 || !strings.HasSuffix(s[:start], ":") {
+		return SliceKey{}, fmt.Errorf("invalid slice key: %s, namespace should be <app>:<version>:", s)
+	}
+
+	key, err := parseMinuteBucket(s[start+1:end], "_")
+	if err != nil {
+		return SliceKey{}, fmt.Errorf("invalid slice key: %s, err: %w", s, err)
+	}
+	key.App, key.Version = namespace[0], namespace[1]
+	return key, key.Validate()
+}
+
+// ParseLegacySliceKey 解析旧格式的分片 key：不带命名空间的 {<minute>_<bucket>}、<minute>_<bucket> 以及 <minute>:<bucket>
+// 解析结果不带命名空间，由调用方补充
+func ParseLegacySliceKey(s string) (SliceKey, error) {
+	if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+		return parseMinuteBucket(s[1:len(s)-1], "_")
+	}
+	if key, err := parseMinuteBucket(s, "_"); err == nil {
+		return key, nil
+	}
+	return parseMinuteBucket(s, ":")
+}
+
+// parseMinuteBucket 按最后一个 sep 拆分分钟与桶，分钟本身带有冒号
+func parseMinuteBucket(s, sep string) (SliceKey, error) {
+	idx := strings.LastIndex(s, sep)
+	if idx < 0 {
+		return SliceKey{}, fmt.Errorf("invalid minute bucket: %s", s)
+	}
+
+	minute, err := GetStartMinute(s[:idx])
+	if err != nil {
+		return SliceKey{}, err
+	}
+	bucket, err := strconv.Atoi(s[idx+len(sep):])
+	if err != nil || bucket < 0 {
+		return SliceKey{}, fmt.Errorf("invalid bucket of minute bucket: %s", s)
+	}
+	return SliceKey{Minute: minute, Bucket: bucket}, nil
+}
+
+// Validate 校验分片的各个字段，命名空间中不能出现分隔符与花括号
+func (k SliceKey) Validate() error {
+	for _, part := range []string{k.App, k.Version} {
+		if part == "" || strings.ContainsAny(part, ":{}") {
+			return fmt.Errorf("invalid namespace of slice key, app: %q, version: %q", k.App, k.Version)
+		}
+	}
+	if k.Minute.IsZero() || !k.Minute.Equal(k.Minute.Truncate(time.Minute)) {
+		return fmt.Errorf("invalid minute of slice key: %v", k.Minute)
+	}
+	if k.Bucket < 0 {
+		return fmt.Errorf("invalid bucket of slice key: %d", k.Bucket)
+	}
+	return nil
+}
+
+// HashTag 分片的 hash tag
+func (k SliceKey) HashTag() string {
+	return fmt.Sprintf("{%s_%d}", k.Minute.Format(consts.MinuteFormat), k.Bucket)
+}
+
+// String 分片 zset 的 key
+func (k SliceKey) String() string {
+	return fmt.Sprintf("%s:%s:%s", k.App, k.Version, k.HashTag())
+}
+
+// LockKey 分片的分布式锁
+func (k SliceKey) LockKey() string {
+	return TimeBucketLockKeyPrefix + k.String()
+}
+
+// SuccessKey 分片处理成功的标记，标记存在时分片的锁不能被接管
+func (k SliceKey) SuccessKey() string {
+	return TimeBucketSuccessKeyPrefix + k.String()
+}
+
+// ProcessingKey 延迟队列模式下分片已经弹出、等待 ack 的 zset
+func (k SliceKey) ProcessingKey() string {
+	return k.String() + "_processing"
+}
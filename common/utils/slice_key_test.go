@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSliceKey(t *testing.T) {
+	minute := time.Date(2024, 1, 2, 3, 4, 0, 0, time.Local)
+	cases := []struct {
+		name       string
+		key        SliceKey
+		str        string
+		lock       string
+		processing string
+	}{
+		{
+			name:       "default app",
+			key:        NewSliceKey("timer", minute.Add(30*time.Second), 7),
+			str:        "timer:v1:{2024-01-02 03:04_7}",
+			lock:       "time_bucket_lock_timer:v1:{2024-01-02 03:04_7}",
+			processing: "timer:v1:{2024-01-02 03:04_7}_processing",
+		},
+		{
+			name:       "custom app",
+			key:        NewSliceKey("billing-prod", minute, 0),
+			str:        "billing-prod:v1:{2024-01-02 03:04_0}",
+			lock:       "time_bucket_lock_billing-prod:v1:{2024-01-02 03:04_0}",
+			processing: "billing-prod:v1:{2024-01-02 03:04_0}_processing",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.key.String(); got != c.str {
+				t.Errorf("String: got %q, want %q", got, c.str)
+			}
+			if got := c.key.LockKey(); got != c.lock {
+				t.Errorf("LockKey: got %q, want %q", got, c.lock)
+			}
+			if got := c.key.ProcessingKey(); got != c.processing {
+				t.Errorf("ProcessingKey: got %q, want %q", got, c.processing)
+			}
+
+			parsed, err := ParseSliceKey(c.key.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.App != c.key.App || parsed.Version != c.key.Version || !parsed.Minute.Equal(c.key.Minute) || parsed.Bucket != c.key.Bucket {
+				t.Errorf("ParseSliceKey: got %+v, want %+v", parsed, c.key)
+			}
+		})
+	}
+}
+
+func TestParseSliceKeyInvalid(t *testing.T) {
+	for _, s := range []string{
+		"{2024-01-02 03:04_7}",
+		"timer:{2024-01-02 03:04_7}",
+		"a:b:timer:v1:{2024-01-02 03:04_7}",
+		"timer:v1:{2024-01-02 03:04_x}",
+		"timer:v1:{2024-01-02 03:04_7}_processing",
+	} {
+		if _, err := ParseSliceKey(s); err == nil {
+			t.Errorf("ParseSliceKey(%q) should fail", s)
+		}
+	}
+}
+
+func TestParseLegacySliceKey(t *testing.T) {
+	minute := time.Date(2024, 1, 2, 3, 4, 0, 0, time.Local)
+	for _, s := range []string{"{2024-01-02 03:04_7}", "2024-01-02 03:04_7", "2024-01-02 03:04:7"} {
+		key, err := ParseLegacySliceKey(s)
+		if err != nil {
+			t.Fatalf("ParseLegacySliceKey(%q): %v", s, err)
+		}
+		if !key.Minute.Equal(minute) || key.Bucket != 7 {
+			t.Errorf("ParseLegacySliceKey(%q): got %+v", s, key)
+		}
+	}
+}
@@ -2,14 +2,16 @@ package utils
 
 import (
 	"fmt"
+	"hash/fnv"
 	"strconv"
 	"strings"
 	"time"
 	"timer/common/consts"
 )
 
-func GetForwardTwoMigrateStepEnd(cur time.Time, diff time.Duration) time.Time {
-	end := cur.Add(diff)
+// GetForwardTwoMigrateStepEnd 迁移器提前写入的截止时间，即 cur 之后两个迁移步长所在的整点，step 为一个迁移步长
+func GetForwardTwoMigrateStepEnd(cur time.Time, step time.Duration) time.Time {
+	end := cur.Add(2 * step)
 	return time.Date(end.Year(), end.Month(), end.Day(), end.Hour(), 0, 0, 0, time.Local)
 }
 
@@ -17,13 +19,22 @@ func UnionTimerIDUnix(timerID uint, unix int64) string {
 	return fmt.Sprintf("%d_%d", timerID, unix)
 }
 
-func GetTimeBucketLockKey(t time.Time, bucketID int) string {
-	return fmt.Sprintf("time_bucket_lock_%s_%d", t.Format(consts.MinuteFormat), bucketID)
-}
-
-func GetSliceMsgKey(t time.Time, bucketID int) string {
-	return fmt.Sprintf("%s_%d", t.Format(consts.MinuteFormat), bucketID)
-}
+const (
+	// TimeBucketLockKeyPrefix 调度分片锁的前缀
+	TimeBucketLockKeyPrefix = "time_bucket_lock_"
+	// TimeBucketSuccessKeyPrefix 分片处理成功的标记的前缀
+	TimeBucketSuccessKeyPrefix = "time_bucket_success_"
+	// TimeBucketLockKeyPattern 匹配全部调度分片锁的 pattern
+	TimeBucketLockKeyPattern = TimeBucketLockKeyPrefix + "*"
+	// MigratorLockKeyPattern 匹配迁移器 leader 租约的 pattern
+	MigratorLockKeyPattern = MigratorLeaderKey + "*"
+	// MigratorLeaderKey 迁移器 leader 租约的 key
+	MigratorLeaderKey = "migrator_leader"
+	// BucketLayoutKey 分桶数量的变更记录，field 为生效的分钟，value 为分桶数量
+	BucketLayoutKey = "bucket_layout"
+	// SchedulerNodesKey 存活的调度节点，member 为节点标识，score 为最近一次心跳的时间（毫秒）
+	SchedulerNodesKey = "scheduler_nodes"
+)
 
 func GetStartMinute(timeStr string) (time.Time, error) {
 	return time.ParseInLocation(consts.MinuteFormat, timeStr, time.Local)
@@ -48,10 +59,31 @@ func GetDayStr(t time.Time) string {
 	return t.Format(consts.DayFormat)
 }
 
-func GetMigratorLockKey(t time.Time) string {
-	return fmt.Sprintf("migrator_lock_%s", t.Format(consts.HourFormat))
-}
-
 func GetStartHour(t time.Time) time.Time {
 	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.Local)
 }
+
+func GetAppRateLimitKey(app string) string {
+	return fmt.Sprintf("app_rate_limit_%s", app)
+}
+
+func GetAppConcurrencyKey(app string) string {
+	return fmt.Sprintf("app_concurrency_%s", app)
+}
+
+// GetJitterOffset 触发时间 fireAt 在抖动窗口内的偏移，由定时器 id 和触发时间确定，重复计算的结果相同，精确到毫秒
+func GetJitterOffset(timerID uint, fireAt time.Time, window time.Duration) time.Duration {
+	windowMs := int64(window / time.Millisecond)
+	if windowMs <= 0 {
+		return 0
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(UnionTimerIDUnix(timerID, fireAt.UnixMilli())))
+	return time.Duration(h.Sum64()%uint64(windowMs)) * time.Millisecond
+}
+
+// GetRetentionLockKey 流水清理每个执行周期一把锁，t 为周期的开始时间
+func GetRetentionLockKey(t time.Time) string {
+	return fmt.Sprintf("retention_lock_%s", t.Format(consts.MinuteFormat))
+}
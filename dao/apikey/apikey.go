@@ -0,0 +1,49 @@
+package apikey
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"timer/common/model/po"
+	"timer/pkg/database"
+)
+
+type APIKeyDao struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyDao(db *gorm.DB) *APIKeyDao {
+	return &APIKeyDao{
+		db: db,
+	}
+}
+
+func (dao *APIKeyDao) TableWithContext(ctx context.Context) *gorm.DB {
+	return database.Conn(ctx, dao.db).Table(po.APIKeyTable)
+}
+
+func (dao *APIKeyDao) CreateAPIKey(ctx context.Context, key *po.APIKey) (uint, error) {
+	err := dao.TableWithContext(ctx).Create(key).Error
+	return key.ID, err
+}
+
+func (dao *APIKeyDao) DeleteAPIKey(ctx context.Context, id uint) error {
+	return dao.TableWithContext(ctx).Delete(&po.APIKey{}, id).Error
+}
+
+func (dao *APIKeyDao) GetAPIKey(ctx context.Context, opts ...Option) (*po.APIKey, error) {
+	db := dao.TableWithContext(ctx)
+	for _, opt := range opts {
+		db = opt(db)
+	}
+	var key po.APIKey
+	return &key, db.First(&key).Error
+}
+
+func (dao *APIKeyDao) GetAPIKeys(ctx context.Context, opts ...Option) ([]*po.APIKey, error) {
+	db := dao.TableWithContext(ctx)
+	for _, opt := range opts {
+		db = opt(db)
+	}
+	var keys []*po.APIKey
+	return keys, db.Where("deleted_at IS NULL").Scan(&keys).Error
+}
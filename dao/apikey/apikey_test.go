@@ -0,0 +1,48 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"timer/common/model/po"
+	"timer/pkg/testenv"
+
+	"gorm.io/gorm"
+)
+
+func TestDeletedAPIKey(t *testing.T) {
+	ctx := context.Background()
+	dao := NewAPIKeyDao(testenv.NewDB(t))
+
+	var ids []uint
+	for _, hash := range []string{"kept", "revoked"} {
+		id, err := dao.CreateAPIKey(ctx, &po.APIKey{Name: hash, KeyHash: hash, Apps: "app", Scopes: "read"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := dao.DeleteAPIKey(ctx, ids[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := dao.GetAPIKey(ctx, WithKeyHash("kept"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.ID != ids[0] || key.Apps != "app" || key.Scopes != "read" {
+		t.Errorf("got key %+v, want id %d of app with read scope", key, ids[0])
+	}
+
+	// 删除后的 key 不能再通过 hash 查到，也不出现在列表中
+	if _, err := dao.GetAPIKey(ctx, WithKeyHash("revoked")); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("get revoked key err %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+	keys, err := dao.GetAPIKeys(ctx, WithDesc())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].ID != ids[0] {
+		t.Errorf("listed %d keys, want only key %d", len(keys), ids[0])
+	}
+}
@@ -0,0 +1,23 @@
+package apikey
+
+import "gorm.io/gorm"
+
+type Option func(*gorm.DB) *gorm.DB
+
+func WithID(id uint) Option {
+	return func(d *gorm.DB) *gorm.DB {
+		return d.Where("id = ?", id)
+	}
+}
+
+func WithKeyHash(keyHash string) Option {
+	return func(d *gorm.DB) *gorm.DB {
+		return d.Where("key_hash = ?", keyHash)
+	}
+}
+
+func WithDesc() Option {
+	return func(d *gorm.DB) *gorm.DB {
+		return d.Order("created_at DESC")
+	}
+}
@@ -0,0 +1,139 @@
+package bucket
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"timer/common/conf"
+	"timer/common/consts"
+	"timer/common/utils"
+	"timer/pkg/redis"
+)
+
+// Layout 从 EffectiveFrom 这一分钟开始，每分钟分为 BucketsNum 个桶
+type Layout struct {
+	EffectiveFrom time.Time
+	BucketsNum    int
+}
+
+// Layouts 按生效时间升序排列的分桶变更记录
+type Layouts []*Layout
+
+// ErrLayoutChanged 校验之后分桶变更记录已经被其他请求修改
+var ErrLayoutChanged = errors.New("bucket layout changed concurrently")
+
+// layoutCacheTTL 进程内缓存分桶变更记录的时间
+// 新的分桶数量最早在两分钟之后生效，缓存 1s 不会让调度读到过期的分桶数量
+const layoutCacheTTL = time.Second
+
+// Last 最后一条变更记录，没有变更记录时返回 nil
+func (layouts Layouts) Last() *Layout {
+	if len(layouts) == 0 {
+		return nil
+	}
+	return layouts[len(layouts)-1]
+}
+
+// BucketsNumAt 获取 t 所在分钟生效的分桶数量，早于全部变更记录时使用默认值
+func (layouts Layouts) BucketsNumAt(t time.Time, defaultNum int) int {
+	minute := t.Truncate(time.Minute)
+	bucketsNum := defaultNum
+	for _, layout := range layouts {
+		if layout.EffectiveFrom.After(minute) {
+			break
+		}
+		bucketsNum = layout.BucketsNum
+	}
+	return bucketsNum
+}
+
+// BucketDao 分桶数量保存在 redis 中，可以在运行时修改，每次修改从指定的分钟开始生效
+type BucketDao struct {
+	client *redis.Client
+	config *conf.SchedulerAppConfig
+
+	// 调度器每个 tick 都要获取分桶数量，短时间缓存变更记录，不必每次都读 redis
+	mu       sync.Mutex
+	cached   Layouts
+	cachedAt time.Time
+}
+
+func NewBucketDao(client *redis.Client, config *conf.SchedulerAppConfig) *BucketDao {
+	return &BucketDao{
+		client: client,
+		config: config,
+	}
+}
+
+// GetLayouts 获取全部分桶变更记录
+func (dao *BucketDao) GetLayouts(ctx context.Context) (Layouts, error) {
+	fields, err := dao.client.HGetAll(ctx, utils.BucketLayoutKey)
+	if err != nil {
+		return nil, err
+	}
+
+	layouts := make(Layouts, 0, len(fields))
+	for minute, num := range fields {
+		effectiveFrom, err := utils.GetStartMinute(minute)
+		if err != nil {
+			return nil, err
+		}
+		bucketsNum, err := strconv.Atoi(num)
+		if err != nil {
+			return nil, err
+		}
+		layouts = append(layouts, &Layout{EffectiveFrom: effectiveFrom, BucketsNum: bucketsNum})
+	}
+
+	sort.Slice(layouts, func(i, j int) bool {
+		return layouts[i].EffectiveFrom.Before(layouts[j].EffectiveFrom)
+	})
+	return layouts, nil
+}
+
+// GetBucketsNum 获取 t 所在分钟生效的分桶数量，没有变更记录时使用配置的分桶数量
+// 读取的是进程内缓存的变更记录，写入缓存 task 时需要用 GetLayouts 读取最新的记录
+func (dao *BucketDao) GetBucketsNum(ctx context.Context, t time.Time) (int, error) {
+	layouts, err := dao.getCachedLayouts(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return layouts.BucketsNumAt(t, dao.config.BucketsNum), nil
+}
+
+func (dao *BucketDao) getCachedLayouts(ctx context.Context) (Layouts, error) {
+	dao.mu.Lock()
+	defer dao.mu.Unlock()
+	if !dao.cachedAt.IsZero() && time.Since(dao.cachedAt) < layoutCacheTTL {
+		return dao.cached, nil
+	}
+
+	layouts, err := dao.GetLayouts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dao.cached, dao.cachedAt = layouts, time.Now()
+	return layouts, nil
+}
+
+// AddLayout 在 last 之后追加一条分桶变更记录，last 为校验时看到的最后一条记录，没有记录时为 nil
+// 校验之后变更记录被其他请求修改时返回 ErrLayoutChanged
+func (dao *BucketDao) AddLayout(ctx context.Context, layout *Layout, last *Layout) error {
+	var lastField string
+	if last != nil {
+		lastField = last.EffectiveFrom.Format(consts.MinuteFormat)
+	}
+
+	reply, err := dao.client.Eval(ctx, luaAddLayout, 1, []interface{}{utils.BucketLayoutKey, lastField,
+		layout.EffectiveFrom.Format(consts.MinuteFormat), layout.BucketsNum})
+	if err != nil {
+		return err
+	}
+	if ok, _ := reply.(int64); ok != 1 {
+		return ErrLayoutChanged
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package bucket
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"timer/common/conf"
+	"timer/pkg/testenv"
+)
+
+func TestAddLayout(t *testing.T) {
+	ctx := context.Background()
+	_, rdb := testenv.NewRedis(t)
+	dao := NewBucketDao(rdb, &conf.SchedulerAppConfig{BucketsNum: 10})
+
+	minute := time.Now().Truncate(time.Minute)
+	first := &Layout{EffectiveFrom: minute.Add(2 * time.Minute), BucketsNum: 20}
+	second := &Layout{EffectiveFrom: minute.Add(5 * time.Minute), BucketsNum: 30}
+
+	if err := dao.AddLayout(ctx, first, nil); err != nil {
+		t.Fatal(err)
+	}
+	// 基于过期的校验结果追加
+	if err := dao.AddLayout(ctx, second, nil); !errors.Is(err, ErrLayoutChanged) {
+		t.Fatalf("add with stale last layout, got err: %v", err)
+	}
+	// 不能早于最后一条记录
+	if err := dao.AddLayout(ctx, &Layout{EffectiveFrom: minute, BucketsNum: 40}, first); !errors.Is(err, ErrLayoutChanged) {
+		t.Fatalf("add before last layout, got err: %v", err)
+	}
+	if err := dao.AddLayout(ctx, second, first); err != nil {
+		t.Fatal(err)
+	}
+
+	layouts, err := dao.GetLayouts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layouts) != 2 || layouts.Last().BucketsNum != 30 {
+		t.Fatalf("unexpected layouts: %+v", layouts)
+	}
+	for _, c := range []struct {
+		at   time.Time
+		want int
+	}{
+		{minute, 10},
+		{first.EffectiveFrom, 20},
+		{second.EffectiveFrom.Add(-time.Second), 20},
+		{second.EffectiveFrom, 30},
+	} {
+		if got, _ := dao.GetBucketsNum(ctx, c.at); got != c.want {
+			t.Errorf("buckets num at %v: got %d, want %d", c.at, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package bucket
+
+// luaAddLayout 只有最后一条变更记录仍是调用方校验时看到的记录，并且新记录晚于它时才写入
+// 生效时间按 2006-01-02 15:04 格式保存，字符串顺序即时间顺序
+// KEYS[1] 分桶变更记录；ARGV[1] 调用方看到的最后一条记录的生效时间，没有记录时为空；ARGV[2] 新记录的生效时间；ARGV[3] 分桶数量
+// 返回 1 写入成功，0 变更记录已经被修改
+const luaAddLayout = `
+  local last = ''
+  for _, field in ipairs(redis.call('hkeys', KEYS[1])) do
+    if field > last then
+      last = field
+    end
+  end
+  if last ~= ARGV[1] or ARGV[2] <= last then
+    return 0
+  end
+  redis.call('hset', KEYS[1], ARGV[2], ARGV[3])
+  return 1
+`
@@ -0,0 +1,57 @@
+package calendar
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"timer/common/model/po"
+	"timer/pkg/database"
+)
+
+type CalendarDao struct {
+	db *gorm.DB
+}
+
+func NewCalendarDao(db *gorm.DB) *CalendarDao {
+	return &CalendarDao{
+		db: db,
+	}
+}
+
+func (dao *CalendarDao) TableWithContext(ctx context.Context) *gorm.DB {
+	return database.Conn(ctx, dao.db).Table(po.CalendarTable)
+}
+
+func (dao *CalendarDao) CreateCalendar(ctx context.Context, calendar *po.Calendar) (uint, error) {
+	err := dao.TableWithContext(ctx).Create(calendar).Error
+	return calendar.ID, err
+}
+
+// UpdateCalendar 更新日历的排除规则
+func (dao *CalendarDao) UpdateCalendar(ctx context.Context, calendar *po.Calendar) error {
+	return dao.TableWithContext(ctx).Where("id = ?", calendar.ID).Updates(map[string]interface{}{
+		"weekdays": calendar.Weekdays,
+		"dates":    calendar.Dates,
+	}).Error
+}
+
+func (dao *CalendarDao) DeleteCalendar(ctx context.Context, id uint) error {
+	return dao.TableWithContext(ctx).Delete(&po.Calendar{}, id).Error
+}
+
+func (dao *CalendarDao) GetCalendar(ctx context.Context, opts ...Option) (*po.Calendar, error) {
+	db := dao.TableWithContext(ctx)
+	for _, opt := range opts {
+		db = opt(db)
+	}
+	var calendar po.Calendar
+	return &calendar, db.First(&calendar).Error
+}
+
+func (dao *CalendarDao) GetCalendars(ctx context.Context, opts ...Option) ([]*po.Calendar, error) {
+	db := dao.TableWithContext(ctx)
+	for _, opt := range opts {
+		db = opt(db)
+	}
+	var calendars []*po.Calendar
+	return calendars, db.Where("deleted_at IS NULL").Scan(&calendars).Error
+}
@@ -0,0 +1,35 @@
+package calendar
+
+import "gorm.io/gorm"
+
+type Option func(*gorm.DB) *gorm.DB
+
+func WithID(id uint) Option {
+	return func(d *gorm.DB) *gorm.DB {
+		return d.Where("id = ?", id)
+	}
+}
+
+func WithIDs(ids []uint) Option {
+	return func(d *gorm.DB) *gorm.DB {
+		return d.Where("id IN ?", ids)
+	}
+}
+
+func WithApp(app string) Option {
+	return func(d *gorm.DB) *gorm.DB {
+		return d.Where("app = ?", app)
+	}
+}
+
+func WithName(name string) Option {
+	return func(d *gorm.DB) *gorm.DB {
+		return d.Where("name = ?", name)
+	}
+}
+
+func WithDesc() Option {
+	return func(d *gorm.DB) *gorm.DB {
+		return d.Order("created_at DESC")
+	}
+}
@@ -0,0 +1,40 @@
+package maintenance
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"timer/common/model/po"
+	"timer/pkg/database"
+)
+
+type MaintenanceDao struct {
+	db *gorm.DB
+}
+
+func NewMaintenanceDao(db *gorm.DB) *MaintenanceDao {
+	return &MaintenanceDao{
+		db: db,
+	}
+}
+
+func (dao *MaintenanceDao) TableWithContext(ctx context.Context) *gorm.DB {
+	return database.Conn(ctx, dao.db).Table(po.MaintenanceWindowTable)
+}
+
+func (dao *MaintenanceDao) CreateWindow(ctx context.Context, window *po.MaintenanceWindow) (uint, error) {
+	err := dao.TableWithContext(ctx).Create(window).Error
+	return window.ID, err
+}
+
+func (dao *MaintenanceDao) DeleteWindow(ctx context.Context, id uint) error {
+	return dao.TableWithContext(ctx).Delete(&po.MaintenanceWindow{}, id).Error
+}
+
+func (dao *MaintenanceDao) GetWindows(ctx context.Context, opts ...Option) ([]*po.MaintenanceWindow, error) {
+	db := dao.TableWithContext(ctx)
+	for _, opt := range opts {
+		db = opt(db)
+	}
+	var windows []*po.MaintenanceWindow
+	return windows, db.Where("deleted_at IS NULL").Scan(&windows).Error
+}
@@ -0,0 +1,21 @@
+package maintenance
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+type Option func(*gorm.DB) *gorm.DB
+
+// WithEndAfter 只查询结束时间晚于 t 的窗口，即尚未结束的窗口
+func WithEndAfter(t time.Time) Option {
+	return func(d *gorm.DB) *gorm.DB {
+		return d.Where("end_at > ?", t)
+	}
+}
+
+func WithStartAsc() Option {
+	return func(d *gorm.DB) *gorm.DB {
+		return d.Order("start_at ASC")
+	}
+}
@@ -0,0 +1,15 @@
+//go:build postgres
+
+package migrator
+
+import (
+	"testing"
+	"timer/pkg/testenv"
+)
+
+// Postgres 上执行与 SQLite 相同的用例，需要设置 TIMER_TEST_POSTGRES_DSN
+// go test -p 1 -tags postgres ./dao/...
+
+func TestPostgresDoWithFence(t *testing.T) {
+	testDoWithFence(t, NewStateDao(testenv.NewPostgresDB(t)))
+}
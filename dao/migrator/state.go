@@ -0,0 +1,69 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+	"timer/common/model/po"
+	"timer/pkg/database"
+)
+
+// ErrStaleFencingToken 写入时携带的 leader fencing token 小于已写入的值，说明已经有新的 leader
+var ErrStaleFencingToken = errors.New("stale migrator fencing token")
+
+// StateDao 迁移器状态的存储，迁移水位与 leader 任期保存在数据库中，不依赖 redis 的持久化
+type StateDao struct {
+	db *gorm.DB
+}
+
+func NewStateDao(db *gorm.DB) *StateDao {
+	return &StateDao{
+		db: db,
+	}
+}
+
+func (dao *StateDao) TableWithContext(ctx context.Context) *gorm.DB {
+	return database.Conn(ctx, dao.db).Table(po.MigratorStateTable).Where("id = ?", po.MigratorStateID)
+}
+
+// GetWatermark 已经完成迁移的截止时间，没有迁移过时返回零值
+func (dao *StateDao) GetWatermark(ctx context.Context) (time.Time, error) {
+	var state po.MigratorState
+	if err := dao.TableWithContext(ctx).Take(&state).Error; err != nil {
+		return time.Time{}, err
+	}
+	if state.Watermark == nil {
+		return time.Time{}, nil
+	}
+	return *state.Watermark, nil
+}
+
+// DoWithFence 在事务中先校验并写入 fencing token，再执行 do，do 中的写入需要使用传入的 ctx 以加入该事务
+// 写入 fencing token 会锁住状态行直到事务结束，新旧 leader 的写入串行执行，旧 leader 之后的事务返回 ErrStaleFencingToken
+// 同时写入 updated_at，MySQL 下同一个 token 重复写入时影响的行数也不会为 0
+// fencingToken 为 0 时不做校验
+func (dao *StateDao) DoWithFence(ctx context.Context, fencingToken int64, do func(ctx context.Context) error) error {
+	if fencingToken <= 0 {
+		return do(ctx)
+	}
+
+	return dao.db.Transaction(func(tx *gorm.DB) error {
+		ctx := database.WithTx(ctx, tx)
+		res := dao.TableWithContext(ctx).Where("fencing_token <= ?", fencingToken).
+			Updates(map[string]interface{}{"fencing_token": fencingToken, "updated_at": time.Now()})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrStaleFencingToken
+		}
+		return do(ctx)
+	})
+}
+
+// AdvanceWatermark 推进迁移水位，只会向后推进，需要在 DoWithFence 中调用
+func (dao *StateDao) AdvanceWatermark(ctx context.Context, through time.Time) error {
+	return dao.TableWithContext(ctx).Where("(watermark IS NULL OR watermark < ?)", through).
+		Update("watermark", through).Error
+}
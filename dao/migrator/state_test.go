@@ -0,0 +1,64 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"timer/common/conf"
+	"timer/pkg/database"
+	"timer/pkg/schema"
+)
+
+func TestDoWithFence(t *testing.T) {
+	ctx := context.Background()
+	config := &conf.DatabaseConfig{Driver: "sqlite", DSN: t.TempDir() + "/timer.db"}
+	db := database.GetClient(config)
+	if _, err := schema.NewMigrator(db, config).Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	testDoWithFence(t, NewStateDao(db))
+}
+
+// testDoWithFence 与数据库驱动无关，postgres_test.go 中对 Postgres 执行同样的用例
+func testDoWithFence(t *testing.T, dao *StateDao) {
+	ctx := context.Background()
+
+	if watermark, err := dao.GetWatermark(ctx); err != nil || !watermark.IsZero() {
+		t.Fatalf("initial watermark: %v, err: %v", watermark, err)
+	}
+
+	through := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	advance := func(ctx context.Context) error { return dao.AdvanceWatermark(ctx, through) }
+	if err := dao.DoWithFence(ctx, 5, advance); err != nil {
+		t.Fatal(err)
+	}
+	// 同一个任期重复写入
+	if err := dao.DoWithFence(ctx, 5, advance); err != nil {
+		t.Fatalf("same term: %v", err)
+	}
+
+	// 已经被取代的 leader 不能写入，do 不会执行
+	called := false
+	err := dao.DoWithFence(ctx, 4, func(ctx context.Context) error {
+		called = true
+		return dao.AdvanceWatermark(ctx, through.Add(time.Hour))
+	})
+	if !errors.Is(err, ErrStaleFencingToken) || called {
+		t.Fatalf("stale term: err %v, called %t", err, called)
+	}
+
+	// 水位只会向后推进
+	if err := dao.DoWithFence(ctx, 6, func(ctx context.Context) error {
+		return dao.AdvanceWatermark(ctx, through.Add(-time.Hour))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	watermark, err := dao.GetWatermark(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !watermark.Equal(through) {
+		t.Fatalf("watermark: got %v, want %v", watermark, through)
+	}
+}
@@ -3,60 +3,171 @@ package task
 import (
 	"context"
 	"fmt"
+	"sort"
 	"time"
 	"timer/common/conf"
-	"timer/common/consts"
 	"timer/common/model/po"
 	"timer/common/utils"
+	"timer/dao/bucket"
 	"timer/pkg/redis"
 )
 
 type TaskCache struct {
-	rdb  cacheClient
-	conf *conf.SchedulerAppConfig
+	rdb          cacheClient
+	buckets      bucketGetter
+	conf         *conf.SchedulerAppConfig
+	migratorConf *conf.MigratorAppConfig
 }
 
-func NewTaskCache(rdb *redis.Client, conf *conf.SchedulerAppConfig) *TaskCache {
+func NewTaskCache(rdb *redis.Client, buckets *bucket.BucketDao, conf *conf.SchedulerAppConfig, migratorConf *conf.MigratorAppConfig) *TaskCache {
 	return &TaskCache{
-		rdb:  rdb,
-		conf: conf,
+		rdb:          rdb,
+		buckets:      buckets,
+		conf:         conf,
+		migratorConf: migratorConf,
 	}
 }
 
+// ChunkError 分批写入时一个批次的失败信息
+type ChunkError struct {
+	Tasks []*po.Task
+	Err   error
+}
+
+// BatchWriteError 分批写入时部分批次失败，调用方可以只重试失败批次中的 task
+type BatchWriteError struct {
+	Total  int
+	Chunks []*ChunkError
+}
+
+func (e *BatchWriteError) Error() string {
+	return fmt.Sprintf("batch write tasks to cache failed, failed chunks: %d, failed tasks: %d/%d, first err: %v",
+		len(e.Chunks), len(e.FailedTasks()), e.Total, e.Chunks[0].Err)
+}
+
+// FailedTasks 全部失败批次中的 task
+func (e *BatchWriteError) FailedTasks() []*po.Task {
+	var tasks []*po.Task
+	for _, chunk := range e.Chunks {
+		tasks = append(tasks, chunk.Tasks...)
+	}
+	return tasks
+}
+
+// BatchCreateTasks 将 task 按分桶 key 排序后分批，每批以 pipeline 写入
+// 同一批次内每个分桶 key 只发送一次 ZADD 和一次 EXPIRE，部分批次失败时返回 *BatchWriteError
 func (tc *TaskCache) BatchCreateTasks(ctx context.Context, tasks []*po.Task) error {
 	if len(tasks) == 0 {
 		return nil
 	}
 
-	commands := make([]*redis.Command, 0, 2*len(tasks))
-	for _, task := range tasks {
-		unix := task.RunTimer.UnixMilli()
-		// 获取桶的 value，根据两个维度，时间，同一个时间再分桶
-		tableName := tc.GetTableName(task)
+	// 每个 task 按执行时间所在分钟生效的分桶数量分桶
+	layouts, err := tc.buckets.GetLayouts(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		if err := tc.batchCreateTasks(ctx, tasks, layouts); err != nil {
+			return err
+		}
 
-		// zadd key score member
-		// zadd key(minute_bucket)  score(runTime) member(timerID_runTime)
-		commands = append(commands, redis.NewZAddCommand(tableName, unix, utils.UnionTimerIDUnix(task.TimerID, unix)))
+		// 写入期间分桶数量被修改时，修改方可能已经按新的分桶重新写入过，按旧分桶写入的 task 不会再被读取
+		// 写入之后再检查一次，分桶变化时按新的分桶重新写入
+		latest, err := tc.buckets.GetLayouts(ctx)
+		if err != nil {
+			return err
+		}
+		// 变更记录只会追加，数量不变即没有变化
+		if len(latest) == len(layouts) {
+			return nil
+		}
+		layouts = latest
+	}
+}
+
+func (tc *TaskCache) batchCreateTasks(ctx context.Context, tasks []*po.Task, layouts bucket.Layouts) error {
+	// 按 key 排序，尽量让同一个 key 的 member 落在同一批次
+	sorted := make([]*po.Task, len(tasks))
+	copy(sorted, tasks)
+	tableNames := make(map[*po.Task]string, len(sorted))
+	for _, task := range sorted {
+		tableNames[task] = tc.GetSliceKey(task, layouts).String()
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return tableNames[sorted[i]] < tableNames[sorted[j]]
+	})
+
+	batchSize := tc.migratorConf.CacheBatchSize
+	if batchSize <= 0 {
+		batchSize = len(sorted)
+	}
+
+	batchErr := BatchWriteError{Total: len(tasks)}
+	for start := 0; start < len(sorted); start += batchSize {
+		end := start + batchSize
+		if end > len(sorted) {
+			end = len(sorted)
+		}
+
+		chunk := sorted[start:end]
+		if _, err := tc.rdb.Pipeline(ctx, tc.chunkCommands(chunk, tableNames)...); err != nil {
+			batchErr.Chunks = append(batchErr.Chunks, &ChunkError{Tasks: chunk, Err: err})
+		}
+	}
+
+	if len(batchErr.Chunks) > 0 {
+		return &batchErr
+	}
+	return nil
+}
+
+// chunkCommands 一个批次内每个 key 一条多 member 的 ZADD，以及一条 EXPIRE
+func (tc *TaskCache) chunkCommands(chunk []*po.Task, tableNames map[*po.Task]string) []*redis.Command {
+	var (
+		commands []*redis.Command
+		zaddArgs []interface{}
+		lastRun  time.Time
+	)
+	flush := func(tableName string) {
+		if len(zaddArgs) == 0 {
+			return
+		}
+		// zadd key(minute_bucket) score(runTime) member(timerID_runTime) [score member ...]
+		commands = append(commands, redis.NewZAddCommand(append([]interface{}{tableName}, zaddArgs...)...))
 		// zset 一天后过期（其实可以再缩短很多，例如：4 小时过期）
-		aliveSeconds := int64(time.Until(task.RunTimer.Add(24*time.Hour)) / time.Second)
+		aliveSeconds := int64(time.Until(lastRun.Add(24*time.Hour)) / time.Second)
 		commands = append(commands, redis.NewExpireCommand(tableName, aliveSeconds))
+		zaddArgs, lastRun = nil, time.Time{}
 	}
 
-	_, err := tc.rdb.Transaction(ctx, commands...)
-	return err
+	for i, task := range chunk {
+		tableName := tableNames[task]
+		if i > 0 && tableNames[chunk[i-1]] != tableName {
+			flush(tableNames[chunk[i-1]])
+		}
+
+		unix := task.RunTimer.UnixMilli()
+		zaddArgs = append(zaddArgs, unix, utils.UnionTimerIDUnix(task.TimerID, unix))
+		if task.RunTimer.After(lastRun) {
+			lastRun = task.RunTimer
+		}
+	}
+	flush(tableNames[chunk[len(chunk)-1]])
+	return commands
 }
 
-func (t *TaskCache) GetTableName(task *po.Task) string {
-	maxBucket := t.conf.BucketsNum
+// GetSliceKey task 所在的分片
+func (t *TaskCache) GetSliceKey(task *po.Task, layouts bucket.Layouts) utils.SliceKey {
+	maxBucket := layouts.BucketsNumAt(task.RunTimer, t.conf.BucketsNum)
 
-	// 二位分片，根据一分钟，一分钟里再分桶
-	// %s:%d 某一个分钟_哪个桶
-	return fmt.Sprintf("%s:%d", task.RunTimer.Format(consts.MinuteFormat), int64(task.TimerID)%int64(maxBucket))
+	// 二位分片，根据一分钟，一分钟里再分桶，与 trigger 读取的 key 一致
+	return utils.NewSliceKey(t.conf.SliceKeyApp, task.RunTimer, int(int64(task.TimerID)%int64(maxBucket)))
 }
 
-func (t *TaskCache) GetTasksByTime(ctx context.Context, table string, start, end int64) ([]*po.Task, error) {
+func (t *TaskCache) GetTasksByTime(ctx context.Context, key utils.SliceKey, start, end int64) ([]*po.Task, error) {
 	// zrangebyscore 获取 score 指定范围内内 value
-	timerIDUnixs, err := t.rdb.ZrangeByScore(ctx, table, start, end-1)
+	timerIDUnixs, err := t.rdb.ZrangeByScore(ctx, key.String(), start, end-1)
 	if err != nil {
 		return nil, err
 	}
@@ -73,10 +184,112 @@ func (t *TaskCache) GetTasksByTime(ctx context.Context, table string, start, end
 	return tasks, nil
 }
 
+// Exists 分桶 key 是否存在，延迟队列模式下 task 全部弹出后只剩处理中集合，同样视为存在
+func (t *TaskCache) Exists(ctx context.Context, key utils.SliceKey) (bool, error) {
+	return t.rdb.Exists(ctx, key.String(), key.ProcessingKey())
+}
+
+// DuePop 延迟队列一次弹出的 task
+type DuePop struct {
+	Tasks []*po.Task
+	// 分片中下一个到期的时间，包括等待 ack 的 task 的可见性超时时间；分片为空时为零值
+	Next time.Time
+	// 分片中还没有 ack 的 task 数量
+	Pending int64
+}
+
+// PopDueTasks 原子地弹出分片中执行时间不晚于 dueBefore 的 task，移入处理中集合
+// visibility 内没有 ack 的 task 会重新投递
+func (t *TaskCache) PopDueTasks(ctx context.Context, key utils.SliceKey, dueBefore time.Time, visibility time.Duration, limit int) (*DuePop, error) {
+	now := time.Now()
+	// 处理中集合与分片一样在一天后过期
+	expire := int64(24 * time.Hour / time.Millisecond)
+	pop, err := t.rdb.PopDueMembers(ctx, key.String(), key.ProcessingKey(),
+		now.UnixMilli(), dueBefore.UnixMilli(), dueBefore.Add(visibility).UnixMilli(), limit, expire)
+	if err != nil {
+		return nil, err
+	}
+
+	due := DuePop{
+		Tasks:   make([]*po.Task, 0, len(pop.Members)),
+		Pending: pop.Pending,
+	}
+	if pop.NextScore >= 0 {
+		due.Next = time.UnixMilli(pop.NextScore)
+	}
+	for _, timerIDUnix := range pop.Members {
+		timerID, unix, _ := utils.SplitTimerIDUnix(timerIDUnix)
+		due.Tasks = append(due.Tasks, &po.Task{
+			TimerID:  timerID,
+			RunTimer: time.UnixMilli(unix),
+		})
+	}
+	return &due, nil
+}
+
+// AckTask 执行结果落库之后从分片以及处理中集合移除 task，不再重新投递或者重新加载
+func (t *TaskCache) AckTask(ctx context.Context, key utils.SliceKey, task *po.Task) error {
+	member := utils.UnionTimerIDUnix(task.TimerID, task.RunTimer.UnixMilli())
+	_, err := t.rdb.Pipeline(ctx,
+		redis.NewZRemCommand(key.String(), member),
+		redis.NewZRemCommand(key.ProcessingKey(), member),
+	)
+	return err
+}
+
+// DeferTask 将 task 从分片 key 移动到 at 所在的分片，member 保持不变，执行器仍按原执行时间定位 task
+// 先从原分片以及处理中集合移除再写入，at 与原执行时间在同一个分片时只更新 score
+func (t *TaskCache) DeferTask(ctx context.Context, key utils.SliceKey, task *po.Task, at time.Time) error {
+	layouts, err := t.buckets.GetLayouts(ctx)
+	if err != nil {
+		return err
+	}
+
+	releaseKey := t.GetSliceKey(&po.Task{TimerID: task.TimerID, RunTimer: at}, layouts).String()
+	member := utils.UnionTimerIDUnix(task.TimerID, task.RunTimer.UnixMilli())
+	_, err = t.rdb.Pipeline(ctx,
+		redis.NewZRemCommand(key.String(), member),
+		redis.NewZRemCommand(key.ProcessingKey(), member),
+		redis.NewZAddCommand(releaseKey, at.UnixMilli(), member),
+		redis.NewExpireCommand(releaseKey, int64(time.Until(at.Add(24*time.Hour))/time.Second)),
+	)
+	return err
+}
+
+// RequeueTask 将 task 重新投递到 at 所在的分片，member 保持不变，用于补偿没有被触发的 task
+func (t *TaskCache) RequeueTask(ctx context.Context, task *po.Task, at time.Time) error {
+	layouts, err := t.buckets.GetLayouts(ctx)
+	if err != nil {
+		return err
+	}
+
+	key := t.GetSliceKey(&po.Task{TimerID: task.TimerID, RunTimer: at}, layouts).String()
+	member := utils.UnionTimerIDUnix(task.TimerID, task.RunTimer.UnixMilli())
+	_, err = t.rdb.Pipeline(ctx,
+		redis.NewZAddCommand(key, at.UnixMilli(), member),
+		redis.NewExpireCommand(key, int64(time.Until(at.Add(24*time.Hour))/time.Second)),
+	)
+	return err
+}
+
+var _ bucketGetter = &bucket.BucketDao{}
+
+type bucketGetter interface {
+	GetLayouts(ctx context.Context) (bucket.Layouts, error)
+}
+
 var _ cacheClient = &redis.Client{}
 
 type cacheClient interface {
-	Transaction(ctx context.Context, commands ...*redis.Command) ([]interface{}, error)
+	Pipeline(ctx context.Context, commands ...*redis.Command) ([]interface{}, error)
 	ZrangeByScore(ctx context.Context, table string, score1, score2 int64) ([]string, error)
 	Expire(ctx context.Context, key string, expireSeconds int64) error
+	Exists(ctx context.Context, keys ...string) (bool, error)
+	Scan(ctx context.Context, pattern string, count int) ([]string, error)
+	ZRangeWithScores(ctx context.Context, table string) (map[string]int64, error)
+	PTTL(ctx context.Context, key string) (int64, error)
+	Del(ctx context.Context, key string) error
+	PopDueMembers(ctx context.Context, queue, processing string, now, dueBefore, visibleAt int64, limit int, expireMilliSeconds int64) (*redis.DuePop, error)
+	ListFencingTokens(ctx context.Context, pattern string) (map[string]int64, error)
+	SeedFencingToken(ctx context.Context, key string, token int64) error
 }
@@ -0,0 +1,100 @@
+package task
+
+import (
+	"context"
+	"testing"
+	"time"
+	"timer/common/conf"
+	"timer/common/model/po"
+	"timer/common/utils"
+	"timer/dao/bucket"
+	"timer/pkg/testenv"
+)
+
+// changingLayouts 第一次读取返回旧的分桶，之后返回修改后的分桶，模拟写入期间分桶数量被修改
+type changingLayouts struct {
+	reads   int
+	changed bucket.Layouts
+}
+
+func (c *changingLayouts) GetLayouts(ctx context.Context) (bucket.Layouts, error) {
+	c.reads++
+	if c.reads == 1 {
+		return nil, nil
+	}
+	return c.changed, nil
+}
+
+func TestBatchCreateTasksRewritesOnLayoutChange(t *testing.T) {
+	ctx := context.Background()
+	server, rdb := testenv.NewRedis(t)
+	minute := time.Now().Truncate(time.Minute).Add(3 * time.Minute)
+	layouts := &changingLayouts{changed: bucket.Layouts{{EffectiveFrom: minute, BucketsNum: 4}}}
+	tc := &TaskCache{
+		rdb:          rdb,
+		buckets:      layouts,
+		conf:         &conf.SchedulerAppConfig{BucketsNum: 2, SliceKeyApp: "timer"},
+		migratorConf: &conf.MigratorAppConfig{CacheBatchSize: 100},
+	}
+
+	task := &po.Task{TimerID: 3, RunTimer: minute.Add(time.Second)}
+	if err := tc.BatchCreateTasks(ctx, []*po.Task{task}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 旧分桶：3 % 2 = 1，新分桶：3 % 4 = 3，新分桶中必须存在该 task
+	member := utils.UnionTimerIDUnix(task.TimerID, task.RunTimer.UnixMilli())
+	for _, bucketID := range []int{1, 3} {
+		key := utils.NewSliceKey("timer", minute, bucketID).String()
+		if _, err := server.ZScore(key, member); err != nil {
+			t.Errorf("member not found in %s: %v", key, err)
+		}
+	}
+	if layouts.reads != 3 {
+		t.Errorf("read layouts %d times, want 3", layouts.reads)
+	}
+}
+
+func TestDeferTask(t *testing.T) {
+	ctx := context.Background()
+	server, rdb := testenv.NewRedis(t)
+	tc := &TaskCache{
+		rdb:     rdb,
+		buckets: &changingLayouts{},
+		conf:    &conf.SchedulerAppConfig{BucketsNum: 1, SliceKeyApp: "timer"},
+	}
+
+	minute := time.Now().Truncate(time.Minute).Add(time.Minute)
+	task := &po.Task{TimerID: 1, RunTimer: minute.Add(time.Second)}
+	member := utils.UnionTimerIDUnix(task.TimerID, task.RunTimer.UnixMilli())
+	key := utils.NewSliceKey("timer", minute, 0)
+	// 延迟队列模式下 task 已经被弹出到处理中集合
+	if _, err := server.ZAdd(key.ProcessingKey(), float64(task.RunTimer.UnixMilli()), member); err != nil {
+		t.Fatal(err)
+	}
+
+	// 释放时间在同一个分片内，member 保留在分片中，score 更新为释放时间
+	releaseAt := minute.Add(30 * time.Second)
+	if err := tc.DeferTask(ctx, key, task, releaseAt); err != nil {
+		t.Fatal(err)
+	}
+	if score, err := server.ZScore(key.String(), member); err != nil || int64(score) != releaseAt.UnixMilli() {
+		t.Errorf("deferred member score %v, err: %v, want %d", score, err, releaseAt.UnixMilli())
+	}
+	if server.Exists(key.ProcessingKey()) {
+		t.Errorf("deferred member is still processing")
+	}
+
+	// 释放时间在下一个分片，member 从原分片移除
+	releaseAt = minute.Add(90 * time.Second)
+	if err := tc.DeferTask(ctx, key, task, releaseAt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.ZScore(key.String(), member); err == nil {
+		t.Errorf("deferred member is still in %s", key)
+	}
+	next := utils.NewSliceKey("timer", minute.Add(time.Minute), 0).String()
+	if score, err := server.ZScore(next, member); err != nil || int64(score) != releaseAt.UnixMilli() {
+		t.Errorf("deferred member score %v in %s, err: %v, want %d", score, next, err, releaseAt.UnixMilli())
+	}
+}
@@ -0,0 +1,118 @@
+package task
+
+import (
+	"context"
+	"strings"
+	"timer/common/utils"
+	"timer/pkg/redis"
+)
+
+// legacySliceKeyPatterns 旧格式分片 key 的 pattern：{<minute>_<bucket>}、<minute>_<bucket>、<minute>:<bucket>
+// 以及延迟队列的处理中集合 {<minute>_<bucket>}_processing
+var legacySliceKeyPatterns = []string{
+	"{????-??-?? ??:??_*}",
+	"{????-??-?? ??:??_*}" + legacyProcessingSuffix,
+	"????-??-?? ??:??_*",
+	"????-??-?? ??:??:*",
+}
+
+const (
+	// legacyProcessingSuffix 旧格式的处理中集合为分片 key 加上该后缀
+	legacyProcessingSuffix = "_processing"
+	// legacyLockKeyPattern 旧格式的分片锁为 time_bucket_lock_{<minute>_<bucket>}
+	legacyLockKeyPattern = utils.TimeBucketLockKeyPrefix + "{????-??-?? ??:??_*}"
+)
+
+// MigrateLegacyKeys 将旧格式的分片 key 迁移到当前格式，返回迁移的 key 数量，升级后执行一次即可
+// 分片 zset 与延迟队列的处理中集合：旧 key 的 member 合并写入新 key 并保留过期时间，之后删除旧 key
+// 分片锁的 fencing token 计数器：用旧计数器初始化新计数器，新锁发放的 token 不会小于已经写入 task 的 token
+// 中途失败可以重复执行
+func (t *TaskCache) MigrateLegacyKeys(ctx context.Context) (int, error) {
+	var migrated int
+	for _, pattern := range legacySliceKeyPatterns {
+		legacyKeys, err := t.rdb.Scan(ctx, pattern, 1000)
+		if err != nil {
+			return migrated, err
+		}
+
+		for _, legacyKey := range legacyKeys {
+			// pattern 可能匹配到其他 key，无法解析的跳过
+			target, ok := t.parseLegacyKey(legacyKey)
+			if !ok {
+				continue
+			}
+			if err := t.migrateKey(ctx, legacyKey, target); err != nil {
+				return migrated, err
+			}
+			migrated++
+		}
+	}
+
+	seeded, err := t.migrateFencingTokens(ctx)
+	return migrated + seeded, err
+}
+
+// parseLegacyKey 旧格式的分片 key 或者处理中集合对应的新 key
+func (t *TaskCache) parseLegacyKey(legacyKey string) (string, bool) {
+	trimmed := strings.TrimSuffix(legacyKey, legacyProcessingSuffix)
+	key, err := utils.ParseLegacySliceKey(trimmed)
+	if err != nil {
+		return "", false
+	}
+
+	key.App, key.Version = t.conf.SliceKeyApp, utils.SliceKeyVersion
+	if trimmed != legacyKey {
+		return key.ProcessingKey(), true
+	}
+	return key.String(), true
+}
+
+// migrateFencingTokens 用旧格式分片锁的 fencing token 计数器初始化新格式分片锁的计数器
+func (t *TaskCache) migrateFencingTokens(ctx context.Context) (int, error) {
+	tokens, err := t.rdb.ListFencingTokens(ctx, legacyLockKeyPattern)
+	if err != nil {
+		return 0, err
+	}
+
+	var seeded int
+	for lockKey, token := range tokens {
+		key, err := utils.ParseLegacySliceKey(strings.TrimPrefix(lockKey, utils.TimeBucketLockKeyPrefix))
+		if err != nil {
+			continue
+		}
+		key.App, key.Version = t.conf.SliceKeyApp, utils.SliceKeyVersion
+		if err := t.rdb.SeedFencingToken(ctx, key.LockKey(), token); err != nil {
+			return seeded, err
+		}
+		seeded++
+	}
+	return seeded, nil
+}
+
+func (t *TaskCache) migrateKey(ctx context.Context, legacyKey, target string) error {
+	members, err := t.rdb.ZRangeWithScores(ctx, legacyKey)
+	if err != nil {
+		return err
+	}
+	ttl, err := t.rdb.PTTL(ctx, legacyKey)
+	if err != nil {
+		return err
+	}
+
+	if len(members) > 0 {
+		args := make([]interface{}, 0, 1+2*len(members))
+		args = append(args, target)
+		for member, score := range members {
+			args = append(args, score, member)
+		}
+		commands := []*redis.Command{redis.NewZAddCommand(args...)}
+		// 新 key 沿用旧 key 的过期时间，向上取整到秒
+		if ttl > 0 {
+			commands = append(commands, redis.NewExpireCommand(target, (ttl+999)/1000))
+		}
+		if _, err := t.rdb.Pipeline(ctx, commands...); err != nil {
+			return err
+		}
+	}
+	return t.rdb.Del(ctx, legacyKey)
+}
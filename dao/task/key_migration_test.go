@@ -0,0 +1,68 @@
+package task
+
+import (
+	"context"
+	"testing"
+	"time"
+	"timer/common/conf"
+	"timer/common/consts"
+	"timer/common/utils"
+	"timer/dao/bucket"
+	"timer/pkg/testenv"
+)
+
+func TestMigrateLegacyKeys(t *testing.T) {
+	ctx := context.Background()
+	server, rdb := testenv.NewRedis(t)
+	schedulerConf := &conf.SchedulerAppConfig{BucketsNum: 2, SliceKeyApp: "timer"}
+	tc := NewTaskCache(rdb, bucket.NewBucketDao(rdb, schedulerConf), schedulerConf, &conf.MigratorAppConfig{})
+
+	minute := time.Now().Truncate(time.Minute)
+	tag := "{" + minute.Format(consts.MinuteFormat) + "_1}"
+	if _, err := server.ZAdd(tag, 1, "1_1000"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.ZAdd(tag+"_processing", 2, "3_2000"); err != nil {
+		t.Fatal(err)
+	}
+	// 旧格式的分片锁已经发放过 3 个 fencing token
+	legacyLock := rdb.GetDistributionLock(utils.TimeBucketLockKeyPrefix + tag)
+	for i := 0; i < 3; i++ {
+		if err := legacyLock.Lock(ctx, 70); err != nil {
+			t.Fatal(err)
+		}
+		if err := legacyLock.Unlock(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrated, err := tc.MigrateLegacyKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated != 3 {
+		t.Errorf("migrated %d keys, want 3", migrated)
+	}
+
+	key := utils.NewSliceKey("timer", minute, 1)
+	if _, err := server.ZScore(key.String(), "1_1000"); err != nil {
+		t.Errorf("slice member not migrated: %v", err)
+	}
+	if _, err := server.ZScore(key.ProcessingKey(), "3_2000"); err != nil {
+		t.Errorf("processing member not migrated: %v", err)
+	}
+	for _, legacyKey := range []string{tag, tag + "_processing"} {
+		if server.Exists(legacyKey) {
+			t.Errorf("legacy key %s not deleted", legacyKey)
+		}
+	}
+
+	// 新格式的分片锁发放的 token 接着旧锁继续递增
+	locker := rdb.GetDistributionLock(key.LockKey())
+	if err := locker.Lock(ctx, 70); err != nil {
+		t.Fatal(err)
+	}
+	if got := locker.FencingToken(); got != 4 {
+		t.Errorf("fencing token of new lock: got %d, want 4", got)
+	}
+}
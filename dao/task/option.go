@@ -2,68 +2,127 @@ package task
 
 import (
 	"time"
-
-	"gorm.io/gorm"
 )
 
-type Option func(*gorm.DB) *gorm.DB
+// Query 任务的查询条件，与具体的存储实现无关，由各存储实现自行转换
+type Query struct {
+	TaskID    *uint
+	App       *string
+	TimerID   *uint
+	RunTimer  *time.Time
+	StartTime *time.Time
+	EndTime   *time.Time
+	Statuses  []int32
+	// 由这些前驱 task 触发的 task
+	ParentTaskIDs []uint
+	// 只查询由前驱 task 触发的 task
+	SuccessorOnly bool
+	// 只查询 id 大于 AfterID 的记录，用于按 id 翻页
+	AfterID *uint
+	// 2 按 id 升序，1 按创建时间升序，-1 按执行时间降序，0 不排序
+	Order  int
+	Offset int
+	Limit  int
+}
+
+type Option func(*Query)
+
+// NewQuery 根据 option 生成查询条件
+func NewQuery(opts ...Option) *Query {
+	var q Query
+	for _, opt := range opts {
+		opt(&q)
+	}
+	return &q
+}
 
 func WithTaskID(id uint) Option {
-	return func(d *gorm.DB) *gorm.DB {
-		return d.Where("id = ?", id)
+	return func(q *Query) {
+		q.TaskID = &id
+	}
+}
+
+func WithApp(app string) Option {
+	return func(q *Query) {
+		q.App = &app
 	}
 }
 
 func WithTimerID(timerID uint) Option {
-	return func(d *gorm.DB) *gorm.DB {
-		return d.Where("timer_id = ?", timerID)
+	return func(q *Query) {
+		q.TimerID = &timerID
 	}
 }
 
 func WithRunTimer(runTimer time.Time) Option {
-	return func(d *gorm.DB) *gorm.DB {
-		return d.Where("run_timer = ?", runTimer)
+	return func(q *Query) {
+		q.RunTimer = &runTimer
 	}
 }
 
 func WithStartTime(start time.Time) Option {
-	return func(d *gorm.DB) *gorm.DB {
-		return d.Where("run_timer >= ?", start)
+	return func(q *Query) {
+		q.StartTime = &start
 	}
 }
 
 func WithEndTime(end time.Time) Option {
-	return func(d *gorm.DB) *gorm.DB {
-		return d.Where("run_timer < ?", end)
+	return func(q *Query) {
+		q.EndTime = &end
 	}
 }
 
 func WithStatus(status int32) Option {
-	return func(d *gorm.DB) *gorm.DB {
-		return d.Where("status = ?", status)
+	return func(q *Query) {
+		q.Statuses = []int32{status}
 	}
 }
 
 func WithStatuses(statuses []int32) Option {
-	return func(d *gorm.DB) *gorm.DB {
-		return d.Where("status IN ?", statuses)
+	return func(q *Query) {
+		q.Statuses = statuses
+	}
+}
+
+func WithParentTaskIDs(ids []uint) Option {
+	return func(q *Query) {
+		q.ParentTaskIDs = ids
+	}
+}
+
+// WithSuccessorOnly 只查询由前驱 task 触发的 task
+func WithSuccessorOnly() Option {
+	return func(q *Query) {
+		q.SuccessorOnly = true
+	}
+}
+
+func WithAfterID(id uint) Option {
+	return func(q *Query) {
+		q.AfterID = &id
+	}
+}
+
+func WithIDAsc() Option {
+	return func(q *Query) {
+		q.Order = 2
 	}
 }
 
 func WithAsc() Option {
-	return func(d *gorm.DB) *gorm.DB {
-		return d.Order("created_at ASC")
+	return func(q *Query) {
+		q.Order = 1
 	}
 }
 
 func WithDesc() Option {
-	return func(d *gorm.DB) *gorm.DB {
-		return d.Order("run_timer DESC")
+	return func(q *Query) {
+		q.Order = -1
 	}
 }
 
 func WithPageLimit(offset, limit int) Option {
-	return func(d *gorm.DB) *gorm.DB {
-		return d.Offset(offset).Limit(limit)
+	return func(q *Query) {
+		q.Offset, q.Limit = offset, limit
 	}
 }
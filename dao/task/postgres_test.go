@@ -0,0 +1,19 @@
+//go:build postgres
+
+package task
+
+import (
+	"testing"
+	"timer/pkg/testenv"
+)
+
+// Postgres 上执行与 SQLite 相同的用例，需要设置 TIMER_TEST_POSTGRES_DSN
+// go test -p 1 -tags postgres ./dao/...
+
+func TestPostgresUpdateTaskFencingToken(t *testing.T) {
+	testUpdateTaskFencingToken(t, NewTaskDao(testenv.NewPostgresDB(t)))
+}
+
+func TestPostgresBatchCreateTasksIgnoresExisting(t *testing.T) {
+	testBatchCreateTasksIgnoresExisting(t, NewTaskDao(testenv.NewPostgresDB(t)))
+}
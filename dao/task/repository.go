@@ -0,0 +1,20 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"timer/common/model/po"
+)
+
+// ErrStaleFencingToken 更新 task 时携带的 fencing token 小于已写入的值，说明写入方持有的锁已经过期
+var ErrStaleFencingToken = errors.New("stale fencing token")
+
+// Repository 任务的存储接口，service 依赖该接口而不是具体的存储实现
+type Repository interface {
+	BatchCreateTasks(ctx context.Context, tasks []*po.Task) error
+	GetTask(ctx context.Context, opts ...Option) (*po.Task, error)
+	GetTasks(ctx context.Context, opts ...Option) ([]*po.Task, error)
+	UpdateTask(ctx context.Context, task *po.Task) error
+	// DeleteTasks 物理删除，用于流水清理
+	DeleteTasks(ctx context.Context, ids []uint) error
+}
@@ -3,9 +3,15 @@ package task
 import (
 	"context"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"timer/common/model/po"
+	"timer/pkg/database"
 )
 
+// 批量插入 task 时每条 insert 语句的行数，避免超出数据库单条语句的占位符数量限制
+const createTasksBatchSize = 500
+
+// TaskDao 基于 gorm 的 Repository 实现，支持 MySQL、PostgreSQL、SQLite
 type TaskDao struct {
 	db *gorm.DB
 }
@@ -16,39 +22,121 @@ func NewTaskDao(db *gorm.DB) *TaskDao {
 	}
 }
 
+// NewRepository 提供给依赖注入使用
+func NewRepository(db *gorm.DB) Repository {
+	return NewTaskDao(db)
+}
+
 func (dao *TaskDao) TableWithContext(ctx context.Context) *gorm.DB {
-	return dao.db.WithContext(ctx).Table(po.TaskTable)
+	return database.Conn(ctx, dao.db).Table(po.TaskTable)
 }
 
 func (dao *TaskDao) Table() *gorm.DB {
 	return dao.db.Table(po.TaskTable)
 }
 
-func (dao *TaskDao) BatchCreateTasks(task []*po.Task) error {
-	return dao.Table().Create(&task).Error
+func (dao *TaskDao) BatchCreateTasks(ctx context.Context, tasks []*po.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	// 迁移器与激活定时器生成的 task 可能重叠，已存在的 task 直接忽略而不是报唯一键冲突
+	return dao.TableWithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		CreateInBatches(&tasks, createTasksBatchSize).Error
 }
 
 func (dao *TaskDao) GetTask(ctx context.Context, opts ...Option) (*po.Task, error) {
-	db := dao.TableWithContext(ctx)
-	for _, opt := range opts {
-		db = opt(db)
-	}
-
+	db := dao.withQuery(dao.TableWithContext(ctx), NewQuery(opts...))
 	var task po.Task
 	return &task, db.First(&task).Error
 }
 
 // GetTasks 根据 option 获取 tasks
 func (dao *TaskDao) GetTasks(ctx context.Context, opts ...Option) ([]*po.Task, error) {
-	db := dao.TableWithContext(ctx)
-	for _, opt := range opts {
-		db = opt(db)
-	}
-
+	db := dao.withQuery(dao.TableWithContext(ctx), NewQuery(opts...))
 	var tasks []*po.Task
 	return tasks, db.Scan(&tasks).Error
 }
 
+// UpdateTask 更新 task，携带 fencing token 时只有 token 不小于已写入值才能更新，否则返回 ErrStaleFencingToken
 func (dao *TaskDao) UpdateTask(ctx context.Context, task *po.Task) error {
-	return dao.TableWithContext(ctx).Updates(task).Error
+	if task.FencingToken <= 0 {
+		return dao.TableWithContext(ctx).Updates(task).Error
+	}
+
+	res := dao.TableWithContext(ctx).Where("fencing_token <= ?", task.FencingToken).Updates(task)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		return nil
+	}
+
+	// MySQL 的 RowsAffected 是实际修改的行数，写入的值与已有的值完全相同时为 0，需要重新确认 token 是否过期
+	var cnt int64
+	if err := dao.TableWithContext(ctx).Where("id = ? AND fencing_token <= ?", task.ID, task.FencingToken).Count(&cnt).Error; err != nil {
+		return err
+	}
+	if cnt == 0 {
+		return ErrStaleFencingToken
+	}
+	return nil
 }
+
+func (dao *TaskDao) DeleteTasks(ctx context.Context, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return dao.TableWithContext(ctx).Unscoped().Where("id IN ?", ids).Delete(&po.Task{}).Error
+}
+
+// withQuery 将与存储无关的查询条件转换为 gorm 的查询条件
+func (dao *TaskDao) withQuery(db *gorm.DB, q *Query) *gorm.DB {
+	if q.TaskID != nil {
+		db = db.Where("id = ?", *q.TaskID)
+	}
+	if q.App != nil {
+		db = db.Where("app = ?", *q.App)
+	}
+	if q.TimerID != nil {
+		db = db.Where("timer_id = ?", *q.TimerID)
+	}
+	if q.RunTimer != nil {
+		db = db.Where("run_timer = ?", *q.RunTimer)
+	}
+	if q.StartTime != nil {
+		db = db.Where("run_timer >= ?", *q.StartTime)
+	}
+	if q.EndTime != nil {
+		db = db.Where("run_timer < ?", *q.EndTime)
+	}
+	if q.ParentTaskIDs != nil {
+		db = db.Where("parent_task_id IN ?", q.ParentTaskIDs)
+	}
+	if q.SuccessorOnly {
+		db = db.Where("parent_task_id <> 0")
+	}
+	if q.AfterID != nil {
+		db = db.Where("id > ?", *q.AfterID)
+	}
+	switch len(q.Statuses) {
+	case 0:
+	case 1:
+		db = db.Where("status = ?", q.Statuses[0])
+	default:
+		db = db.Where("status IN ?", q.Statuses)
+	}
+	switch {
+	case q.Order > 1:
+		db = db.Order("id ASC")
+	case q.Order > 0:
+		db = db.Order("created_at ASC")
+	case q.Order < 0:
+		db = db.Order("run_timer DESC")
+	}
+	if q.Limit > 0 {
+		db = db.Offset(q.Offset).Limit(q.Limit)
+	}
+	return db
+}
+
+var _ Repository = &TaskDao{}
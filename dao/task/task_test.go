@@ -0,0 +1,101 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"timer/common/conf"
+	"timer/common/consts"
+	"timer/common/model/po"
+	"timer/pkg/database"
+	"timer/pkg/schema"
+)
+
+func newTestTaskDao(t *testing.T) *TaskDao {
+	t.Helper()
+	config := &conf.DatabaseConfig{Driver: "sqlite", DSN: t.TempDir() + "/timer.db"}
+	db := database.GetClient(config)
+	if _, err := schema.NewMigrator(db, config).Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return NewTaskDao(db)
+}
+
+func TestUpdateTaskFencingToken(t *testing.T) {
+	testUpdateTaskFencingToken(t, newTestTaskDao(t))
+}
+
+func TestBatchCreateTasksIgnoresExisting(t *testing.T) {
+	testBatchCreateTasksIgnoresExisting(t, newTestTaskDao(t))
+}
+
+// 以下用例与数据库驱动无关，postgres_test.go 中对 Postgres 执行同样的用例
+
+func testUpdateTaskFencingToken(t *testing.T, dao *TaskDao) {
+	ctx := context.Background()
+
+	runTimer := time.UnixMilli(time.Now().UnixMilli())
+	if err := dao.BatchCreateTasks(ctx, []*po.Task{{App: "app", TimerID: 1, RunTimer: runTimer, Status: consts.NotRunned.ToInt()}}); err != nil {
+		t.Fatal(err)
+	}
+	task, err := dao.GetTask(ctx, WithTimerID(1), WithRunTimer(runTimer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task.Status, task.Output, task.FencingToken = consts.Successed.ToInt(), "ok", 5
+	if err := dao.UpdateTask(ctx, task); err != nil {
+		t.Fatalf("first update: %v", err)
+	}
+	// 与已有的值完全相同的重复写入不是过期的 token
+	if err := dao.UpdateTask(ctx, task); err != nil {
+		t.Fatalf("idempotent update: %v", err)
+	}
+
+	stale := *task
+	stale.FencingToken, stale.Output = 4, "stale"
+	if err := dao.UpdateTask(ctx, &stale); !errors.Is(err, ErrStaleFencingToken) {
+		t.Fatalf("stale update: got %v, want ErrStaleFencingToken", err)
+	}
+
+	got, err := dao.GetTask(ctx, WithTaskID(task.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Output != "ok" || got.FencingToken != 5 {
+		t.Fatalf("task overwritten by stale holder: output %q, token %d", got.Output, got.FencingToken)
+	}
+}
+
+func testBatchCreateTasksIgnoresExisting(t *testing.T, dao *TaskDao) {
+	ctx := context.Background()
+	runTimer := time.UnixMilli(time.Now().UnixMilli())
+	first := []*po.Task{{App: "app", TimerID: 1, RunTimer: runTimer, Status: consts.Successed.ToInt(), Output: "ok"}}
+	if err := dao.BatchCreateTasks(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+
+	// 迁移器与激活定时器生成的 task 重叠时，已存在的 task 保持不变，新的 task 正常写入
+	overlapped := []*po.Task{
+		{App: "app", TimerID: 1, RunTimer: runTimer, Status: consts.NotRunned.ToInt()},
+		{App: "app", TimerID: 1, RunTimer: runTimer.Add(time.Second), Status: consts.NotRunned.ToInt()},
+	}
+	if err := dao.BatchCreateTasks(ctx, overlapped); err != nil {
+		t.Fatalf("create overlapped tasks: %v", err)
+	}
+
+	tasks, err := dao.GetTasks(ctx, WithTimerID(1), WithAsc())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("got %d tasks, want 2", len(tasks))
+	}
+	if tasks[0].Status != consts.Successed.ToInt() || tasks[0].Output != "ok" {
+		t.Errorf("existing task overwritten: status %d, output %q", tasks[0].Status, tasks[0].Output)
+	}
+	if !tasks[1].RunTimer.Equal(runTimer.Add(time.Second)) {
+		t.Errorf("new task run at %v, want %v", tasks[1].RunTimer, runTimer.Add(time.Second))
+	}
+}
@@ -1,56 +1,106 @@
 package timer
 
-import (
-	"fmt"
-	"gorm.io/gorm"
-)
+import "time"
 
-type Option func(*gorm.DB) *gorm.DB
+// Query 定时器的查询条件，与具体的存储实现无关，由各存储实现自行转换
+type Query struct {
+	ID        *uint
+	IDs       []uint
+	Status    *int32
+	App       *string
+	FuzzyName *string
+	// 只查询 id 大于 AfterID 的记录，用于按 id 翻页
+	AfterID *uint
+	// 只查询生成 task 的截止时间早于该时间（或者还没有生成过）的定时器
+	GeneratedBefore *time.Time
+	// 锁读，只能在事务中使用，见 Repository.DoWithTransactionAndLock
+	ForUpdate bool
+	// 2 按 id 升序，1 按创建时间升序，-1 按创建时间降序，0 不排序
+	Order  int
+	Offset int
+	Limit  int
+}
+
+type Option func(*Query)
+
+// NewQuery 根据 option 生成查询条件
+func NewQuery(opts ...Option) *Query {
+	var q Query
+	for _, opt := range opts {
+		opt(&q)
+	}
+	return &q
+}
 
 func WithID(id uint) Option {
-	return func(d *gorm.DB) *gorm.DB {
-		return d.Where("id = ?", id)
+	return func(q *Query) {
+		q.ID = &id
 	}
 }
 
 func WithIDs(ids []uint) Option {
-	return func(d *gorm.DB) *gorm.DB {
-		return d.Where("id IN ?", ids)
+	return func(q *Query) {
+		q.IDs = ids
 	}
 }
 
 func WithStatus(status int32) Option {
-	return func(d *gorm.DB) *gorm.DB {
-		return d.Where("status = ?", status)
+	return func(q *Query) {
+		q.Status = &status
+	}
+}
+
+// WithForUpdate 加锁读取，事务提交之前其他事务不能修改读到的定时器
+func WithForUpdate() Option {
+	return func(q *Query) {
+		q.ForUpdate = true
 	}
 }
 
 func WithAsc() Option {
-	return func(d *gorm.DB) *gorm.DB {
-		return d.Order("created_at ASC")
+	return func(q *Query) {
+		q.Order = 1
 	}
 }
 
 func WithDesc() Option {
-	return func(d *gorm.DB) *gorm.DB {
-		return d.Order("created_at DESC")
+	return func(q *Query) {
+		q.Order = -1
 	}
 }
 
 func WithApp(app string) Option {
-	return func(d *gorm.DB) *gorm.DB {
-		return d.Where("app = ?", app)
+	return func(q *Query) {
+		q.App = &app
 	}
 }
 
 func WithFuzzyName(name string) Option {
-	return func(d *gorm.DB) *gorm.DB {
-		return d.Where(fmt.Sprintf("name like %q", ("%" + name + "%")))
+	return func(q *Query) {
+		q.FuzzyName = &name
 	}
 }
 
 func WithPageLimit(offset, limit int) Option {
-	return func(d *gorm.DB) *gorm.DB {
-		return d.Offset(offset).Limit(limit)
+	return func(q *Query) {
+		q.Offset, q.Limit = offset, limit
+	}
+}
+
+func WithAfterID(id uint) Option {
+	return func(q *Query) {
+		q.AfterID = &id
+	}
+}
+
+func WithGeneratedBefore(t time.Time) Option {
+	return func(q *Query) {
+		q.GeneratedBefore = &t
+	}
+}
+
+func WithIDAsc() Option {
+	return func(q *Query) {
+		q.Order = 2
 	}
 }
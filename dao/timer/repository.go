@@ -0,0 +1,24 @@
+package timer
+
+import (
+	"context"
+	"time"
+	"timer/common/model/po"
+)
+
+// Repository 定时器定义的存储接口，service 依赖该接口而不是具体的存储实现
+type Repository interface {
+	CreateTimer(ctx context.Context, timer *po.Timer) (uint, error)
+	DeleteTimer(ctx context.Context, id uint) error
+	GetTimerByID(ctx context.Context, timer *po.Timer) error
+	GetTimer(ctx context.Context, opts ...Option) (*po.Timer, error)
+	GetTimers(ctx context.Context, opts ...Option) ([]*po.Timer, error)
+	CountTimers(ctx context.Context, opts ...Option) (int64, error)
+	UpdateTimerStatus(ctx context.Context, id uint, timerStatus int) error
+	// UpdateSuccessors 覆盖定时器的后继以及是否向后继传递执行结果
+	UpdateSuccessors(ctx context.Context, timer *po.Timer) error
+	// UpdateGeneratedThrough 推进定时器生成 task 的截止时间，只会向后推进
+	UpdateGeneratedThrough(ctx context.Context, ids []uint, through time.Time) error
+	// DoWithTransactionAndLock 在事务中锁住 id 对应的定时器后执行 do，do 中需要使用传入的 Repository 以加入该事务
+	DoWithTransactionAndLock(ctx context.Context, id uint, do func(context.Context, Repository, *po.Timer) error) error
+}
@@ -3,10 +3,14 @@ package timer
 import (
 	"context"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 	"timer/common/model/po"
+	"timer/pkg/database"
 	"timer/pkg/logger"
 )
 
+// TimerDao 基于 gorm 的 Repository 实现，支持 MySQL、PostgreSQL、SQLite
 type TimerDao struct {
 	db *gorm.DB
 }
@@ -17,8 +21,13 @@ func NewTimerDao(db *gorm.DB) *TimerDao {
 	}
 }
 
+// NewRepository 提供给依赖注入使用
+func NewRepository(db *gorm.DB) Repository {
+	return NewTimerDao(db)
+}
+
 func (dao *TimerDao) TableWithContext(ctx context.Context) *gorm.DB {
-	return dao.db.WithContext(ctx).Table(po.TimerTable)
+	return database.Conn(ctx, dao.db).Table(po.TimerTable)
 }
 
 func (dao *TimerDao) Table() *gorm.DB {
@@ -26,7 +35,8 @@ func (dao *TimerDao) Table() *gorm.DB {
 }
 
 func (dao *TimerDao) CreateTimer(ctx context.Context, timer *po.Timer) (uint, error) {
-	return timer.ID, dao.TableWithContext(ctx).Create(timer).Error
+	err := dao.TableWithContext(ctx).Create(timer).Error
+	return timer.ID, err
 }
 
 func (dao *TimerDao) DeleteTimer(ctx context.Context, id uint) error {
@@ -37,7 +47,7 @@ func (dao *TimerDao) GetTimerByID(ctx context.Context, timer *po.Timer) error {
 	return dao.TableWithContext(ctx).First(timer).Error
 }
 
-func (dao *TimerDao) DoWithTransactionAndLock(ctx context.Context, id uint, do func(context.Context, *TimerDao, *po.Timer) error) error {
+func (dao *TimerDao) DoWithTransactionAndLock(ctx context.Context, id uint, do func(context.Context, Repository, *po.Timer) error) error {
 	// 数据库事务
 	return dao.db.Transaction(func(tx *gorm.DB) error {
 		defer func() {
@@ -50,12 +60,13 @@ func (dao *TimerDao) DoWithTransactionAndLock(ctx context.Context, id uint, do f
 
 		var timer po.Timer
 
-		// 设置该事务为锁读
-		if err := tx.Set("gorm:query_option", "FOR UPDATE").WithContext(ctx).Table(po.TimerTable).First(&timer, id).Error; err != nil {
+		// 设置该事务为锁读，SQLite 不支持 FOR UPDATE，gorm 的 sqlite 驱动会忽略该子句（SQLite 写事务本身就是串行的）
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).WithContext(ctx).Table(po.TimerTable).First(&timer, id).Error; err != nil {
 			return err
 		}
 
-		return do(ctx, NewTimerDao(tx), &timer)
+		// 事务放入 ctx，do 中使用同一个 ctx 的其他 dao（例如写 task）也会加入该事务
+		return do(database.WithTx(ctx, tx), NewTimerDao(tx), &timer)
 	})
 }
 
@@ -63,24 +74,81 @@ func (dao *TimerDao) UpdateTimerStatus(ctx context.Context, id uint, timerStatus
 	return dao.TableWithContext(ctx).Where("id=?", id).Update("status", timerStatus).Error
 }
 
-func (dao *TimerDao) GetTimer(ctx context.Context, opts ...Option) (*po.Timer, error) {
-	db := dao.TableWithContext(ctx)
-	for _, opt := range opts {
-		db = opt(db)
+// UpdateSuccessors 覆盖定时器的后继以及是否向后继传递执行结果
+func (dao *TimerDao) UpdateSuccessors(ctx context.Context, timer *po.Timer) error {
+	return dao.TableWithContext(ctx).Where("id = ?", timer.ID).Updates(map[string]interface{}{
+		"on_success_ids": timer.OnSuccessIDs,
+		"on_failure_ids": timer.OnFailureIDs,
+		"pass_output":    timer.PassOutput,
+	}).Error
+}
+
+// UpdateGeneratedThrough 推进定时器生成 task 的截止时间，只会向后推进
+func (dao *TimerDao) UpdateGeneratedThrough(ctx context.Context, ids []uint, through time.Time) error {
+	if len(ids) == 0 {
+		return nil
 	}
+	return dao.TableWithContext(ctx).Where("id IN ?", ids).
+		Where("(generated_through IS NULL OR generated_through < ?)", through).
+		Update("generated_through", through).Error
+}
+
+func (dao *TimerDao) GetTimer(ctx context.Context, opts ...Option) (*po.Timer, error) {
+	db := dao.withQuery(dao.TableWithContext(ctx), NewQuery(opts...))
 	var timer po.Timer
 	return &timer, db.First(&timer).Error
 }
 
 func (dao *TimerDao) GetTimers(ctx context.Context, opts ...Option) ([]*po.Timer, error) {
-	db := dao.TableWithContext(ctx)
-	for _, opt := range opts {
-		db = opt(db)
-	}
+	db := dao.withQuery(dao.TableWithContext(ctx), NewQuery(opts...))
 	var timers []*po.Timer
 	return timers, db.Scan(&timers).Error
 }
 
-func (dao *TimerDao) BatchCreateRecords(ctx context.Context, tasks []*po.Task) error {
-	return dao.TableWithContext(ctx).CreateInBatches(tasks, len(tasks)).Error
+func (dao *TimerDao) CountTimers(ctx context.Context, opts ...Option) (int64, error) {
+	db := dao.withQuery(dao.TableWithContext(ctx).Where("deleted_at IS NULL"), NewQuery(opts...))
+	var cnt int64
+	return cnt, db.Count(&cnt).Error
+}
+
+// withQuery 将与存储无关的查询条件转换为 gorm 的查询条件
+func (dao *TimerDao) withQuery(db *gorm.DB, q *Query) *gorm.DB {
+	if q.ID != nil {
+		db = db.Where("id = ?", *q.ID)
+	}
+	if q.IDs != nil {
+		db = db.Where("id IN ?", q.IDs)
+	}
+	if q.Status != nil {
+		db = db.Where("status = ?", *q.Status)
+	}
+	if q.App != nil {
+		db = db.Where("app = ?", *q.App)
+	}
+	if q.FuzzyName != nil {
+		db = db.Where("name LIKE ?", "%"+*q.FuzzyName+"%")
+	}
+	if q.AfterID != nil {
+		db = db.Where("id > ?", *q.AfterID)
+	}
+	if q.GeneratedBefore != nil {
+		db = db.Where("(generated_through IS NULL OR generated_through < ?)", *q.GeneratedBefore)
+	}
+	if q.ForUpdate {
+		db = db.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	switch {
+	case q.Order == 2:
+		db = db.Order("id ASC")
+	case q.Order > 0:
+		db = db.Order("created_at ASC")
+	case q.Order < 0:
+		db = db.Order("created_at DESC")
+	}
+	if q.Limit > 0 {
+		db = db.Offset(q.Offset).Limit(q.Limit)
+	}
+	return db
 }
+
+var _ Repository = &TimerDao{}
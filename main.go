@@ -1,16 +1,52 @@
 package main
 
 import (
+	"os"
+	"os/signal"
+	"syscall"
+
 	"timer/app"
+	"timer/common/conf"
 )
 
 func main() {
+	// 单机模式下先启动内嵌存储并恢复快照，之后的迁移、各模块都使用内嵌存储
+	standalone := conf.GetDefaultStandaloneConfig().Enabled
+	if standalone {
+		app.GetStandaloneApp().Start()
+	}
+
+	// ./timer migrate up|down [n]|status 只执行 schema 迁移，./timer migrate keys 迁移旧格式的分片 key
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		code := runMigrate(os.Args[2:])
+		if standalone {
+			app.GetStandaloneApp().Stop()
+		}
+		os.Exit(code)
+	}
+
+	autoMigrate()
+
 	app.GetMigratorApp().Start()
 
+	// 调度器和健康检查都依赖成员表，先于它们启动
+	app.GetRegistryApp().Start()
+
 	app.GetSchedulerApp().Start()
 
 	app.GetWebApp().Start()
 
-	var c chan struct{}
-	<-c
+	app.GetRetentionApp().Start()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	// 主动下线，其他节点立即接管分桶
+	app.GetRegistryApp().Stop()
+
+	// 单机模式退出前写一次快照
+	if standalone {
+		app.GetStandaloneApp().Stop()
+	}
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"timer/app"
+	"timer/common/conf"
+	"timer/pkg/logger"
+)
+
+const migrateUsage = "usage: timer migrate up | down [n] | status | keys"
+
+// autoMigrate 启动时执行未执行的迁移，多个实例并发启动时由数据库锁保证只执行一次
+func autoMigrate() {
+	if conf.GetDefaultDatabaseConfig().DisableAutoMigrate {
+		return
+	}
+
+	versions, err := app.GetSchemaMigrator().Up(context.Background())
+	if err != nil {
+		panic(fmt.Errorf("schema migrate failed, err: %w", err))
+	}
+	if len(versions) > 0 {
+		logger.Infof("schema migrate success, versions: %v", versions)
+	}
+}
+
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	ctx := context.Background()
+	migrator := app.GetSchemaMigrator()
+	switch args[0] {
+	case "up":
+		versions, err := migrator.Up(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate up failed: %v\n", err)
+			return 1
+		}
+		fmt.Printf("applied versions: %v\n", versions)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				return 2
+			}
+			steps = n
+		}
+		versions, err := migrator.Down(ctx, steps)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate down failed: %v\n", err)
+			return 1
+		}
+		fmt.Printf("reverted versions: %v\n", versions)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate status failed: %v\n", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, status := range statuses {
+			state, appliedAt := "pending", ""
+			if status.Applied {
+				state, appliedAt = "applied", status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+		}
+		_ = w.Flush()
+	case "keys":
+		// 旧格式的分片 key 迁移到当前格式，升级后执行一次
+		migrated, err := app.GetTaskCache().MigrateLegacyKeys(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate slice keys failed, migrated: %d, err: %v\n", migrated, err)
+			return 1
+		}
+		fmt.Printf("migrated slice keys: %d\n", migrated)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,32 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"timer/common/conf"
+)
+
+// Store 归档存储，name 为以 / 分隔的相对路径
+type Store interface {
+	Put(ctx context.Context, name string, reader io.Reader, size int64) error
+}
+
+// NewStore 根据配置创建归档存储，不需要归档时返回 nil
+func NewStore(config *conf.RetentionConfig) (Store, error) {
+	archiveConfig := config.Archive
+	if archiveConfig == nil {
+		return nil, nil
+	}
+
+	switch archiveConfig.Type {
+	case conf.ArchiveTypeNone:
+		return nil, nil
+	case conf.ArchiveTypeLocal, "":
+		return NewLocalStore(archiveConfig.Dir), nil
+	case conf.ArchiveTypeS3:
+		return NewS3Store(archiveConfig.S3)
+	default:
+		return nil, fmt.Errorf("unsupported archive type: %s", archiveConfig.Type)
+	}
+}
@@ -0,0 +1,48 @@
+package archive
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore 归档到本地目录
+type LocalStore struct {
+	dir string
+}
+
+func NewLocalStore(dir string) *LocalStore {
+	return &LocalStore{
+		dir: dir,
+	}
+}
+
+// Put 先写临时文件再重命名，避免进程中断时留下不完整的归档文件
+func (s *LocalStore) Put(ctx context.Context, name string, reader io.Reader, _ int64) error {
+	path := filepath.Join(s.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, reader); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+var _ Store = &LocalStore{}
@@ -0,0 +1,64 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// failingReader 读到一半失败，模拟上传过程中断
+type failingReader struct {
+	sent bool
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.sent {
+		return 0, errors.New("connection reset")
+	}
+	r.sent = true
+	return copy(p, "partial"), nil
+}
+
+func TestLocalStorePut(t *testing.T) {
+	dir := t.TempDir()
+	store := NewLocalStore(dir)
+
+	if err := store.Put(context.Background(), "task/20240101/task_1_2.jsonl.gz", strings.NewReader("content"), 7); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "task", "20240101", "task_1_2.jsonl.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "content" {
+		t.Errorf("archived content %q, want content", content)
+	}
+
+	// 重复归档覆盖同一个文件
+	if err := store.Put(context.Background(), "task/20240101/task_1_2.jsonl.gz", strings.NewReader("again"), 5); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ = os.ReadFile(filepath.Join(dir, "task", "20240101", "task_1_2.jsonl.gz")); string(content) != "again" {
+		t.Errorf("archived content %q after overwrite, want again", content)
+	}
+}
+
+func TestLocalStorePutFailed(t *testing.T) {
+	dir := t.TempDir()
+	store := NewLocalStore(dir)
+
+	if err := store.Put(context.Background(), "task/task_1_2.jsonl.gz", &failingReader{}, 0); err == nil {
+		t.Fatal("put with failing reader succeeded")
+	}
+	// 不留下不完整的归档文件，也不留下临时文件
+	entries, err := os.ReadDir(filepath.Join(dir, "task"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("%d files left after failed put, want none", len(entries))
+	}
+}
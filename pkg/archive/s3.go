@@ -0,0 +1,50 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"timer/common/conf"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Store 归档到兼容 S3 协议的对象存储，本地可以使用 MinIO 代替
+type S3Store struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func NewS3Store(config *conf.S3Config) (*S3Store, error) {
+	if config == nil || config.Endpoint == "" || config.Bucket == "" {
+		return nil, errors.New("s3 archive store requires endpoint and bucket")
+	}
+
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: config.UseSSL,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Store{
+		client: client,
+		bucket: config.Bucket,
+		prefix: config.Prefix,
+	}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, name string, reader io.Reader, size int64) error {
+	_, err := s.client.PutObject(ctx, s.bucket, path.Join(s.prefix, name), reader, size, minio.PutObjectOptions{
+		ContentType:     "application/x-ndjson",
+		ContentEncoding: "gzip",
+	})
+	return err
+}
+
+var _ Store = &S3Store{}
@@ -0,0 +1,181 @@
+package calendar
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DateFormat 排除日期的格式
+	DateFormat = "2006-01-02"
+	// rangeSep 日期区间的起止分隔符，例如 2026-10-01~2026-10-07
+	rangeSep = "~"
+)
+
+// DateRange 排除的日期区间，首尾都包含，只比较年月日
+type DateRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (r DateRange) String() string {
+	if r.Start.Equal(r.End) {
+		return r.Start.Format(DateFormat)
+	}
+	return r.Start.Format(DateFormat) + rangeSep + r.End.Format(DateFormat)
+}
+
+// contains 判断日期 day（零点）是否在区间内
+func (r DateRange) contains(day time.Time) bool {
+	return !day.Before(r.Start) && !day.After(r.End)
+}
+
+// Calendar 排除规则：星期规则 + 日期区间，触发时间命中任意一条即被排除
+type Calendar struct {
+	Weekdays []time.Weekday
+	Ranges   []DateRange
+}
+
+// Excludes 判断 t 所在的日期是否被排除，日期按 t 的时区计算
+func (c *Calendar) Excludes(t time.Time) bool {
+	for _, weekday := range c.Weekdays {
+		if t.Weekday() == weekday {
+			return true
+		}
+	}
+
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	for _, r := range c.Ranges {
+		if r.contains(day) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter 过滤掉被任意一个日历排除的触发时间
+func Filter(times []time.Time, calendars ...*Calendar) []time.Time {
+	if len(calendars) == 0 {
+		return times
+	}
+
+	res := make([]time.Time, 0, len(times))
+	for _, t := range times {
+		excluded := false
+		for _, c := range calendars {
+			if excluded = c.Excludes(t); excluded {
+				break
+			}
+		}
+		if !excluded {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// ParseWeekdays 解析逗号分隔的星期，0 为周日，6 为周六
+func ParseWeekdays(str string) ([]time.Weekday, error) {
+	var weekdays []time.Weekday
+	for _, s := range strings.Split(str, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > 6 {
+			return nil, fmt.Errorf("invalid weekday: %q, should be between 0 and 6", s)
+		}
+		weekdays = append(weekdays, time.Weekday(n))
+	}
+	return weekdays, nil
+}
+
+// FormatWeekdays 格式化为逗号分隔的星期
+func FormatWeekdays(weekdays []time.Weekday) string {
+	strs := make([]string, 0, len(weekdays))
+	for _, weekday := range weekdays {
+		strs = append(strs, strconv.Itoa(int(weekday)))
+	}
+	return strings.Join(strs, ",")
+}
+
+// ParseDate 解析 2006-01-02 格式的日期
+func ParseDate(str string) (time.Time, error) {
+	return time.ParseInLocation(DateFormat, strings.TrimSpace(str), time.UTC)
+}
+
+// ParseRange 解析单个日期 2026-01-01 或者日期区间 2026-10-01~2026-10-07
+func ParseRange(str string) (DateRange, error) {
+	startStr, endStr := str, str
+	if idx := strings.Index(str, rangeSep); idx >= 0 {
+		startStr, endStr = str[:idx], str[idx+len(rangeSep):]
+	}
+
+	start, err := ParseDate(startStr)
+	if err != nil {
+		return DateRange{}, fmt.Errorf("invalid date range: %q, err: %w", str, err)
+	}
+	end, err := ParseDate(endStr)
+	if err != nil {
+		return DateRange{}, fmt.Errorf("invalid date range: %q, err: %w", str, err)
+	}
+	if end.Before(start) {
+		return DateRange{}, fmt.Errorf("invalid date range: %q, end can not earlier than start", str)
+	}
+	return DateRange{Start: start, End: end}, nil
+}
+
+// ParseRanges 解析逗号分隔的日期与日期区间
+func ParseRanges(str string) ([]DateRange, error) {
+	var ranges []DateRange
+	for _, s := range strings.Split(str, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		r, err := ParseRange(s)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// FormatRanges 合并重叠与相邻的区间后格式化为逗号分隔的字符串
+func FormatRanges(ranges []DateRange) string {
+	merged := MergeRanges(ranges)
+	strs := make([]string, 0, len(merged))
+	for _, r := range merged {
+		strs = append(strs, r.String())
+	}
+	return strings.Join(strs, ",")
+}
+
+// MergeRanges 按开始日期排序，并合并重叠与相邻的区间
+func MergeRanges(ranges []DateRange) []DateRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	sorted := make([]DateRange, len(ranges))
+	copy(sorted, ranges)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start.Before(sorted[j].Start)
+	})
+
+	merged := []DateRange{sorted[0]}
+	for _, r := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start.After(last.End.AddDate(0, 0, 1)) {
+			merged = append(merged, r)
+			continue
+		}
+		if r.End.After(last.End) {
+			last.End = r.End
+		}
+	}
+	return merged
+}
@@ -0,0 +1,66 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilter(t *testing.T) {
+	ranges, err := ParseRanges("2026-10-01~2026-10-07, 2026-12-25")
+	if err != nil {
+		t.Fatal(err)
+	}
+	weekend := &Calendar{Weekdays: []time.Weekday{time.Saturday, time.Sunday}}
+	holidays := &Calendar{Ranges: ranges}
+
+	cases := []struct {
+		at       string
+		excluded bool
+	}{
+		{at: "2026-09-30 23:59", excluded: false}, // 周三，假期前一天
+		{at: "2026-10-01 00:00", excluded: true},  // 区间的第一天
+		{at: "2026-10-07 23:59", excluded: true},  // 区间的最后一天
+		{at: "2026-10-08 09:00", excluded: false}, // 周四，假期之后
+		{at: "2026-10-10 09:00", excluded: true},  // 周六
+		{at: "2026-12-25 09:00", excluded: true},  // 单个日期
+	}
+	var times, want []time.Time
+	for _, c := range cases {
+		at, err := time.ParseInLocation("2006-01-02 15:04", c.at, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		times = append(times, at)
+		if !c.excluded {
+			want = append(want, at)
+		}
+	}
+
+	got := Filter(times, weekend, holidays)
+	if len(got) != len(want) {
+		t.Fatalf("filter kept %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("filter kept %v, want %v", got[i], want[i])
+		}
+	}
+
+	if got := Filter(times); len(got) != len(times) {
+		t.Errorf("filter without calendars kept %d of %d times", len(got), len(times))
+	}
+}
+
+func TestFormatRangesMerges(t *testing.T) {
+	ranges, err := ParseRanges("2026-10-05~2026-10-07,2026-10-01~2026-10-04,2026-10-06,2026-12-25")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := FormatRanges(ranges), "2026-10-01~2026-10-07,2026-12-25"; got != want {
+		t.Errorf("format ranges: %s, want %s", got, want)
+	}
+
+	if _, err := ParseRanges("2026-10-07~2026-10-01"); err == nil {
+		t.Error("range ending before it starts is accepted")
+	}
+}
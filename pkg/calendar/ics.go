@@ -0,0 +1,292 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	icsDateFormat     = "20060102"
+	icsDateTimeFormat = "20060102T150405"
+)
+
+// icsEvent 解析中的 VEVENT，只关心起止时间与重复规则
+type icsEvent struct {
+	summary  string
+	start    time.Time
+	end      time.Time
+	allDay   bool
+	hasEnd   bool
+	duration time.Duration
+	rrule    string
+}
+
+// ParseICS 从 iCalendar 文件中解析排除的日期区间，每个 VEVENT 覆盖的日期都会被排除
+// 全天事件的 DTEND 不包含在内；重复规则只支持 FREQ=YEARLY，没有 UNTIL、COUNT 时展开到 until 为止
+func ParseICS(r io.Reader, until time.Time) ([]DateRange, error) {
+	lines, err := unfoldICS(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		ranges []DateRange
+		event  *icsEvent
+	)
+	for i, line := range lines {
+		name, params, value := splitICSLine(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			event = &icsEvent{}
+		case name == "END" && value == "VEVENT":
+			if event == nil {
+				return nil, fmt.Errorf("invalid ics, line %d: END:VEVENT without BEGIN", i+1)
+			}
+			eventRanges, err := event.ranges(until)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ics event %q, err: %w", event.summary, err)
+			}
+			ranges = append(ranges, eventRanges...)
+			event = nil
+		case event == nil:
+			// VEVENT 之外的属性（VCALENDAR、VTIMEZONE 等）不关心
+		case name == "SUMMARY":
+			event.summary = value
+		case name == "DTSTART":
+			if event.start, event.allDay, err = parseICSTime(params, value); err != nil {
+				return nil, fmt.Errorf("invalid ics, line %d: %w", i+1, err)
+			}
+		case name == "DTEND":
+			if event.end, _, err = parseICSTime(params, value); err != nil {
+				return nil, fmt.Errorf("invalid ics, line %d: %w", i+1, err)
+			}
+			event.hasEnd = true
+		case name == "DURATION":
+			if event.duration, err = parseICSDuration(value); err != nil {
+				return nil, fmt.Errorf("invalid ics, line %d: %w", i+1, err)
+			}
+		case name == "RRULE":
+			event.rrule = value
+		}
+	}
+	return MergeRanges(ranges), nil
+}
+
+// ranges 事件覆盖的日期区间，重复事件每次重复一个区间
+func (e *icsEvent) ranges(until time.Time) ([]DateRange, error) {
+	if e.start.IsZero() {
+		return nil, fmt.Errorf("DTSTART not found")
+	}
+
+	first := e.dateRange()
+	if e.rrule == "" {
+		return []DateRange{first}, nil
+	}
+
+	rule, err := parseICSRule(e.rrule)
+	if err != nil {
+		return nil, err
+	}
+	if rule.until.IsZero() || rule.until.After(until) {
+		rule.until = until
+	}
+
+	var ranges []DateRange
+	for n := 0; rule.count <= 0 || n < rule.count; n++ {
+		r := DateRange{
+			Start: first.Start.AddDate(n*rule.interval, 0, 0),
+			End:   first.End.AddDate(n*rule.interval, 0, 0),
+		}
+		if r.Start.After(rule.until) {
+			break
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// dateRange 单次事件覆盖的日期，按本地时区换算
+func (e *icsEvent) dateRange() DateRange {
+	end := e.start
+	switch {
+	case e.hasEnd:
+		end = e.end
+	case e.duration > 0:
+		end = e.start.Add(e.duration)
+	case e.allDay:
+		end = e.start.AddDate(0, 0, 1)
+	}
+
+	start := toDate(e.start)
+	// 结束时间是开区间，恰好在零点结束时不包含当天
+	last := toDate(end)
+	if end.After(e.start) && end.Equal(time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, end.Location())) {
+		last = last.AddDate(0, 0, -1)
+	}
+	if last.Before(start) {
+		last = start
+	}
+	return DateRange{Start: start, End: last}
+}
+
+type icsRule struct {
+	interval int
+	count    int
+	until    time.Time
+}
+
+func parseICSRule(value string) (*icsRule, error) {
+	rule := icsRule{interval: 1}
+	var yearly bool
+	for _, part := range strings.Split(value, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid RRULE: %s", value)
+		}
+		switch strings.ToUpper(kv[0]) {
+		case "FREQ":
+			if strings.ToUpper(kv[1]) != "YEARLY" {
+				return nil, fmt.Errorf("unsupported RRULE: %s, only FREQ=YEARLY is supported", value)
+			}
+			yearly = true
+		case "INTERVAL":
+			n, err := strconv.Atoi(kv[1])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid RRULE interval: %s", value)
+			}
+			rule.interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(kv[1])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid RRULE count: %s", value)
+			}
+			rule.count = n
+		case "UNTIL":
+			t, _, err := parseICSTime("", kv[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid RRULE until: %s", value)
+			}
+			rule.until = toDate(t)
+		default:
+			// BYMONTH、BYMONTHDAY 等与 DTSTART 一致时可以忽略，否则无法正确展开
+			return nil, fmt.Errorf("unsupported RRULE: %s, only FREQ, INTERVAL, COUNT and UNTIL are supported", value)
+		}
+	}
+	// FREQ 是必填项，缺少时不能按每年重复展开
+	if !yearly {
+		return nil, fmt.Errorf("invalid RRULE: %s, FREQ is required", value)
+	}
+	return &rule, nil
+}
+
+// parseICSTime 解析 DATE 或 DATE-TIME，UTC 时间与 TZID 指定时区的时间换算到本地时区，返回是否为全天
+func parseICSTime(params, value string) (time.Time, bool, error) {
+	if len(value) == len(icsDateFormat) {
+		t, err := time.ParseInLocation(icsDateFormat, value, time.Local)
+		return t, true, err
+	}
+
+	loc := time.Local
+	if strings.HasSuffix(value, "Z") {
+		loc, value = time.UTC, strings.TrimSuffix(value, "Z")
+	} else if tzid := getICSParam(params, "TZID"); tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation(icsDateTimeFormat, value, loc)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t.In(time.Local), false, nil
+}
+
+// parseICSDuration 解析 P1D、P2W、PT12H 形式的时长
+func parseICSDuration(value string) (time.Duration, error) {
+	str := strings.TrimPrefix(strings.TrimPrefix(value, "+"), "P")
+	if str == value || str == "" {
+		return 0, fmt.Errorf("invalid duration: %s", value)
+	}
+
+	var (
+		d      time.Duration
+		n      int
+		digits bool
+	)
+	for _, c := range str {
+		switch {
+		case c >= '0' && c <= '9':
+			n, digits = n*10+int(c-'0'), true
+			continue
+		case c == 'T':
+			continue
+		case !digits:
+			return 0, fmt.Errorf("invalid duration: %s", value)
+		case c == 'W':
+			d += time.Duration(n) * 7 * 24 * time.Hour
+		case c == 'D':
+			d += time.Duration(n) * 24 * time.Hour
+		case c == 'H':
+			d += time.Duration(n) * time.Hour
+		case c == 'M':
+			d += time.Duration(n) * time.Minute
+		case c == 'S':
+			d += time.Duration(n) * time.Second
+		default:
+			return 0, fmt.Errorf("invalid duration: %s", value)
+		}
+		n, digits = 0, false
+	}
+	return d, nil
+}
+
+// unfoldICS 按行读取，以空格或 tab 开头的行是上一行的延续
+func unfoldICS(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// splitICSLine 拆分 NAME;PARAM=VALUE:VALUE 形式的内容行
+func splitICSLine(line string) (name, params, value string) {
+	idx := strings.Index(line, ":")
+	if idx < 0 {
+		return strings.ToUpper(line), "", ""
+	}
+	name, value = line[:idx], line[idx+1:]
+	if i := strings.Index(name, ";"); i >= 0 {
+		name, params = name[:i], name[i+1:]
+	}
+	return strings.ToUpper(name), params, value
+}
+
+func getICSParam(params, key string) string {
+	for _, param := range strings.Split(params, ";") {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) == 2 && strings.EqualFold(kv[0], key) {
+			return strings.Trim(kv[1], `"`)
+		}
+	}
+	return ""
+}
+
+// toDate 取 t 在本地时区的日期，与 DateRange 一致使用 UTC 零点表示
+func toDate(t time.Time) time.Time {
+	t = t.In(time.Local)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
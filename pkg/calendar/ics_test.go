@@ -0,0 +1,48 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func icsEvents(events ...string) string {
+	lines := []string{"BEGIN:VCALENDAR", "VERSION:2.0"}
+	for _, event := range events {
+		lines = append(lines, "BEGIN:VEVENT", event, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+	return strings.Join(lines, "\r\n")
+}
+
+func TestParseICS(t *testing.T) {
+	ics := icsEvents(
+		// 全天事件的 DTEND 不包含在内
+		"SUMMARY:National Day\r\nDTSTART;VALUE=DATE:20261001\r\nDTEND;VALUE=DATE:20261008",
+		// 每年重复三次
+		"SUMMARY:New Year\r\nDTSTART;VALUE=DATE:20260101\r\nRRULE:FREQ=YEARLY;COUNT=3",
+	)
+	ranges, err := ParseICS(strings.NewReader(ics), time.Date(2030, 1, 1, 0, 0, 0, 0, time.Local))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := FormatRanges(ranges), "2026-01-01,2026-10-01~2026-10-07,2027-01-01,2028-01-01"; got != want {
+		t.Errorf("parse ics: %s, want %s", got, want)
+	}
+}
+
+func TestParseICSRejectsUnsupportedRRule(t *testing.T) {
+	rules := []string{
+		"FREQ=WEEKLY",
+		"FREQ=MONTHLY;COUNT=12",
+		"FREQ=DAILY",
+		"INTERVAL=2",
+		"FREQ=YEARLY;BYMONTH=1",
+	}
+	for _, rule := range rules {
+		ics := icsEvents("DTSTART;VALUE=DATE:20260101\r\nRRULE:" + rule)
+		if _, err := ParseICS(strings.NewReader(ics), time.Date(2030, 1, 1, 0, 0, 0, 0, time.Local)); err == nil {
+			t.Errorf("RRULE %s is accepted", rule)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package cron
+
+import (
+	"time"
+
+	"github.com/gorhill/cronexpr"
+)
+
+const (
+	// 统计最小触发间隔时最多采样的触发次数
+	analysisSampleNum = 1000
+	// 查找相邻两天都触发的日期时向后观察的年数，覆盖闰年的 2 月 29 日
+	analysisHorizonYears = 4
+)
+
+// Analysis cron 表达式的调度密度分析结果
+type Analysis struct {
+	// 是否永远不会触发，例如 0 0 30 2 *
+	NeverFires bool
+	// 相邻两次触发的最小间隔，只触发一次时为 0
+	MinInterval time.Duration
+	// 任意一天内的最大触发次数
+	FiresPerDay int
+	// 从 from 开始的前 N 次触发时间
+	Nexts []time.Time
+}
+
+// Analyze 分析 cron 表达式的调度密度，并给出从 from 开始的前 previewNum 次触发时间
+// cron 的秒、分、时字段与日期无关，每个会触发的日期一天内的触发时间都相同（不考虑夏令时切换），
+// 因此完整统计第一个会触发的日期即可得到任意一天的触发次数，例如周二分析 * * * * 1 时统计的是下周一
+func (c *Parser) Analyze(cron string, from time.Time, previewNum int) (*Analysis, error) {
+	expr, err := cronexpr.Parse(cron)
+	if err != nil {
+		return nil, err
+	}
+
+	var analysis Analysis
+	first := expr.Next(from)
+	if first.IsZero() {
+		analysis.NeverFires = true
+		return &analysis, nil
+	}
+
+	analysis.Nexts = nextN(expr, from, previewNum)
+
+	// 采样连续的触发时间，计算最小间隔，覆盖间隔跨越多天的稀疏表达式
+	samples := nextN(expr, from, analysisSampleNum)
+	for i := 1; i < len(samples); i++ {
+		analysis.observeInterval(samples[i].Sub(samples[i-1]))
+	}
+
+	// 统计第一个会触发的日期一整天的触发次数以及一天内的最小间隔
+	dayStart := startOfDay(first)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+	var dayFirst, dayLast time.Time
+	for next := expr.Next(dayStart.Add(-time.Second)); !next.IsZero() && next.Before(dayEnd); next = expr.Next(next) {
+		if analysis.FiresPerDay == 0 {
+			dayFirst = next
+		} else {
+			analysis.observeInterval(next.Sub(dayLast))
+		}
+		dayLast = next
+		analysis.FiresPerDay++
+	}
+
+	// 存在相邻两天都触发时，前一天最后一次与后一天第一次之间的间隔也要计入
+	if hasAdjacentFireDays(expr, dayStart) {
+		analysis.observeInterval(dayFirst.AddDate(0, 0, 1).Sub(dayLast))
+	}
+
+	return &analysis, nil
+}
+
+// DailyProfile cron 表达式在一段时间内的触发分布
+type DailyProfile struct {
+	// 一天内第几分钟 -> 该分钟的触发次数，所有会触发的日期都相同
+	Minutes map[int]int
+	// 会触发的日期，为当天零点，按时间升序
+	Days []time.Time
+}
+
+// Profile 统计 cron 表达式从 from 所在日期开始 days 天内的触发分布，与 Analyze 一样只需要统计第一个会触发的日期，
+// 多个定时器按日期叠加即可得到任意一分钟的触发次数，覆盖每天、每周、每月、每年的峰值
+func (c *Parser) Profile(cron string, from time.Time, days int) (*DailyProfile, error) {
+	expr, err := cronexpr.Parse(cron)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := DailyProfile{Minutes: make(map[int]int)}
+	end := startOfDay(from).AddDate(0, 0, days)
+	for day := startOfDay(from); day.Before(end); day = day.AddDate(0, 0, 1) {
+		next := expr.Next(day.Add(-time.Second))
+		if next.IsZero() || !next.Before(end) {
+			break
+		}
+		day = startOfDay(next)
+		profile.Days = append(profile.Days, day)
+	}
+	if len(profile.Days) == 0 {
+		return &profile, nil
+	}
+
+	dayStart := profile.Days[0]
+	dayEnd := dayStart.AddDate(0, 0, 1)
+	for next := expr.Next(dayStart.Add(-time.Second)); !next.IsZero() && next.Before(dayEnd); next = expr.Next(next) {
+		profile.Minutes[next.Hour()*60+next.Minute()]++
+	}
+	return &profile, nil
+}
+
+func (a *Analysis) observeInterval(gap time.Duration) {
+	if gap > 0 && (a.MinInterval == 0 || gap < a.MinInterval) {
+		a.MinInterval = gap
+	}
+}
+
+// hasAdjacentFireDays 从 day 开始按会触发的日期跳跃查找，是否存在相邻两天都会触发
+func hasAdjacentFireDays(expr *cronexpr.Expression, day time.Time) bool {
+	horizon := day.AddDate(analysisHorizonYears, 0, 0)
+	for day.Before(horizon) {
+		following := day.AddDate(0, 0, 1)
+		next := expr.Next(following.Add(-time.Second))
+		if next.IsZero() {
+			return false
+		}
+		day = startOfDay(next)
+		if day.Equal(following) {
+			return true
+		}
+	}
+	return false
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+func nextN(expr *cronexpr.Expression, from time.Time, n int) []time.Time {
+	nexts := make([]time.Time, 0, n)
+	for next := expr.Next(from); !next.IsZero() && len(nexts) < n; next = expr.Next(next) {
+		nexts = append(nexts, next)
+	}
+	return nexts
+}
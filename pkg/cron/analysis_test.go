@@ -0,0 +1,95 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnalyze(t *testing.T) {
+	// 2024-01-02 是周二
+	tuesday := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name        string
+		cron        string
+		from        time.Time
+		firesPerDay int
+		minInterval time.Duration
+		neverFires  bool
+	}{
+		{name: "every minute", cron: "* * * * *", from: tuesday, firesPerDay: 1440, minInterval: time.Minute},
+		{name: "dense only on monday evaluated on tuesday", cron: "* * * * 1", from: tuesday, firesPerDay: 1440, minInterval: time.Minute},
+		{name: "dense only on the 15th", cron: "*/2 * 15 * *", from: tuesday, firesPerDay: 720, minInterval: 2 * time.Minute},
+		{name: "evaluated late in the day", cron: "0 * * * *", from: time.Date(2024, 1, 2, 23, 30, 0, 0, time.UTC), firesPerDay: 24, minInterval: time.Hour},
+		{name: "daily", cron: "0 3 * * *", from: tuesday, firesPerDay: 1, minInterval: 24 * time.Hour},
+		// 23:59:59 与次日 00:00:00 之间只间隔 1 秒
+		{name: "across midnight", cron: "0,59 0,59 0,23 * * * *", from: tuesday, firesPerDay: 8, minInterval: time.Second},
+		{name: "never", cron: "0 0 30 2 *", from: tuesday, neverFires: true},
+	}
+
+	parser := NewCronParser()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			analysis, err := parser.Analyze(c.cron, c.from, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if analysis.NeverFires != c.neverFires {
+				t.Fatalf("never fires: got %t, want %t", analysis.NeverFires, c.neverFires)
+			}
+			if analysis.FiresPerDay != c.firesPerDay {
+				t.Errorf("fires per day: got %d, want %d", analysis.FiresPerDay, c.firesPerDay)
+			}
+			if analysis.MinInterval != c.minInterval {
+				t.Errorf("min interval: got %v, want %v", analysis.MinInterval, c.minInterval)
+			}
+		})
+	}
+}
+
+func TestProfile(t *testing.T) {
+	tuesday := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		name    string
+		cron    string
+		from    time.Time
+		days    int
+		minutes map[int]int
+		fires   []time.Time
+	}{
+		// 当天的零点已经过去，从下个月开始统计
+		{name: "monthly", cron: "0 0 1 * *", from: tuesday, days: 62, minutes: map[int]int{0: 1}, fires: []time.Time{date(2024, 2, 1), date(2024, 3, 1)}},
+		{name: "weekly", cron: "*/30 9 * * 1", from: tuesday, days: 14, minutes: map[int]int{540: 1, 570: 1}, fires: []time.Time{date(2024, 1, 8), date(2024, 1, 15)}},
+		// 当天还会触发的日期计入
+		{name: "daily", cron: "0,30 0 23 * * * *", from: tuesday, days: 2, minutes: map[int]int{23*60 + 0: 2, 23*60 + 1: 0}, fires: []time.Time{date(2024, 1, 2), date(2024, 1, 3)}},
+		{name: "leap day", cron: "0 0 29 2 *", from: date(2025, 1, 1), days: 4 * 366, minutes: map[int]int{0: 1}, fires: []time.Time{date(2028, 2, 29)}},
+		{name: "never", cron: "0 0 30 2 *", from: tuesday, days: 366, minutes: map[int]int{}},
+	}
+
+	parser := NewCronParser()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			profile, err := parser.Profile(c.cron, c.from, c.days)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for minute, want := range c.minutes {
+				if got := profile.Minutes[minute]; got != want {
+					t.Errorf("minute %d: got %d fires, want %d", minute, got, want)
+				}
+			}
+			if len(profile.Days) != len(c.fires) {
+				t.Fatalf("fire days: got %v, want %v", profile.Days, c.fires)
+			}
+			for i := range c.fires {
+				if !profile.Days[i].Equal(c.fires[i]) {
+					t.Errorf("fire day %d: got %v, want %v", i, profile.Days[i], c.fires[i])
+				}
+			}
+		})
+	}
+}
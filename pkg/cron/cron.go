@@ -36,6 +36,10 @@ func (c *Parser) NextsBetween(cron string, start, end time.Time) ([]time.Time, e
 	var nexts []time.Time
 	for start.Before(end) {
 		next := expr.Next(start)
+		// 零值代表之后不会再触发，例如 0 0 30 2 *
+		if next.IsZero() {
+			break
+		}
 		if next.UnixNano() < 0 {
 			return nil, fmt.Errorf("fail to parse time from cron: %s", cron)
 		}
@@ -45,3 +49,12 @@ func (c *Parser) NextsBetween(cron string, start, end time.Time) ([]time.Time, e
 
 	return nexts, nil
 }
+
+// NextN 获取 start 之后的 n 次触发时间，触发时间与 start 处于同一时区
+func (c *Parser) NextN(cron string, start time.Time, n int) ([]time.Time, error) {
+	expr, err := cronexpr.Parse(cron)
+	if err != nil {
+		return nil, err
+	}
+	return nextN(expr, start, n), nil
+}
@@ -0,0 +1,306 @@
+package cron
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gorhill/cronexpr"
+)
+
+// 与 cronexpr 保持一致的别名
+var cronAliases = map[string]string{
+	"@yearly":   "0 0 0 1 1 * *",
+	"@annually": "0 0 0 1 1 * *",
+	"@monthly":  "0 0 0 1 * * *",
+	"@weekly":   "0 0 0 * * 0 *",
+	"@daily":    "0 0 0 * * * *",
+	"@hourly":   "0 0 * * * * *",
+}
+
+var fieldFinder = regexp.MustCompile(`\S+`)
+
+const (
+	fieldSecond = iota
+	fieldMinute
+	fieldHour
+	fieldDayOfMonth
+	fieldMonth
+	fieldDayOfWeek
+	fieldYear
+	fieldNum
+)
+
+var fieldNames = [fieldNum]string{"second", "minute", "hour", "day-of-month", "month", "day-of-week", "year"}
+
+// 单独校验某个字段时，其余字段使用的默认值
+var fieldDefaults = [fieldNum]string{"0", "*", "*", "*", "*", "*", "*"}
+
+var monthNames = []string{"", "January", "February", "March", "April", "May", "June", "July",
+	"August", "September", "October", "November", "December"}
+
+var weekdayNames = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
+
+// ExprError cron 表达式的校验错误，包含出错字段在表达式中的位置
+type ExprError struct {
+	Field    string // 出错的字段，例如 minute
+	Position int    // 出错字段在表达式中的起始下标（从 0 开始）
+	Message  string
+}
+
+func (e *ExprError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("invalid cron expression at position %d: %s", e.Position, e.Message)
+	}
+	return fmt.Sprintf("invalid %s field at position %d: %s", e.Field, e.Position, e.Message)
+}
+
+type exprField struct {
+	kind     int
+	value    string
+	position int
+}
+
+// splitFields 按 cronexpr 的规则拆分字段：5 个字段为 分 时 日 月 周，6 个字段末尾多了年，7 个字段开头多了秒
+func splitFields(cron string) ([]exprField, error) {
+	if alias, ok := cronAliases[strings.TrimSpace(cron)]; ok {
+		cron = alias
+	}
+
+	indices := fieldFinder.FindAllStringIndex(cron, -1)
+	if len(indices) < 5 {
+		return nil, &ExprError{Position: len(cron), Message: fmt.Sprintf("expected at least 5 fields, got %d", len(indices))}
+	}
+	if len(indices) > 7 {
+		return nil, &ExprError{Position: indices[7][0], Message: fmt.Sprintf("expected at most 7 fields, got %d", len(indices))}
+	}
+
+	kind := fieldMinute
+	if len(indices) == 7 {
+		kind = fieldSecond
+	}
+
+	fields := make([]exprField, 0, len(indices))
+	for _, index := range indices {
+		fields = append(fields, exprField{
+			kind:     kind,
+			value:    cron[index[0]:index[1]],
+			position: index[0],
+		})
+		kind++
+	}
+	return fields, nil
+}
+
+// Validate 校验 cron 表达式，不合法时返回带有出错位置的 *ExprError
+func (c *Parser) Validate(cron string) error {
+	fields, err := splitFields(cron)
+	if err != nil {
+		return err
+	}
+
+	// 逐个字段单独校验，定位出错的字段
+	for _, field := range fields {
+		exprFields := fieldDefaults
+		exprFields[field.kind] = field.value
+		if _, err := cronexpr.Parse(strings.Join(exprFields[:], " ")); err != nil {
+			return &ExprError{Field: fieldNames[field.kind], Position: field.position, Message: err.Error()}
+		}
+	}
+
+	// 字段之间的组合错误，无法定位到具体字段
+	if _, err := cronexpr.Parse(cron); err != nil {
+		return &ExprError{Message: err.Error()}
+	}
+	return nil
+}
+
+// Explain 生成 cron 表达式的英文描述，例如 "at 09:00 on Monday through Friday"
+func (c *Parser) Explain(cron string) (string, error) {
+	if err := c.Validate(cron); err != nil {
+		return "", err
+	}
+
+	fields, _ := splitFields(cron)
+	values := fieldDefaults
+	for _, field := range fields {
+		values[field.kind] = field.value
+	}
+
+	parts := []string{describeTime(values[fieldSecond], values[fieldMinute], values[fieldHour])}
+	if dom := values[fieldDayOfMonth]; !isAny(dom) {
+		parts = append(parts, "on "+describeDayOfMonth(dom))
+	}
+	if dow := values[fieldDayOfWeek]; !isAny(dow) {
+		parts = append(parts, "on "+describeDayOfWeek(dow))
+	}
+	if month := values[fieldMonth]; !isAny(month) {
+		parts = append(parts, "in "+describeField(month, "month", monthNames))
+	}
+	if year := values[fieldYear]; !isAny(year) {
+		parts = append(parts, "in "+describeField(year, "year", nil))
+	}
+	return strings.Join(parts, " "), nil
+}
+
+func describeTime(second, minute, hour string) string {
+	s, sOK := parseNumber(second, nil)
+	m, mOK := parseNumber(minute, nil)
+	h, hOK := parseNumber(hour, nil)
+
+	// 时分秒都是固定值，直接描述为时刻
+	if sOK && mOK && hOK {
+		if s == 0 {
+			return fmt.Sprintf("at %02d:%02d", h, m)
+		}
+		return fmt.Sprintf("at %02d:%02d:%02d", h, m, s)
+	}
+
+	var parts []string
+	switch {
+	case isAny(second):
+		parts = append(parts, "every second")
+	case !sOK || s != 0:
+		parts = append(parts, describeUnit(second, "second"))
+	}
+
+	switch {
+	case isAny(minute):
+		if len(parts) == 0 {
+			parts = append(parts, "every minute")
+		}
+	default:
+		parts = append(parts, describeUnit(minute, "minute"))
+	}
+
+	if !isAny(hour) {
+		parts = append(parts, "past "+strings.TrimPrefix(describeUnit(hour, "hour"), "at "))
+	}
+	return strings.Join(parts, " ")
+}
+
+// describeDayOfMonth 描述日字段，支持 L（最后一天）和 W（最近的工作日）
+func describeDayOfMonth(value string) string {
+	items := strings.Split(value, ",")
+	descs := make([]string, 0, len(items))
+	for _, item := range items {
+		switch {
+		case item == "L":
+			descs = append(descs, "the last day of the month")
+		case item == "LW":
+			descs = append(descs, "the last weekday of the month")
+		case strings.HasSuffix(item, "W"):
+			descs = append(descs, fmt.Sprintf("the weekday nearest day %s", strings.TrimSuffix(item, "W")))
+		default:
+			descs = append(descs, "day "+describeItem(item, "day", nil))
+		}
+	}
+	return joinAnd(descs)
+}
+
+// describeDayOfWeek 描述周字段，支持 5L（最后一个周五）和 5#3（第三个周五）
+func describeDayOfWeek(value string) string {
+	items := strings.Split(value, ",")
+	descs := make([]string, 0, len(items))
+	for _, item := range items {
+		if day, nth, ok := strings.Cut(item, "#"); ok {
+			descs = append(descs, fmt.Sprintf("the %s %s of the month", ordinal(nth), nameOf(day, weekdayNames)))
+			continue
+		}
+		if day := strings.TrimSuffix(item, "L"); day != item && day != "" {
+			descs = append(descs, fmt.Sprintf("the last %s of the month", nameOf(day, weekdayNames)))
+			continue
+		}
+		descs = append(descs, describeItem(item, "day", weekdayNames))
+	}
+	return joinAnd(descs)
+}
+
+func ordinal(value string) string {
+	switch value {
+	case "1":
+		return "1st"
+	case "2":
+		return "2nd"
+	case "3":
+		return "3rd"
+	default:
+		return value + "th"
+	}
+}
+
+// describeUnit 描述时分秒字段，例如 "every 5 minutes"、"at minute 0 and 30"
+func describeUnit(value, unit string) string {
+	if strings.HasPrefix(value, "*/") {
+		return fmt.Sprintf("every %s %ss", strings.TrimPrefix(value, "*/"), unit)
+	}
+	if strings.Contains(value, "/") {
+		return describeField(value, unit, nil)
+	}
+	return fmt.Sprintf("at %s %s", unit, describeField(value, unit, nil))
+}
+
+// describeField 描述单个字段，names 用于将数字转换为月份、星期名称
+func describeField(value, unit string, names []string) string {
+	items := strings.Split(value, ",")
+	descs := make([]string, 0, len(items))
+	for _, item := range items {
+		descs = append(descs, describeItem(item, unit, names))
+	}
+	return joinAnd(descs)
+}
+
+func describeItem(item, unit string, names []string) string {
+	rangePart, step, hasStep := strings.Cut(item, "/")
+	var desc string
+	if from, to, ok := strings.Cut(rangePart, "-"); ok {
+		desc = fmt.Sprintf("%s through %s", nameOf(from, names), nameOf(to, names))
+	} else if rangePart == "*" {
+		desc = ""
+	} else {
+		desc = nameOf(rangePart, names)
+	}
+
+	if !hasStep {
+		return desc
+	}
+	if desc == "" {
+		return fmt.Sprintf("every %s %ss", step, unit)
+	}
+	if !strings.Contains(rangePart, "-") {
+		desc += " onwards"
+	}
+	return fmt.Sprintf("every %s %ss from %s", step, unit, desc)
+}
+
+func nameOf(value string, names []string) string {
+	if n, ok := parseNumber(value, names); ok && names != nil && n < len(names) {
+		return names[n]
+	}
+	// L、W、# 等特殊语法原样保留
+	return value
+}
+
+func parseNumber(value string, names []string) (int, bool) {
+	if n, err := strconv.Atoi(value); err == nil {
+		return n, true
+	}
+	for i, name := range names {
+		if name != "" && strings.EqualFold(value, name[:3]) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func isAny(value string) bool {
+	return value == "*" || value == "?"
+}
+
+func joinAnd(items []string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
@@ -0,0 +1,68 @@
+// Package database 按配置的 driver 打开 MySQL、PostgreSQL 或 SQLite
+// SQLite 驱动 mattn/go-sqlite3 依赖 cgo，即使只使用 MySQL、PostgreSQL，编译时也需要 CGO_ENABLED=1 和 C 编译器
+package database
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"timer/common/conf"
+)
+
+// GetClient 根据配置的 driver 打开对应的数据库
+func GetClient(config *conf.DatabaseConfig) *gorm.DB {
+	dialector, err := getDialector(config)
+	if err != nil {
+		panic(err)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		panic(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		panic(err)
+	}
+
+	sqlDB.SetMaxIdleConns(config.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	if config.Driver == conf.DriverSQLite {
+		// SQLite 同一时间只允许一个写者，多连接并发写只会得到 database is locked
+		// 内存数据库每个连接都是独立的库，也必须只用一个连接，并且保持空闲连接不被关闭
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+	}
+	return db
+}
+
+func getDialector(config *conf.DatabaseConfig) (gorm.Dialector, error) {
+	switch config.Driver {
+	case conf.DriverMySQL, "":
+		return mysql.Open(config.DSN), nil
+	case conf.DriverPostgres:
+		return postgres.Open(config.DSN), nil
+	case conf.DriverSQLite:
+		return sqlite.Open(config.DSN), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", config.Driver)
+	}
+}
+
+type txKey struct{}
+
+// WithTx 将事务放入 ctx 中，使用 Conn 获取连接的 dao 会自动加入该事务
+func WithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// Conn 如果 ctx 中有事务则返回事务，否则返回 db
+func Conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
@@ -0,0 +1,25 @@
+package database
+
+import (
+	"errors"
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"github.com/mattn/go-sqlite3"
+)
+
+// IsDuplicatedKey 写入违反了唯一索引
+func IsDuplicatedKey(err error) bool {
+	var mysqlErr *mysqlDriver.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505"
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+	return false
+}
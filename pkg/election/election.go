@@ -0,0 +1,110 @@
+package election
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+	"timer/pkg/logger"
+	"timer/pkg/redis"
+)
+
+// Elector 基于 redis 租约的选主组件
+// 租约即一把分布式锁，leader 每隔 renewGap 续期一次；leader 宕机后租约最多 leaseSeconds 过期，其他节点随即当选
+type Elector struct {
+	key          string
+	leaseSeconds int64
+	renewGap     time.Duration
+	lockService  *redis.Client
+	leader       atomic.Bool
+	// 最近一次竞选或续期的时间戳（毫秒）
+	lastTick atomic.Int64
+}
+
+func NewElector(key string, lockService *redis.Client, leaseSeconds int64, renewGap time.Duration) *Elector {
+	return &Elector{
+		key:          key,
+		leaseSeconds: leaseSeconds,
+		renewGap:     renewGap,
+		lockService:  lockService,
+	}
+}
+
+// Run 持续参与竞选，直到 ctx 结束
+// 当选后在新协程中执行 lead，传入的 ctx 在失去 leader 身份时取消，并携带本任期的 fencing token；
+// lead 返回之后才会重新参与竞选，保证同一个节点上不会有两个任期同时运行
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	// 锁的 token 与协程绑定，竞选与续期都在当前协程内进行
+	locker := e.lockService.GetDistributionLock(e.key)
+	ticker := time.NewTicker(e.renewGap)
+	defer ticker.Stop()
+
+	var (
+		cancel func()
+		done   chan struct{}
+	)
+	resign := func() {
+		cancel()
+		<-done
+		cancel = nil
+		e.leader.Store(false)
+	}
+
+	for {
+		e.lastTick.Store(time.Now().UnixMilli())
+		if cancel == nil {
+			if err := locker.Lock(ctx, e.leaseSeconds); err == nil {
+				logger.InfoContextf(ctx, "elected as leader, key: %s, term: %d", e.key, locker.FencingToken())
+				e.leader.Store(true)
+
+				var leadCtx context.Context
+				leadCtx, cancel = context.WithCancel(redis.WithFencingToken(ctx, locker.FencingToken()))
+				done = make(chan struct{})
+				go func() {
+					defer close(done)
+					lead(leadCtx)
+				}()
+			}
+		} else if isDone(done) {
+			// lead 自行退出，让出 leader 身份
+			resign()
+			_ = locker.Unlock(ctx)
+		} else if err := locker.ExpireLock(ctx, e.leaseSeconds); err != nil {
+			// 续期失败无法确认租约是否仍然有效，保守地认为已经失去 leader 身份
+			logger.ErrorContextf(ctx, "renew leader lease failed, key: %s, err: %v", e.key, err)
+			resign()
+		}
+
+		select {
+		case <-ctx.Done():
+			if cancel != nil {
+				resign()
+				// 主动释放租约，其他节点无需等待租约过期即可当选
+				_ = locker.Unlock(context.Background())
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func isDone(done chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsLeader 当前节点是否为 leader
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// LastTick 返回最近一次竞选或续期的时间，未启动时返回零值
+func (e *Elector) LastTick() time.Time {
+	if ms := e.lastTick.Load(); ms > 0 {
+		return time.UnixMilli(ms)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,163 @@
+package election
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"timer/pkg/redis"
+	"timer/pkg/testenv"
+)
+
+const testKey = "election"
+
+// waitFor 轮询直到 cond 成立，超时后测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// runElector 在新协程中运行 elector，返回当前任期的 ctx 以及停止运行的函数
+func runElector(e *Elector) (leadCtx func() context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var (
+		mu      sync.Mutex
+		current context.Context
+		wg      sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		e.Run(ctx, func(ctx context.Context) {
+			mu.Lock()
+			current = ctx
+			mu.Unlock()
+			<-ctx.Done()
+		})
+	}()
+	leadCtx = func() context.Context {
+		mu.Lock()
+		defer mu.Unlock()
+		return current
+	}
+	return leadCtx, func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// holdLease 在新协程中以其他持有者的身份获取租约，模拟其他节点
+func holdLease(t *testing.T, lockService *redis.Client, leaseSeconds int64) error {
+	t.Helper()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- lockService.GetDistributionLock(testKey).Lock(context.Background(), leaseSeconds)
+	}()
+	return <-errCh
+}
+
+func TestSingleLeader(t *testing.T) {
+	_, rdb := testenv.NewRedis(t)
+	a, b := NewElector(testKey, rdb, 3, 20*time.Millisecond), NewElector(testKey, rdb, 3, 20*time.Millisecond)
+
+	var terms atomic.Int32
+	run := func(e *Elector) func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			e.Run(ctx, func(ctx context.Context) {
+				terms.Add(1)
+				<-ctx.Done()
+			})
+		}()
+		return func() {
+			cancel()
+			<-done
+		}
+	}
+	stopA, stopB := run(a), run(b)
+	defer stopB()
+
+	waitFor(t, "leader elected", func() bool { return a.IsLeader() || b.IsLeader() })
+	// 多个续期周期内始终只有一个 leader，任期也不会重复开始
+	for i := 0; i < 20; i++ {
+		if a.IsLeader() == b.IsLeader() {
+			t.Fatalf("a leader %v, b leader %v, want exactly one", a.IsLeader(), b.IsLeader())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := terms.Load(); n != 1 {
+		t.Fatalf("%d terms started, want 1", n)
+	}
+
+	// leader 退出时主动释放租约，另一个节点无需等待租约过期
+	leader, follower := a, b
+	if b.IsLeader() {
+		leader, follower = b, a
+		stopA, stopB = stopB, stopA
+	}
+	stopA()
+	if leader.IsLeader() {
+		t.Error("stopped elector still leader")
+	}
+	waitFor(t, "follower takes over", follower.IsLeader)
+	if n := terms.Load(); n != 2 {
+		t.Errorf("%d terms started, want 2", n)
+	}
+}
+
+func TestTakeoverAfterLeaseExpiry(t *testing.T) {
+	server, rdb := testenv.NewRedis(t)
+	// 其他节点持有租约后宕机，不再续期
+	if err := holdLease(t, rdb, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewElector(testKey, rdb, 3, 20*time.Millisecond)
+	leadCtx, stop := runElector(e)
+	defer stop()
+
+	time.Sleep(100 * time.Millisecond)
+	if e.IsLeader() {
+		t.Fatal("elected while lease held by others")
+	}
+
+	server.FastForward(3 * time.Second)
+	waitFor(t, "takeover after lease expired", e.IsLeader)
+	waitFor(t, "lead started", func() bool { return leadCtx() != nil })
+	// 宕机节点的任期为 1，新任期的 fencing token 更大
+	if token := redis.GetFencingToken(leadCtx()); token != 2 {
+		t.Errorf("term fencing token %d, want 2", token)
+	}
+}
+
+func TestLeadCanceledOnRenewFailure(t *testing.T) {
+	server, rdb := testenv.NewRedis(t)
+	e := NewElector(testKey, rdb, 3, 20*time.Millisecond)
+	leadCtx, stop := runElector(e)
+	defer stop()
+	waitFor(t, "lead started", func() bool { return leadCtx() != nil })
+
+	// 续期失败后无法确认租约是否有效，取消当前任期
+	server.SetError("connection refused")
+	waitFor(t, "lead ctx canceled", func() bool { return leadCtx().Err() != nil })
+	if e.IsLeader() {
+		t.Error("still leader after renew failed")
+	}
+
+	// 恢复之后开始新的任期，上一个任期已经结束
+	canceled := leadCtx()
+	server.SetError("")
+	waitFor(t, "new term started", func() bool { return leadCtx() != canceled })
+	if !e.IsLeader() || leadCtx().Err() != nil {
+		t.Errorf("leader %v, lead ctx err %v after recovered, want leading", e.IsLeader(), leadCtx().Err())
+	}
+}
@@ -0,0 +1,125 @@
+package membership
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+	"timer/common/conf"
+	"timer/common/utils"
+	"timer/pkg/hash"
+	"timer/pkg/logger"
+	"timer/pkg/redis"
+)
+
+// Registry 调度节点的成员表，节点定期在 redis zset 中上报心跳
+// 分桶通过 rendezvous hashing 分配给存活的节点，节点增减时只有少量分桶会换主
+type Registry struct {
+	key       string
+	nodeID    string
+	client    *redis.Client
+	hasher    *hash.Murmur3Encyptor
+	heartbeat time.Duration
+	expire    time.Duration
+
+	mu    sync.RWMutex
+	nodes []string
+}
+
+func NewRegistry(client *redis.Client, hasher *hash.Murmur3Encyptor, config *conf.SchedulerAppConfig) *Registry {
+	return &Registry{
+		key:       utils.SchedulerNodesKey,
+		nodeID:    utils.GetCurrentNodeID(),
+		client:    client,
+		hasher:    hasher,
+		heartbeat: time.Duration(config.HeartbeatSeconds) * time.Second,
+		expire:    time.Duration(config.NodeExpireSeconds) * time.Second,
+	}
+}
+
+// Run 定期上报心跳并刷新存活节点列表，直到 ctx 结束；结束时主动下线
+func (r *Registry) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		if err := r.refresh(ctx); err != nil && ctx.Err() == nil {
+			logger.ErrorContextf(ctx, "refresh scheduler nodes failed, err: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			// 主动下线，其他节点无需等待心跳过期即可接管
+			_ = r.client.ZRem(context.Background(), r.key, r.nodeID)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Registry) refresh(ctx context.Context) error {
+	now := time.Now()
+	if err := r.client.ZAdd(ctx, r.key, now.UnixMilli(), r.nodeID); err != nil {
+		return err
+	}
+
+	deadline := now.Add(-r.expire).UnixMilli()
+	if err := r.client.ZRemRangeByScore(ctx, r.key, 0, deadline); err != nil {
+		return err
+	}
+
+	nodes, err := r.client.ZrangeByScore(ctx, r.key, deadline+1, math.MaxInt64)
+	if err != nil {
+		return err
+	}
+	sort.Strings(nodes)
+
+	r.mu.Lock()
+	r.nodes = nodes
+	r.mu.Unlock()
+	return nil
+}
+
+// NodeID 当前节点的标识
+func (r *Registry) NodeID() string {
+	return r.nodeID
+}
+
+// Nodes 最近一次刷新时存活的节点
+func (r *Registry) Nodes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.nodes
+}
+
+// IsAlive 节点在最近一次刷新时是否存活
+func (r *Registry) IsAlive(node string) bool {
+	nodes := r.Nodes()
+	idx := sort.SearchStrings(nodes, node)
+	return idx < len(nodes) && nodes[idx] == node
+}
+
+// HeartbeatExpired 直接读取 redis 判断节点的心跳是否已经过期，不依赖本地最近一次刷新的结果
+// 刚刚加入、本地还没有刷新到的节点不会被误判为下线
+func (r *Registry) HeartbeatExpired(ctx context.Context, node string) (bool, error) {
+	score, ok, err := r.client.ZScore(ctx, r.key, node)
+	if err != nil {
+		return false, err
+	}
+	return !ok || score < time.Now().Add(-r.expire).UnixMilli(), nil
+}
+
+// Owner 通过 rendezvous hashing 获取 resource 的负责节点，还没有存活节点时返回空字符串
+func (r *Registry) Owner(resource string) string {
+	var (
+		owner string
+		max   uint64
+	)
+	for _, node := range r.Nodes() {
+		if weight := r.hasher.Encrypt(node + "_" + resource); owner == "" || weight > max {
+			owner, max = node, weight
+		}
+	}
+	return owner
+}
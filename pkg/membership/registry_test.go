@@ -0,0 +1,133 @@
+package membership
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+	"timer/common/conf"
+	"timer/pkg/hash"
+	"timer/pkg/testenv"
+)
+
+const testBuckets = 1000
+
+// newStaticRegistry 存活节点固定为 nodes 的成员表，只用于计算分桶归属
+func newStaticRegistry(nodes ...string) *Registry {
+	nodes = append([]string(nil), nodes...)
+	sort.Strings(nodes)
+	return &Registry{hasher: hash.NewMurmur3Encryptor(), nodes: nodes}
+}
+
+func owners(r *Registry) []string {
+	res := make([]string, testBuckets)
+	for i := range res {
+		res[i] = r.Owner(fmt.Sprint(i))
+	}
+	return res
+}
+
+func TestOwnerStable(t *testing.T) {
+	if owner := newStaticRegistry().Owner("0"); owner != "" {
+		t.Errorf("owner %q without nodes, want empty", owner)
+	}
+
+	before := owners(newStaticRegistry("a", "b", "c"))
+	after := owners(newStaticRegistry("c", "a", "b"))
+	counts := make(map[string]int)
+	for i := range before {
+		if before[i] != after[i] {
+			t.Fatalf("bucket %d owned by %s then %s with the same nodes", i, before[i], after[i])
+		}
+		counts[before[i]]++
+	}
+	// 分桶大致均匀地分配给各节点
+	for _, node := range []string{"a", "b", "c"} {
+		if n := counts[node]; n < testBuckets/6 {
+			t.Errorf("node %s owns %d of %d buckets, want about 1/3", node, n, testBuckets)
+		}
+	}
+}
+
+func TestOwnerNodeJoin(t *testing.T) {
+	nodes := []string{"a", "b", "c", "d"}
+	before := owners(newStaticRegistry(nodes...))
+	after := owners(newStaticRegistry(append(nodes, "e")...))
+
+	// 新节点只接管部分分桶，其余分桶的负责节点不变
+	var moved int
+	for i := range before {
+		if before[i] == after[i] {
+			continue
+		}
+		if after[i] != "e" {
+			t.Fatalf("bucket %d moved from %s to %s, want only moves to the joined node", i, before[i], after[i])
+		}
+		moved++
+	}
+	if moved == 0 || moved > testBuckets*2/5 {
+		t.Errorf("%d of %d buckets moved, want about 1/5", moved, testBuckets)
+	}
+}
+
+func TestOwnerNodeLeave(t *testing.T) {
+	before := owners(newStaticRegistry("a", "b", "c", "d"))
+	after := owners(newStaticRegistry("a", "b", "d"))
+
+	// 只有离开的节点负责的分桶换主
+	for i := range before {
+		if before[i] != "c" && before[i] != after[i] {
+			t.Errorf("bucket %d moved from %s to %s, want only buckets of the left node moved", i, before[i], after[i])
+		}
+		if after[i] == "c" {
+			t.Fatalf("bucket %d still owned by the left node", i)
+		}
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	ctx := context.Background()
+	server, rdb := testenv.NewRedis(t)
+	config := &conf.SchedulerAppConfig{HeartbeatSeconds: 1, NodeExpireSeconds: 3}
+	a, b := NewRegistry(rdb, hash.NewMurmur3Encryptor(), config), NewRegistry(rdb, hash.NewMurmur3Encryptor(), config)
+	a.nodeID, b.nodeID = "a", "b"
+
+	// 心跳过期的节点会被清理
+	if _, err := server.ZAdd(a.key, float64(time.Now().Add(-time.Minute).UnixMilli()), "dead"); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []*Registry{a, b, a} {
+		if err := r.refresh(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if nodes := a.Nodes(); fmt.Sprint(nodes) != "[a b]" {
+		t.Errorf("alive nodes %v, want [a b]", nodes)
+	}
+	if !a.IsAlive("b") || a.IsAlive("dead") {
+		t.Errorf("alive b %v, dead %v, want true, false", a.IsAlive("b"), a.IsAlive("dead"))
+	}
+	if expired, err := a.HeartbeatExpired(ctx, "dead"); err != nil || !expired {
+		t.Errorf("dead heartbeat expired %v, err: %v, want true", expired, err)
+	}
+
+	// 下线后其他节点立即感知，不需要等待心跳过期
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Run(runCtx)
+	}()
+	cancel()
+	<-done
+	if expired, err := a.HeartbeatExpired(ctx, "b"); err != nil || !expired {
+		t.Errorf("left node heartbeat expired %v, err: %v, want true", expired, err)
+	}
+	if err := a.refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if nodes := a.Nodes(); fmt.Sprint(nodes) != "[a]" {
+		t.Errorf("alive nodes %v after b left, want [a]", nodes)
+	}
+}
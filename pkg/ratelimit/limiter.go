@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+	"timer/pkg/redis"
+)
+
+// Limiter 基于 redis 的分布式限流器，所有节点共享同一份配额
+type Limiter struct {
+	client *redis.Client
+}
+
+func NewLimiter(client *redis.Client) *Limiter {
+	return &Limiter{
+		client: client,
+	}
+}
+
+// TakeToken 从令牌桶 key 中获取一个令牌，桶每分钟补充 perMinute 个令牌，容量为 burst
+// 获取成功返回 0，否则返回需要等待的时间（此时没有消耗令牌）
+func (l *Limiter) TakeToken(ctx context.Context, key string, perMinute, burst int) (time.Duration, error) {
+	if perMinute <= 0 || burst <= 0 {
+		return 0, errors.New("rate limit per minute and burst must be positive")
+	}
+
+	rate := float64(perMinute) / float64(time.Minute/time.Millisecond)
+	reply, err := l.client.Eval(ctx, luaTakeToken, 1, []interface{}{key, rate, burst, time.Now().UnixMilli()})
+	if err != nil {
+		return 0, err
+	}
+
+	wait, _ := reply.(int64)
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+// AcquireSlot 在 key 上占用一个并发槽位，槽位在 lease 后自动过期，防止持有者宕机后槽位泄露
+func (l *Limiter) AcquireSlot(ctx context.Context, key, token string, limit int, lease time.Duration) (bool, error) {
+	if limit <= 0 {
+		return false, errors.New("concurrency limit must be positive")
+	}
+
+	reply, err := l.client.Eval(ctx, luaAcquireSlot, 1, []interface{}{key, limit, time.Now().UnixMilli(), token, lease.Milliseconds()})
+	if err != nil {
+		return false, err
+	}
+
+	ok, _ := reply.(int64)
+	return ok == 1, nil
+}
+
+// ReleaseSlot 释放 key 上 token 占用的并发槽位
+func (l *Limiter) ReleaseSlot(ctx context.Context, key, token string) error {
+	return l.client.ZRem(ctx, key, token)
+}
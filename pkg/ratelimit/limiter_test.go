@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+	"timer/pkg/testenv"
+)
+
+func TestTakeTokenRefills(t *testing.T) {
+	ctx := context.Background()
+	_, client := testenv.NewRedis(t)
+	limiter := NewLimiter(client)
+
+	// 每 10ms 补充一个令牌，容量为 2
+	for i := 0; i < 2; i++ {
+		if wait, err := limiter.TakeToken(ctx, "app", 6000, 2); err != nil || wait != 0 {
+			t.Fatalf("take token %d: wait %v, err: %v", i, wait, err)
+		}
+	}
+	wait, err := limiter.TakeToken(ctx, "app", 6000, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait <= 0 || wait > 10*time.Millisecond {
+		t.Fatalf("empty bucket: wait %v, want (0, 10ms]", wait)
+	}
+
+	// 没有拿到令牌时不消耗，等待返回的时间之后可以拿到
+	time.Sleep(wait)
+	if wait, err := limiter.TakeToken(ctx, "app", 6000, 2); err != nil || wait != 0 {
+		t.Errorf("take token after refill: wait %v, err: %v", wait, err)
+	}
+	// 补充的令牌不超过容量
+	time.Sleep(50 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		wait, err := limiter.TakeToken(ctx, "app", 6000, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := wait == 0; got != (i < 2) {
+			t.Errorf("take token %d after idle: wait %v", i, wait)
+		}
+	}
+}
+
+func TestAcquireSlotCapsConcurrency(t *testing.T) {
+	ctx := context.Background()
+	_, client := testenv.NewRedis(t)
+	limiter := NewLimiter(client)
+	lease := time.Minute
+
+	for _, token := range []string{"a", "b"} {
+		if ok, err := limiter.AcquireSlot(ctx, "app", token, 2, lease); err != nil || !ok {
+			t.Fatalf("acquire slot %s: %t, err: %v", token, ok, err)
+		}
+	}
+	if ok, err := limiter.AcquireSlot(ctx, "app", "c", 2, lease); err != nil || ok {
+		t.Fatalf("acquire slot over limit: %t, err: %v", ok, err)
+	}
+
+	if err := limiter.ReleaseSlot(ctx, "app", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := limiter.AcquireSlot(ctx, "app", "c", 2, lease); err != nil || !ok {
+		t.Fatalf("acquire released slot: %t, err: %v", ok, err)
+	}
+}
+
+func TestAcquireSlotReclaimsExpiredLease(t *testing.T) {
+	ctx := context.Background()
+	_, client := testenv.NewRedis(t)
+	limiter := NewLimiter(client)
+
+	// 持有者宕机没有释放的槽位在租约到期后回收
+	if ok, err := limiter.AcquireSlot(ctx, "app", "a", 1, 20*time.Millisecond); err != nil || !ok {
+		t.Fatalf("acquire slot: %t, err: %v", ok, err)
+	}
+	if ok, _ := limiter.AcquireSlot(ctx, "app", "b", 1, 20*time.Millisecond); ok {
+		t.Fatal("acquired slot over limit")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if ok, err := limiter.AcquireSlot(ctx, "app", "b", 1, 20*time.Millisecond); err != nil || !ok {
+		t.Fatalf("acquire slot after lease expired: %t, err: %v", ok, err)
+	}
+}
+
+func TestLimiterRedisFailure(t *testing.T) {
+	ctx := context.Background()
+	server, client := testenv.NewRedis(t)
+	limiter := NewLimiter(client)
+	server.Close()
+
+	if _, err := limiter.TakeToken(ctx, "app", 60, 60); err == nil {
+		t.Error("take token without redis succeeded")
+	}
+	if _, err := limiter.AcquireSlot(ctx, "app", "a", 1, time.Minute); err == nil {
+		t.Error("acquire slot without redis succeeded")
+	}
+}
@@ -0,0 +1,44 @@
+package ratelimit
+
+// luaTakeToken 令牌桶：按时间差补充令牌，足够则消耗一个并返回 0，否则不消耗并返回需要等待的毫秒数
+// KEYS[1] 桶 key；ARGV[1] 每毫秒补充的令牌数；ARGV[2] 桶容量；ARGV[3] 当前时间戳（毫秒）
+const luaTakeToken = `
+  local key = KEYS[1]
+  local rate = tonumber(ARGV[1])
+  local burst = tonumber(ARGV[2])
+  local now = tonumber(ARGV[3])
+  local data = redis.call('hmget', key, 'tokens', 'ts')
+  local tokens = tonumber(data[1]) or burst
+  local ts = tonumber(data[2]) or now
+  if now > ts then
+    tokens = math.min(burst, tokens + (now - ts) * rate)
+  else
+    now = ts
+  end
+  local wait = 0
+  if tokens >= 1 then
+    tokens = tokens - 1
+  else
+    wait = math.ceil((1 - tokens) / rate)
+  end
+  redis.call('hmset', key, 'tokens', tostring(tokens), 'ts', now)
+  redis.call('pexpire', key, math.ceil(burst / rate) + 1000)
+  return wait
+`
+
+// luaAcquireSlot 并发槽位：先清理租约到期的槽位，未满则占用一个槽位返回 1，否则返回 0
+// KEYS[1] 槽位 zset key；ARGV[1] 并发上限；ARGV[2] 当前时间戳（毫秒）；ARGV[3] 槽位 token；ARGV[4] 租约时长（毫秒）
+const luaAcquireSlot = `
+  local key = KEYS[1]
+  local limit = tonumber(ARGV[1])
+  local now = tonumber(ARGV[2])
+  local token = ARGV[3]
+  local lease = tonumber(ARGV[4])
+  redis.call('zremrangebyscore', key, '-inf', now - lease)
+  if redis.call('zcard', key) >= limit then
+    return 0
+  end
+  redis.call('zadd', key, now, token)
+  redis.call('pexpire', key, lease)
+  return 1
+`
@@ -4,23 +4,46 @@ import (
 	"context"
 	"errors"
 	"github.com/gomodule/redigo/redis"
+	"strconv"
+	"strings"
+	"time"
 	"timer/common/utils"
+	"timer/pkg/logger"
 )
 
-const ftimerLockKeyPrefix = "FTIMER_LOCK_PREFIX_"
+const (
+	ftimerLockKeyPrefix    = "FTIMER_LOCK_PREFIX_"
+	ftimerFencingKeyPrefix = "FTIMER_LOCK_FENCING_"
+	// fencing token 计数器的保留时间，锁 key 按时间分片，一天之后不会再被使用
+	fencingKeyExpire = 24 * time.Hour
+)
+
+var (
+	ErrLockAcquiredByOthers = errors.New("lock is acquired by others")
+	ErrLockNotHeld          = errors.New("can not operate lock without ownership of lock")
+)
 
 type DistributeLocker interface {
-	Lock(context.Context, int64) error
+	// Lock 加锁，锁已经由自己持有时为重入
+	Lock(ctx context.Context, expireSeconds int64) error
+	// Unlock 释放锁，只有持有者可以释放
+	Unlock(ctx context.Context) error
+	// ExpireLock 重新设置锁的过期时间，只有持有者可以设置
 	ExpireLock(ctx context.Context, expireSeconds int64) error
+	// Watch 启动看门狗，在调用返回的 stop 或 ctx 结束之前定期续期
+	Watch(ctx context.Context, expireSeconds int64) (stop func())
+	// FencingToken 加锁成功后获得的 fencing token，同一个锁 key 的 token 单调递增
+	FencingToken() int64
 }
 
 // DistributeLock 分布式锁
 type DistributeLock struct {
 	key string
-	// 线程ID_协程ID，防止错误删锁。
+	// 主机名_进程ID_协程ID，防止错误删锁。
 	// 存 redis 就是 value
-	token  string
-	client *Client
+	token        string
+	fencingToken int64
+	client       *Client
 }
 
 func NewReentrantDistributeLock(key string, client *Client) *DistributeLock {
@@ -31,53 +54,239 @@ func NewReentrantDistributeLock(key string, client *Client) *DistributeLock {
 	}
 }
 
-// Lock 加锁.
+// Lock 加锁，基于 lua 脚本保证 SET NX PX 与 fencing token 自增的原子性.
 func (r *DistributeLock) Lock(ctx context.Context, expireSeconds int64) error {
-	// key:app,value:线程id+协程id，Get 获取，有返回，没有返回nil
-	res, err := r.client.Get(ctx, r.key)
-	if err != nil && !errors.Is(err, redis.ErrNil) {
+	keysAndArgs := []interface{}{r.getLockKey(), r.getFencingKey(), r.token,
+		expireSeconds * int64(time.Second/time.Millisecond), int64(fencingKeyExpire / time.Millisecond)}
+	reply, err := r.client.Eval(ctx, LuaAcquireDistributionLock, 2, keysAndArgs)
+	if err != nil {
 		return err
 	}
 
-	// 锁已存在，并且是自己的，此时是重入
-	if res == r.token {
-		return nil
+	// 0 代表锁被别人持有
+	fencingToken, _ := reply.(int64)
+	if fencingToken <= 0 {
+		return ErrLockAcquiredByOthers
 	}
 
-	// 创建分布式锁，setnx 如果没有则创建，有则不干任何事情
-	reply, err := r.client.SetNX(ctx, r.getLockKey(), r.token, expireSeconds)
+	r.fencingToken = fencingToken
+	return nil
+}
+
+// Unlock 释放锁，基于 lua 脚本校验归属权，避免删除别人的锁
+func (r *DistributeLock) Unlock(ctx context.Context) error {
+	reply, err := r.client.Eval(ctx, LuaReleaseDistributionLock, 1, []interface{}{r.getLockKey(), r.token})
 	if err != nil {
 		return err
 	}
 
-	re, _ := reply.(int64)
-	// 1 设置成功，0 没有设置成功（别人已经设置成功）
-	if re != 1 {
-		return errors.New("lock is acquired by others")
+	if ret, _ := reply.(int64); ret != 1 {
+		return ErrLockNotHeld
 	}
-
 	return nil
 }
 
 // ExpireLock 更新锁的过期时间，基于 lua 脚本实现操作原子性
 func (r *DistributeLock) ExpireLock(ctx context.Context, expireSeconds int64) error {
-	keysAndArgs := []interface{}{r.getLockKey(), r.token, expireSeconds}
+	keysAndArgs := []interface{}{r.getLockKey(), r.token, expireSeconds * int64(time.Second/time.Millisecond)}
 	reply, err := r.client.Eval(ctx, LuaCheckAndExpireDistributionLock, 1, keysAndArgs)
 	if err != nil {
 		return err
 	}
 
 	if ret, _ := reply.(int64); ret != 1 {
-		return errors.New("can not expire lock without ownership of lock")
+		return ErrLockNotHeld
 	}
 
 	return nil
 }
 
+// Watch 每过 1/3 的过期时间续期一次，续期失败说明锁已经丢失，停止续期
+func (r *DistributeLock) Watch(ctx context.Context, expireSeconds int64) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		interval := time.Duration(expireSeconds) * time.Second / 3
+		if interval <= 0 {
+			interval = time.Second
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := r.ExpireLock(ctx, expireSeconds); err != nil {
+				if ctx.Err() == nil {
+					logger.ErrorContextf(ctx, "watchdog renew lock failed, key: %s, err: %v", r.key, err)
+				}
+				return
+			}
+		}
+	}()
+
+	// stop 返回时续期协程已经退出，之后对锁的操作不会与续期交错
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (r *DistributeLock) FencingToken() int64 {
+	return r.fencingToken
+}
+
+// getLockKey 锁 key 与 fencing token 计数器 key 需要在同一个 slot，没有 hash tag 的 key 整体作为 hash tag
 func (r *DistributeLock) getLockKey() string {
-	return ftimerLockKeyPrefix + r.key
+	return ftimerLockKeyPrefix + hashTagged(r.key)
+}
+
+func (r *DistributeLock) getFencingKey() string {
+	return ftimerFencingKeyPrefix + hashTagged(r.key)
+}
+
+func hashTagged(key string) string {
+	if start := strings.Index(key, "{"); start >= 0 {
+		if end := strings.Index(key[start+1:], "}"); end > 0 {
+			return key
+		}
+	}
+	return "{" + key + "}"
 }
 
 func (c *Client) GetDistributionLock(key string) DistributeLocker {
 	return NewReentrantDistributeLock(key, c)
 }
+
+// GetDistributionLockHolder 获取锁当前持有者的 token，锁不存在时返回空字符串
+func (c *Client) GetDistributionLockHolder(ctx context.Context, key string) (string, error) {
+	token, err := c.Get(ctx, ftimerLockKeyPrefix+hashTagged(key))
+	if errors.Is(err, redis.ErrNil) {
+		return "", nil
+	}
+	return token, err
+}
+
+// ReleaseDistributionLockOf 释放由 token 持有的锁，用于接管已经失联的节点持有的锁
+// 只有锁仍由 token 持有时才会释放，锁已经易主时返回 ErrLockNotHeld
+func (c *Client) ReleaseDistributionLockOf(ctx context.Context, key, token string) error {
+	reply, err := c.Eval(ctx, LuaReleaseDistributionLock, 1, []interface{}{ftimerLockKeyPrefix + hashTagged(key), token})
+	if err != nil {
+		return err
+	}
+
+	if ret, _ := reply.(int64); ret != 1 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// ListFencingTokens 列出锁 key 匹配 pattern 的 fencing token 计数器，返回锁 key 到已经发放的最大 token 的映射
+func (c *Client) ListFencingTokens(ctx context.Context, pattern string) (map[string]int64, error) {
+	keys, err := c.Scan(ctx, ftimerFencingKeyPrefix+pattern, 1000)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make(map[string]int64, len(keys))
+	for _, key := range keys {
+		value, err := c.Get(ctx, key)
+		if err != nil {
+			// 扫描和读取之间计数器可能已经过期
+			if errors.Is(err, redis.ErrNil) {
+				continue
+			}
+			return nil, err
+		}
+		token, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		tokens[strings.TrimPrefix(key, ftimerFencingKeyPrefix)] = token
+	}
+	return tokens, nil
+}
+
+// SeedFencingToken 保证锁 key 的 fencing token 计数器不小于 token
+// 锁 key 改名时用旧锁的计数器初始化新锁，新锁发放的 token 大于旧锁已经发放过的 token，已经写入的数据不会拒绝新的持有者
+func (c *Client) SeedFencingToken(ctx context.Context, key string, token int64) error {
+	fencingKey := ftimerFencingKeyPrefix + hashTagged(key)
+	if _, err := c.SetIfGreater(ctx, fencingKey, token); err != nil {
+		return err
+	}
+	return c.Expire(ctx, fencingKey, int64(fencingKeyExpire/time.Second))
+}
+
+type fencingTokenCtxKey struct{}
+
+// WithFencingToken 将持有的锁的 fencing token 传递给下游，下游写入数据时携带该 token
+func WithFencingToken(ctx context.Context, fencingToken int64) context.Context {
+	return context.WithValue(ctx, fencingTokenCtxKey{}, fencingToken)
+}
+
+// GetFencingToken 获取 ctx 中的 fencing token，没有则返回 0
+func GetFencingToken(ctx context.Context) int64 {
+	fencingToken, _ := ctx.Value(fencingTokenCtxKey{}).(int64)
+	return fencingToken
+}
+
+// LockInfo 分布式锁的持有情况
+type LockInfo struct {
+	Key      string
+	Token    string
+	ExpireAt time.Time
+}
+
+// ListDistributionLocks 列出 key 匹配 pattern 的全部分布式锁及其持有者、过期时间
+func (c *Client) ListDistributionLocks(ctx context.Context, pattern string) ([]*LockInfo, error) {
+	keys, err := c.Scan(ctx, ftimerLockKeyPrefix+pattern, 1000)
+	if err != nil {
+		return nil, err
+	}
+	// 没有 hash tag 的 key 加锁时整体作为 hash tag
+	taggedKeys, err := c.Scan(ctx, ftimerLockKeyPrefix+"{"+pattern, 1000)
+	if err != nil {
+		return nil, err
+	}
+	keys = append(keys, taggedKeys...)
+
+	now := time.Now()
+	locks := make([]*LockInfo, 0, len(keys))
+	for _, key := range keys {
+		token, err := c.Get(ctx, key)
+		if err != nil {
+			// 扫描和读取之间锁可能已经过期
+			if errors.Is(err, redis.ErrNil) {
+				continue
+			}
+			return nil, err
+		}
+
+		ttl, err := c.PTTL(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+
+		// 去掉前缀以及加锁时补充的 hash tag
+		lockKey := strings.TrimPrefix(key, ftimerLockKeyPrefix)
+		if strings.HasPrefix(lockKey, "{") && strings.HasSuffix(lockKey, "}") {
+			lockKey = lockKey[1 : len(lockKey)-1]
+		}
+		lock := LockInfo{
+			Key:   lockKey,
+			Token: token,
+		}
+		// ttl < 0 代表没有设置过期时间（或已经被删除），此时 ExpireAt 为零值
+		if ttl >= 0 {
+			lock.ExpireAt = now.Add(time.Duration(ttl) * time.Millisecond)
+		}
+		locks = append(locks, &lock)
+	}
+	return locks, nil
+}
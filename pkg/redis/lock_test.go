@@ -0,0 +1,192 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"timer/common/conf"
+)
+
+// newTestClient 启动一个内存 redis 并返回连接它的客户端，testenv 依赖本包，这里不能复用
+func newTestClient(t *testing.T) (*miniredis.Miniredis, *Client) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	config := *conf.GetDefaultRedisConfig()
+	config.Mode = conf.RedisModeSingle
+	config.Network = "tcp"
+	config.Address = server.Addr()
+	config.Password = ""
+	return server, GetClient(&config)
+}
+
+// newTestLock 指定持有者 token，模拟不同节点上的锁
+func newTestLock(client *Client, key, token string) *DistributeLock {
+	return &DistributeLock{key: key, token: token, client: client}
+}
+
+func TestLockAndUnlock(t *testing.T) {
+	ctx := context.Background()
+	server, client := newTestClient(t)
+	a, b := newTestLock(client, "slice", "a"), newTestLock(client, "slice", "b")
+
+	if err := a.Lock(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := server.TTL(a.getLockKey()); ttl != 10*time.Second {
+		t.Errorf("lock ttl %v, want 10s", ttl)
+	}
+	if err := b.Lock(ctx, 10); !errors.Is(err, ErrLockAcquiredByOthers) {
+		t.Errorf("lock held by others: %v, want %v", err, ErrLockAcquiredByOthers)
+	}
+	// 只有持有者可以续期、释放
+	if err := b.ExpireLock(ctx, 10); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("expire lock held by others: %v, want %v", err, ErrLockNotHeld)
+	}
+	if err := b.Unlock(ctx); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("unlock lock held by others: %v, want %v", err, ErrLockNotHeld)
+	}
+	if holder, _ := client.GetDistributionLockHolder(ctx, "slice"); holder != "a" {
+		t.Errorf("lock holder %q after foreign unlock, want a", holder)
+	}
+
+	if err := a.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Unlock(ctx); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("unlock released lock: %v, want %v", err, ErrLockNotHeld)
+	}
+	if err := b.Lock(ctx, 10); err != nil {
+		t.Errorf("lock released lock: %v", err)
+	}
+}
+
+func TestLockReentry(t *testing.T) {
+	ctx := context.Background()
+	server, client := newTestClient(t)
+	lock := newTestLock(client, "slice", "a")
+
+	if err := lock.Lock(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+	first := lock.FencingToken()
+
+	// 重入刷新过期时间，token 不变
+	server.FastForward(5 * time.Second)
+	if err := lock.Lock(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := server.TTL(lock.getLockKey()); ttl != 10*time.Second {
+		t.Errorf("lock ttl %v after reentry, want 10s", ttl)
+	}
+	if lock.FencingToken() != first {
+		t.Errorf("fencing token %d after reentry, want %d", lock.FencingToken(), first)
+	}
+
+	// 计数器过期后重入，重新发放 token，不能把持有者自己当作他人
+	server.Del(lock.getFencingKey())
+	if err := lock.Lock(ctx, 10); err != nil {
+		t.Fatalf("reenter after fencing counter expired: %v", err)
+	}
+	if lock.FencingToken() <= 0 {
+		t.Errorf("fencing token %d after counter expired, want > 0", lock.FencingToken())
+	}
+	if ttl := server.TTL(lock.getFencingKey()); ttl != fencingKeyExpire {
+		t.Errorf("fencing counter ttl %v, want %v", ttl, fencingKeyExpire)
+	}
+}
+
+func TestFencingTokenIncreases(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestClient(t)
+	a, b := newTestLock(client, "slice", "a"), newTestLock(client, "slice", "b")
+
+	var last int64
+	for i, lock := range []*DistributeLock{a, b, a} {
+		if err := lock.Lock(ctx, 10); err != nil {
+			t.Fatal(err)
+		}
+		if lock.FencingToken() <= last {
+			t.Errorf("holder %d got fencing token %d, want > %d", i, lock.FencingToken(), last)
+		}
+		last = lock.FencingToken()
+		if err := lock.Unlock(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWatchRenewsLock(t *testing.T) {
+	ctx := context.Background()
+	server, client := newTestClient(t)
+	lock := newTestLock(client, "slice", "a")
+	if err := lock.Lock(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// 每 1s 续期一次，续期后过期时间恢复为 3s
+	stop := lock.Watch(ctx, 3)
+	server.FastForward(2 * time.Second)
+	time.Sleep(1500 * time.Millisecond)
+	if ttl := server.TTL(lock.getLockKey()); ttl != 3*time.Second {
+		t.Errorf("lock ttl %v after renewal, want 3s", ttl)
+	}
+
+	// stop 之后不再续期
+	stop()
+	server.FastForward(2 * time.Second)
+	time.Sleep(1500 * time.Millisecond)
+	if ttl := server.TTL(lock.getLockKey()); ttl != time.Second {
+		t.Errorf("lock ttl %v after stop, want 1s", ttl)
+	}
+}
+
+func TestWatchStopsWhenLockLost(t *testing.T) {
+	ctx := context.Background()
+	server, client := newTestClient(t)
+	lock := newTestLock(client, "slice", "a")
+	if err := lock.Lock(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// 锁被他人持有后续期失败，看门狗不能把他人的锁续期
+	stop := lock.Watch(ctx, 3)
+	server.Del(lock.getLockKey())
+	if err := newTestLock(client, "slice", "b").Lock(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+	server.FastForward(2 * time.Second)
+	time.Sleep(1500 * time.Millisecond)
+	stop()
+	if ttl := server.TTL(lock.getLockKey()); ttl != time.Second {
+		t.Errorf("lock of others ttl %v, want 1s", ttl)
+	}
+}
+
+func TestSetNXExpires(t *testing.T) {
+	ctx := context.Background()
+	server, client := newTestClient(t)
+
+	reply, err := client.SetNX(ctx, "marker", "1", 130)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != int64(1) {
+		t.Errorf("first SetNX reply %v, want 1", reply)
+	}
+	if ttl := server.TTL("marker"); ttl != 130*time.Second {
+		t.Errorf("marker ttl %v, want 130s", ttl)
+	}
+
+	if reply, err = client.SetNX(ctx, "marker", "2", 130); err != nil {
+		t.Fatal(err)
+	}
+	if reply != int64(0) {
+		t.Errorf("second SetNX reply %v, want 0", reply)
+	}
+	if value, _ := server.Get("marker"); value != "1" {
+		t.Errorf("marker value %q, want 1", value)
+	}
+}
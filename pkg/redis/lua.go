@@ -1,6 +1,33 @@
 package redis
 
+// LuaAcquireDistributionLock 原子地加锁并发放 fencing token
+// 锁由自己持有时视为重入，刷新过期时间并返回当前的 token，计数器已经过期时重新自增；被他人持有返回 0；否则 SET NX PX 加锁并自增 token
+// KEYS[1] 锁 key；KEYS[2] fencing token 计数器 key；ARGV[1] 持有者 token；ARGV[2] 过期时间（毫秒）；ARGV[3] 计数器过期时间（毫秒）
+const LuaAcquireDistributionLock = `
+  local lockerKey = KEYS[1]
+  local fencingKey = KEYS[2]
+  local token = ARGV[1]
+  local ttl = ARGV[2]
+  local getToken = redis.call('get', lockerKey)
+  if getToken == token then
+    redis.call('pexpire', lockerKey, ttl)
+    local current = redis.call('get', fencingKey)
+    if not current then
+      current = redis.call('incr', fencingKey)
+    end
+    redis.call('pexpire', fencingKey, ARGV[3])
+    return tonumber(current)
+  end
+  if not redis.call('set', lockerKey, token, 'NX', 'PX', ttl) then
+    return 0
+  end
+  local fencing = redis.call('incr', fencingKey)
+  redis.call('pexpire', fencingKey, ARGV[3])
+  return fencing
+`
+
 // LuaCheckAndExpireDistributionLock 判断是否拥有分布式锁的归属权，是则则重新设置过期时间
+// KEYS[1] 锁 key；ARGV[1] 持有者 token；ARGV[2] 过期时间（毫秒），与加锁时的 PX 单位一致
 const LuaCheckAndExpireDistributionLock = `
   local lockerKey = KEYS[1]
   local targetToken = ARGV[1]
@@ -9,6 +36,65 @@ const LuaCheckAndExpireDistributionLock = `
   if (not getToken or getToken ~= targetToken) then
     return 0
 	else
-		return redis.call('expire',lockerKey,duration)
+		return redis.call('pexpire',lockerKey,duration)
+  end
+`
+
+// LuaReleaseDistributionLock 判断是否拥有分布式锁的归属权，是则删除锁
+const LuaReleaseDistributionLock = `
+  local lockerKey = KEYS[1]
+  local targetToken = ARGV[1]
+  if redis.call('get', lockerKey) == targetToken then
+    return redis.call('del', lockerKey)
+  end
+  return 0
+`
+
+// LuaSetIfGreater 只有新值大于当前值时才写入，保证 key 中保存的数值单调递增
+// KEYS[1] key；ARGV[1] 新值
+const LuaSetIfGreater = `
+  local cur = tonumber(redis.call('get', KEYS[1]) or '0')
+  if tonumber(ARGV[1]) > cur then
+    redis.call('set', KEYS[1], ARGV[1])
+    return 1
+  end
+  return 0
+`
+
+// LuaPopDueMembers 延迟队列的弹出：处理中集合里可见性超时的 member 先重新入队，
+// 再把队列中 score 不超过 ARGV[2] 的 member 移入处理中集合，score 为可见性超时的时间
+// KEYS[1] 队列 zset；KEYS[2] 处理中 zset；ARGV[1] 当前时间（毫秒）；ARGV[2] 到期时间上限（毫秒）；
+// ARGV[3] 可见性超时的时间（毫秒）；ARGV[4] 最多弹出的数量；ARGV[5] 处理中 zset 的过期时间（毫秒）
+// 返回 {下一个到期的 score（没有则为 -1），队列与处理中的 member 总数，弹出的 member}
+const LuaPopDueMembers = `
+  local queue = KEYS[1]
+  local processing = KEYS[2]
+  local now = ARGV[1]
+  local expired = redis.call('zrangebyscore', processing, '-inf', now)
+  for _, member in ipairs(expired) do
+    redis.call('zrem', processing, member)
+    redis.call('zadd', queue, now, member)
+  end
+  if #expired > 0 then
+    redis.call('pexpire', queue, ARGV[5])
+  end
+  local members = redis.call('zrangebyscore', queue, '-inf', ARGV[2], 'LIMIT', 0, ARGV[4])
+  for _, member in ipairs(members) do
+    redis.call('zrem', queue, member)
+    redis.call('zadd', processing, ARGV[3], member)
+  end
+  if #members > 0 then
+    redis.call('pexpire', processing, ARGV[5])
+  end
+  local nextScore = -1
+  local head = redis.call('zrange', queue, 0, 0, 'WITHSCORES')
+  if #head > 0 then
+    nextScore = tonumber(head[2])
+  end
+  head = redis.call('zrange', processing, 0, 0, 'WITHSCORES')
+  if #head > 0 and (nextScore < 0 or tonumber(head[2]) < nextScore) then
+    nextScore = tonumber(head[2])
   end
+  local pending = redis.call('zcard', queue) + redis.call('zcard', processing)
+  return {nextScore, pending, members}
 `
@@ -0,0 +1,186 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"timer/common/conf"
+	"timer/pkg/logger"
+
+	"github.com/FZambia/sentinel"
+	"github.com/gomodule/redigo/redis"
+	"github.com/mna/redisc"
+)
+
+const (
+	// 集群模式下遇到 MOVED、ASK、TRYAGAIN 时的最大重试次数
+	clusterMaxAttempts  = 3
+	clusterTryAgainWait = 100 * time.Millisecond
+)
+
+// connPool 屏蔽单机、哨兵、集群三种部署方式下连接的获取
+type connPool interface {
+	// GetContext 获取可以执行 keys 相关命令的连接，集群模式下 keys 必须在同一个 slot，连接只支持 Do
+	GetContext(ctx context.Context, keys ...string) (redis.Conn, error)
+	// GetPipelineContext 获取固定在 keys 所在节点上的连接，用于 MULTI、pipeline
+	GetPipelineContext(ctx context.Context, keys ...string) (redis.Conn, error)
+	// EachNode 在每个主节点上执行 fn，用于 SCAN 这类与 key 无关的命令
+	EachNode(ctx context.Context, fn func(conn redis.Conn) error) error
+	// Slot key 所在的 slot，非集群模式下全部返回 0
+	Slot(key string) int
+}
+
+func newConnPool(config *conf.RedisConfig) connPool {
+	switch config.Mode {
+	case conf.RedisModeCluster:
+		return newClusterPool(config)
+	case conf.RedisModeSentinel:
+		return &nodePool{pool: getSentinelPool(config)}
+	default:
+		return &nodePool{pool: getRedisPool(config)}
+	}
+}
+
+// nodePool 单机或哨兵模式，全部 key 都在同一个节点上
+type nodePool struct {
+	pool *redis.Pool
+}
+
+func (p *nodePool) GetContext(ctx context.Context, _ ...string) (redis.Conn, error) {
+	return p.pool.GetContext(ctx)
+}
+
+func (p *nodePool) GetPipelineContext(ctx context.Context, _ ...string) (redis.Conn, error) {
+	return p.pool.GetContext(ctx)
+}
+
+func (p *nodePool) EachNode(ctx context.Context, fn func(conn redis.Conn) error) error {
+	conn, err := p.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return fn(conn)
+}
+
+func (p *nodePool) Slot(_ string) int {
+	return 0
+}
+
+// getSentinelPool 通过哨兵获取当前的主节点地址，主从切换后借出连接时校验角色，淘汰指向旧主节点的连接
+func getSentinelPool(config *conf.RedisConfig) *redis.Pool {
+	sntnl := &sentinel.Sentinel{
+		Addrs:      config.Addresses,
+		MasterName: config.MasterName,
+		Dial: func(addr string) (redis.Conn, error) {
+			return redis.Dial(config.Network, addr,
+				redis.DialPassword(config.SentinelPassword),
+				redis.DialConnectTimeout(time.Second),
+				redis.DialReadTimeout(time.Second),
+				redis.DialWriteTimeout(time.Second),
+			)
+		},
+	}
+
+	return &redis.Pool{
+		MaxIdle:     config.MaxIdle,
+		IdleTimeout: time.Duration(config.IdleTimeoutSeconds) * time.Second,
+		MaxActive:   config.MaxActive,
+		Wait:        config.Wait,
+		Dial: func() (redis.Conn, error) {
+			masterAddr, err := sntnl.MasterAddr()
+			if err != nil {
+				logger.Errorf("Failed to get redis master from sentinel, cased by %s", err)
+				return nil, err
+			}
+			conn, err := redis.Dial(config.Network, masterAddr, redis.DialPassword(config.Password))
+			if err != nil {
+				logger.Errorf("Failed to connect to redis, cased by %s", err)
+				return nil, err
+			}
+			return conn, nil
+		},
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			if !sentinel.TestRole(c, "master") {
+				return errors.New("redis role check failed, not master")
+			}
+			return nil
+		},
+	}
+}
+
+// clusterPool 集群模式，按 key 的 slot 路由到对应的节点
+type clusterPool struct {
+	cluster *redisc.Cluster
+}
+
+func newClusterPool(config *conf.RedisConfig) *clusterPool {
+	cluster := &redisc.Cluster{
+		StartupNodes: config.Addresses,
+		DialOptions:  []redis.DialOption{redis.DialPassword(config.Password)},
+		CreatePool: func(address string, options ...redis.DialOption) (*redis.Pool, error) {
+			return &redis.Pool{
+				MaxIdle:     config.MaxIdle,
+				IdleTimeout: time.Duration(config.IdleTimeoutSeconds) * time.Second,
+				MaxActive:   config.MaxActive,
+				Wait:        config.Wait,
+				Dial: func() (redis.Conn, error) {
+					return redis.Dial(config.Network, address, options...)
+				},
+			}, nil
+		},
+		BgError: func(src redisc.BgErrorSrc, err error) {
+			logger.Errorf("redis cluster background error, src: %d, err: %v", src, err)
+		},
+	}
+
+	// 启动时获取一次集群拓扑，失败也不影响，第一次执行命令时会再次获取
+	if err := cluster.Refresh(); err != nil {
+		logger.Errorf("Failed to refresh redis cluster slots, cased by %s", err)
+	}
+	return &clusterPool{cluster: cluster}
+}
+
+func (p *clusterPool) GetContext(ctx context.Context, keys ...string) (redis.Conn, error) {
+	conn, err := p.GetPipelineContext(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	retryConn, err := redisc.RetryConn(conn, clusterMaxAttempts, clusterTryAgainWait)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return retryConn, nil
+}
+
+func (p *clusterPool) GetPipelineContext(ctx context.Context, keys ...string) (redis.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	conn := p.cluster.Get()
+	if len(keys) == 0 {
+		return conn, nil
+	}
+	if err := redisc.BindConn(conn, keys...); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("bind redis cluster conn failed, keys: %v, err: %w", keys, err)
+	}
+	return conn, nil
+}
+
+func (p *clusterPool) EachNode(ctx context.Context, fn func(conn redis.Conn) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return p.cluster.EachNode(false, func(_ string, conn redis.Conn) error {
+		return fn(conn)
+	})
+}
+
+func (p *clusterPool) Slot(key string) int {
+	return redisc.Slot(key)
+}
@@ -3,19 +3,23 @@ package redis
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/gomodule/redigo/redis"
 	"time"
 	"timer/common/conf"
 	"timer/pkg/logger"
 )
 
+// ErrNil key 不存在
+var ErrNil = redis.ErrNil
+
 type Client struct {
-	pool *redis.Pool
+	pool connPool
 }
 
 func GetClient(config *conf.RedisConfig) *Client {
 	return &Client{
-		pool: getRedisPool(config),
+		pool: newConnPool(config),
 	}
 }
 
@@ -51,13 +55,14 @@ func newRedisConn(config *conf.RedisConfig) (redis.Conn, error) {
 	return conn, nil
 }
 
-func (c *Client) GetConn(ctx context.Context) (redis.Conn, error) {
-	return c.pool.GetContext(ctx)
+// GetConn 获取执行 keys 相关命令的连接，集群模式下 keys 必须在同一个 slot
+func (c *Client) GetConn(ctx context.Context, keys ...string) (redis.Conn, error) {
+	return c.pool.GetContext(ctx, keys...)
 }
 
 // Get 执行 Redis GET 命令.
 func (c *Client) Get(ctx context.Context, key string) (string, error) {
-	conn, err := c.pool.GetContext(ctx)
+	conn, err := c.pool.GetContext(ctx, key)
 	if err != nil {
 		return "", err
 	}
@@ -65,34 +70,32 @@ func (c *Client) Get(ctx context.Context, key string) (string, error) {
 	return redis.String(conn.Do("GET", key))
 }
 
+// SetNX 执行 SET key value NX EX，写入与设置过期时间是同一个命令，不会留下没有过期时间的 key
+// key 被设置返回 1，已经存在返回 0
 func (c *Client) SetNX(ctx context.Context, key, value string, expireSeconds int64) (interface{}, error) {
 	if key == "" || value == "" {
 		return -1, errors.New("redis SET keyNX or value can't be empty")
 	}
 
-	conn, err := c.pool.GetContext(ctx)
+	conn, err := c.pool.GetContext(ctx, key)
 	if err != nil {
 		return -1, err
 	}
 	defer conn.Close()
 
-	// key 被设置，返回 1，否则返回 0
-	reply, err := conn.Do("SETNX", key, value)
+	// key 已经存在时返回 nil
+	reply, err := conn.Do("SET", key, value, "NX", "EX", expireSeconds)
 	if err != nil {
 		return -1, err
 	}
-
-	r, _ := reply.(int64)
-	if r == 1 {
-		// 设置过期时间
-		_, _ = conn.Do("EXPIRE", key, expireSeconds)
+	if reply == nil {
+		return int64(0), nil
 	}
-
-	return reply, nil
+	return int64(1), nil
 }
 
 func (c *Client) ZrangeByScore(ctx context.Context, table string, score1, score2 int64) ([]string, error) {
-	conn, err := c.pool.GetContext(ctx)
+	conn, err := c.pool.GetContext(ctx, table)
 	if err != nil {
 		return nil, err
 	}
@@ -116,7 +119,7 @@ func (c *Client) ZrangeByScore(ctx context.Context, table string, score1, score2
 
 // ZAdd 执行Redis ZAdd 命令.
 func (c *Client) ZAdd(ctx context.Context, table string, score int64, value interface{}) error {
-	conn, err := c.pool.GetContext(ctx)
+	conn, err := c.pool.GetContext(ctx, table)
 	if err != nil {
 		return err
 	}
@@ -126,8 +129,70 @@ func (c *Client) ZAdd(ctx context.Context, table string, score int64, value inte
 	return err
 }
 
+// ZScore 执行 Redis ZSCORE 命令，member 不存在时 ok 为 false.
+func (c *Client) ZScore(ctx context.Context, table, member string) (score int64, ok bool, err error) {
+	conn, err := c.pool.GetContext(ctx, table)
+	if err != nil {
+		return 0, false, err
+	}
+	defer conn.Close()
+
+	score, err = redis.Int64(conn.Do("ZSCORE", table, member))
+	if errors.Is(err, redis.ErrNil) {
+		return 0, false, nil
+	}
+	return score, err == nil, err
+}
+
+// ZRemRangeByScore 执行 Redis ZREMRANGEBYSCORE 命令.
+func (c *Client) ZRemRangeByScore(ctx context.Context, table string, score1, score2 int64) error {
+	conn, err := c.pool.GetContext(ctx, table)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("ZREMRANGEBYSCORE", table, score1, score2)
+	return err
+}
+
+// ZRangeWithScores 获取 zset 的全部 member 及其 score.
+func (c *Client) ZRangeWithScores(ctx context.Context, table string) (map[string]int64, error) {
+	conn, err := c.pool.GetContext(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return redis.Int64Map(conn.Do("ZRANGE", table, 0, -1, "WITHSCORES"))
+}
+
+// ZRem 执行 Redis ZREM 命令.
+func (c *Client) ZRem(ctx context.Context, table string, members ...interface{}) error {
+	conn, err := c.pool.GetContext(ctx, table)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("ZREM", append([]interface{}{table}, members...)...)
+	return err
+}
+
+// Del 执行 Redis DEL 命令.
+func (c *Client) Del(ctx context.Context, key string) error {
+	conn, err := c.pool.GetContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("DEL", key)
+	return err
+}
+
 func (c *Client) Expire(ctx context.Context, key string, expireSeconds int64) error {
-	conn, err := c.pool.GetContext(ctx)
+	conn, err := c.pool.GetContext(ctx, key)
 	if err != nil {
 		return err
 	}
@@ -144,7 +209,13 @@ func (c *Client) Eval(ctx context.Context, src string, keyCount int, keysAndArgs
 	args[1] = keyCount
 	copy(args[2:], keysAndArgs)
 
-	conn, err := c.pool.GetContext(ctx)
+	// 集群模式下脚本涉及的 key 必须在同一个 slot，连接绑定到 key 所在的节点
+	keys := make([]string, 0, keyCount)
+	for i := 0; i < keyCount && i < len(keysAndArgs); i++ {
+		keys = append(keys, fmt.Sprint(keysAndArgs[i]))
+	}
+
+	conn, err := c.pool.GetContext(ctx, keys...)
 	if err != nil {
 		return -1, err
 	}
@@ -153,8 +224,66 @@ func (c *Client) Eval(ctx context.Context, src string, keyCount int, keysAndArgs
 	return conn.Do("EVAL", args...)
 }
 
+// HSet 执行 Redis HSET 命令.
+func (c *Client) HSet(ctx context.Context, key, field string, value interface{}) error {
+	conn, err := c.pool.GetContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("HSET", key, field, value)
+	return err
+}
+
+// HGetAll 执行 Redis HGETALL 命令.
+func (c *Client) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	conn, err := c.pool.GetContext(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return redis.StringMap(conn.Do("HGETALL", key))
+}
+
+// SetIfGreater 只有 value 大于 key 当前保存的数值时才写入，返回是否写入
+func (c *Client) SetIfGreater(ctx context.Context, key string, value int64) (bool, error) {
+	reply, err := c.Eval(ctx, LuaSetIfGreater, 1, []interface{}{key, value})
+	if err != nil {
+		return false, err
+	}
+
+	ret, _ := reply.(int64)
+	return ret == 1, nil
+}
+
+// DuePop 延迟队列一次弹出的结果
+type DuePop struct {
+	Members []string
+	// 队列与处理中集合里最早的 score，没有 member 时为 -1
+	NextScore int64
+	// 队列与处理中集合的 member 总数，为 0 说明全部已经 ack
+	Pending int64
+}
+
+// PopDueMembers 将 queue 中 score 不超过 dueBefore 的 member 原子地移入 processing，visibleAt 之前没有 ack 的 member 会重新入队
+// queue 与 processing 需要带有相同的 hash tag，时间均为毫秒
+func (c *Client) PopDueMembers(ctx context.Context, queue, processing string, now, dueBefore, visibleAt int64, limit int, expireMilliSeconds int64) (*DuePop, error) {
+	reply, err := redis.Values(c.Eval(ctx, LuaPopDueMembers, 2, []interface{}{queue, processing, now, dueBefore, visibleAt, limit, expireMilliSeconds}))
+	if err != nil {
+		return nil, err
+	}
+
+	var pop DuePop
+	if _, err := redis.Scan(reply, &pop.NextScore, &pop.Pending, &pop.Members); err != nil {
+		return nil, err
+	}
+	return &pop, nil
+}
+
 func (c *Client) SetBit(ctx context.Context, key string, offset int32) (bool, error) {
-	conn, err := c.pool.GetContext(ctx)
+	conn, err := c.pool.GetContext(ctx, key)
 	if err != nil {
 		return false, err
 	}
@@ -165,7 +294,7 @@ func (c *Client) SetBit(ctx context.Context, key string, offset int32) (bool, er
 }
 
 func (c *Client) GetBit(ctx context.Context, key string, offset int32) (bool, error) {
-	conn, err := c.pool.GetContext(ctx)
+	conn, err := c.pool.GetContext(ctx, key)
 	if err != nil {
 		return false, err
 	}
@@ -181,17 +310,19 @@ func (c *Client) Exists(ctx context.Context, keys ...string) (bool, error) {
 		return false, errors.New("redis Exists args can't be nil or empty")
 	}
 
-	conn, err := c.pool.GetContext(ctx)
-	if err != nil {
-		return false, err
-	}
-	defer conn.Close()
-
-	args := make([]interface{}, len(keys))
-	for i := range keys {
-		args[i] = keys[i]
+	// 集群模式下不同 slot 的 key 不能在同一条命令中，逐个判断
+	for _, key := range keys {
+		conn, err := c.pool.GetContext(ctx, key)
+		if err != nil {
+			return false, err
+		}
+		exist, err := redis.Bool(conn.Do("EXISTS", key))
+		_ = conn.Close()
+		if err != nil || exist {
+			return exist, err
+		}
 	}
-	return redis.Bool(conn.Do("exists", args...))
+	return false, nil
 }
 
 type Command struct {
@@ -199,6 +330,14 @@ type Command struct {
 	Args []interface{}
 }
 
+// Key 命令操作的 key，即第一个参数
+func (c *Command) Key() string {
+	if len(c.Args) == 0 {
+		return ""
+	}
+	return fmt.Sprint(c.Args[0])
+}
+
 func NewExpireCommand(args ...interface{}) *Command {
 	return &Command{
 		Name: "EXPIRE",
@@ -213,6 +352,13 @@ func NewZAddCommand(args ...interface{}) *Command {
 	}
 }
 
+func NewZRemCommand(args ...interface{}) *Command {
+	return &Command{
+		Name: "ZREM",
+		Args: args,
+	}
+}
+
 func NewSetBitCommand(args ...interface{}) *Command {
 	return &Command{
 		Name: "SETBIT",
@@ -220,21 +366,150 @@ func NewSetBitCommand(args ...interface{}) *Command {
 	}
 }
 
+// Transaction 以 MULTI/EXEC 执行一批命令，命令的第一个参数为 key
+// 集群模式下 MULTI 不能跨 slot，按 slot 分组后每组各自执行一个事务，只保证同一个 slot 内的原子性
+// 返回值与 commands 一一对应
 func (c *Client) Transaction(ctx context.Context, commands ...*Command) ([]interface{}, error) {
 	if len(commands) == 0 {
 		return nil, nil
 	}
 
-	conn, err := c.pool.GetContext(ctx)
+	groups, slots := c.groupBySlot(commands)
+	res := make([]interface{}, len(commands))
+	for _, slot := range slots {
+		indexes := groups[slot]
+		replies, err := c.transaction(ctx, commands, indexes)
+		if err != nil {
+			return nil, err
+		}
+		for i, reply := range replies {
+			res[indexes[i]] = reply
+		}
+	}
+	return res, nil
+}
+
+// Pipeline 以 pipeline 的方式批量执行命令，不保证原子性，命令的第一个参数为 key
+// 集群模式下按 slot 分组，每组在对应节点的一个连接上发送
+// 返回值与 commands 一一对应，任意一条命令失败都会返回 error
+func (c *Client) Pipeline(ctx context.Context, commands ...*Command) ([]interface{}, error) {
+	if len(commands) == 0 {
+		return nil, nil
+	}
+
+	groups, slots := c.groupBySlot(commands)
+	res := make([]interface{}, len(commands))
+	for _, slot := range slots {
+		indexes := groups[slot]
+		replies, err := c.pipeline(ctx, commands, indexes)
+		if err != nil {
+			return nil, err
+		}
+		for i, reply := range replies {
+			res[indexes[i]] = reply
+		}
+	}
+	return res, nil
+}
+
+// groupBySlot 按 key 所在的 slot 对命令分组，slots 保持命令首次出现的顺序
+func (c *Client) groupBySlot(commands []*Command) (map[int][]int, []int) {
+	groups := make(map[int][]int)
+	var slots []int
+	for i, command := range commands {
+		slot := c.pool.Slot(command.Key())
+		if _, ok := groups[slot]; !ok {
+			slots = append(slots, slot)
+		}
+		groups[slot] = append(groups[slot], i)
+	}
+	return groups, slots
+}
+
+func (c *Client) pipeline(ctx context.Context, commands []*Command, indexes []int) ([]interface{}, error) {
+	conn, err := c.pool.GetPipelineContext(ctx, commands[indexes[0]].Key())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	for _, i := range indexes {
+		if err := conn.Send(commands[i].Name, commands[i].Args...); err != nil {
+			return nil, err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+
+	replies := make([]interface{}, 0, len(indexes))
+	for _, i := range indexes {
+		reply, err := conn.Receive()
+		if err != nil {
+			return nil, fmt.Errorf("command %s %s failed, err: %w", commands[i].Name, commands[i].Key(), err)
+		}
+		replies = append(replies, reply)
+	}
+	return replies, nil
+}
+
+func (c *Client) transaction(ctx context.Context, commands []*Command, indexes []int) ([]interface{}, error) {
+	conn, err := c.pool.GetPipelineContext(ctx, commands[indexes[0]].Key())
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
 
 	_ = conn.Send("MULTI")
-	for _, command := range commands {
-		_ = conn.Send(command.Name, command.Args...)
+	for _, i := range indexes {
+		_ = conn.Send(commands[i].Name, commands[i].Args...)
 	}
 
 	return redis.Values(conn.Do("EXEC"))
 }
+
+// Ping 执行 Redis PING 命令，用于健康检查.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.pool.EachNode(ctx, func(conn redis.Conn) error {
+		_, err := conn.Do("PING")
+		return err
+	})
+}
+
+// Scan 基于 SCAN 游标遍历匹配 pattern 的全部 key，避免 KEYS 阻塞 redis.
+// 集群模式下需要遍历每个主节点.
+func (c *Client) Scan(ctx context.Context, pattern string, count int) ([]string, error) {
+	var keys []string
+	err := c.pool.EachNode(ctx, func(conn redis.Conn) error {
+		var cursor int64
+		for {
+			values, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", pattern, "COUNT", count))
+			if err != nil {
+				return err
+			}
+
+			var batch []string
+			if _, err = redis.Scan(values, &cursor, &batch); err != nil {
+				return err
+			}
+			keys = append(keys, batch...)
+
+			// 游标回到 0 代表遍历结束
+			if cursor == 0 {
+				return nil
+			}
+		}
+	})
+	return keys, err
+}
+
+// PTTL 获取 key 剩余的存活时间，单位：毫秒. -1 代表没有设置过期时间，-2 代表 key 不存在.
+func (c *Client) PTTL(ctx context.Context, key string) (int64, error) {
+	conn, err := c.pool.GetContext(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	return redis.Int64(conn.Do("PTTL", key))
+}
@@ -0,0 +1,98 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"timer/common/conf"
+)
+
+const (
+	// 各实例并发启动时，获取迁移锁的最长等待时间
+	lockTimeout = 5 * time.Minute
+	// 与 MySQL GET_LOCK 的锁名、PostgreSQL advisory lock 的 key 对应
+	lockName = "timer_schema_migrations"
+	lockID   = 72946361
+)
+
+// dbLocker 数据库级别的迁移锁，保证多个实例同时启动时只有一个在执行迁移
+// 锁都是会话级别的，必须在同一个连接上加锁、迁移、解锁
+type dbLocker interface {
+	Lock(ctx context.Context, conn *sql.Conn) error
+	Unlock(ctx context.Context, conn *sql.Conn) error
+}
+
+func newLocker(driver string) (dbLocker, error) {
+	switch driver {
+	case conf.DriverMySQL, "":
+		return &mysqlLocker{}, nil
+	case conf.DriverPostgres:
+		return &postgresLocker{}, nil
+	case conf.DriverSQLite:
+		return &sqliteLocker{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", driver)
+	}
+}
+
+// mysqlLocker 基于 GET_LOCK/RELEASE_LOCK，连接断开时锁自动释放
+type mysqlLocker struct{}
+
+func (l *mysqlLocker) Lock(ctx context.Context, conn *sql.Conn) error {
+	var res sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(lockTimeout/time.Second)).Scan(&res); err != nil {
+		return err
+	}
+	if !res.Valid || res.Int64 != 1 {
+		return errors.New("get schema migration lock timeout")
+	}
+	return nil
+}
+
+func (l *mysqlLocker) Unlock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName)
+	return err
+}
+
+// postgresLocker 基于 advisory lock，连接断开时锁自动释放
+type postgresLocker struct{}
+
+func (l *postgresLocker) Lock(ctx context.Context, conn *sql.Conn) error {
+	ctx, cancel := context.WithTimeout(ctx, lockTimeout)
+	defer cancel()
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID)
+	return err
+}
+
+func (l *postgresLocker) Unlock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockID)
+	return err
+}
+
+// sqliteLocker SQLite 没有会话锁，基于 BEGIN IMMEDIATE 获取数据库写锁，其他进程的写事务会等待
+type sqliteLocker struct{}
+
+func (l *sqliteLocker) Lock(ctx context.Context, conn *sql.Conn) error {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		_, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE")
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("get schema migration lock timeout, err: %w", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func (l *sqliteLocker) Unlock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "COMMIT")
+	return err
+}
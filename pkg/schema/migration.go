@@ -0,0 +1,100 @@
+package schema
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var migrationFileRegexp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration 一个版本的 schema 变更
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// LoadMigrations 从 fsys 的 dir 目录加载全部迁移文件，按版本号升序返回
+func LoadMigrations(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := migrationFileRegexp.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := migrations[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			migrations[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("conflict migration name of version %d: %s, %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	res := make([]*Migration, 0, len(migrations))
+	for _, migration := range migrations {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("missing up migration of version %d", migration.Version)
+		}
+		res = append(res, migration)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Version < res[j].Version
+	})
+	return res, nil
+}
+
+// splitStatements 按行尾的分号拆分 sql 语句，MySQL 驱动默认不支持一次执行多条语句
+func splitStatements(content string) []string {
+	var (
+		statements []string
+		builder    strings.Builder
+	)
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if builder.Len() == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "--")) {
+			continue
+		}
+
+		builder.WriteString(line)
+		builder.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(builder.String()))
+			builder.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(builder.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
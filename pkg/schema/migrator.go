@@ -0,0 +1,290 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+	"timer/common/conf"
+	migrations "timer/common/model/sql"
+	"timer/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+const migrationTable = "schema_migrations"
+
+// Migrator 版本化的 schema 迁移，迁移文件内嵌在二进制中，按数据库驱动分目录存放
+type Migrator struct {
+	db         *sql.DB
+	driver     string
+	migrations []*Migration
+	locker     dbLocker
+}
+
+// MigrationStatus 单个版本的迁移状态
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+func NewMigrator(db *gorm.DB, config *conf.DatabaseConfig) *Migrator {
+	sqlDB, err := db.DB()
+	if err != nil {
+		panic(err)
+	}
+
+	migrator, err := newMigrator(sqlDB, config.Driver, migrations.Migrations)
+	if err != nil {
+		panic(err)
+	}
+	return migrator
+}
+
+func newMigrator(db *sql.DB, driver string, fsys fs.FS) (*Migrator, error) {
+	if driver == "" {
+		driver = conf.DriverMySQL
+	}
+
+	locker, err := newLocker(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	ms, err := LoadMigrations(fsys, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		driver:     driver,
+		migrations: ms,
+		locker:     locker,
+	}, nil
+}
+
+// Up 执行全部未执行的迁移，返回本次执行的版本
+func (m *Migrator) Up(ctx context.Context) ([]int64, error) {
+	var applied []int64
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+
+			logger.InfoContextf(ctx, "schema migrate up to version: %d_%s", migration.Version, migration.Name)
+			if err := m.apply(ctx, conn, migration.Up, func(tx execer) error {
+				_, err := tx.ExecContext(ctx, m.rebind(fmt.Sprintf("INSERT INTO %s (version, name, applied_at) VALUES (?, ?, ?)", migrationTable)),
+					migration.Version, migration.Name, time.Now())
+				return err
+			}); err != nil {
+				return fmt.Errorf("migrate up version %d failed, err: %w", migration.Version, err)
+			}
+			applied = append(applied, migration.Version)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down 按版本号倒序回滚 steps 个已执行的迁移，返回本次回滚的版本
+func (m *Migrator) Down(ctx context.Context, steps int) ([]int64, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+
+	var reverted []int64
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("version %d can not be reverted, missing down migration", migration.Version)
+			}
+
+			logger.InfoContextf(ctx, "schema migrate down version: %d_%s", migration.Version, migration.Name)
+			if err := m.apply(ctx, conn, migration.Down, func(tx execer) error {
+				_, err := tx.ExecContext(ctx, m.rebind(fmt.Sprintf("DELETE FROM %s WHERE version = ?", migrationTable)), migration.Version)
+				return err
+			}); err != nil {
+				return fmt.Errorf("migrate down version %d failed, err: %w", migration.Version, err)
+			}
+			reverted = append(reverted, migration.Version)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status 全部迁移的执行状态，以及数据库中存在但二进制中不认识的版本
+func (m *Migrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := m.ensureMigrationTable(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	versions, err := m.appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{
+			Version: migration.Version,
+			Name:    migration.Name,
+		}
+		if appliedAt, ok := versions[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = appliedAt
+			delete(versions, migration.Version)
+		}
+		res = append(res, &status)
+	}
+
+	for version, appliedAt := range versions {
+		res = append(res, &MigrationStatus{
+			Version:   version,
+			Name:      "unknown",
+			Applied:   true,
+			AppliedAt: appliedAt,
+		})
+	}
+	return res, nil
+}
+
+func (m *Migrator) withLock(ctx context.Context, do func(conn *sql.Conn) error) (err error) {
+	// 会话级别的锁，需要固定在同一个连接上
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err = m.locker.Lock(ctx, conn); err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := m.locker.Unlock(context.Background(), conn); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+
+	if err = m.ensureMigrationTable(ctx, conn); err != nil {
+		return err
+	}
+	return do(conn)
+}
+
+func (m *Migrator) ensureMigrationTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+  version BIGINT NOT NULL PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  applied_at TIMESTAMP NOT NULL
+)`, migrationTable))
+	return err
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT version, applied_at FROM %s", migrationTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			version   int64
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		versions[version] = appliedAt
+	}
+	return versions, rows.Err()
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// apply 在事务中执行迁移语句并记录版本
+// MySQL 的 DDL 会隐式提交事务，迁移文件需要保证可以重复执行
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, content string, record func(tx execer) error) error {
+	// SQLite 的迁移锁本身就是一个写事务，使用 savepoint 隔离单个版本
+	if m.driver == conf.DriverSQLite {
+		if _, err := conn.ExecContext(ctx, "SAVEPOINT schema_migration"); err != nil {
+			return err
+		}
+		if err := m.exec(ctx, conn, content, record); err != nil {
+			_, _ = conn.ExecContext(ctx, "ROLLBACK TO SAVEPOINT schema_migration")
+			return err
+		}
+		_, err := conn.ExecContext(ctx, "RELEASE SAVEPOINT schema_migration")
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := m.exec(ctx, tx, content, record); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) exec(ctx context.Context, tx execer, content string, record func(tx execer) error) error {
+	for _, statement := range splitStatements(content) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("exec statement failed, statement: %s, err: %w", statement, err)
+		}
+	}
+	return record(tx)
+}
+
+// rebind PostgreSQL 的占位符为 $n
+func (m *Migrator) rebind(query string) string {
+	if m.driver != conf.DriverPostgres {
+		return query
+	}
+
+	var (
+		builder strings.Builder
+		n       int
+	)
+	for _, c := range query {
+		if c == '?' {
+			n++
+			builder.WriteString(fmt.Sprintf("$%d", n))
+			continue
+		}
+		builder.WriteRune(c)
+	}
+	return builder.String()
+}
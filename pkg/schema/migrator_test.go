@@ -0,0 +1,248 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+	"timer/common/conf"
+	migrations "timer/common/model/sql"
+	"timer/pkg/database"
+)
+
+// newTestMigrator 打开 dsn 对应的 sqlite 数据库，同一个 dsn 多次调用模拟多个实例
+func newTestMigrator(t *testing.T, dsn string) *Migrator {
+	t.Helper()
+	config := &conf.DatabaseConfig{Driver: conf.DriverSQLite, DSN: dsn}
+	return NewMigrator(database.GetClient(config), config)
+}
+
+func tableExists(t *testing.T, db *sql.DB, table string) bool {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n > 0
+}
+
+func TestLoadMigrations(t *testing.T) {
+	// 各存储的迁移版本号保持一致，每条语句都能被拆分出来
+	var versions map[int64]string
+	for _, driver := range []string{conf.DriverMySQL, conf.DriverPostgres, conf.DriverSQLite} {
+		ms, err := LoadMigrations(migrations.Migrations, driver)
+		if err != nil {
+			t.Fatalf("load %s migrations: %v", driver, err)
+		}
+
+		current := make(map[int64]string, len(ms))
+		for _, m := range ms {
+			current[m.Version] = m.Name
+			for _, content := range []string{m.Up, m.Down} {
+				for _, statement := range splitStatements(content) {
+					if !strings.HasSuffix(statement, ";") {
+						t.Errorf("%s version %d: statement not terminated: %s", driver, m.Version, statement)
+					}
+				}
+			}
+			if len(splitStatements(m.Up)) == 0 {
+				t.Errorf("%s version %d has no up statement", driver, m.Version)
+			}
+		}
+		if versions == nil {
+			versions = current
+			continue
+		}
+		for version, name := range current {
+			if versions[version] != name {
+				t.Errorf("%s version %d named %q, want %q", driver, version, name, versions[version])
+			}
+		}
+	}
+}
+
+func TestUpDownStatus(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMigrator(t, t.TempDir()+"/timer.db")
+	all := len(m.migrations)
+	last := m.migrations[all-1]
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != all {
+		t.Fatalf("applied %d versions, want %d", len(applied), all)
+	}
+	if !tableExists(t, m.db, "calendar") {
+		t.Error("table calendar not created")
+	}
+	// 已执行的版本不会重复执行
+	if applied, err = m.Up(ctx); err != nil || len(applied) != 0 {
+		t.Fatalf("second up applied %v, err: %v, want none", applied, err)
+	}
+
+	reverted, err := m.Down(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != 2 || reverted[0] != last.Version {
+		t.Fatalf("reverted %v, want the latest 2 versions from %d", reverted, last.Version)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != all {
+		t.Fatalf("%d statuses, want %d", len(statuses), all)
+	}
+	for i, status := range statuses {
+		if want := i < all-2; status.Applied != want {
+			t.Errorf("version %d applied %v, want %v", status.Version, status.Applied, want)
+		}
+		if status.Applied && status.AppliedAt.IsZero() {
+			t.Errorf("version %d applied without time", status.Version)
+		}
+	}
+
+	// 回滚后可以重新执行
+	if applied, err = m.Up(ctx); err != nil || len(applied) != 2 {
+		t.Fatalf("up after down applied %v, err: %v, want 2 versions", applied, err)
+	}
+}
+
+func TestStatusUnknownVersion(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMigrator(t, t.TempDir()+"/timer.db")
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.db.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", 999999, "future", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown := statuses[len(statuses)-1]
+	if unknown.Version != 999999 || unknown.Name != "unknown" || !unknown.Applied {
+		t.Errorf("last status %+v, want applied unknown version 999999", unknown)
+	}
+}
+
+func TestUpRollsBackFailedVersion(t *testing.T) {
+	ctx := context.Background()
+	fsys := fstest.MapFS{
+		"sqlite/000001_a.up.sql": {Data: []byte("CREATE TABLE a (id INTEGER);")},
+		"sqlite/000002_b.up.sql": {Data: []byte("CREATE TABLE b (id INTEGER);\nINSERT INTO missing VALUES (1);")},
+	}
+	db, err := database.GetClient(&conf.DatabaseConfig{Driver: conf.DriverSQLite, DSN: t.TempDir() + "/timer.db"}).DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := newMigrator(db, conf.DriverSQLite, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := m.Up(ctx)
+	if err == nil {
+		t.Fatal("up with broken version succeeded")
+	}
+	if len(applied) != 1 || applied[0] != 1 {
+		t.Errorf("applied %v before failure, want [1]", applied)
+	}
+	// 失败的版本整体回滚，不留下执行了一半的变更，也不记录版本
+	if !tableExists(t, db, "a") || tableExists(t, db, "b") {
+		t.Error("failed version not rolled back")
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statuses[0].Applied || statuses[1].Applied {
+		t.Errorf("statuses applied %v, %v, want true, false", statuses[0].Applied, statuses[1].Applied)
+	}
+}
+
+func TestUpWaitsForLock(t *testing.T) {
+	ctx := context.Background()
+	dsn := t.TempDir() + "/timer.db"
+	holder, waiter := newTestMigrator(t, dsn), newTestMigrator(t, dsn)
+
+	conn, err := holder.db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := holder.locker.Lock(ctx, conn); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := waiter.Up(ctx)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("up finished while lock held by others, err: %v", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	if err := holder.locker.Unlock(ctx, conn); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("up not finished after lock released")
+	}
+}
+
+func TestUpConcurrently(t *testing.T) {
+	ctx := context.Background()
+	dsn := t.TempDir() + "/timer.db"
+	migrators := []*Migrator{newTestMigrator(t, dsn), newTestMigrator(t, dsn)}
+
+	// 多个实例同时启动，每个版本只执行一次
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		counts = make(map[int64]int)
+	)
+	for _, m := range migrators {
+		wg.Add(1)
+		go func(m *Migrator) {
+			defer wg.Done()
+			applied, err := m.Up(ctx)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, version := range applied {
+				counts[version]++
+			}
+		}(m)
+	}
+	wg.Wait()
+
+	if len(counts) != len(migrators[0].migrations) {
+		t.Errorf("applied %d versions, want %d", len(counts), len(migrators[0].migrations))
+	}
+	for version, n := range counts {
+		if n != 1 {
+			t.Errorf("version %d applied %d times, want 1", version, n)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package standalone
+
+import (
+	"context"
+	"sync"
+	"time"
+	"timer/common/conf"
+	"timer/pkg/logger"
+
+	"github.com/alicebob/miniredis/v2"
+	"gorm.io/gorm"
+)
+
+// 内嵌 redis 的过期时间不会自动流逝，需要定期推进
+const ttlTickInterval = 100 * time.Millisecond
+
+// Server 单机模式下进程内嵌的存储，对上层的 dao、service 透明
+// 数据库为 SQLite 内存库，redis 为进程内的 miniredis，二者一起写入同一个快照文件
+type Server struct {
+	sync.Mutex
+	redis  *miniredis.Miniredis
+	db     *gorm.DB
+	config *conf.StandaloneConfig
+}
+
+// NewServer 启动内嵌 redis，并将 redis 配置指向它
+// redis 连接池在第一次使用时才建立连接，因此需要在各模块启动之前创建
+func NewServer(config *conf.StandaloneConfig, redisConfig *conf.RedisConfig, db *gorm.DB) *Server {
+	s := Server{
+		redis:  miniredis.NewMiniRedis(),
+		db:     db,
+		config: config,
+	}
+	if !config.Enabled {
+		return &s
+	}
+
+	if err := s.redis.StartAddr(config.RedisAddress); err != nil {
+		panic(err)
+	}
+	redisConfig.Mode = conf.RedisModeSingle
+	redisConfig.Network = "tcp"
+	redisConfig.Address = s.redis.Addr()
+	redisConfig.Password = ""
+	return &s
+}
+
+// Restore 从快照文件恢复数据，需要在 schema 迁移和各模块启动之前调用
+func (s *Server) Restore(ctx context.Context) error {
+	if !s.config.Enabled || s.config.SnapshotFile == "" {
+		return nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	restored, err := restore(ctx, s.db, s.redis, s.config.SnapshotFile)
+	if err != nil {
+		return err
+	}
+	if restored {
+		logger.InfoContextf(ctx, "standalone restore from snapshot: %s", s.config.SnapshotFile)
+	}
+	return nil
+}
+
+// Start 推进内嵌 redis 的过期时间，并定期写快照
+func (s *Server) Start(ctx context.Context) {
+	if !s.config.Enabled {
+		return
+	}
+
+	ttlTicker := time.NewTicker(ttlTickInterval)
+	defer ttlTicker.Stop()
+
+	// 没有配置快照文件时 snapshotC 为 nil，永远不会触发
+	var snapshotC <-chan time.Time
+	if s.config.SnapshotFile != "" && s.config.SnapshotIntervalSeconds > 0 {
+		snapshotTicker := time.NewTicker(time.Duration(s.config.SnapshotIntervalSeconds) * time.Second)
+		defer snapshotTicker.Stop()
+		snapshotC = snapshotTicker.C
+	}
+
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ttlTicker.C:
+			s.redis.FastForward(now.Sub(last))
+			last = now
+		case <-snapshotC:
+			if err := s.Snapshot(ctx); err != nil {
+				logger.ErrorContextf(ctx, "standalone write snapshot failed, err: %v", err)
+			}
+		}
+	}
+}
+
+// Snapshot 将数据库和 redis 的数据写入快照文件
+func (s *Server) Snapshot(ctx context.Context) error {
+	if !s.config.Enabled || s.config.SnapshotFile == "" {
+		return nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	return snapshot(ctx, s.db, s.redis, s.config.SnapshotFile)
+}
+
+func (s *Server) Close() {
+	if s.config.Enabled {
+		s.redis.Close()
+	}
+}
@@ -0,0 +1,272 @@
+package standalone
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+// 快照文件本身是一个 SQLite 数据库：业务表来自内存库的完整拷贝，redis 的数据保存在 redisSnapshotTable 中
+const redisSnapshotTable = "standalone_redis_snapshot"
+
+// redisEntry 一个 redis key 的快照
+type redisEntry struct {
+	Type string `json:"type"`
+	// 过期时间，单位：毫秒，0 代表不过期
+	TTL int64 `json:"ttl"`
+	// 布隆过滤器的 bitmap 是稀疏的大字符串，gzip 压缩后保存
+	String []byte             `json:"string,omitempty"`
+	Hash   map[string]string  `json:"hash,omitempty"`
+	List   []string           `json:"list,omitempty"`
+	Set    []string           `json:"set,omitempty"`
+	ZSet   map[string]float64 `json:"zset,omitempty"`
+}
+
+func snapshot(ctx context.Context, db *gorm.DB, rdb *miniredis.Miniredis, file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	// 先写临时文件，完成后再替换，避免进程中断时留下不完整的快照
+	tmp := file + ".tmp"
+	_ = os.Remove(tmp)
+	defer os.Remove(tmp)
+
+	if err := db.WithContext(ctx).Exec("VACUUM INTO ?", tmp).Error; err != nil {
+		return err
+	}
+
+	snapshotDB, err := sql.Open("sqlite3", tmp)
+	if err != nil {
+		return err
+	}
+	defer snapshotDB.Close()
+
+	tx, err := snapshotDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s (`key` TEXT PRIMARY KEY, `value` TEXT NOT NULL)", redisSnapshotTable)); err != nil {
+		return err
+	}
+	for _, key := range rdb.Keys() {
+		entry, err := dumpKey(rdb, key)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (`key`, `value`) VALUES (?, ?)", redisSnapshotTable), key, string(value)); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if err := snapshotDB.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+func restore(ctx context.Context, db *gorm.DB, rdb *miniredis.Miniredis, file string) (bool, error) {
+	if _, err := os.Stat(file); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	snapshotDB, err := sql.Open("sqlite3", "file:"+file+"?mode=ro")
+	if err != nil {
+		return false, err
+	}
+	defer snapshotDB.Close()
+
+	if err := restoreRedis(ctx, snapshotDB, rdb); err != nil {
+		return false, err
+	}
+	if err := restoreDatabase(ctx, db, snapshotDB); err != nil {
+		return false, err
+	}
+
+	// redis 快照表随数据库一起拷贝进了内存库，恢复完成后删除
+	return true, db.WithContext(ctx).Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", redisSnapshotTable)).Error
+}
+
+// restoreDatabase 通过 SQLite 的 online backup 将快照整体拷贝到内存库
+func restoreDatabase(ctx context.Context, db *gorm.DB, snapshotDB *sql.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	dstConn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+
+	srcConn, err := snapshotDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return dstConn.Raw(func(dst interface{}) error {
+		return srcConn.Raw(func(src interface{}) error {
+			dstSQLite, ok := dst.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("standalone database is not sqlite")
+			}
+			srcSQLite, ok := src.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("snapshot database is not sqlite")
+			}
+
+			backup, err := dstSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return err
+			}
+			if _, err := backup.Step(-1); err != nil {
+				_ = backup.Finish()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+}
+
+func restoreRedis(ctx context.Context, snapshotDB *sql.DB, rdb *miniredis.Miniredis) error {
+	rows, err := snapshotDB.QueryContext(ctx, fmt.Sprintf("SELECT `key`, `value` FROM %s", redisSnapshotTable))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+
+		var entry redisEntry
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			return err
+		}
+		if err := loadKey(rdb, key, &entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func dumpKey(rdb *miniredis.Miniredis, key string) (*redisEntry, error) {
+	entry := redisEntry{
+		Type: rdb.Type(key),
+		TTL:  rdb.TTL(key).Milliseconds(),
+	}
+
+	var err error
+	switch entry.Type {
+	case "string":
+		var value string
+		if value, err = rdb.Get(key); err == nil {
+			entry.String, err = compress(value)
+		}
+	case "hash":
+		var fields []string
+		if fields, err = rdb.HKeys(key); err == nil {
+			entry.Hash = make(map[string]string, len(fields))
+			for _, field := range fields {
+				entry.Hash[field] = rdb.HGet(key, field)
+			}
+		}
+	case "list":
+		entry.List, err = rdb.List(key)
+	case "set":
+		entry.Set, err = rdb.Members(key)
+	case "zset":
+		entry.ZSet, err = rdb.SortedSet(key)
+	default:
+		err = fmt.Errorf("unsupported redis type %s of key %s", entry.Type, key)
+	}
+	return &entry, err
+}
+
+func loadKey(rdb *miniredis.Miniredis, key string, entry *redisEntry) error {
+	switch entry.Type {
+	case "string":
+		value, err := decompress(entry.String)
+		if err != nil {
+			return err
+		}
+		if err := rdb.Set(key, value); err != nil {
+			return err
+		}
+	case "hash":
+		for field, value := range entry.Hash {
+			rdb.HSet(key, field, value)
+		}
+	case "list":
+		if _, err := rdb.Push(key, entry.List...); err != nil {
+			return err
+		}
+	case "set":
+		if _, err := rdb.SetAdd(key, entry.Set...); err != nil {
+			return err
+		}
+	case "zset":
+		for member, score := range entry.ZSet {
+			if _, err := rdb.ZAdd(key, score, member); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported redis type %s of key %s", entry.Type, key)
+	}
+
+	if entry.TTL > 0 {
+		rdb.SetTTL(key, time.Duration(entry.TTL)*time.Millisecond)
+	}
+	return nil
+}
+
+func compress(value string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(zw, value); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(data []byte) (string, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+
+	value, err := io.ReadAll(zr)
+	return string(value), err
+}
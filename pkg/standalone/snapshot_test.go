@@ -0,0 +1,115 @@
+package standalone
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"timer/common/conf"
+	"timer/common/model/po"
+	"timer/pkg/database"
+	"timer/pkg/schema"
+
+	"gorm.io/gorm"
+)
+
+// newMemoryDB 与单机模式一样的 SQLite 内存库，name 区分不同的库
+func newMemoryDB(t *testing.T, name string, migrate bool) *gorm.DB {
+	t.Helper()
+	config := &conf.DatabaseConfig{Driver: conf.DriverSQLite, DSN: fmt.Sprintf("file:%s?mode=memory&cache=shared&_busy_timeout=5000", name)}
+	db := database.GetClient(config)
+	if migrate {
+		if _, err := schema.NewMigrator(db, config).Up(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// newTestServer 启用单机模式的 Server，快照写入 file
+func newTestServer(t *testing.T, db *gorm.DB, file string) *Server {
+	t.Helper()
+	config := &conf.StandaloneConfig{Enabled: true, RedisAddress: "127.0.0.1:0", SnapshotFile: file}
+	s := NewServer(config, &conf.RedisConfig{}, db)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	file := t.TempDir() + "/snapshot.db"
+
+	src := newTestServer(t, newMemoryDB(t, "snapshot_src", true), file)
+	timer := &po.Timer{App: "app", Name: "timer", Cron: "0 * * * * * *", NotifyHTTPParam: "{}"}
+	if err := src.db.Table(po.TimerTable).Create(timer).Error; err != nil {
+		t.Fatal(err)
+	}
+	// 布隆过滤器的 bitmap 是稀疏的大字符串
+	bitmap := strings.Repeat("\x00", 1<<16) + "\x01"
+	if err := src.redis.Set("bloom", bitmap); err != nil {
+		t.Fatal(err)
+	}
+	src.redis.SetTTL("bloom", time.Hour)
+	src.redis.HSet("hash", "field", "value")
+	if _, err := src.redis.Push("list", "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.redis.SetAdd("set", "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.redis.ZAdd("zset", 1700000000000, "1_1700000000000"); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Snapshot(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 新进程从快照恢复，数据库为空库
+	dst := newTestServer(t, newMemoryDB(t, "snapshot_dst", false), file)
+	if err := dst.Restore(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var timers []*po.Timer
+	if err := dst.db.Table(po.TimerTable).Find(&timers).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(timers) != 1 || timers[0].ID != timer.ID || timers[0].Name != timer.Name {
+		t.Errorf("restored timers %+v, want timer %d", timers, timer.ID)
+	}
+	// 恢复之后 schema 迁移可以继续执行，redis 快照表不会留在库中
+	if !dst.db.Migrator().HasTable("schema_migrations") || dst.db.Migrator().HasTable(redisSnapshotTable) {
+		t.Errorf("schema_migrations restored %v, %s left %v, want true, false",
+			dst.db.Migrator().HasTable("schema_migrations"), redisSnapshotTable, dst.db.Migrator().HasTable(redisSnapshotTable))
+	}
+
+	if value, _ := dst.redis.Get("bloom"); value != bitmap {
+		t.Errorf("restored bloom of %d bytes, want %d", len(value), len(bitmap))
+	}
+	if ttl := dst.redis.TTL("bloom"); ttl != time.Hour {
+		t.Errorf("restored bloom ttl %v, want 1h", ttl)
+	}
+	if value := dst.redis.HGet("hash", "field"); value != "value" {
+		t.Errorf("restored hash field %q, want value", value)
+	}
+	if list, _ := dst.redis.List("list"); fmt.Sprint(list) != "[a b]" {
+		t.Errorf("restored list %v, want [a b]", list)
+	}
+	if members, _ := dst.redis.Members("set"); fmt.Sprint(members) != "[a b]" {
+		t.Errorf("restored set %v, want [a b]", members)
+	}
+	if score, _ := dst.redis.ZScore("zset", "1_1700000000000"); score != 1700000000000 {
+		t.Errorf("restored zset score %v, want 1700000000000", score)
+	}
+}
+
+func TestRestoreWithoutSnapshot(t *testing.T) {
+	s := newTestServer(t, newMemoryDB(t, "snapshot_missing", false), t.TempDir()+"/snapshot.db")
+	if err := s.Restore(context.Background()); err != nil {
+		t.Errorf("restore without snapshot file: %v", err)
+	}
+	if keys := s.redis.Keys(); len(keys) != 0 {
+		t.Errorf("redis keys %v after restore without snapshot, want none", keys)
+	}
+}
@@ -0,0 +1,43 @@
+package testenv
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"gorm.io/gorm"
+	"timer/common/conf"
+	"timer/pkg/database"
+	"timer/pkg/schema"
+)
+
+// PostgresDSNEnv Postgres 测试连接的数据库，例如 host=127.0.0.1 user=postgres password=123456 dbname=timer_test port=5432 sslmode=disable
+const PostgresDSNEnv = "TIMER_TEST_POSTGRES_DSN"
+
+// NewPostgresDB 清空 PostgresDSNEnv 指定的库并执行全部迁移，没有设置时跳过测试
+// 会删除 public schema 下的全部数据，只能指向专用的测试库；多个包共用一个库，需要 go test -p 1 串行执行
+func NewPostgresDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv(PostgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", PostgresDSNEnv)
+	}
+
+	config := &conf.DatabaseConfig{Driver: conf.DriverPostgres, DSN: dsn, MaxOpenConns: 10, MaxIdleConns: 2}
+	db := database.GetClient(config)
+	for _, statement := range []string{"DROP SCHEMA IF EXISTS public CASCADE", "CREATE SCHEMA public"} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := schema.NewMigrator(db, config).Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return db
+}
@@ -0,0 +1,37 @@
+// Package testenv 为测试准备 sqlite 数据库与内存 redis，只在 _test.go 中使用
+package testenv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"gorm.io/gorm"
+	"timer/common/conf"
+	"timer/pkg/database"
+	"timer/pkg/redis"
+	"timer/pkg/schema"
+)
+
+// NewDB 在临时目录中创建 sqlite 数据库并执行全部迁移
+func NewDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	config := &conf.DatabaseConfig{Driver: conf.DriverSQLite, DSN: t.TempDir() + "/timer.db"}
+	db := database.GetClient(config)
+	if _, err := schema.NewMigrator(db, config).Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// NewRedis 启动一个内存 redis，返回服务端（用于快进时间、直接读写）以及连接它的客户端
+func NewRedis(t testing.TB) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	config := *conf.GetDefaultRedisConfig()
+	config.Mode = conf.RedisModeSingle
+	config.Network = "tcp"
+	config.Address = server.Addr()
+	config.Password = ""
+	return server, redis.GetClient(&config)
+}
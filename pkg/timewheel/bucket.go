@@ -0,0 +1,90 @@
+package timewheel
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+// bucket 时间轮上的一格，保存到期时间落在该格内的定时器
+type bucket struct {
+	// 该格的到期时间（毫秒），-1 表示该格为空
+	expiration int64
+	timers     *list.List
+	// 在延迟队列中的下标，-1 表示不在队列中
+	index int
+}
+
+func newBucket() *bucket {
+	return &bucket{
+		expiration: -1,
+		timers:     list.New(),
+		index:      -1,
+	}
+}
+
+func (b *bucket) add(t *Timer) {
+	t.bucket = b
+	t.element = b.timers.PushBack(t)
+}
+
+func (b *bucket) remove(t *Timer) {
+	b.timers.Remove(t.element)
+	t.bucket, t.element = nil, nil
+}
+
+// flush 取出该格的全部定时器，并重置为空
+func (b *bucket) flush() []*Timer {
+	timers := make([]*Timer, 0, b.timers.Len())
+	for e := b.timers.Front(); e != nil; e = e.Next() {
+		timers = append(timers, e.Value.(*Timer))
+	}
+	for _, t := range timers {
+		b.remove(t)
+	}
+	b.expiration = -1
+	return timers
+}
+
+// delayQueue 按到期时间排序的格子，时间轮只在最早的格子到期时推进，没有到期的格子不需要空转
+type delayQueue []*bucket
+
+func (q delayQueue) Len() int { return len(q) }
+
+func (q delayQueue) Less(i, j int) bool { return q[i].expiration < q[j].expiration }
+
+func (q delayQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index, q[j].index = i, j
+}
+
+func (q *delayQueue) Push(x interface{}) {
+	b := x.(*bucket)
+	b.index = len(*q)
+	*q = append(*q, b)
+}
+
+func (q *delayQueue) Pop() interface{} {
+	old := *q
+	b := old[len(old)-1]
+	old[len(old)-1] = nil
+	b.index = -1
+	*q = old[:len(old)-1]
+	return b
+}
+
+// offer 格子的到期时间变化后加入队列或者调整位置
+func (q *delayQueue) offer(b *bucket) {
+	if b.index < 0 {
+		heap.Push(q, b)
+		return
+	}
+	heap.Fix(q, b.index)
+}
+
+// peek 最早到期的格子，队列为空时返回 nil
+func (q delayQueue) peek() *bucket {
+	if len(q) == 0 {
+		return nil
+	}
+	return q[0]
+}
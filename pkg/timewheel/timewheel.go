@@ -0,0 +1,192 @@
+package timewheel
+
+import (
+	"container/heap"
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Wheel 多层时间轮，定时器按到期时间精确到 tick 触发
+// 第一层每格 tick，共 size 格；超出当前层范围的定时器放入上一层（每格为下一层的一圈），到期前逐层降级
+// 只有最早到期的格子会唤醒时间轮，没有定时器时不空转
+type Wheel struct {
+	mu     sync.Mutex
+	root   *level
+	queue  delayQueue
+	wakeup chan struct{}
+}
+
+// Timer 时间轮上的一个定时器
+type Timer struct {
+	wheel *Wheel
+	// 到期时间（毫秒）
+	expiration int64
+	task       func()
+	bucket     *bucket
+	element    *list.Element
+}
+
+// level 时间轮的一层
+type level struct {
+	// 每格的跨度与一圈的跨度（毫秒）
+	tick     int64
+	interval int64
+	// 当前时间（毫秒），按 tick 取整
+	current  int64
+	buckets  []*bucket
+	overflow *level
+}
+
+// NewWheel 创建第一层每格为 tick、每层 size 格的时间轮，tick 最小为 1ms
+func NewWheel(tick time.Duration, size int) *Wheel {
+	tickMs := int64(tick / time.Millisecond)
+	if tickMs <= 0 {
+		tickMs = 1
+	}
+	if size <= 0 {
+		size = 1
+	}
+	return &Wheel{
+		root:   newLevel(tickMs, int64(size), time.Now().UnixMilli()),
+		wakeup: make(chan struct{}, 1),
+	}
+}
+
+func newLevel(tick, size, now int64) *level {
+	buckets := make([]*bucket, size)
+	for i := range buckets {
+		buckets[i] = newBucket()
+	}
+	return &level{
+		tick:     tick,
+		interval: tick * size,
+		current:  now - now%tick,
+		buckets:  buckets,
+	}
+}
+
+// add 将定时器放入所在的格子，已经到期时返回 false
+func (l *level) add(t *Timer, queue *delayQueue) bool {
+	if t.expiration < l.current+l.tick {
+		return false
+	}
+
+	if t.expiration < l.current+l.interval {
+		virtualID := t.expiration / l.tick
+		b := l.buckets[virtualID%int64(len(l.buckets))]
+		b.add(t)
+		if expiration := virtualID * l.tick; b.expiration != expiration {
+			b.expiration = expiration
+			queue.offer(b)
+		}
+		return true
+	}
+
+	// 超出当前层的范围，放入上一层
+	if l.overflow == nil {
+		l.overflow = newLevel(l.interval, int64(len(l.buckets)), l.current)
+	}
+	return l.overflow.add(t, queue)
+}
+
+// advance 推进当前时间，上一层随之推进
+func (l *level) advance(now int64) {
+	if now < l.current+l.tick {
+		return
+	}
+	l.current = now - now%l.tick
+	if l.overflow != nil {
+		l.overflow.advance(l.current)
+	}
+}
+
+// Add 添加一个在 expiration 触发的定时器，task 在时间轮的协程中执行，不应阻塞
+// 已经到期的定时器立即在当前协程中执行
+func (w *Wheel) Add(expiration time.Time, task func()) *Timer {
+	t := &Timer{
+		wheel:      w,
+		expiration: expiration.UnixMilli(),
+		task:       task,
+	}
+
+	w.mu.Lock()
+	head := w.queue.peek()
+	added := w.root.add(t, &w.queue)
+	// 最早到期的格子变化时唤醒时间轮重新计算休眠时间
+	changed := added && w.queue.peek() != head
+	w.mu.Unlock()
+
+	if !added {
+		task()
+		return t
+	}
+	if changed {
+		select {
+		case w.wakeup <- struct{}{}:
+		default:
+		}
+	}
+	return t
+}
+
+// Cancel 取消定时器，定时器已经触发或者已经取消时返回 false
+func (t *Timer) Cancel() bool {
+	t.wheel.mu.Lock()
+	defer t.wheel.mu.Unlock()
+
+	if t.bucket == nil {
+		return false
+	}
+	t.bucket.remove(t)
+	return true
+}
+
+// Tick 将时间轮推进到 now，依次处理到期的格子：到期的定时器触发，其余的降级到下一层
+func (w *Wheel) Tick(now time.Time) {
+	nowMs := now.UnixMilli()
+
+	var expired []*Timer
+	w.mu.Lock()
+	for b := w.queue.peek(); b != nil && b.expiration <= nowMs; b = w.queue.peek() {
+		heap.Pop(&w.queue)
+		w.root.advance(b.expiration)
+		for _, t := range b.flush() {
+			if !w.root.add(t, &w.queue) {
+				expired = append(expired, t)
+			}
+		}
+	}
+	w.root.advance(nowMs)
+	w.mu.Unlock()
+
+	for _, t := range expired {
+		t.task()
+	}
+}
+
+// Run 在最早的格子到期时推进时间轮，直到 ctx 结束
+func (w *Wheel) Run(ctx context.Context) {
+	for {
+		w.Tick(time.Now())
+
+		// 没有定时器时等待新的定时器加入
+		wait := time.Hour
+		w.mu.Lock()
+		if head := w.queue.peek(); head != nil {
+			wait = time.Until(time.UnixMilli(head.expiration))
+		}
+		w.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-w.wakeup:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
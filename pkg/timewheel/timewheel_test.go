@@ -0,0 +1,195 @@
+package timewheel
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// firedAt 手动推进时间轮，记录每个定时器触发时时间轮推进到的时间
+type firedAt struct {
+	now   time.Time
+	fired map[int][]time.Time
+}
+
+func (f *firedAt) task(id int) func() {
+	return func() {
+		f.fired[id] = append(f.fired[id], f.now)
+	}
+}
+
+// newManualWheel 第一层每格 1ms、每层 4 格，base 为对齐到毫秒的当前时间，测试中只通过 Tick 推进
+func newManualWheel() (*Wheel, time.Time, *firedAt) {
+	w := NewWheel(time.Millisecond, 4)
+	base := time.UnixMilli(w.root.current)
+	return w, base, &firedAt{now: base, fired: make(map[int][]time.Time)}
+}
+
+func (f *firedAt) tickTo(w *Wheel, end time.Time) {
+	for !f.now.After(end) {
+		w.Tick(f.now)
+		f.now = f.now.Add(time.Millisecond)
+	}
+}
+
+func TestTickFiresAtExpiration(t *testing.T) {
+	w, base, f := newManualWheel()
+	expiration := base.Add(3 * time.Millisecond)
+	w.Add(expiration, f.task(1))
+
+	f.tickTo(w, expiration.Add(-time.Millisecond))
+	if len(f.fired[1]) != 0 {
+		t.Fatalf("fired at %v, before expiration %v", f.fired[1], expiration)
+	}
+	f.tickTo(w, expiration.Add(5*time.Millisecond))
+	if len(f.fired[1]) != 1 || !f.fired[1][0].Equal(expiration) {
+		t.Errorf("fired at %v, want once at %v", f.fired[1], expiration)
+	}
+}
+
+func TestAddCascadesOverflowLevels(t *testing.T) {
+	w, base, f := newManualWheel()
+	// 每层 4 格，各层一圈的跨度为 4ms、16ms、64ms、256ms，这些定时器分布在 5 层上
+	offsets := []time.Duration{1, 2, 5, 15, 17, 63, 64, 100, 255, 300}
+	for i, offset := range offsets {
+		w.Add(base.Add(offset*time.Millisecond), f.task(i))
+	}
+	levels := 0
+	for l := w.root; l != nil; l = l.overflow {
+		levels++
+	}
+	if levels != 5 {
+		t.Errorf("%d levels, want 5", levels)
+	}
+
+	// 逐毫秒推进，上层的定时器逐层降级，在到期的那一毫秒触发
+	f.tickTo(w, base.Add(300*time.Millisecond))
+	for i, offset := range offsets {
+		if want := base.Add(offset * time.Millisecond); len(f.fired[i]) != 1 || !f.fired[i][0].Equal(want) {
+			t.Errorf("timer at +%dms fired at %v, want once at %v", offset, f.fired[i], want)
+		}
+	}
+}
+
+func TestTickSkipsAhead(t *testing.T) {
+	w, base, f := newManualWheel()
+	for i, offset := range []time.Duration{1, 20, 100} {
+		w.Add(base.Add(offset*time.Millisecond), f.task(i))
+	}
+
+	// 时间轮休眠期间错过的定时器在下一次 Tick 时全部触发
+	f.now = base.Add(200 * time.Millisecond)
+	w.Tick(f.now)
+	for i := 0; i < 3; i++ {
+		if len(f.fired[i]) != 1 {
+			t.Errorf("timer %d fired %d times, want 1", i, len(f.fired[i]))
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	w, base, f := newManualWheel()
+	canceled := w.Add(base.Add(10*time.Millisecond), f.task(1))
+	fired := w.Add(base.Add(20*time.Millisecond), f.task(2))
+
+	if !canceled.Cancel() {
+		t.Error("cancel pending timer returned false")
+	}
+	if canceled.Cancel() {
+		t.Error("cancel canceled timer returned true")
+	}
+
+	f.tickTo(w, base.Add(30*time.Millisecond))
+	if len(f.fired[1]) != 0 {
+		t.Errorf("canceled timer fired at %v", f.fired[1])
+	}
+	if len(f.fired[2]) != 1 {
+		t.Errorf("timer fired %d times, want 1", len(f.fired[2]))
+	}
+	if fired.Cancel() {
+		t.Error("cancel fired timer returned true")
+	}
+}
+
+func TestAddExpired(t *testing.T) {
+	w, base, f := newManualWheel()
+	// 已经到期的定时器在 Add 中立即执行，不进入时间轮
+	for i, expiration := range []time.Time{base.Add(-time.Second), base} {
+		timer := w.Add(expiration, f.task(i))
+		if len(f.fired[i]) != 1 {
+			t.Errorf("expired timer %v fired %d times in Add, want 1", expiration, len(f.fired[i]))
+		}
+		if timer.Cancel() {
+			t.Errorf("cancel expired timer %v returned true", expiration)
+		}
+	}
+	if w.queue.Len() != 0 {
+		t.Errorf("%d buckets queued for expired timers", w.queue.Len())
+	}
+}
+
+// 一个分钟分片的 task 数量
+const sliceTimers = 100000
+
+// BenchmarkTimeWheel 每个分钟分片 10 万个定时器
+// add 把执行时间均匀分布在一分钟内的定时器加入时间轮；fire 把 10 万个定时器压缩到 500ms 内，测量触发延迟
+// go test -run ^$ -bench TimeWheel ./pkg/timewheel
+func BenchmarkTimeWheel(b *testing.B) {
+	b.Run("add", func(b *testing.B) {
+		w := NewWheel(time.Millisecond, 64)
+		var elapsed time.Duration
+		for i := 0; i < b.N; i++ {
+			begin := time.Now()
+			start := begin.Add(time.Second)
+			timers := make([]*Timer, 0, sliceTimers)
+			for j := 0; j < sliceTimers; j++ {
+				timers = append(timers, w.Add(start.Add(time.Duration(j)*time.Minute/sliceTimers), func() {}))
+			}
+			elapsed += time.Since(begin)
+
+			b.StopTimer()
+			for _, t := range timers {
+				t.Cancel()
+			}
+			b.StartTimer()
+		}
+		b.ReportMetric(float64(elapsed)/float64(b.N*sliceTimers), "ns/timer")
+	})
+
+	b.Run("fire", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ctx, cancel := context.WithCancel(context.Background())
+			w := NewWheel(time.Millisecond, 64)
+			go w.Run(ctx)
+
+			var (
+				wg     sync.WaitGroup
+				mu     sync.Mutex
+				delays = make([]time.Duration, 0, sliceTimers)
+			)
+			wg.Add(sliceTimers)
+			start := time.Now().Add(100 * time.Millisecond)
+			for j := 0; j < sliceTimers; j++ {
+				expiration := start.Add(time.Duration(j) * 500 * time.Millisecond / sliceTimers)
+				w.Add(expiration, func() {
+					delay := time.Since(expiration)
+					mu.Lock()
+					delays = append(delays, delay)
+					mu.Unlock()
+					wg.Done()
+				})
+			}
+			wg.Wait()
+			cancel()
+
+			sort.Slice(delays, func(i, j int) bool {
+				return delays[i] < delays[j]
+			})
+			b.ReportMetric(float64(delays[len(delays)/2])/float64(time.Millisecond), "p50-ms")
+			b.ReportMetric(float64(delays[len(delays)*99/100])/float64(time.Millisecond), "p99-ms")
+			b.ReportMetric(float64(delays[len(delays)-1])/float64(time.Millisecond), "max-ms")
+		}
+	})
+}
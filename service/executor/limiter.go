@@ -0,0 +1,108 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+	"timer/common/conf"
+	"timer/common/utils"
+	"timer/pkg/logger"
+	"timer/pkg/ratelimit"
+)
+
+// appLimiter 在触发时按 app 维度执行限流：
+// 1. 令牌桶限制整个 app 每分钟的触发次数
+// 2. 并发槽位限制整个 app 同时执行的回调数量
+// 超过配额的任务不会被丢弃，也不会占用协程等待，而是返回 *DeferredError，由触发器延后到预计可以拿到配额的时间重新投递
+type appLimiter struct {
+	limiter limiter
+	config  *conf.QuotaConfig
+	seq     atomic.Uint64
+}
+
+func newAppLimiter(limiter *ratelimit.Limiter, config *conf.QuotaConfig) *appLimiter {
+	return &appLimiter{
+		limiter: limiter,
+		config:  config,
+	}
+}
+
+// Acquire 获取 app 的配额，返回的 release 需要在回调执行完成后调用，没有配额时返回 *DeferredError
+// 先占用并发槽位再获取令牌，令牌不足时释放槽位，延后的 task 不会消耗令牌
+// redis 异常时按 failOpen 配置放行，或者延后 retryGap 重新投递
+func (l *appLimiter) Acquire(ctx context.Context, app string) (func(), error) {
+	quota := l.config.GetAppQuota(app)
+
+	release, err := l.acquireSlot(ctx, app, quota)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.takeToken(ctx, app, quota); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+func (l *appLimiter) takeToken(ctx context.Context, app string, quota *conf.AppQuota) error {
+	if quota.MaxFiresPerMinute <= 0 {
+		return nil
+	}
+
+	wait, err := l.limiter.TakeToken(ctx, utils.GetAppRateLimitKey(app), quota.MaxFiresPerMinute, quota.MaxFiresPerMinute)
+	if err != nil {
+		return l.failed(ctx, app, "take rate limit token", err)
+	}
+	if wait > 0 {
+		logger.WarnContextf(ctx, "app fires over limit, deferred: %v, app: %s", wait, app)
+		return &DeferredError{ReleaseAt: time.Now().Add(wait), Reason: "rate limit"}
+	}
+	return nil
+}
+
+func (l *appLimiter) acquireSlot(ctx context.Context, app string, quota *conf.AppQuota) (func(), error) {
+	if quota.MaxConcurrency <= 0 {
+		return func() {}, nil
+	}
+
+	key := utils.GetAppConcurrencyKey(app)
+	token := fmt.Sprintf("%s_%d", utils.GetCurrentNodeID(), l.seq.Add(1))
+	lease := time.Duration(l.config.ConcurrencyLeaseSeconds) * time.Second
+	ok, err := l.limiter.AcquireSlot(ctx, key, token, quota.MaxConcurrency, lease)
+	if err != nil {
+		return func() {}, l.failed(ctx, app, "acquire concurrency slot", err)
+	}
+	if !ok {
+		logger.WarnContextf(ctx, "app concurrency over limit, deferred: %v, app: %s", l.retryGap(), app)
+		return nil, &DeferredError{ReleaseAt: time.Now().Add(l.retryGap()), Reason: "concurrency limit"}
+	}
+
+	return func() {
+		if err := l.limiter.ReleaseSlot(context.Background(), key, token); err != nil {
+			logger.ErrorContextf(ctx, "release concurrency slot failed, app: %s, err: %v", app, err)
+		}
+	}, nil
+}
+
+// failed redis 异常时 failOpen 为 true 返回 nil 放行，否则延后 retryGap 重新投递
+func (l *appLimiter) failed(ctx context.Context, app, op string, err error) error {
+	if l.config.FailOpen {
+		logger.ErrorContextf(ctx, "%s failed, fail open, app: %s, err: %v", op, app, err)
+		return nil
+	}
+	logger.ErrorContextf(ctx, "%s failed, deferred: %v, app: %s, err: %v", op, l.retryGap(), app, err)
+	return &DeferredError{ReleaseAt: time.Now().Add(l.retryGap()), Reason: "rate limiter failure"}
+}
+
+func (l *appLimiter) retryGap() time.Duration {
+	return time.Duration(l.config.RetryGapMilliSeconds) * time.Millisecond
+}
+
+var _ limiter = &ratelimit.Limiter{}
+
+type limiter interface {
+	TakeToken(ctx context.Context, key string, perMinute, burst int) (time.Duration, error)
+	AcquireSlot(ctx context.Context, key, token string, limit int, lease time.Duration) (bool, error)
+	ReleaseSlot(ctx context.Context, key, token string) error
+}
@@ -0,0 +1,107 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"timer/common/conf"
+	"timer/pkg/ratelimit"
+	"timer/pkg/testenv"
+)
+
+func TestAcquireDefersOverConcurrency(t *testing.T) {
+	ctx := context.Background()
+	_, rdb := testenv.NewRedis(t)
+	l := newAppLimiter(ratelimit.NewLimiter(rdb), &conf.QuotaConfig{
+		Default:                 &conf.AppQuota{MaxConcurrency: 1},
+		ConcurrencyLeaseSeconds: 30,
+		RetryGapMilliSeconds:    200,
+	})
+
+	release, err := l.Acquire(ctx, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 槽位已满时不等待，延后 retryGap 重新投递
+	start := time.Now()
+	_, err = l.Acquire(ctx, "app")
+	var deferred *DeferredError
+	if !errors.As(err, &deferred) {
+		t.Fatalf("acquire over concurrency: %v, want *DeferredError", err)
+	}
+	if time.Since(start) > 100*time.Millisecond {
+		t.Errorf("acquire over concurrency blocked %v", time.Since(start))
+	}
+	if gap := deferred.ReleaseAt.Sub(start); gap < 200*time.Millisecond || gap > time.Second {
+		t.Errorf("deferred %v, want about 200ms", gap)
+	}
+
+	release()
+	if _, err := l.Acquire(ctx, "app"); err != nil {
+		t.Errorf("acquire released slot: %v", err)
+	}
+}
+
+func TestAcquireDefersOverRate(t *testing.T) {
+	ctx := context.Background()
+	_, rdb := testenv.NewRedis(t)
+	l := newAppLimiter(ratelimit.NewLimiter(rdb), &conf.QuotaConfig{
+		Default:                 &conf.AppQuota{MaxFiresPerMinute: 60, MaxConcurrency: 1},
+		ConcurrencyLeaseSeconds: 30,
+		RetryGapMilliSeconds:    200,
+	})
+
+	// 60 个令牌用完之后延后到补充一个令牌的时间
+	for i := 0; i < 60; i++ {
+		release, err := l.Acquire(ctx, "app")
+		if err != nil {
+			t.Fatalf("acquire %d: %v", i, err)
+		}
+		release()
+	}
+	start := time.Now()
+	_, err := l.Acquire(ctx, "app")
+	var deferred *DeferredError
+	if !errors.As(err, &deferred) {
+		t.Fatalf("acquire over rate: %v, want *DeferredError", err)
+	}
+	if gap := deferred.ReleaseAt.Sub(start); gap <= 0 || gap > time.Second {
+		t.Errorf("deferred %v, want (0, 1s]", gap)
+	}
+
+	// 令牌不足时释放已经占用的并发槽位，不限制触发次数之后可以直接拿到槽位
+	l.config.Default = &conf.AppQuota{MaxConcurrency: 1}
+	if _, err := l.Acquire(ctx, "app"); err != nil {
+		t.Errorf("slot is leaked by a deferred task: %v", err)
+	}
+}
+
+func TestAcquireRedisFailure(t *testing.T) {
+	ctx := context.Background()
+	for _, failOpen := range []bool{true, false} {
+		server, rdb := testenv.NewRedis(t)
+		l := newAppLimiter(ratelimit.NewLimiter(rdb), &conf.QuotaConfig{
+			Default:                 &conf.AppQuota{MaxFiresPerMinute: 60, MaxConcurrency: 1},
+			ConcurrencyLeaseSeconds: 30,
+			RetryGapMilliSeconds:    200,
+			FailOpen:                failOpen,
+		})
+		server.Close()
+
+		release, err := l.Acquire(ctx, "app")
+		if failOpen {
+			if err != nil || release == nil {
+				t.Errorf("fail open: release %v, err: %v", release != nil, err)
+				continue
+			}
+			release()
+			continue
+		}
+		var deferred *DeferredError
+		if !errors.As(err, &deferred) {
+			t.Errorf("fail closed: %v, want *DeferredError", err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"timer/common/conf"
+	"timer/common/consts"
+	"timer/common/model/po"
+	"timer/dao/maintenance"
+	"timer/pkg/logger"
+)
+
+// DeferredError task 被 defer 策略的维护窗口（task 已经标记为暂缓）或者 app 限流暂缓到 ReleaseAt 执行
+// 调用方需要把 task 移动到 ReleaseAt 所在的分片，不能再按原来的执行时间 ack
+type DeferredError struct {
+	ReleaseAt time.Time
+	// 暂缓的原因，例如 maintenance window、rate limit
+	Reason string
+}
+
+func (e *DeferredError) Error() string {
+	return fmt.Sprintf("task deferred by %s until %s", e.Reason, e.ReleaseAt.Format(time.RFC3339Nano))
+}
+
+// maintenanceService 在进程内缓存尚未结束的维护窗口，定期从数据库刷新
+// 执行每个 task 时都需要判断，不能每次都查数据库
+type maintenanceService struct {
+	once    sync.Once
+	dao     maintenanceDAO
+	config  *conf.MaintenanceConfig
+	mu      sync.RWMutex
+	windows []*po.MaintenanceWindow
+}
+
+func newMaintenanceService(dao maintenanceDAO, config *conf.MaintenanceConfig) *maintenanceService {
+	return &maintenanceService{
+		dao:    dao,
+		config: config,
+	}
+}
+
+// Start 先同步加载一次，之后定期刷新
+func (m *maintenanceService) Start(ctx context.Context) {
+	m.once.Do(func() {
+		m.refresh(ctx)
+		go func() {
+			ticker := time.NewTicker(time.Duration(m.config.RefreshSeconds) * time.Second)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					m.refresh(ctx)
+				}
+			}
+		}()
+	})
+}
+
+// refresh 加载失败时保留上一次的结果
+func (m *maintenanceService) refresh(ctx context.Context) {
+	windows, err := m.dao.GetWindows(ctx, maintenance.WithEndAfter(time.Now()), maintenance.WithStartAsc())
+	if err != nil {
+		logger.ErrorContextf(ctx, "refresh maintenance windows failed, err: %v", err)
+		return
+	}
+
+	m.mu.Lock()
+	m.windows = windows
+	m.mu.Unlock()
+}
+
+// Match 返回 app 在 t 时刻命中的维护窗口，没有命中时返回 nil
+// 同时命中多个窗口时 skip 优先，都是 defer 时取结束最晚的窗口，避免释放后再次被暂缓
+func (m *maintenanceService) Match(app string, t time.Time) *po.MaintenanceWindow {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var matched *po.MaintenanceWindow
+	for _, window := range m.windows {
+		if !window.Matches(app, t) {
+			continue
+		}
+		if window.Policy == string(consts.MaintenancePolicySkip) {
+			return window
+		}
+		if matched == nil || window.EndAt.After(matched.EndAt) {
+			matched = window
+		}
+	}
+	return matched
+}
+
+var _ maintenanceDAO = &maintenance.MaintenanceDao{}
+
+type maintenanceDAO interface {
+	GetWindows(ctx context.Context, opts ...maintenance.Option) ([]*po.MaintenanceWindow, error)
+}
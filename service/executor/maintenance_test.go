@@ -0,0 +1,118 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+	"timer/common/conf"
+	"timer/common/consts"
+	"timer/common/model/po"
+	"timer/common/model/vo"
+	"timer/common/utils"
+	"timer/dao/task"
+	"timer/pkg/bloom"
+	"timer/pkg/hash"
+	"timer/pkg/testenv"
+)
+
+func TestMatchPrefersSkip(t *testing.T) {
+	now := time.Now()
+	window := func(id uint, apps string, policy consts.MaintenancePolicy, end time.Duration) *po.MaintenanceWindow {
+		return &po.MaintenanceWindow{Model: gorm.Model{ID: id}, Apps: apps, StartAt: now.Add(-time.Minute), EndAt: now.Add(end), Policy: string(policy)}
+	}
+	m := &maintenanceService{windows: []*po.MaintenanceWindow{
+		window(1, "*", consts.MaintenancePolicyDefer, time.Hour),
+		window(2, "app", consts.MaintenancePolicyDefer, 2*time.Hour),
+		window(3, "app", consts.MaintenancePolicySkip, time.Minute),
+		window(4, "other", consts.MaintenancePolicySkip, time.Hour),
+	}}
+
+	cases := []struct {
+		app  string
+		at   time.Time
+		want uint
+	}{
+		{app: "app", at: now, want: 3},                        // skip 优先
+		{app: "app", at: now.Add(10 * time.Minute), want: 2},  // skip 窗口结束后，取结束最晚的 defer 窗口
+		{app: "another", at: now, want: 1},                    // * 命中全部 app
+		{app: "another", at: now.Add(3 * time.Hour), want: 0}, // 全部窗口都已经结束
+	}
+	for _, c := range cases {
+		var got uint
+		if window := m.Match(c.app, c.at); window != nil {
+			got = window.ID
+		}
+		if got != c.want {
+			t.Errorf("app %s at %v matched window %d, want %d", c.app, c.at, got, c.want)
+		}
+	}
+}
+
+func TestHold(t *testing.T) {
+	ctx := context.Background()
+	db := testenv.NewDB(t)
+	_, rdb := testenv.NewRedis(t)
+	w := &Worker{
+		taskDAO:     task.NewTaskDao(db),
+		bloomFilter: bloom.NewFilter(rdb, hash.NewSHA1Encryptor(), hash.NewMurmur3Encryptor()),
+		quotaConfig: &conf.QuotaConfig{Default: &conf.AppQuota{SpreadSeconds: 30}},
+	}
+
+	runTimer := time.Now().Truncate(time.Second)
+	if err := w.taskDAO.BatchCreateTasks(ctx, []*po.Task{
+		{App: "app", TimerID: 1, RunTimer: runTimer},
+		{App: "app", TimerID: 2, RunTimer: runTimer},
+		{App: "app", TimerID: 3, RunTimer: runTimer},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	getTask := func(timerID uint) *po.Task {
+		task, err := w.taskDAO.GetTask(ctx, task.WithTimerID(timerID), task.WithRunTimer(runTimer))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return task
+	}
+	endAt := runTimer.Add(time.Hour)
+	window := func(policy consts.MaintenancePolicy) *po.MaintenanceWindow {
+		return &po.MaintenanceWindow{Model: gorm.Model{ID: 1}, Apps: "app", StartAt: runTimer, EndAt: endAt, Policy: string(policy)}
+	}
+
+	// skip：标记为跳过并写入布隆过滤器，正常 ack
+	if err := w.hold(ctx, window(consts.MaintenancePolicySkip), &vo.Timer{ID: 1, App: "app"}, runTimer.UnixMilli()); err != nil {
+		t.Fatal(err)
+	}
+	if status := getTask(1).Status; status != consts.Skipped.ToInt() {
+		t.Errorf("skipped task status %d, want %d", status, consts.Skipped.ToInt())
+	}
+	if exist, err := w.bloomFilter.Exist(ctx, utils.GetTaskBloomFilterKey(utils.GetDayStr(runTimer)), utils.UnionTimerIDUnix(1, runTimer.UnixMilli())); err != nil || !exist {
+		t.Errorf("skipped task not in bloom filter, err: %v", err)
+	}
+
+	// defer：标记为暂缓，返回释放时间，由调用方重新投递；释放时间在 app 的打散窗口内偏移
+	err := w.hold(ctx, window(consts.MaintenancePolicyDefer), &vo.Timer{ID: 2, App: "app"}, runTimer.UnixMilli())
+	var deferred *DeferredError
+	if !errors.As(err, &deferred) {
+		t.Fatalf("defer hold returned %v, want *DeferredError", err)
+	}
+	if deferred.ReleaseAt.Before(endAt) || !deferred.ReleaseAt.Before(endAt.Add(30*time.Second)) {
+		t.Errorf("release at %v, want in [%v, %v)", deferred.ReleaseAt, endAt, endAt.Add(30*time.Second))
+	}
+	held := getTask(2)
+	if held.Status != consts.Held.ToInt() {
+		t.Errorf("deferred task status %d, want %d", held.Status, consts.Held.ToInt())
+	}
+	var hold vo.TaskHold
+	if err := json.Unmarshal([]byte(held.Output), &hold); err != nil || hold.ReleaseAt == nil || !hold.ReleaseAt.Equal(deferred.ReleaseAt) {
+		t.Errorf("held task output %s, err: %v, want release at %v", held.Output, err, deferred.ReleaseAt)
+	}
+
+	// 定时器配置了不抖动时在窗口结束时准时释放
+	err = w.hold(ctx, window(consts.MaintenancePolicyDefer), &vo.Timer{ID: 3, App: "app", JitterSeconds: po.JitterDisabled}, runTimer.UnixMilli())
+	if !errors.As(err, &deferred) || !deferred.ReleaseAt.Equal(endAt) {
+		t.Errorf("defer hold without jitter returned %v, want release at %v", err, endAt)
+	}
+}
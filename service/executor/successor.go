@@ -0,0 +1,152 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+	"text/template"
+	"time"
+	"timer/common/consts"
+	"timer/common/model/po"
+	"timer/common/model/vo"
+	"timer/dao/task"
+	"timer/pkg/logger"
+)
+
+// successorInput 后继的回调 body 模板中可以引用的前驱 task 字段，按时间触发的 task 没有前驱，全部为零值
+type successorInput struct {
+	TaskID   uint
+	TimerID  uint
+	RunTimer time.Time
+	Status   int
+	Output   string
+}
+
+var successorFuncs = template.FuncMap{
+	// json 把值编码成 json，例如 {{json .Output}} 把执行结果作为 json 字符串嵌入 body
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// renderSuccessor 开启了 passOutput 的定时器，回调 body 按 text/template 渲染前驱 task 的执行结果
+// 返回渲染后的副本，不能修改进程缓存中的定时器
+func (w *Worker) renderSuccessor(ctx context.Context, timer *vo.Timer, unix int64) (*vo.Timer, error) {
+	if !timer.PassOutput || timer.NotifyHTTPParam.Body == "" {
+		return timer, nil
+	}
+
+	var input successorInput
+	current, err := w.taskDAO.GetTask(ctx, task.WithTimerID(timer.ID), task.WithRunTimer(time.UnixMilli(unix)))
+	if err != nil {
+		return nil, fmt.Errorf("get task failed, timerID: %d, runTimer: %v, err: %w", timer.ID, time.UnixMilli(unix), err)
+	}
+	if current.ParentTaskID != 0 {
+		parent, err := w.taskDAO.GetTask(ctx, task.WithTaskID(current.ParentTaskID))
+		if err != nil {
+			return nil, fmt.Errorf("get parent task failed, id: %d, err: %w", current.ParentTaskID, err)
+		}
+		input = successorInput{
+			TaskID:   parent.ID,
+			TimerID:  parent.TimerID,
+			RunTimer: parent.RunTimer,
+			Status:   parent.Status,
+			Output:   parent.Output,
+		}
+	}
+
+	tmpl, err := template.New("body").Funcs(successorFuncs).Parse(timer.NotifyHTTPParam.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parse body template of timer: %d failed, err: %w", timer.ID, err)
+	}
+	var body strings.Builder
+	if err = tmpl.Execute(&body, input); err != nil {
+		return nil, fmt.Errorf("render body template of timer: %d failed, err: %w", timer.ID, err)
+	}
+
+	param := *timer.NotifyHTTPParam
+	param.Body = body.String()
+	rendered := *timer
+	rendered.NotifyHTTPParam = &param
+	return &rendered, nil
+}
+
+// maxSuccessorAttempts 后继 task 的执行时间与已有 task 冲突时，顺延 1ms 重试的最大次数
+const maxSuccessorAttempts = 10
+
+// fireSuccessors 按前驱 task 的执行结果触发后继定时器
+// 后继 task 落库之后写入触发器还没有读取的分片，与按时间触发的 task 一样由触发器执行
+// 落库之后没有写入分片的 task 由迁移器的 leader 重新投递，见 migrator.Worker.recoverSuccessors
+func (w *Worker) fireSuccessors(ctx context.Context, timer *vo.Timer, parent *po.Task) {
+	ids := timer.OnFailure
+	if parent.Status == consts.Successed.ToInt() {
+		ids = timer.OnSuccess
+	}
+
+	for _, id := range ids {
+		if err := w.fireSuccessor(ctx, id, timer.App, parent); err != nil {
+			logger.ErrorContextf(ctx, "fire successor timer failed, id: %d, parent task: %d, err: %v", id, parent.ID, err)
+		}
+	}
+}
+
+// fireSuccessor 为后继生成一个 task，记录前驱 task 的 id 后写入缓存
+func (w *Worker) fireSuccessor(ctx context.Context, timerID uint, app string, parent *po.Task) error {
+	successor, err := w.timerService.GetTimer(ctx, timerID)
+	if err != nil {
+		return fmt.Errorf("get successor timer failed: %w", err)
+	}
+	// 后继已经去激活或者不再属于同一个 app 时不触发
+	if successor.Status != consts.Enabled || successor.App != app {
+		logger.WarnContextf(ctx, "successor timer is unabled or not belongs to app: %s, id: %d, parent task: %d", app, timerID, parent.ID)
+		return nil
+	}
+
+	// 前驱重复执行时（例如 ack 失败后重新投递）后继只触发一次
+	created, err := w.taskDAO.GetTask(ctx, task.WithTimerID(timerID), task.WithParentTaskIDs([]uint{parent.ID}))
+	if err == nil {
+		logger.WarnContextf(ctx, "successor task already fired, timerID: %d, parent task: %d", timerID, parent.ID)
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("get successor task failed: %w", err)
+	}
+
+	lookahead := time.Duration(w.schedulerConfig.LookaheadMilliSeconds) * time.Millisecond
+	runTimer := time.UnixMilli(w.triggerConfig.GetUnreadAfter(time.Now(), lookahead).UnixMilli())
+	if created, err = w.createSuccessorTask(ctx, timerID, app, parent, runTimer); err != nil {
+		return err
+	}
+
+	logger.InfoContextf(ctx, "fire successor timer: %d, parent task: %d, runTimer: %v", timerID, parent.ID, created.RunTimer)
+	return w.taskCache.BatchCreateTasks(ctx, []*po.Task{created})
+}
+
+// createSuccessorTask 插入后继 task，与已有 task 的执行时间冲突时插入会被忽略，执行时间顺延 1ms 重试
+func (w *Worker) createSuccessorTask(ctx context.Context, timerID uint, app string, parent *po.Task, runTimer time.Time) (*po.Task, error) {
+	for i := 0; i < maxSuccessorAttempts; i++ {
+		if err := w.taskDAO.BatchCreateTasks(ctx, []*po.Task{{
+			App:          app,
+			TimerID:      timerID,
+			Status:       consts.NotRunned.ToInt(),
+			RunTimer:     runTimer,
+			ParentTaskID: parent.ID,
+		}}); err != nil {
+			return nil, fmt.Errorf("create successor task failed: %w", err)
+		}
+
+		created, err := w.taskDAO.GetTask(ctx, task.WithTimerID(timerID), task.WithRunTimer(runTimer))
+		if err != nil {
+			return nil, fmt.Errorf("get successor task failed: %w", err)
+		}
+		if created.ParentTaskID == parent.ID {
+			return created, nil
+		}
+		runTimer = runTimer.Add(time.Millisecond)
+	}
+	return nil, fmt.Errorf("successor task conflicts with existing tasks %d times, runTimer: %v", maxSuccessorAttempts, runTimer)
+}
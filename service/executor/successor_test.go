@@ -0,0 +1,97 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+	"timer/common/conf"
+	"timer/common/consts"
+	"timer/common/model/po"
+	"timer/common/model/vo"
+	"timer/common/utils"
+	"timer/dao/bucket"
+	"timer/dao/task"
+	"timer/dao/timer"
+	"timer/pkg/testenv"
+)
+
+func TestFireSuccessors(t *testing.T) {
+	ctx := context.Background()
+	db := testenv.NewDB(t)
+	server, rdb := testenv.NewRedis(t)
+	schedulerConfig := &conf.SchedulerAppConfig{BucketsNum: 1, SliceKeyApp: "timer", LookaheadMilliSeconds: 2000}
+	migratorConfig := &conf.MigratorAppConfig{CacheBatchSize: 100}
+	timerDAO, taskDAO := timer.NewTimerDao(db), task.NewTaskDao(db)
+	w := &Worker{
+		timerService:    NewTimerService(timerDAO, taskDAO, migratorConfig),
+		taskDAO:         taskDAO,
+		taskCache:       task.NewTaskCache(rdb, bucket.NewBucketDao(rdb, schedulerConfig), schedulerConfig, migratorConfig),
+		triggerConfig:   &conf.TriggerAppConfig{Engine: conf.TriggerEngineTimeWheel},
+		schedulerConfig: schedulerConfig,
+	}
+
+	timers := []*po.Timer{
+		{App: "app", Name: "parent", Status: consts.Enabled.ToInt(), NotifyHTTPParam: "{}"},
+		{App: "app", Name: "successor", Status: consts.Enabled.ToInt(), NotifyHTTPParam: "{}"},
+		{App: "app", Name: "disabled", Status: consts.Unabled.ToInt(), NotifyHTTPParam: "{}"},
+	}
+	if err := db.Table(po.TimerTable).Create(timers).Error; err != nil {
+		t.Fatal(err)
+	}
+	successorID, disabledID := timers[1].ID, timers[2].ID
+
+	// 时间轮写入下一个还没有被认领的分片的起点，预先占用候选的执行时间，后继 task 需要顺延 1ms
+	next := time.Now().Truncate(time.Minute).Add(time.Minute)
+	candidates := []time.Time{next, next.Add(time.Minute)}
+	var occupied []*po.Task
+	for _, at := range candidates {
+		occupied = append(occupied, &po.Task{App: "app", TimerID: successorID, RunTimer: at})
+	}
+	parent := &po.Task{App: "app", TimerID: timers[0].ID, RunTimer: time.Now().Truncate(time.Second), Status: consts.Successed.ToInt()}
+	if err := taskDAO.BatchCreateTasks(ctx, append(occupied, parent)); err != nil {
+		t.Fatal(err)
+	}
+	parent, err := taskDAO.GetTask(ctx, task.WithTimerID(parent.TimerID), task.WithRunTimer(parent.RunTimer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vTimer := &vo.Timer{ID: parent.TimerID, App: "app", OnSuccess: []uint{successorID, disabledID}, OnFailure: []uint{disabledID}}
+	// 前驱重复执行时后继只触发一次
+	for i := 0; i < 2; i++ {
+		w.fireSuccessors(ctx, vTimer, parent)
+	}
+
+	successors, err := taskDAO.GetTasks(ctx, task.WithParentTaskIDs([]uint{parent.ID}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(successors) != 1 || successors[0].TimerID != successorID {
+		t.Fatalf("parent task %d fired %d successor tasks, want 1 of timer %d", parent.ID, len(successors), successorID)
+	}
+	successor := successors[0]
+	if successor.Status != consts.NotRunned.ToInt() {
+		t.Errorf("successor task status %d, want %d", successor.Status, consts.NotRunned.ToInt())
+	}
+	var bumped bool
+	for _, at := range candidates {
+		bumped = bumped || successor.RunTimer.Equal(at.Add(time.Millisecond))
+	}
+	if !bumped {
+		t.Errorf("successor task run at %v, want 1ms after one of %v", successor.RunTimer, candidates)
+	}
+
+	// 后继 task 按执行时间写入对应的分片，由触发器执行
+	key := utils.NewSliceKey("timer", successor.RunTimer, 0).String()
+	member := utils.UnionTimerIDUnix(successorID, successor.RunTimer.UnixMilli())
+	score, err := server.ZScore(key, member)
+	if err != nil {
+		t.Fatalf("successor task not found in %s: %v", key, err)
+	}
+	if int64(score) != successor.RunTimer.UnixMilli() {
+		t.Errorf("successor task scored %v in %s, want %d", score, key, successor.RunTimer.UnixMilli())
+	}
+	if members, _ := server.ZMembers(key); len(members) != 1 {
+		t.Errorf("%d members in %s, want 1", len(members), key)
+	}
+}
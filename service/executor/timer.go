@@ -20,10 +20,10 @@ type TimerService struct {
 	stop     func()
 	timers   map[uint]*vo.Timer
 	timerDAO timerDAO
-	taskDAO  *task.TaskDao
+	taskDAO  taskDAO
 }
 
-func NewTimerService(timerDAO *timer.TimerDao, taskDAO *task.TaskDao, conf *conf.MigratorAppConfig) *TimerService {
+func NewTimerService(timerDAO timer.Repository, taskDAO task.Repository, conf *conf.MigratorAppConfig) *TimerService {
 	return &TimerService{
 		config:   conf,
 		timers:   make(map[uint]*vo.Timer),
@@ -137,9 +137,17 @@ func (t *TimerService) Stop() {
 	t.stop()
 }
 
-var _ timerDAO = &timer.TimerDao{}
+var _ timerDAO = timer.Repository(nil)
+var _ taskDAO = task.Repository(nil)
 
 type timerDAO interface {
 	GetTimer(context.Context, ...timer.Option) (*po.Timer, error)
 	GetTimers(ctx context.Context, opts ...timer.Option) ([]*po.Timer, error)
 }
+
+type taskDAO interface {
+	GetTask(ctx context.Context, opts ...task.Option) (*po.Task, error)
+	GetTasks(ctx context.Context, opts ...task.Option) ([]*po.Task, error)
+	UpdateTask(ctx context.Context, task *po.Task) error
+	BatchCreateTasks(ctx context.Context, tasks []*po.Task) error
+}
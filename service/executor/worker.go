@@ -7,33 +7,54 @@ import (
 	nethttp "net/http"
 	"strings"
 	"time"
+	"timer/common/conf"
 	"timer/common/consts"
+	"timer/common/model/po"
 	"timer/common/model/vo"
 	"timer/common/utils"
+	"timer/dao/maintenance"
 	"timer/dao/task"
 	"timer/pkg/bloom"
 	"timer/pkg/logger"
+	"timer/pkg/ratelimit"
+	"timer/pkg/redis"
 	"timer/pkg/xhttp"
 )
 
 type Worker struct {
 	timerService *TimerService
-	taskDAO      *task.TaskDao
+	taskDAO      taskDAO
+	taskCache    *task.TaskCache
 	httpClient   *xhttp.JSONClient
 	bloomFilter  *bloom.Filter
+	limiter      *appLimiter
+	maintenance  *maintenanceService
+	quotaConfig  *conf.QuotaConfig
+	// 后继 task 写入触发器还没有读取的分片
+	triggerConfig   *conf.TriggerAppConfig
+	schedulerConfig *conf.SchedulerAppConfig
 }
 
-func NewWorker(timerService *TimerService, taskDAO *task.TaskDao, httpClient *xhttp.JSONClient, bloomFilter *bloom.Filter) *Worker {
+func NewWorker(timerService *TimerService, taskDAO task.Repository, taskCache *task.TaskCache, httpClient *xhttp.JSONClient, bloomFilter *bloom.Filter,
+	limiter *ratelimit.Limiter, maintenanceDAO *maintenance.MaintenanceDao, quotaConfig *conf.QuotaConfig, maintenanceConfig *conf.MaintenanceConfig,
+	triggerConfig *conf.TriggerAppConfig, schedulerConfig *conf.SchedulerAppConfig) *Worker {
 	return &Worker{
-		timerService: timerService,
-		taskDAO:      taskDAO,
-		httpClient:   httpClient,
-		bloomFilter:  bloomFilter,
+		timerService:    timerService,
+		taskDAO:         taskDAO,
+		taskCache:       taskCache,
+		httpClient:      httpClient,
+		bloomFilter:     bloomFilter,
+		limiter:         newAppLimiter(limiter, quotaConfig),
+		maintenance:     newMaintenanceService(maintenanceDAO, maintenanceConfig),
+		quotaConfig:     quotaConfig,
+		triggerConfig:   triggerConfig,
+		schedulerConfig: schedulerConfig,
 	}
 }
 
 func (w *Worker) Start(ctx context.Context) {
 	w.timerService.Start(ctx)
+	w.maintenance.Start(ctx)
 }
 
 func (w *Worker) Work(ctx context.Context, timerIDUnixKey string) error {
@@ -54,7 +75,8 @@ func (w *Worker) Work(ctx context.Context, timerIDUnixKey string) error {
 		// 查 mysql 判断 task 被执行过没有
 		// 这里不需要事务+独占锁，因为不会产生并发的情况，一个 task 只能在该 1 分钟后分布式锁过期，才可能被执行
 		task, err := w.taskDAO.GetTask(ctx, task.WithTimerID(timerID), task.WithRunTimer(time.UnixMilli(unix)))
-		if err == nil && task.Status != consts.NotRunned.ToInt() {
+		// 被维护窗口暂缓的 task 窗口结束后重新投递，同样需要执行
+		if err == nil && task.Status != consts.NotRunned.ToInt() && task.Status != consts.Held.ToInt() {
 			// 重复执行的任务
 			logger.WarnContextf(ctx, "task is already executed, timerID: %d, exec_time: %v", timerID, task.RunTimer)
 			return nil
@@ -80,12 +102,80 @@ func (w *Worker) executeAndPostProcess(ctx context.Context, timerID uint, unix i
 		return nil
 	}
 
+	// 处于维护窗口内的 app，按窗口的策略跳过或者延后到窗口结束
+	if window := w.maintenance.Match(timer.App, time.Now()); window != nil {
+		return w.hold(ctx, window, timer, unix)
+	}
+
+	// app 维度限流，超过配额时返回 *DeferredError，由触发器延后到预计可以拿到配额的时间重新投递
+	release, err := w.limiter.Acquire(ctx, timer.App)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	execTime := time.Now()
-	// 执行 task 的 http 回调请求
-	resp, err := w.execute(ctx, timer)
+	// 作为后继触发时，按模板把前驱 task 的执行结果渲染进回调 body，渲染失败视为执行失败
+	var resp map[string]interface{}
+	notifyTimer, err := w.renderSuccessor(ctx, timer, unix)
+	if err == nil {
+		// 执行 task 的 http 回调请求
+		resp, err = w.execute(ctx, notifyTimer)
+	}
 	// log.InfoContextf(ctx, "execute timer: %d, resp: %v, err: %v", timerID, resp, err)
 	// 加入布隆过滤器和更新 task 状态
-	return w.postProcess(ctx, resp, err, timer.App, timerID, unix, execTime)
+	task, err := w.postProcess(ctx, resp, err, timer.App, timerID, unix, execTime)
+	if err != nil {
+		return err
+	}
+
+	// 按执行结果触发后继定时器
+	w.fireSuccessors(ctx, timer, task)
+	return nil
+}
+
+// hold 维护窗口内不执行回调：skip 策略直接标记为跳过，defer 策略标记为暂缓并返回 *DeferredError
+func (w *Worker) hold(ctx context.Context, window *po.MaintenanceWindow, timer *vo.Timer, unix int64) error {
+	task, err := w.taskDAO.GetTask(ctx, task.WithTimerID(timer.ID), task.WithRunTimer(time.UnixMilli(unix)))
+	if err != nil {
+		return fmt.Errorf("get task failed, timerID: %d, runTimer: %v, err: %w", timer.ID, time.UnixMilli(unix), err)
+	}
+
+	hold := vo.TaskHold{
+		WindowID: window.ID,
+		Policy:   consts.MaintenancePolicy(window.Policy),
+		Reason:   window.Reason,
+	}
+	if hold.Policy == consts.MaintenancePolicySkip {
+		task.Status = consts.Skipped.ToInt()
+		// 与执行过的 task 一样写入布隆过滤器，不会再次执行
+		if err := w.bloomFilter.Set(ctx, utils.GetTaskBloomFilterKey(utils.GetDayStr(time.UnixMilli(unix))), utils.UnionTimerIDUnix(timer.ID, unix), consts.BloomFilterKeyExpireSeconds); err != nil {
+			logger.ErrorContextf(ctx, "set bloom filter failed, key: %s, err: %v", utils.GetTaskBloomFilterKey(utils.GetDayStr(time.UnixMilli(unix))), err)
+		}
+	} else {
+		// 窗口结束时按 app 的打散窗口错开释放，避免全部 task 同时回调，定时器配置了不抖动时准时释放
+		releaseAt := window.EndAt
+		if timer.JitterSeconds != po.JitterDisabled {
+			releaseAt = releaseAt.Add(utils.GetJitterOffset(timer.ID, task.RunTimer, w.quotaConfig.GetAppQuota(timer.App).GetSpread()))
+		}
+		hold.ReleaseAt = &releaseAt
+		task.Status = consts.Held.ToInt()
+	}
+
+	output, _ := json.Marshal(hold)
+	task.Output = string(output)
+	task.FencingToken = redis.GetFencingToken(ctx)
+	if err := w.taskDAO.UpdateTask(ctx, task); err != nil {
+		return fmt.Errorf("update held task failed, timerID: %d, runTimer: %v, fencing token: %d, err: %w", timer.ID, task.RunTimer, task.FencingToken, err)
+	}
+	logger.InfoContextf(ctx, "task held by maintenance window: %d, policy: %s, timerID: %d, runTimer: %v", window.ID, window.Policy, timer.ID, task.RunTimer)
+
+	// 暂缓的 task 由调用方移动到释放时间所在的分片，只有调用方知道 task 当前所在的分片以及处理方式
+	// 移动失败时 task 停留在暂缓状态，重新投递后再次被暂缓
+	if hold.ReleaseAt != nil {
+		return &DeferredError{ReleaseAt: *hold.ReleaseAt, Reason: "maintenance window"}
+	}
+	return nil
 }
 
 func (w *Worker) execute(ctx context.Context, timer *vo.Timer) (map[string]interface{}, error) {
@@ -109,7 +199,7 @@ func (w *Worker) execute(ctx context.Context, timer *vo.Timer) (map[string]inter
 	return resp, err
 }
 
-func (w *Worker) postProcess(ctx context.Context, resp map[string]interface{}, execErr error, app string, timerID uint, unix int64, execTime time.Time) error {
+func (w *Worker) postProcess(ctx context.Context, resp map[string]interface{}, execErr error, app string, timerID uint, unix int64, execTime time.Time) (*po.Task, error) {
 	// 布隆过滤器设置已经执行
 	if err := w.bloomFilter.Set(ctx, utils.GetTaskBloomFilterKey(utils.GetDayStr(time.UnixMilli(unix))), utils.UnionTimerIDUnix(timerID, unix), consts.BloomFilterKeyExpireSeconds); err != nil {
 		logger.ErrorContextf(ctx, "set bloom filter failed, key: %s, err: %v", utils.GetTaskBloomFilterKey(utils.GetDayStr(time.UnixMilli(unix))), err)
@@ -118,7 +208,7 @@ func (w *Worker) postProcess(ctx context.Context, resp map[string]interface{}, e
 	// 查询 mysql 的整个 task
 	task, err := w.taskDAO.GetTask(ctx, task.WithTimerID(timerID), task.WithRunTimer(time.UnixMilli(unix)))
 	if err != nil {
-		return fmt.Errorf("get task failed, timerID: %d, runTimer: %d, err: %w", timerID, time.UnixMilli(unix), err)
+		return nil, fmt.Errorf("get task failed, timerID: %d, runTimer: %v, err: %w", timerID, time.UnixMilli(unix), err)
 	}
 
 	respBody, _ := json.Marshal(resp)
@@ -129,7 +219,12 @@ func (w *Worker) postProcess(ctx context.Context, resp map[string]interface{}, e
 	} else {
 		task.Status = consts.Successed.ToInt()
 	}
+	// 携带调度分片锁的 fencing token，锁过期后旧的持有者无法覆盖新持有者的结果
+	task.FencingToken = redis.GetFencingToken(ctx)
 
 	// update task 数据库的状态
-	return w.taskDAO.UpdateTask(ctx, task)
+	if err := w.taskDAO.UpdateTask(ctx, task); err != nil {
+		return nil, fmt.Errorf("update task failed, timerID: %d, runTimer: %v, fencing token: %d, err: %w", timerID, time.UnixMilli(unix), task.FencingToken, err)
+	}
+	return task, nil
 }
@@ -2,101 +2,295 @@ package service
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"time"
 	"timer/common/conf"
 	"timer/common/consts"
+	"timer/common/model/po"
 	"timer/common/utils"
+	"timer/dao/calendar"
+	"timer/dao/migrator"
 	"timer/dao/task"
 	"timer/dao/timer"
 	"timer/pkg/cron"
+	"timer/pkg/election"
 	"timer/pkg/logger"
 	"timer/pkg/pool"
 	"timer/pkg/redis"
 )
 
 type Worker struct {
-	timerDAO    *timer.TimerDao
-	taskDAO     *task.TaskDao
+	timerDAO    timer.Repository
+	taskDAO     task.Repository
 	taskCache   *task.TaskCache
+	calendarDAO *calendar.CalendarDao
+	stateDAO    *migrator.StateDao
 	cronParser  *cron.Parser
 	lockService *redis.Client
 	appConfig   *conf.MigratorAppConfig
-	pool        pool.WorkerPool
+	quotaConfig *conf.QuotaConfig
+	// 重新投递的后继 task 写入触发器还没有读取的分片
+	triggerConfig   *conf.TriggerAppConfig
+	schedulerConfig *conf.SchedulerAppConfig
+	pool            pool.WorkerPool
+	elector         *election.Elector
+	// 已经记录过 cron 解析失败的定时器 id
+	invalidCrons sync.Map
 }
 
-func NewWorker(timerDAO *timer.TimerDao, taskDAO *task.TaskDao, taskCache *task.TaskCache, lockService *redis.Client,
-	cronParser *cron.Parser, appConfig *conf.MigratorAppConfig) *Worker {
+func NewWorker(timerDAO timer.Repository, taskDAO task.Repository, taskCache *task.TaskCache, calendarDAO *calendar.CalendarDao, stateDAO *migrator.StateDao,
+	lockService *redis.Client, cronParser *cron.Parser, appConfig *conf.MigratorAppConfig, quotaConfig *conf.QuotaConfig,
+	triggerConfig *conf.TriggerAppConfig, schedulerConfig *conf.SchedulerAppConfig) *Worker {
 	return &Worker{
-		pool:        pool.NewGoWorkerPool(appConfig.WorkersNum),
-		timerDAO:    timerDAO,
-		taskDAO:     taskDAO,
-		taskCache:   taskCache,
-		lockService: lockService,
-		cronParser:  cronParser,
-		appConfig:   appConfig,
+		pool:            pool.NewGoWorkerPool(appConfig.WorkersNum),
+		timerDAO:        timerDAO,
+		taskDAO:         taskDAO,
+		taskCache:       taskCache,
+		calendarDAO:     calendarDAO,
+		stateDAO:        stateDAO,
+		lockService:     lockService,
+		cronParser:      cronParser,
+		appConfig:       appConfig,
+		quotaConfig:     quotaConfig,
+		triggerConfig:   triggerConfig,
+		schedulerConfig: schedulerConfig,
+		elector: election.NewElector(utils.MigratorLeaderKey, lockService, int64(appConfig.LeaderLeaseSeconds),
+			time.Duration(appConfig.LeaderRenewSeconds)*time.Second),
 	}
 }
 
 // Start 一级迁移模块
 // 负责扫描全部的 timer 打点生成 task，存入数据库，存入 redis.zset
+// 全部节点通过 redis 租约选出一个 leader 执行迁移，leader 宕机后其他节点在一个租约时长内接替
 func (w *Worker) Start(ctx context.Context) error {
-	// 一级迁移时间 60 分钟，
-	// 但我觉得一级迁移时间 60 分钟，获取分布式锁的轮询时间应该是 1 分钟
-	// 这样有节点工作一半宕机了，分布式锁没延期，其他节点才能迅速获取到
-	ticker := time.NewTicker(time.Duration(w.appConfig.MigrateStepMinutes) * time.Minute)
+	w.elector.Run(ctx, w.lead)
+	return nil
+}
+
+// LastTick 返回迁移循环最近一次 tick 的时间，迁移循环未启动时返回零值
+// 非 leader 节点同样在参与竞选，以竞选循环的 tick 作为存活依据
+func (w *Worker) LastTick() time.Time {
+	return w.elector.LastTick()
+}
+
+// lead 当选 leader 后定期检查迁移进度，直到失去 leader 身份
+func (w *Worker) lead(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(w.appConfig.MigrateCheckSeconds) * time.Second)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	// 本任期内已经检查过的后继 task 的执行时间
+	var recovered time.Time
+	for {
 		logger.InfoContext(ctx, "migrator ticking...")
+		if err := w.migrateToWatermark(ctx); err != nil && ctx.Err() == nil {
+			logger.ErrorContextf(ctx, "migrate failed, err: %v", err)
+		}
+		var err error
+		if recovered, err = w.recoverSuccessors(ctx, recovered); err != nil && ctx.Err() == nil {
+			logger.ErrorContextf(ctx, "recover successors failed, err: %v", err)
+		}
+
 		select {
 		case <-ctx.Done():
-			return nil
-		default:
+			return
+		case <-ticker.C:
 		}
+	}
+}
 
-		// 获取 migrator 的每一个小时的分布式锁，获取得到则表示由该节点来处理改小时的模块。
-		locker := w.lockService.GetDistributionLock(utils.GetMigratorLockKey(utils.GetStartHour(time.Now())))
-		// 初设过期时间 20 min
-		if err := locker.Lock(ctx, int64(w.appConfig.MigrateTryLockMinutes)*int64(time.Minute/time.Second)); err != nil {
-			logger.ErrorContext(ctx, "migrator get lock failed, key: %s, err: %v", utils.GetMigratorLockKey(utils.GetStartHour(time.Now())), err)
-			continue
-		}
+// migrateToWatermark 从持久化的水位开始逐个时间段迁移，直到覆盖未来两个迁移步长
+// 每完成一个时间段推进一次水位，新的 leader 从上一任停下的地方继续
+func (w *Worker) migrateToWatermark(ctx context.Context) error {
+	step := time.Duration(w.appConfig.MigrateStepMinutes) * time.Minute
+	now := time.Now()
+	target := utils.GetStartHour(now.Add(2 * step))
 
-		// 获取分布式锁，代表获取该时间段的处理权
-		if err := w.migrate(ctx); err != nil {
-			logger.ErrorContext(ctx, "migrate failed, err: %v", err)
-			continue
+	start, err := w.stateDAO.GetWatermark(ctx)
+	if err != nil {
+		return err
+	}
+	if start.IsZero() {
+		// 首次迁移，与之前一样从下一个时间段开始
+		start = utils.GetStartHour(now.Add(step))
+	} else if cur := utils.GetStartHour(now); start.Before(cur) {
+		// 水位落后太多时，更早时间段的 task 已经没有执行的意义
+		start = cur
+	}
+
+	for ; start.Before(target); start = start.Add(step) {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
-		// 迁移成功过期时间设置为 120 分钟
-		_ = locker.ExpireLock(ctx, int64(w.appConfig.MigrateSuccessExpireMinutes)*int64(time.Minute/time.Second))
+		end := start.Add(step)
+		if err := w.migrate(ctx, start, end); err != nil {
+			return err
+		}
+		// 水位与 leader 任期保存在数据库中，redis 数据丢失不会影响迁移进度
+		if err := w.stateDAO.DoWithFence(ctx, redis.GetFencingToken(ctx), func(ctx context.Context) error {
+			return w.stateDAO.AdvanceWatermark(ctx, end)
+		}); err != nil {
+			return err
+		}
+		logger.InfoContextf(ctx, "migrator advance watermark to %s", end.Format(consts.HourFormat))
 	}
 	return nil
 }
 
-func (w *Worker) migrate(ctx context.Context) error {
-	// 返回所有的 定时器，牛逼，这么多啊
-	// 其实内存还算很小，就算 1 w 个定时器，每个定时器 0.1KB（实际小很多），也就 1 mb
-	timers, err := w.timerDAO.GetTimers(ctx, timer.WithStatus(int32(consts.Enabled.ToInt())))
+func (w *Worker) migrate(ctx context.Context, start, end time.Time) error {
+	// 按 id 分页加载截止时间落后于 end 的定时器，已经生成过该时间段 task 的定时器不再处理
+	var afterID uint
+	for {
+		timers, err := w.timerDAO.GetTimers(ctx,
+			timer.WithStatus(int32(consts.Enabled.ToInt())),
+			timer.WithGeneratedBefore(end),
+			timer.WithAfterID(afterID),
+			timer.WithIDAsc(),
+			timer.WithPageLimit(0, w.appConfig.TimerPageSize),
+		)
+		if err != nil {
+			return err
+		}
+		if len(timers) == 0 {
+			break
+		}
+
+		if err := w.generateTasks(ctx, timers, start, end); err != nil {
+			return err
+		}
+		afterID = timers[len(timers)-1].ID
+		if len(timers) < w.appConfig.TimerPageSize {
+			break
+		}
+	}
+
+	// log.InfoContext(ctx, "migrator batch create db tasks susccess")
+	// 迁移完成后，将所有添加的 task 取出，添加到 redis 当中
+	return w.migrateToCache(ctx, start, end)
+}
+
+// generateTasks 为一页定时器生成截止时间到 end 之间的 task，并推进截止时间
+func (w *Worker) generateTasks(ctx context.Context, timers []*po.Timer, start, end time.Time) error {
+	rules, err := w.getCalendarRules(ctx, timers)
 	if err != nil {
 		return err
 	}
 
 	now := time.Now()
-	// 步长 60，即下一个 60 的时间
-	start, end := utils.GetStartHour(now.Add(time.Duration(w.appConfig.MigrateStepMinutes)*time.Minute)), utils.GetStartHour(now.Add(2*time.Duration(w.appConfig.MigrateStepMinutes)*time.Minute))
+	tasks := make([]*po.Task, 0, len(timers))
+	ids := make([]uint, 0, len(timers))
+	for _, timer := range timers {
+		// 只作为后继执行的定时器不按时间生成 task，同样推进截止时间，避免每轮都被重新扫描
+		if timer.IsSuccessorOnly() {
+			ids = append(ids, timer.ID)
+			continue
+		}
+
+		// 从已生成的截止时间之后开始，接替迁移落后的时间段时，已经过去的时间点不再生成 task
+		from := start
+		if timer.GeneratedThrough != nil && timer.GeneratedThrough.After(from) {
+			from = *timer.GeneratedThrough
+		}
+		if from.Before(now) {
+			from = now
+		}
+
+		nexts, err := w.cronParser.NextsBetween(timer.Cron, from, end)
+		if err != nil {
+			// 解析失败的定时器同样推进截止时间，不再在每一轮迁移中被重新扫描，错误只记录一次
+			if _, logged := w.invalidCrons.LoadOrStore(timer.ID, struct{}{}); !logged {
+				logger.ErrorContextf(ctx, "migrator parse cron of timer: %d failed, skip generating tasks, err: %v", timer.ID, err)
+			}
+			ids = append(ids, timer.ID)
+			continue
+		}
+		// 执行时间在抖动窗口内偏移，同一秒触发的定时器错开执行
+		window := timer.JitterWindow(w.quotaConfig.GetAppQuota(timer.App).GetSpread())
+		runTimers := timer.JitterTimes(nexts, window)
+		// 按偏移后的执行时间去掉被引用的日历排除的部分，偏移到排除日期上的执行同样不执行
+		runTimers = rules.Filter(timer, runTimers)
+		tasks = append(tasks, timer.BatchTasksFromTimer(runTimers)...)
+		ids = append(ids, timer.ID)
+	}
+
+	// 已经存在的 task 会被忽略，重复迁移同一个时间段是安全的
+	// 写入前在同一个事务中校验 leader 的 fencing token，已经被取代的 leader 无法继续写入
+	return w.stateDAO.DoWithFence(ctx, redis.GetFencingToken(ctx), func(ctx context.Context) error {
+		if err := w.taskDAO.BatchCreateTasks(ctx, tasks); err != nil {
+			return err
+		}
+		return w.timerDAO.UpdateGeneratedThrough(ctx, ids, end)
+	})
+}
+
+// getCalendarRules 一次加载一页定时器引用的全部日历，已经删除的日历忽略
+func (w *Worker) getCalendarRules(ctx context.Context, timers []*po.Timer) (po.CalendarRules, error) {
+	var ids []uint
+	seen := make(map[uint]bool)
 	for _, timer := range timers {
-		// 获取下一个时间段执行的 task
-		nexts, _ := w.cronParser.NextsBetween(timer.Cron, start, end)
-		// 根据时间创建 task，加入 mysql 中
-		if err := w.timerDAO.BatchCreateRecords(ctx, timer.BatchTasksFromTimer(nexts)); err != nil {
-			logger.ErrorContextf(ctx, "migrator batch create records for timer: %d failed, err: %v", timer.ID, err)
+		for _, id := range timer.GetCalendarIDs() {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
 		}
 	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
 
-	// log.InfoContext(ctx, "migrator batch create db tasks susccess")
-	// 迁移完成后，将所有添加的 task 取出，添加到 redis 当中
-	return w.migrateToCache(ctx, start, end)
+	calendars, err := w.calendarDAO.GetCalendars(ctx, calendar.WithIDs(ids))
+	if err != nil {
+		return nil, err
+	}
+	return po.NewCalendarRules(calendars)
+}
+
+// successorRecoverHorizon 新的 leader 检查的后继 task 的时间范围
+const successorRecoverHorizon = 24 * time.Hour
+
+// recoverSuccessors 重新投递执行时间在 [start, now-successorRecoverSeconds) 内仍然没有执行的后继 task，返回新的检查进度
+// 例如后继 task 落库之后、写入缓存之前节点宕机；start 为零值时从 successorRecoverHorizon 之前开始
+// 每个任期内每个 task 最多重新投递一次，定时器已经去激活等原因不会执行的 task 不会被反复投递
+func (w *Worker) recoverSuccessors(ctx context.Context, start time.Time) (time.Time, error) {
+	now := time.Now()
+	end := now.Add(-time.Duration(w.appConfig.SuccessorRecoverSeconds) * time.Second)
+	if start.IsZero() {
+		start = end.Add(-successorRecoverHorizon)
+	}
+	if !start.Before(end) {
+		return start, nil
+	}
+
+	// member 保持原来的执行时间，写入触发器还没有读取的分片
+	at := w.triggerConfig.GetUnreadAfter(now, time.Duration(w.schedulerConfig.LookaheadMilliSeconds)*time.Millisecond)
+	var afterID uint
+	for {
+		tasks, err := w.taskDAO.GetTasks(ctx,
+			task.WithSuccessorOnly(),
+			task.WithStatus(int32(consts.NotRunned.ToInt())),
+			task.WithStartTime(start),
+			task.WithEndTime(end),
+			task.WithAfterID(afterID),
+			task.WithIDAsc(),
+			task.WithPageLimit(0, w.appConfig.TimerPageSize),
+		)
+		if err != nil {
+			return start, err
+		}
+		for _, t := range tasks {
+			if err := w.taskCache.RequeueTask(ctx, t, at); err != nil {
+				return start, err
+			}
+			logger.WarnContextf(ctx, "requeue successor task: %d, timerID: %d, runTimer: %v, parent task: %d", t.ID, t.TimerID, t.RunTimer, t.ParentTaskID)
+		}
+		if len(tasks) < w.appConfig.TimerPageSize {
+			break
+		}
+		afterID = tasks[len(tasks)-1].ID
+	}
+	return end, nil
 }
 
 func (w *Worker) migrateToCache(ctx context.Context, start, end time.Time) error {
@@ -107,5 +301,21 @@ func (w *Worker) migrateToCache(ctx context.Context, start, end time.Time) error
 		return err
 	}
 	// log.InfoContext(ctx, "migrator batch get tasks susccess")
-	return w.taskCache.BatchCreateTasks(ctx, tasks)
+	err = w.taskCache.BatchCreateTasks(ctx, tasks)
+	// 部分批次写入失败时，只重试失败批次中的 task
+	for i := 0; i < w.appConfig.CacheRetryTimes; i++ {
+		var batchErr *task.BatchWriteError
+		if !errors.As(err, &batchErr) {
+			break
+		}
+
+		logger.WarnContextf(ctx, "migrator batch create cache tasks partially failed, retry: %d, err: %v", i+1, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(i+1) * time.Second):
+		}
+		err = w.taskCache.BatchCreateTasks(ctx, batchErr.FailedTasks())
+	}
+	return err
 }
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+	"timer/common/conf"
+	"timer/common/consts"
+	"timer/common/model/po"
+	"timer/common/utils"
+	"timer/dao/bucket"
+	"timer/dao/calendar"
+	"timer/dao/migrator"
+	"timer/dao/task"
+	"timer/dao/timer"
+	"timer/pkg/cron"
+	"timer/pkg/testenv"
+)
+
+func TestGenerateTasksSkipsInvalidCron(t *testing.T) {
+	ctx := context.Background()
+	db := testenv.NewDB(t)
+	w := &Worker{
+		timerDAO:    timer.NewTimerDao(db),
+		taskDAO:     task.NewTaskDao(db),
+		calendarDAO: calendar.NewCalendarDao(db),
+		stateDAO:    migrator.NewStateDao(db),
+		cronParser:  cron.NewCronParser(),
+		quotaConfig: conf.GetDefaultQuotaConfig(),
+	}
+
+	timers := []*po.Timer{
+		{App: "app", Name: "invalid", Status: consts.Enabled.ToInt(), Cron: "not a cron", NotifyHTTPParam: "{}"},
+		{App: "app", Name: "valid", Status: consts.Enabled.ToInt(), Cron: "0 * * * * * *", NotifyHTTPParam: "{}"},
+	}
+	if err := db.Table(po.TimerTable).Create(timers).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now().Truncate(time.Hour).Add(time.Hour)
+	end := start.Add(time.Hour)
+	// 连续两轮迁移，第二轮不应该再处理解析失败的定时器
+	for i := 0; i < 2; i++ {
+		if err := w.generateTasks(ctx, timers, start, end); err != nil {
+			t.Fatalf("round %d: %v", i, err)
+		}
+	}
+
+	for _, want := range timers {
+		got, err := w.timerDAO.GetTimer(ctx, timer.WithID(want.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.GeneratedThrough == nil || !got.GeneratedThrough.Equal(end) {
+			t.Errorf("timer %s generated through %v, want %v", want.Name, got.GeneratedThrough, end)
+		}
+	}
+
+	pending, err := w.timerDAO.GetTimers(ctx, timer.WithStatus(int32(consts.Enabled.ToInt())), timer.WithGeneratedBefore(end))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("%d timers still pending migration", len(pending))
+	}
+
+	tasks, err := w.taskDAO.GetTasks(ctx, task.WithTimerID(timers[1].ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 60 {
+		t.Errorf("valid timer generated %d tasks, want 60", len(tasks))
+	}
+}
+
+func TestRecoverSuccessors(t *testing.T) {
+	ctx := context.Background()
+	db := testenv.NewDB(t)
+	server, rdb := testenv.NewRedis(t)
+	schedulerConfig := &conf.SchedulerAppConfig{BucketsNum: 1, SliceKeyApp: "timer", LookaheadMilliSeconds: 2000}
+	appConfig := &conf.MigratorAppConfig{TimerPageSize: 1, CacheBatchSize: 100, SuccessorRecoverSeconds: 120}
+	w := &Worker{
+		taskDAO:         task.NewTaskDao(db),
+		taskCache:       task.NewTaskCache(rdb, bucket.NewBucketDao(rdb, schedulerConfig), schedulerConfig, appConfig),
+		appConfig:       appConfig,
+		triggerConfig:   &conf.TriggerAppConfig{Engine: conf.TriggerEngineDelayQueue},
+		schedulerConfig: schedulerConfig,
+	}
+
+	stale := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+	tasks := []*po.Task{
+		// 落库之后没有写入缓存的后继 task，需要重新投递
+		{App: "app", TimerID: 1, RunTimer: stale, ParentTaskID: 100},
+		{App: "app", TimerID: 2, RunTimer: stale, ParentTaskID: 101},
+		// 已经执行的后继 task、按时间触发的 task、还没有超时的后继 task 都不投递
+		{App: "app", TimerID: 3, RunTimer: stale, ParentTaskID: 102, Status: consts.Successed.ToInt()},
+		{App: "app", TimerID: 4, RunTimer: stale},
+		{App: "app", TimerID: 5, RunTimer: time.Now().Truncate(time.Second), ParentTaskID: 103},
+	}
+	if err := w.taskDAO.BatchCreateTasks(ctx, tasks); err != nil {
+		t.Fatal(err)
+	}
+
+	recovered, err := w.recoverSuccessors(ctx, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requeued := make(map[string]bool)
+	for _, key := range server.Keys() {
+		members, err := server.ZMembers(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, member := range members {
+			requeued[member] = true
+		}
+	}
+	if len(requeued) != 2 {
+		t.Errorf("requeued %d tasks, want 2: %v", len(requeued), requeued)
+	}
+	for _, timerID := range []uint{1, 2} {
+		// member 保持原来的执行时间，执行器按它定位 task
+		if member := utils.UnionTimerIDUnix(timerID, stale.UnixMilli()); !requeued[member] {
+			t.Errorf("stranded successor task %s not requeued", member)
+		}
+	}
+
+	// 同一个任期内已经检查过的 task 不再重复投递
+	server.FlushAll()
+	if _, err := w.recoverSuccessors(ctx, recovered); err != nil {
+		t.Fatal(err)
+	}
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Errorf("requeued again into %v", keys)
+	}
+}
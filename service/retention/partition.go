@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"timer/common/conf"
+	"timer/common/model/po"
+	"timer/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+const (
+	partitionPrefix  = "p"
+	partitionLayout  = "20060102"
+	maxValuePartName = "pmax"
+)
+
+// PartitionManager 管理 task 表按 run_timer 的 MySQL range 分区，每天一个分区
+// task 表需要提前转换为分区表，例如：
+//
+//	ALTER TABLE task DROP PRIMARY KEY, ADD PRIMARY KEY (id, run_timer)
+//	PARTITION BY RANGE COLUMNS(run_timer) (PARTITION pmax VALUES LESS THAN (MAXVALUE));
+type PartitionManager struct {
+	db       *gorm.DB
+	dbConfig *conf.DatabaseConfig
+	config   *conf.RetentionConfig
+}
+
+func NewPartitionManager(db *gorm.DB, dbConfig *conf.DatabaseConfig, config *conf.RetentionConfig) *PartitionManager {
+	return &PartitionManager{
+		db:       db,
+		dbConfig: dbConfig,
+		config:   config,
+	}
+}
+
+func (m *PartitionManager) enabled() bool {
+	return m.config.Partition != nil && m.config.Partition.Enabled && m.dbConfig.Driver == conf.DriverMySQL
+}
+
+// Ensure 在 pmax 分区之前补齐从今天起 FutureDays 天的分区
+func (m *PartitionManager) Ensure(ctx context.Context) error {
+	if !m.enabled() {
+		return nil
+	}
+
+	partitions, err := m.partitions(ctx)
+	if err != nil {
+		return err
+	}
+	if len(partitions) == 0 {
+		logger.WarnContextf(ctx, "table %s is not partitioned by run_timer, skip partition management", po.TaskTable)
+		return nil
+	}
+	if _, ok := partitions[time.Time{}]; !ok {
+		return fmt.Errorf("table %s has no %s partition", po.TaskTable, maxValuePartName)
+	}
+
+	// 只能在已有分区的上界之后追加
+	last := time.Time{}
+	for day := range partitions {
+		if day.After(last) {
+			last = day
+		}
+	}
+
+	today := startOfDay(time.Now())
+	var defs []string
+	for i := 0; i <= m.config.Partition.FutureDays; i++ {
+		day := today.AddDate(0, 0, i)
+		if !day.After(last) {
+			continue
+		}
+		defs = append(defs, fmt.Sprintf("PARTITION %s VALUES LESS THAN ('%s')",
+			partitionName(day), day.AddDate(0, 0, 1).Format("2006-01-02")))
+	}
+	if len(defs) == 0 {
+		return nil
+	}
+
+	defs = append(defs, fmt.Sprintf("PARTITION %s VALUES LESS THAN (MAXVALUE)", maxValuePartName))
+	return m.db.WithContext(ctx).Exec(fmt.Sprintf("ALTER TABLE %s REORGANIZE PARTITION %s INTO (%s)",
+		po.TaskTable, maxValuePartName, strings.Join(defs, ", "))).Error
+}
+
+// Prune 删除整个范围都早于 cutoff 的空分区，分区内的数据已经由归档流程处理
+// 仍有数据的分区说明存在未完成的 task，保留并告警
+func (m *PartitionManager) Prune(ctx context.Context, cutoff time.Time) error {
+	if !m.enabled() {
+		return nil
+	}
+
+	partitions, err := m.partitions(ctx)
+	if err != nil {
+		return err
+	}
+
+	days := make([]time.Time, 0, len(partitions))
+	for day := range partitions {
+		if !day.IsZero() && !day.AddDate(0, 0, 1).After(cutoff) {
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Before(days[j])
+	})
+
+	for _, day := range days {
+		name := partitionName(day)
+		var cnt int64
+		if err := m.db.WithContext(ctx).Raw(fmt.Sprintf("SELECT COUNT(*) FROM %s PARTITION (%s)", po.TaskTable, name)).Scan(&cnt).Error; err != nil {
+			return err
+		}
+		if cnt > 0 {
+			logger.WarnContextf(ctx, "partition %s still has %d unfinished tasks, skip dropping", name, cnt)
+			continue
+		}
+		if err := m.db.WithContext(ctx).Exec(fmt.Sprintf("ALTER TABLE %s DROP PARTITION %s", po.TaskTable, name)).Error; err != nil {
+			return err
+		}
+		logger.InfoContextf(ctx, "drop partition %s of table %s", name, po.TaskTable)
+	}
+	return nil
+}
+
+// partitions 查询 task 表当前的分区，key 为分区对应的日期，pmax 分区以零值保存
+func (m *PartitionManager) partitions(ctx context.Context) (map[time.Time]string, error) {
+	var names []string
+	if err := m.db.WithContext(ctx).Raw("SELECT PARTITION_NAME FROM information_schema.PARTITIONS "+
+		"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND PARTITION_NAME IS NOT NULL", po.TaskTable).
+		Scan(&names).Error; err != nil {
+		return nil, err
+	}
+
+	partitions := make(map[time.Time]string, len(names))
+	for _, name := range names {
+		if name == maxValuePartName {
+			partitions[time.Time{}] = name
+			continue
+		}
+		day, err := time.ParseInLocation(partitionLayout, strings.TrimPrefix(name, partitionPrefix), time.Local)
+		if err != nil {
+			logger.WarnContextf(ctx, "unknown partition %s of table %s", name, po.TaskTable)
+			continue
+		}
+		partitions[day] = name
+	}
+	return partitions, nil
+}
+
+func partitionName(day time.Time) string {
+	return partitionPrefix + day.Format(partitionLayout)
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"timer/common/conf"
+	"timer/common/consts"
+	"timer/common/model/po"
+	"timer/common/utils"
+	"timer/dao/task"
+	"timer/pkg/archive"
+	"timer/pkg/logger"
+	"timer/pkg/redis"
+)
+
+// Worker 定期将超过保留天数的已完成 task 归档为 gzip 压缩的 JSONL 文件，再分批删除
+type Worker struct {
+	taskDAO     task.Repository
+	store       archive.Store
+	partition   *PartitionManager
+	lockService *redis.Client
+	config      *conf.RetentionConfig
+}
+
+func NewWorker(taskDAO task.Repository, partition *PartitionManager, lockService *redis.Client, config *conf.RetentionConfig) *Worker {
+	w := Worker{
+		taskDAO:     taskDAO,
+		partition:   partition,
+		lockService: lockService,
+		config:      config,
+	}
+
+	if config.Enabled {
+		store, err := archive.NewStore(config)
+		if err != nil {
+			panic(err)
+		}
+		w.store = store
+	}
+	return &w
+}
+
+func (w *Worker) Start(ctx context.Context) error {
+	if !w.config.Enabled {
+		return nil
+	}
+
+	interval := time.Duration(w.config.IntervalMinutes) * time.Minute
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		w.tick(ctx, interval)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) tick(ctx context.Context, interval time.Duration) {
+	// 每个执行周期一把锁，同一周期内只有一个节点执行清理
+	locker := w.lockService.GetDistributionLock(utils.GetRetentionLockKey(time.Now().Truncate(interval)))
+	if err := locker.Lock(ctx, int64(w.config.LockSeconds)); err != nil {
+		return
+	}
+
+	if err := w.partition.Ensure(ctx); err != nil {
+		logger.ErrorContextf(ctx, "retention ensure partitions failed, err: %v", err)
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -w.config.RetainDays)
+	archived, err := w.archive(ctx, cutoff, func() error {
+		return locker.ExpireLock(ctx, int64(w.config.LockSeconds))
+	})
+	if err != nil {
+		logger.ErrorContextf(ctx, "retention archive tasks before %v failed, archived: %d, err: %v", cutoff, archived, err)
+		// 失败释放锁，本周期内其他节点可以重试
+		_ = locker.Unlock(ctx)
+		return
+	}
+	logger.InfoContextf(ctx, "retention archive tasks before %v success, archived: %d", cutoff, archived)
+
+	if err := w.partition.Prune(ctx, cutoff); err != nil {
+		logger.ErrorContextf(ctx, "retention prune partitions failed, err: %v", err)
+	}
+
+	// 处理完成后锁保留到本周期结束，防止其他节点重复执行
+	_ = locker.ExpireLock(ctx, int64(interval/time.Second))
+}
+
+// archive 按 id 翻页处理 run_timer 早于 cutoff 的已完成 task，每批先归档成功再删除
+func (w *Worker) archive(ctx context.Context, cutoff time.Time, renew func() error) (int, error) {
+	var (
+		afterID  uint
+		archived int
+	)
+	for i := 0; i < w.config.MaxBatchesPerRun; i++ {
+		tasks, err := w.taskDAO.GetTasks(ctx,
+			task.WithEndTime(cutoff),
+			task.WithStatuses([]int32{int32(consts.Successed.ToInt()), int32(consts.Failed.ToInt()), int32(consts.Skipped.ToInt())}),
+			task.WithAfterID(afterID),
+			task.WithIDAsc(),
+			task.WithPageLimit(0, w.config.BatchSize),
+		)
+		if err != nil {
+			return archived, err
+		}
+		if len(tasks) == 0 {
+			return archived, nil
+		}
+
+		if err := w.put(ctx, tasks); err != nil {
+			return archived, err
+		}
+
+		ids := make([]uint, 0, len(tasks))
+		for _, t := range tasks {
+			ids = append(ids, t.ID)
+		}
+		if err := w.taskDAO.DeleteTasks(ctx, ids); err != nil {
+			return archived, err
+		}
+
+		archived += len(tasks)
+		afterID = ids[len(ids)-1]
+
+		// 续期失败说明锁已经被其他节点获取，停止本次清理
+		if err := renew(); err != nil {
+			return archived, err
+		}
+	}
+	return archived, nil
+}
+
+// archivedTask 归档文件中一行的格式
+type archivedTask struct {
+	ID       uint      `json:"id"`
+	App      string    `json:"app"`
+	TimerID  uint      `json:"timer_id"`
+	Output   string    `json:"output"`
+	RunTimer time.Time `json:"run_timer"`
+	CostTime int       `json:"cost_time"`
+	Status   int       `json:"status"`
+	// 写入执行结果时的 fencing token
+	FencingToken int64     `json:"fencing_token"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (w *Worker) put(ctx context.Context, tasks []*po.Task) error {
+	if w.store == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(zw)
+	for _, t := range tasks {
+		if err := encoder.Encode(&archivedTask{
+			ID:           t.ID,
+			App:          t.App,
+			TimerID:      t.TimerID,
+			Output:       t.Output,
+			RunTimer:     t.RunTimer,
+			CostTime:     t.CostTime,
+			Status:       t.Status,
+			FencingToken: t.FencingToken,
+			CreatedAt:    t.CreatedAt,
+			UpdatedAt:    t.UpdatedAt,
+		}); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	// 按归档日期分目录，文件名中的 id 范围保证重复归档时覆盖同一个文件
+	name := fmt.Sprintf("task/%s/task_%d_%d.jsonl.gz", time.Now().Format("20060102"), tasks[0].ID, tasks[len(tasks)-1].ID)
+	return w.store.Put(ctx, name, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"timer/common/conf"
+	"timer/common/consts"
+	"timer/common/model/po"
+	"timer/dao/task"
+	"timer/pkg/archive"
+	"timer/pkg/testenv"
+)
+
+// checkedStore 写入归档前检查 task 仍然存在，再交给 next 写入
+type checkedStore struct {
+	t       *testing.T
+	taskDAO *task.TaskDao
+	next    archive.Store
+	err     error
+}
+
+func (s *checkedStore) Put(ctx context.Context, name string, reader io.Reader, size int64) error {
+	raw, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	content, err := io.ReadAll(zr)
+	if err != nil {
+		return err
+	}
+
+	// 本批的 task 在归档写入之前不能被删除
+	tasks, err := s.taskDAO.GetTasks(ctx)
+	if err != nil {
+		return err
+	}
+	exists := make(map[uint]bool, len(tasks))
+	for _, task := range tasks {
+		exists[task.ID] = true
+	}
+	for _, line := range bytes.Split(bytes.TrimSpace(content), []byte("\n")) {
+		var row archivedTask
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		if !exists[row.ID] {
+			s.t.Errorf("task %d deleted before archived", row.ID)
+		}
+	}
+	if s.err != nil {
+		return s.err
+	}
+	return s.next.Put(ctx, name, bytes.NewReader(raw), size)
+}
+
+func newTestWorker(t *testing.T, store archive.Store) (*Worker, *task.TaskDao) {
+	t.Helper()
+	taskDAO := task.NewTaskDao(testenv.NewDB(t))
+	return &Worker{
+		taskDAO: taskDAO,
+		store:   store,
+		config:  &conf.RetentionConfig{BatchSize: 2, MaxBatchesPerRun: 10},
+	}, taskDAO
+}
+
+// createTasks 写入截止时间前的已完成 task、未完成 task，以及截止时间后的已完成 task
+func createTasks(t *testing.T, taskDAO *task.TaskDao, cutoff time.Time) {
+	t.Helper()
+	old := cutoff.Add(-time.Hour)
+	tasks := []*po.Task{
+		{App: "app", TimerID: 1, RunTimer: old, Status: consts.Successed.ToInt()},
+		{App: "app", TimerID: 2, RunTimer: old, Status: consts.Failed.ToInt()},
+		{App: "app", TimerID: 3, RunTimer: old, Status: consts.Skipped.ToInt()},
+		{App: "app", TimerID: 4, RunTimer: old, Status: consts.Running.ToInt()},
+		{App: "app", TimerID: 5, RunTimer: cutoff.Add(time.Hour), Status: consts.Successed.ToInt()},
+	}
+	if err := taskDAO.BatchCreateTasks(context.Background(), tasks); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func remainingTimerIDs(t *testing.T, taskDAO *task.TaskDao) map[uint]bool {
+	t.Helper()
+	tasks, err := taskDAO.GetTasks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := make(map[uint]bool, len(tasks))
+	for _, task := range tasks {
+		res[task.TimerID] = true
+	}
+	return res
+}
+
+func TestArchiveBeforeDelete(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := &checkedStore{t: t, next: archive.NewLocalStore(dir)}
+	w, taskDAO := newTestWorker(t, store)
+	store.taskDAO = taskDAO
+	cutoff := time.Now().Truncate(time.Second)
+	createTasks(t, taskDAO, cutoff)
+
+	archived, err := w.archive(ctx, cutoff, func() error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archived != 3 {
+		t.Errorf("archived %d tasks, want 3", archived)
+	}
+	// 未完成以及未到截止时间的 task 保留
+	if remaining := remainingTimerIDs(t, taskDAO); len(remaining) != 2 || !remaining[4] || !remaining[5] {
+		t.Errorf("remaining tasks of timers %v, want 4 and 5", remaining)
+	}
+
+	// 归档文件中按行保存被删除的 task
+	files, err := filepath.Glob(filepath.Join(dir, "task", "*", "*.jsonl.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	timerIDs := make(map[uint]bool)
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner := bufio.NewScanner(zr)
+		for scanner.Scan() {
+			var row archivedTask
+			if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+				t.Fatalf("invalid archived line %q: %v", scanner.Text(), err)
+			}
+			timerIDs[row.TimerID] = true
+		}
+		_ = f.Close()
+	}
+	if len(timerIDs) != 3 || !timerIDs[1] || !timerIDs[2] || !timerIDs[3] {
+		t.Errorf("archived tasks of timers %v, want 1, 2 and 3", timerIDs)
+	}
+}
+
+func TestArchiveFailedDeletesNothing(t *testing.T) {
+	ctx := context.Background()
+	store := &checkedStore{t: t, err: errors.New("upload failed")}
+	w, taskDAO := newTestWorker(t, store)
+	store.taskDAO = taskDAO
+	cutoff := time.Now().Truncate(time.Second)
+	createTasks(t, taskDAO, cutoff)
+
+	archived, err := w.archive(ctx, cutoff, func() error { return nil })
+	if !errors.Is(err, store.err) {
+		t.Errorf("archive err %v, want %v", err, store.err)
+	}
+	if archived != 0 {
+		t.Errorf("archived %d tasks, want 0", archived)
+	}
+	if remaining := remainingTimerIDs(t, taskDAO); len(remaining) != 5 {
+		t.Errorf("remaining tasks of timers %v after failed upload, want all 5", remaining)
+	}
+}
@@ -2,29 +2,41 @@ package scheduler
 
 import (
 	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 	"timer/common/conf"
 	"timer/common/utils"
+	"timer/dao/bucket"
 	"timer/pkg/logger"
+	"timer/pkg/membership"
 	"timer/pkg/redis"
 	"timer/service/trigger"
 )
 
 type Worker struct {
-	conf          *conf.SchedulerAppConfig
-	trigger       *trigger.Worker
-	lockService   lockService
-	bucketGetter  bucketGetter
-	minuteBuckets map[string]int
+	conf         *conf.SchedulerAppConfig
+	trigger      *trigger.Worker
+	lockService  lockService
+	bucketGetter bucketGetter
+	registry     nodeRegistry
+	// 当前节点正在处理或者已经处理完成的分片，key 为分片 key，value 为分片所在的分钟
+	// 已经认领的分片不再重复争抢分布式锁
+	claimed sync.Map
+	// 最近一次 ticker 触发的时间戳（毫秒），用于健康检查判断调度循环是否存活
+	lastTick atomic.Int64
 }
 
-func NewWorker(trigger *trigger.Worker, redisClient *redis.Client, conf *conf.SchedulerAppConfig) *Worker {
+func NewWorker(trigger *trigger.Worker, redisClient *redis.Client, buckets *bucket.BucketDao, registry *membership.Registry,
+	conf *conf.SchedulerAppConfig) *Worker {
 	return &Worker{
-		trigger:       trigger,
-		lockService:   redisClient,
-		bucketGetter:  redisClient,
-		conf:          conf,
-		minuteBuckets: make(map[string]int),
+		trigger:      trigger,
+		lockService:  redisClient,
+		bucketGetter: buckets,
+		registry:     registry,
+		conf:         conf,
 	}
 }
 
@@ -36,6 +48,7 @@ func (w *Worker) Start(ctx context.Context) error {
 	ticker := time.NewTicker(time.Duration(w.conf.TryLockGapMilliSeconds) * time.Millisecond)
 	defer ticker.Stop()
 
+	w.lastTick.Store(time.Now().UnixMilli())
 	for range ticker.C {
 		select {
 		case <-ctx.Done():
@@ -44,75 +57,177 @@ func (w *Worker) Start(ctx context.Context) error {
 		default:
 		}
 
+		w.lastTick.Store(time.Now().UnixMilli())
+
 		w.handleSlices(ctx)
+		w.releaseClaims(time.Now().Add(-2 * time.Minute))
 	}
 	return nil
 }
 
-func (w *Worker) handleSlices(ctx context.Context) {
-	// 获取 1 分钟内再分桶的数量
-	for i := 0; i < w.getValidBucket(ctx); i++ {
-		// 逐个获取分布式锁
-		w.handleSlice(ctx, i)
+// LastTick 返回调度循环最近一次 tick 的时间，调度循环未启动时返回零值
+func (w *Worker) LastTick() time.Time {
+	if ms := w.lastTick.Load(); ms > 0 {
+		return time.UnixMilli(ms)
 	}
+	return time.Time{}
 }
 
-func (w *Worker) getValidBucket(ctx context.Context) int {
-	return w.conf.BucketsNum
-}
-
-func (w *Worker) handleSlice(ctx context.Context, bucketID int) {
+func (w *Worker) handleSlices(ctx context.Context) {
 	now := time.Now()
 	// logger.InfoContextf(ctx, "scheduler_1 start: %v", time.Now())
 
 	// 处理前一分钟的，因为有可能前一分钟失败了，分布式锁没有续期。也就是说允许拿到锁后崩掉一次
-	go w.asyncHandleSlice(ctx, now.Add(-time.Minute), bucketID)
+	w.handleMinute(ctx, now.Add(-time.Minute))
 
 	// 处理当前分钟的
-	go w.asyncHandleSlice(ctx, now, bucketID)
+	w.handleMinute(ctx, now)
 
-	// 提前获取下一个分钟的，这样可以减少分钟开头的延迟，但是会增加无效的空转时间
-	// go w.asyncHandleSlice(ctx, now.Add(time.Minute), bucketID)
-	// 另外的解决方法：把分钟的前几秒划分到前一个分钟里去，实现：在 tableName format 那里处理一下
-	// 这里采用另外的解决方法（还没处理，后续 update）
+	// 临近下一分钟时提前获取下一个分钟的，加锁和首次拉取在分钟开始前完成，减少分钟开头的延迟
+	lookahead := time.Duration(w.conf.LookaheadMilliSeconds) * time.Millisecond
+	if next := now.Truncate(time.Minute).Add(time.Minute); lookahead > 0 && time.Until(next) <= lookahead {
+		w.handleMinute(ctx, next)
+	}
 
 	// logger.InfoContextf(ctx, "scheduler_1 end: %v", time.Now())
 }
 
-func (w *Worker) asyncHandleSlice(ctx context.Context, t time.Time, bucketID int) {
+// handleMinute 处理 t 所在分钟由当前节点负责的分桶
+func (w *Worker) handleMinute(ctx context.Context, t time.Time) {
+	// 获取该分钟内再分桶的数量
+	bucketsNum := w.getValidBucket(ctx, t)
+	minute := t.Truncate(time.Minute)
+	for i := 0; i < bucketsNum; i++ {
+		// 只处理分配给自己的分桶；还没有获取到存活节点时全部尝试，由分布式锁兜底
+		if owner := w.registry.Owner(strconv.Itoa(i)); owner != "" && owner != w.registry.NodeID() {
+			continue
+		}
+		// 已经认领的分片不再争抢
+		sliceKey := utils.NewSliceKey(w.conf.SliceKeyApp, minute, i)
+		if _, loaded := w.claimed.LoadOrStore(sliceKey.String(), minute); loaded {
+			continue
+		}
+		// 逐个获取分布式锁
+		go w.asyncHandleSlice(ctx, sliceKey)
+	}
+}
+
+// releaseClaims 清理 before 之前的分片认领记录，这些分片已经不会再被调度
+func (w *Worker) releaseClaims(before time.Time) {
+	w.claimed.Range(func(key, value interface{}) bool {
+		if minute, _ := value.(time.Time); minute.Before(before) {
+			w.claimed.Delete(key)
+		}
+		return true
+	})
+}
+
+// takeOver 锁的持有者心跳已经过期时释放其持有的锁，由当前节点接管，不必等待锁过期
+// 旧的持有者如果只是失联，之后的写入会因为 fencing token 过期而失败
+// 已经处理成功的分片保留锁作为成功标记，不能接管，否则分片会被重复处理
+func (w *Worker) takeOver(ctx context.Context, sliceKey utils.SliceKey) bool {
+	lockKey := sliceKey.LockKey()
+	holder, err := w.lockService.GetDistributionLockHolder(ctx, lockKey)
+	if err != nil || holder == "" {
+		return false
+	}
+
+	if succeeded, err := w.lockService.Exists(ctx, sliceKey.SuccessKey()); err != nil || succeeded {
+		return false
+	}
+	// 以 redis 中的心跳为准，本地的成员表可能还没有刷新到刚刚加入的节点
+	node := utils.GetNodeIDFromToken(holder)
+	if expired, err := w.registry.HeartbeatExpired(ctx, node); err != nil || !expired {
+		return false
+	}
+	if err := w.lockService.ReleaseDistributionLockOf(ctx, lockKey, holder); err != nil {
+		return false
+	}
+
+	logger.WarnContextf(ctx, "take over lock from offline node: %s, key: %s", node, lockKey)
+	return true
+}
+
+// getValidBucket 获取 t 所在分钟生效的分桶数量，分桶数量可以在运行时修改
+func (w *Worker) getValidBucket(ctx context.Context, t time.Time) int {
+	bucketsNum, err := w.bucketGetter.GetBucketsNum(ctx, t)
+	if err != nil {
+		// 分桶数量不确定时跳过本次 tick，避免按错误的分桶数量加锁处理
+		logger.ErrorContextf(ctx, "get buckets num failed, err: %v", err)
+		return 0
+	}
+	return bucketsNum
+}
+
+func (w *Worker) asyncHandleSlice(ctx context.Context, sliceKey utils.SliceKey) {
 	// logger.InfoContextf(ctx, "scheduler_2 start: %v", time.Now())
 	// defer func() {
 	// 	logger.InfoContextf(ctx, "scheduler_2 end: %v", time.Now())
 	// }()
 
-	locker := w.lockService.GetDistributionLock(utils.GetTimeBucketLockKey(t, bucketID))
+	lockKey := sliceKey.LockKey()
+	locker := w.lockService.GetDistributionLock(lockKey)
 
 	// 获取该分片的分布式锁
 	// 获取到分布式锁并设置分布式锁过期时间 70 s。（成功的话会再续期增加 130 s）
-	if err := locker.Lock(ctx, int64(w.conf.TryLockSeconds)); err != nil {
-		// log.WarnContextf(ctx, "get lock failed, err: %v, key: %s", err, utils.GetTimeBucketLockKey(t, bucketID))
+	// 锁被已经下线的节点持有时接管该锁
+	err := locker.Lock(ctx, int64(w.conf.TryLockSeconds))
+	if errors.Is(err, redis.ErrLockAcquiredByOthers) && w.takeOver(ctx, sliceKey) {
+		err = locker.Lock(ctx, int64(w.conf.TryLockSeconds))
+	}
+	if err != nil {
+		// log.WarnContextf(ctx, "get lock failed, err: %v, key: %s", err, lockKey)
+		// 取消认领，下一次 tick 重试，例如分桶刚刚换主，旧的负责节点还没有处理完
+		w.claimed.Delete(sliceKey.String())
 		return
 	}
 
-	logger.InfoContextf(ctx, "get scheduler lock success, key: %s", utils.GetTimeBucketLockKey(t, bucketID))
+	logger.InfoContextf(ctx, "get scheduler lock success, key: %s, fencing token: %d", lockKey, locker.FencingToken())
 
+	// 处理期间由看门狗续期，避免处理时间超过锁的过期时间后被其他节点重复处理
+	stopWatch := locker.Watch(ctx, int64(w.conf.TryLockSeconds))
 	ack := func() {
+		stopWatch()
+		// 先写入成功标记，持有者之后下线时其他节点也不会接管该锁
+		if _, err := w.lockService.SetNX(ctx, sliceKey.SuccessKey(), strconv.FormatInt(locker.FencingToken(), 10), int64(w.conf.SuccessExpireSeconds)); err != nil {
+			logger.ErrorContextf(ctx, "set success marker failed, key: %s, err: %v", sliceKey, err)
+		}
 		// 时间片执行成功后，更新的分布式锁时间为 130 s
 		if err := locker.ExpireLock(ctx, int64(w.conf.SuccessExpireSeconds)); err != nil {
-			logger.ErrorContextf(ctx, "expire lock failed, lock key: %s, err: %v", utils.GetTimeBucketLockKey(t, bucketID), err)
+			logger.ErrorContextf(ctx, "expire lock failed, lock key: %s, err: %v", lockKey, err)
 		}
 	}
 
-	// 处理该分片（也就是该分钟的某一个桶的全部任务）
-	if err := w.trigger.Work(ctx, utils.GetSliceMsgKey(t, bucketID), ack); err != nil {
+	// 处理该分片（也就是该分钟的某一个桶的全部任务），执行结果携带 fencing token 写入
+	if err := w.trigger.Work(redis.WithFencingToken(ctx, locker.FencingToken()), sliceKey, ack); err != nil {
 		logger.ErrorContextf(ctx, "trigger work failed, err: %v", err)
+		// 处理失败释放锁并取消认领，该分片可以立即重试
+		stopWatch()
+		w.claimed.Delete(sliceKey.String())
+		if err := locker.Unlock(ctx); err != nil {
+			logger.ErrorContextf(ctx, "unlock failed, lock key: %s, err: %v", lockKey, err)
+		}
 	}
 }
 
 type lockService interface {
 	GetDistributionLock(key string) redis.DistributeLocker
+	GetDistributionLockHolder(ctx context.Context, key string) (string, error)
+	ReleaseDistributionLockOf(ctx context.Context, key, token string) error
+	Exists(ctx context.Context, keys ...string) (bool, error)
+	SetNX(ctx context.Context, key, value string, expireSeconds int64) (interface{}, error)
 }
 
+var _ nodeRegistry = &membership.Registry{}
+
+type nodeRegistry interface {
+	NodeID() string
+	HeartbeatExpired(ctx context.Context, node string) (bool, error)
+	Owner(resource string) string
+}
+
+var _ bucketGetter = &bucket.BucketDao{}
+
 type bucketGetter interface {
-	Get(ctx context.Context, key string) (string, error)
+	GetBucketsNum(ctx context.Context, t time.Time) (int, error)
 }
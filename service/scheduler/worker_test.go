@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+	"timer/common/conf"
+	"timer/common/utils"
+	"timer/pkg/hash"
+	"timer/pkg/membership"
+	"timer/pkg/testenv"
+)
+
+func TestTakeOver(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	cases := []struct {
+		name string
+		// 持有者最近一次心跳的时间，零值表示没有心跳
+		heartbeat time.Time
+		succeeded bool
+		want      bool
+	}{
+		{name: "no heartbeat", want: true},
+		{name: "heartbeat expired", heartbeat: now.Add(-time.Minute), want: true},
+		// 刚刚加入的节点，本地成员表还没有刷新到
+		{name: "just joined", heartbeat: now, want: false},
+		{name: "success marker", heartbeat: now.Add(-time.Minute), succeeded: true, want: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server, rdb := testenv.NewRedis(t)
+			config := &conf.SchedulerAppConfig{SliceKeyApp: "timer", HeartbeatSeconds: 2, NodeExpireSeconds: 6, SuccessExpireSeconds: 130}
+			w := &Worker{
+				conf:        config,
+				lockService: rdb,
+				registry:    membership.NewRegistry(rdb, hash.NewMurmur3Encryptor(), config),
+			}
+
+			sliceKey := utils.NewSliceKey("timer", now, 1)
+			locker := rdb.GetDistributionLock(sliceKey.LockKey())
+			if err := locker.Lock(ctx, 70); err != nil {
+				t.Fatal(err)
+			}
+			if !c.heartbeat.IsZero() {
+				if _, err := server.ZAdd(utils.SchedulerNodesKey, float64(c.heartbeat.UnixMilli()), utils.GetCurrentNodeID()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if c.succeeded {
+				if err := server.Set(sliceKey.SuccessKey(), strconv.FormatInt(locker.FencingToken(), 10)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := w.takeOver(ctx, sliceKey); got != c.want {
+				t.Fatalf("take over: got %v, want %v", got, c.want)
+			}
+			holder, err := rdb.GetDistributionLockHolder(ctx, sliceKey.LockKey())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if released := holder == ""; released != c.want {
+				t.Errorf("lock released: %v, want %v", released, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package trigger
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"timer/common/model/vo"
+	"timer/common/utils"
+	"timer/pkg/logger"
+)
+
+// consume 以延迟队列的方式处理分片：原子地弹出到期的 task 移入处理中集合，执行结果落库之后才 ack
+// 没有到期的 task 时休眠到下一个到期时间，而不是按固定间隔轮询；执行失败没有 ack 的 task 在可见性超时后重新投递
+func (w *Worker) consume(ctx context.Context, key utils.SliceKey, ack func()) error {
+	var (
+		endTime    = key.Minute.Add(time.Minute)
+		preload    = time.Duration(w.config.PreloadMilliSeconds) * time.Millisecond
+		visibility = time.Duration(w.config.VisibilityTimeoutSeconds) * time.Second
+		maxWait    = time.Duration(w.config.MaxWaitMilliSeconds) * time.Millisecond
+		// 分钟结束后最多再等待一个可见性超时，期间重新投递执行失败的 task
+		deadline = endTime.Add(visibility)
+		// 不限制每次弹出的数量时为 -1
+		limit   = w.config.PopBatchSize
+		tracker fireTracker
+	)
+	if limit <= 0 {
+		limit = -1
+	}
+	defer tracker.wait()
+
+	for {
+		// 提前 preload 弹出，task 在执行时间之前已经准备好触发
+		now := time.Now()
+		tasks, next, pending, err := w.task.PopDueTasks(ctx, key, now.Add(preload), visibility, limit)
+		if err != nil {
+			return err
+		}
+
+		for _, task := range tasks {
+			if err := w.submit(ctx, task, task.RunTimer, &tracker, w.acker(ctx, key, task)); err != nil {
+				// 已经弹出的 task 在可见性超时后重新投递
+				return err
+			}
+		}
+
+		// 分钟结束并且全部 task 都已经 ack，该分片处理完成
+		if pending == 0 && !now.Before(endTime) {
+			break
+		}
+		if !now.Before(deadline) {
+			return fmt.Errorf("tasks not acked before deadline, key: %s, pending: %d", key, pending)
+		}
+
+		// 休眠到下一个到期时间，期间新写入分片的 task 最多延迟 maxWait 被发现；弹满一批时立即继续弹出
+		wait := maxWait
+		if !next.IsZero() && time.Until(next.Add(-preload)) < wait {
+			wait = time.Until(next.Add(-preload))
+		}
+		if limit > 0 && len(tasks) >= limit {
+			wait = 0
+		}
+		if wait <= 0 {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+
+	tracker.wait()
+	logger.InfoContextf(ctx, "fire delay of key: %s, %s", key, &tracker)
+
+	// 增加分布式锁的过期时间，那么就代表不会有人能抢到这个锁，证明完成成功
+	ack()
+	logger.InfoContextf(ctx, "ack success, key: %s", key)
+	return nil
+}
+
+// acker task 的执行结果落库之后 ack，见 settle
+func (w *Worker) acker(ctx context.Context, key utils.SliceKey, task *vo.Task) func(err error) {
+	return func(err error) {
+		w.settle(ctx, key, task, err)
+	}
+}
+
+// settle 按执行结果处理分片中的 task：执行成功的 ack，ack 失败的 task 会被重新投递，由 executor 去重
+// 执行失败的 task 不 ack，被暂缓的 task 移动到释放时间所在的分片，并返回释放时间
+func (w *Worker) settle(ctx context.Context, key utils.SliceKey, task *vo.Task, err error) (time.Time, bool) {
+	if releaseAt, deferred := w.deferTask(ctx, key, task, err); deferred || err != nil {
+		return releaseAt, deferred
+	}
+	if err := w.task.AckTask(ctx, key, task); err != nil {
+		logger.ErrorContextf(ctx, "ack task failed, key: %s, timerID: %d, runTimer: %v, err: %v", key, task.TimerID, task.RunTimer, err)
+	}
+	return time.Time{}, false
+}
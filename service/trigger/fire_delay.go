@@ -0,0 +1,54 @@
+package trigger
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// fireTracker 跟踪一个分片内的 task 是否都已经触发，并记录实际触发时间相对于执行时间的延迟
+type fireTracker struct {
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	delays []time.Duration
+}
+
+func (f *fireTracker) add() {
+	f.wg.Add(1)
+}
+
+// fired task 已经触发，delay 为触发延迟；没有触发（例如 ctx 结束）时 delay 传负数
+func (f *fireTracker) fired(delay time.Duration) {
+	defer f.wg.Done()
+	if delay < 0 {
+		return
+	}
+
+	f.mu.Lock()
+	f.delays = append(f.delays, delay)
+	f.mu.Unlock()
+}
+
+// wait 等待分片内的 task 全部触发
+func (f *fireTracker) wait() {
+	f.wg.Wait()
+}
+
+// String 触发延迟的分布：数量、p50、p99、最大值
+func (f *fireTracker) String() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.delays) == 0 {
+		return "count: 0"
+	}
+	sort.Slice(f.delays, func(i, j int) bool {
+		return f.delays[i] < f.delays[j]
+	})
+	percentile := func(p int) time.Duration {
+		return f.delays[(len(f.delays)-1)*p/100]
+	}
+	return fmt.Sprintf("count: %d, p50: %s, p99: %s, max: %s",
+		len(f.delays), percentile(50), percentile(99), f.delays[len(f.delays)-1])
+}
@@ -0,0 +1,103 @@
+package trigger
+
+import (
+	"context"
+	"testing"
+	"time"
+	"timer/common/conf"
+	"timer/common/model/po"
+	"timer/common/utils"
+	"timer/dao/bucket"
+	"timer/dao/task"
+	"timer/pkg/pool"
+	"timer/pkg/redis"
+	"timer/pkg/testenv"
+)
+
+// newRedisTaskService 基于内存 redis 的 TaskService，分片只有一个桶
+func newRedisTaskService(tb testing.TB, rdb *redis.Client) (*TaskService, *task.TaskCache) {
+	schedulerConf := &conf.SchedulerAppConfig{BucketsNum: 1, SliceKeyApp: "timer"}
+	buckets := bucket.NewBucketDao(rdb, schedulerConf)
+	cache := task.NewTaskCache(rdb, buckets, schedulerConf, &conf.MigratorAppConfig{CacheBatchSize: 1000})
+	return NewTaskService(task.NewTaskDao(testenv.NewDB(tb)), cache, buckets, schedulerConf), cache
+}
+
+// BenchmarkFireDelay 测量分钟开始时的触发延迟分布，即实际触发时间减去 RunTimer
+// before 模拟提前认领和预拉取之前：分片在分钟开始后的第一个 tick（平均晚 50ms）才开始处理，到达时间片开始时才拉取
+// after 为当前的默认配置：提前 2s 认领分片，每个时间片提前 200ms 拉取
+// go test -run ^$ -bench FireDelay -benchtime 1x ./service/trigger
+func BenchmarkFireDelay(b *testing.B) {
+	cases := []struct {
+		name    string
+		enter   time.Duration
+		preload time.Duration
+	}{
+		{name: "before", enter: 50 * time.Millisecond},
+		{name: "after", enter: -2 * time.Second, preload: 200 * time.Millisecond},
+	}
+
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				exec := measureFireDelay(b, c.enter, c.preload)
+				b.ReportMetric(float64(exec.percentile(50))/float64(time.Millisecond), "p50-ms")
+				b.ReportMetric(float64(exec.percentile(99))/float64(time.Millisecond), "p99-ms")
+				b.ReportMetric(float64(exec.percentile(100))/float64(time.Millisecond), "max-ms")
+			}
+		})
+	}
+}
+
+// measureFireDelay 分片中写入 2s 的 task，每秒开头 500 个，其余 500 个在这一秒内均匀分布
+// 在第一秒开始前后 enter 开始处理，按 1s 的轮询间隔、preload 的预拉取时间触发全部 task
+func measureFireDelay(b *testing.B, enter, preload time.Duration) *recordExecutor {
+	b.StopTimer()
+	ctx := context.Background()
+	_, rdb := testenv.NewRedis(b)
+	tasks, cache := newRedisTaskService(b, rdb)
+
+	// 留出提前进入的时间，并且 2s 的时间段不跨分钟
+	start := time.Now().Truncate(time.Second).Add(3 * time.Second)
+	if start.Add(2*time.Second).Minute() != start.Minute() {
+		start = start.Truncate(time.Minute).Add(time.Minute)
+	}
+	var pos []*po.Task
+	for sec := 0; sec < 2; sec++ {
+		second := start.Add(time.Duration(sec) * time.Second)
+		for i := 0; i < 1000; i++ {
+			runTimer := second
+			if i >= 500 {
+				runTimer = second.Add(time.Duration(i-500) * 2 * time.Millisecond)
+			}
+			pos = append(pos, &po.Task{TimerID: uint(sec*1000 + i + 1), RunTimer: runTimer})
+		}
+	}
+	if err := cache.BatchCreateTasks(ctx, pos); err != nil {
+		b.Fatal(err)
+	}
+
+	exec := &recordExecutor{}
+	w := &Worker{
+		task:     tasks,
+		executor: exec,
+		pool:     pool.NewGoWorkerPool(10000),
+		config:   &conf.TriggerAppConfig{ZRangeGapSeconds: 1, PreloadMilliSeconds: int(preload / time.Millisecond)},
+	}
+	key := utils.NewSliceKey("timer", start, 0)
+
+	time.Sleep(time.Until(start.Add(enter)))
+	b.StartTimer()
+	var tracker fireTracker
+	if err := w.task.LoadMinute(ctx, key); err != nil {
+		b.Fatal(err)
+	}
+	if err := w.poll(ctx, key, start, start.Add(2*time.Second), &tracker); err != nil {
+		b.Fatal(err)
+	}
+	b.StopTimer()
+
+	if len(exec.delays) != len(pos) {
+		b.Fatalf("fired %d tasks, want %d", len(exec.delays), len(pos))
+	}
+	return exec
+}
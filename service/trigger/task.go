@@ -7,47 +7,90 @@ import (
 	"timer/common/consts"
 	"timer/common/model/po"
 	"timer/common/model/vo"
+	"timer/common/utils"
+	"timer/dao/bucket"
 	"timer/dao/task"
 )
 
 type TaskService struct {
-	config *conf.SchedulerAppConfig
-	cache  *task.TaskCache
-	dao    taskDAO
+	config  *conf.SchedulerAppConfig
+	cache   *task.TaskCache
+	dao     taskDAO
+	buckets bucketGetter
 }
 
-func NewTaskService(dao *task.TaskDao, cache *task.TaskCache, confPrivder *conf.SchedulerAppConfig) *TaskService {
+func NewTaskService(dao task.Repository, cache *task.TaskCache, buckets *bucket.BucketDao, confPrivder *conf.SchedulerAppConfig) *TaskService {
 	return &TaskService{
-		config: confPrivder,
-		dao:    dao,
-		cache:  cache,
+		config:  confPrivder,
+		dao:     dao,
+		cache:   cache,
+		buckets: buckets,
 	}
 }
 
-func (t *TaskService) GetTasksByTime(ctx context.Context, key string, bucket int, start, end time.Time) ([]*vo.Task, error) {
-	// 先走 redis zset， timerID_runTime
-	if tasks, err := t.cache.GetTasksByTime(ctx, key, start.UnixMilli(), end.UnixMilli()); err == nil && len(tasks) > 0 {
-		return vo.NewTasks(tasks), nil
+// LoadMinute 分片的缓存不存在时（例如 redis 数据丢失），从 db 加载该分钟属于该桶的 task 写入缓存
+// 每个分片只在开始处理时检查一次，之后的轮询只读缓存，避免没有 task 的分片在每次轮询时都查询 db
+func (t *TaskService) LoadMinute(ctx context.Context, key utils.SliceKey) error {
+	exist, err := t.cache.Exists(ctx, key)
+	if err != nil || exist {
+		return err
 	}
 
-	// 倘若缓存 miss 再走 db
+	start, end := key.Minute, key.Minute.Add(time.Minute)
+
 	// 注意：db 是查 task 表；其实是 migrator 把 task 提取到 redis 中的
 	tasks, err := t.dao.GetTasks(ctx, task.WithStartTime(start), task.WithEndTime(end), task.WithStatus(int32(consts.NotRunned.ToInt())))
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	maxBucket := t.config.BucketsNum
+	// 按该分钟生效的分桶数量分桶，与写入缓存时一致
+	maxBucket, err := t.buckets.GetBucketsNum(ctx, start)
+	if err != nil {
+		return err
+	}
 	var validTask []*po.Task
 	for _, task := range tasks {
 		// 提取属于该桶的，因为 mysql 存的 task 并没有分桶，所有这里需要分桶
-		if task.TimerID%uint(maxBucket) != uint(bucket) {
+		if task.TimerID%uint(maxBucket) != uint(key.Bucket) {
 			continue
 		}
 		validTask = append(validTask, task)
 	}
 
-	return vo.NewTasks(validTask), nil
+	return t.cache.BatchCreateTasks(ctx, validTask)
+}
+
+// GetTasksByTime 从 redis zset 中获取时间片内的 task， timerID_runTime
+func (t *TaskService) GetTasksByTime(ctx context.Context, key utils.SliceKey, start, end time.Time) ([]*vo.Task, error) {
+	tasks, err := t.cache.GetTasksByTime(ctx, key, start.UnixMilli(), end.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	return vo.NewTasks(tasks), nil
+}
+
+// PopDueTasks 弹出分片中执行时间不晚于 dueBefore 的 task，返回弹出的 task、分片中下一个到期的时间以及还没有 ack 的 task 数量
+func (t *TaskService) PopDueTasks(ctx context.Context, key utils.SliceKey, dueBefore time.Time, visibility time.Duration, limit int) ([]*vo.Task, time.Time, int64, error) {
+	pop, err := t.cache.PopDueTasks(ctx, key, dueBefore, visibility, limit)
+	if err != nil {
+		return nil, time.Time{}, 0, err
+	}
+	return vo.NewTasks(pop.Tasks), pop.Next, pop.Pending, nil
+}
+
+// AckTask 确认 task 的执行结果已经落库
+func (t *TaskService) AckTask(ctx context.Context, key utils.SliceKey, task *vo.Task) error {
+	return t.cache.AckTask(ctx, key, task.ToPO())
+}
+
+// DeferTask 被维护窗口或者限流暂缓的 task 移动到 releaseAt 所在的分片
+func (t *TaskService) DeferTask(ctx context.Context, key utils.SliceKey, task *vo.Task, releaseAt time.Time) error {
+	return t.cache.DeferTask(ctx, key, task.ToPO(), releaseAt)
+}
+
+type bucketGetter interface {
+	GetBucketsNum(ctx context.Context, t time.Time) (int, error)
 }
 
 type taskDAO interface {
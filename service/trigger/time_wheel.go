@@ -0,0 +1,99 @@
+package trigger
+
+import (
+	"context"
+	"sync"
+	"time"
+	"timer/common/model/vo"
+	"timer/common/utils"
+	"timer/pkg/concurrency"
+	"timer/pkg/logger"
+	"timer/pkg/timewheel"
+)
+
+// schedule 以时间轮的方式处理分片：一次性加载分片的全部 task 放入进程内的时间轮，在执行时间精确触发
+// 处理期间不再轮询 redis，redis 只用于分片的归属以及 task 的 ack
+func (w *Worker) schedule(ctx context.Context, key utils.SliceKey, ack func()) error {
+	tasks, err := w.task.GetTasksByTime(ctx, key, key.Minute, key.Minute.Add(time.Minute))
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg      sync.WaitGroup
+		tracker fireTracker
+		mu      sync.Mutex
+		timers  = make([]*timewheel.Timer, 0, len(tasks))
+	)
+	// 用来保存 err，任一 task 提交失败，结束该分钟的处理
+	notifier := concurrency.NewSafeChan(1)
+	defer notifier.Close()
+
+	// add 在 at 触发 task，执行结束之后才算处理完成
+	// 被维护窗口暂缓到本分钟之内的 task 已经加载过，不会再从 redis 读取，由时间轮重新触发
+	var add func(task *vo.Task, at time.Time)
+	add = func(task *vo.Task, at time.Time) {
+		timer := w.wheel.Add(at, func() {
+			// 时间轮的协程只负责提交，执行成功之后 ack，没有 ack 的 task 在分片重试时重新加载
+			if err := w.submit(ctx, task, at, &tracker, func(err error) {
+				defer wg.Done()
+				if releaseAt, deferred := w.settle(ctx, key, task, err); deferred && releaseAt.Before(key.Minute.Add(time.Minute)) {
+					mu.Lock()
+					defer mu.Unlock()
+					if ctx.Err() == nil {
+						wg.Add(1)
+						add(task, releaseAt)
+					}
+				}
+			}); err != nil {
+				wg.Done()
+				notifier.Put(err)
+			}
+		})
+		timers = append(timers, timer)
+	}
+
+	wg.Add(len(tasks))
+	mu.Lock()
+	for _, task := range tasks {
+		add(task, task.RunTimer)
+	}
+	mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		// 取消还没有触发的定时器，已经触发的等待执行完成
+		mu.Lock()
+		for _, timer := range timers {
+			if timer.Cancel() {
+				wg.Done()
+			}
+		}
+		mu.Unlock()
+		<-done
+		tracker.wait()
+		return ctx.Err()
+	case <-done:
+	}
+
+	// 等待全部 task 都已经触发
+	tracker.wait()
+	select {
+	case e := <-notifier.GetChan():
+		err, _ = e.(error)
+		return err
+	default:
+	}
+	logger.InfoContextf(ctx, "fire delay of key: %s, %s", key, &tracker)
+
+	// 增加分布式锁的过期时间，那么就代表不会有人能抢到这个锁，证明完成成功
+	ack()
+	logger.InfoContextf(ctx, "ack success, key: %s", key)
+	return nil
+}
@@ -0,0 +1,151 @@
+package trigger
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"timer/common/conf"
+	"timer/common/model/po"
+	"timer/common/utils"
+	"timer/pkg/pool"
+	"timer/pkg/testenv"
+	"timer/pkg/timewheel"
+	"timer/service/executor"
+)
+
+// deferExecutor 第一次执行时按维护窗口暂缓到 releaseAt，之后记录执行时间
+type deferExecutor struct {
+	releaseAt time.Time
+
+	mu       sync.Mutex
+	deferred bool
+	fired    []time.Time
+}
+
+func (e *deferExecutor) Start(ctx context.Context) {}
+
+func (e *deferExecutor) Work(ctx context.Context, timerIDUnixKey string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.deferred {
+		e.deferred = true
+		return &executor.DeferredError{ReleaseAt: e.releaseAt}
+	}
+	e.fired = append(e.fired, time.Now())
+	return nil
+}
+
+func TestScheduleRefiresDeferredTask(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server, rdb := testenv.NewRedis(t)
+	tasks, cache := newRedisTaskService(t, rdb)
+	wheel := timewheel.NewWheel(time.Millisecond, 64)
+	go wheel.Run(ctx)
+
+	// 执行时间与释放时间在同一个分钟分片内
+	start := time.Now().Add(200 * time.Millisecond)
+	if start.Add(time.Second).Minute() != start.Minute() {
+		start = start.Truncate(time.Minute).Add(time.Minute)
+	}
+	task := &po.Task{TimerID: 1, RunTimer: start}
+	if err := cache.BatchCreateTasks(ctx, []*po.Task{task}); err != nil {
+		t.Fatal(err)
+	}
+
+	exec := &deferExecutor{releaseAt: start.Add(300 * time.Millisecond)}
+	w := &Worker{
+		task:     tasks,
+		executor: exec,
+		pool:     pool.NewGoWorkerPool(10),
+		config:   &conf.TriggerAppConfig{Engine: conf.TriggerEngineTimeWheel},
+		wheel:    wheel,
+	}
+	key := utils.NewSliceKey("timer", start, 0)
+	if err := w.schedule(ctx, key, func() {}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 分片已经加载过，暂缓的 task 由时间轮在释放时间重新触发，执行之后 ack
+	if len(exec.fired) != 1 {
+		t.Fatalf("deferred task fired %d times, want 1", len(exec.fired))
+	}
+	if exec.fired[0].Before(exec.releaseAt) {
+		t.Errorf("deferred task fired at %v, before release at %v", exec.fired[0], exec.releaseAt)
+	}
+	if server.Exists(key.String()) {
+		members, _ := server.ZMembers(key.String())
+		t.Errorf("members %v left in slice after ack", members)
+	}
+}
+
+func TestSettleKeepsDeferredTask(t *testing.T) {
+	ctx := context.Background()
+	server, rdb := testenv.NewRedis(t)
+	tasks, cache := newRedisTaskService(t, rdb)
+	w := &Worker{task: tasks, config: &conf.TriggerAppConfig{Engine: conf.TriggerEngineDelayQueue}}
+
+	minute := time.Now().Truncate(time.Minute)
+	task := &po.Task{TimerID: 1, RunTimer: minute.Add(time.Second)}
+	if err := cache.BatchCreateTasks(ctx, []*po.Task{task}); err != nil {
+		t.Fatal(err)
+	}
+	key := utils.NewSliceKey("timer", minute, 0)
+	popped, _, _, err := w.task.PopDueTasks(ctx, key, minute.Add(time.Minute), time.Minute, -1)
+	if err != nil || len(popped) != 1 {
+		t.Fatalf("popped %d tasks, err: %v", len(popped), err)
+	}
+
+	// 延迟队列模式下 executor 暂缓之后不能 ack，否则释放时间在同一个分片内的 member 会被删除
+	releaseAt := minute.Add(30 * time.Second)
+	if _, deferred := w.settle(ctx, key, popped[0], &executor.DeferredError{ReleaseAt: releaseAt}); !deferred {
+		t.Fatal("task not deferred")
+	}
+	member := utils.UnionTimerIDUnix(task.TimerID, task.RunTimer.UnixMilli())
+	if score, err := server.ZScore(key.String(), member); err != nil || int64(score) != releaseAt.UnixMilli() {
+		t.Errorf("deferred member score %v, err: %v, want %d", score, err, releaseAt.UnixMilli())
+	}
+	if server.Exists(key.ProcessingKey()) {
+		t.Errorf("deferred member is still processing")
+	}
+}
+
+// BenchmarkTimeWheel 时间轮模式处理一个 10 万个 task 的分钟分片：一次性拉取、加入时间轮、触发、ack
+// task 压缩到 500ms 内触发，测量整个分片的处理时间以及触发延迟
+// go test -run ^$ -bench TimeWheel ./service/trigger
+func BenchmarkTimeWheel(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, rdb := testenv.NewRedis(b)
+	tasks, cache := newRedisTaskService(b, rdb)
+	wheel := timewheel.NewWheel(time.Millisecond, 64)
+	go wheel.Run(ctx)
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		// 留出写入分片的时间，并且 500ms 的时间段不跨分钟
+		start := time.Now().Add(time.Second)
+		if start.Add(500*time.Millisecond).Minute() != start.Minute() {
+			start = start.Truncate(time.Minute).Add(time.Minute)
+		}
+		writeSlice(b, cache, start, 500*time.Millisecond, 100000)
+		exec := &recordExecutor{}
+		w := &Worker{
+			task:     tasks,
+			executor: exec,
+			pool:     pool.NewGoWorkerPool(10000),
+			config:   &conf.TriggerAppConfig{Engine: conf.TriggerEngineTimeWheel},
+			wheel:    wheel,
+		}
+		time.Sleep(time.Until(start.Add(-200 * time.Millisecond)))
+		b.StartTimer()
+
+		if err := w.schedule(ctx, utils.NewSliceKey("timer", start, 0), func() {}); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportMetric(float64(exec.percentile(50))/float64(time.Millisecond), "p50-ms")
+		b.ReportMetric(float64(exec.percentile(99))/float64(time.Millisecond), "p99-ms")
+		b.ReportMetric(float64(exec.percentile(100))/float64(time.Millisecond), "max-ms")
+	}
+}
@@ -2,9 +2,7 @@ package trigger
 
 import (
 	"context"
-	"fmt"
-	"strconv"
-	"strings"
+	"errors"
 	"sync"
 	"time"
 	"timer/common/conf"
@@ -14,6 +12,7 @@ import (
 	"timer/pkg/logger"
 	"timer/pkg/pool"
 	"timer/pkg/redis"
+	"timer/pkg/timewheel"
 	"timer/service/executor"
 )
 
@@ -21,118 +20,124 @@ type Worker struct {
 	task        taskService
 	config      *conf.TriggerAppConfig
 	pool        pool.WorkerPool
-	executor    *executor.Worker
+	executor    taskExecutor
 	lockService *redis.Client
+	wheel       *timewheel.Wheel
+	// 暂缓的 task 不能移动到已经被认领的分片
+	schedulerConfig *conf.SchedulerAppConfig
 }
 
-func NewWorker(executor *executor.Worker, task *TaskService, lockService *redis.Client, conf *conf.TriggerAppConfig) *Worker {
+func NewWorker(executor *executor.Worker, task *TaskService, lockService *redis.Client, conf *conf.TriggerAppConfig, schedulerConf *conf.SchedulerAppConfig) *Worker {
 	return &Worker{
-		executor:    executor,
-		task:        task,
-		lockService: lockService,
-		pool:        pool.NewGoWorkerPool(conf.WorkersNum),
-		config:      conf,
+		executor:        executor,
+		task:            task,
+		lockService:     lockService,
+		pool:            pool.NewGoWorkerPool(conf.WorkersNum),
+		config:          conf,
+		wheel:           timewheel.NewWheel(time.Duration(conf.TimeWheelTickMilliSeconds)*time.Millisecond, conf.TimeWheelSize),
+		schedulerConfig: schedulerConf,
 	}
 }
 
 func (w *Worker) Start(ctx context.Context) {
 	w.executor.Start(ctx)
+	if w.config.Engine == conf.TriggerEngineTimeWheel {
+		go w.wheel.Run(ctx)
+	}
 }
 
-func (w *Worker) Work(ctx context.Context, minuteBucketKey string, ack func()) error {
+func (w *Worker) Work(ctx context.Context, key utils.SliceKey, ack func()) error {
 	// log.InfoContextf(ctx, "trigger_1 start: %v", time.Now())
 	// defer func() {
 	// 	log.InfoContextf(ctx, "trigger_1 end: %v", time.Now())
 	// }()
 
+	// 缓存不存在时从 db 加载该分片的 task
+	if err := w.task.LoadMinute(ctx, key); err != nil {
+		return err
+	}
+
+	// 延迟队列模式：弹出到期的 task，执行结果落库后 ack
+	if w.config.Engine == conf.TriggerEngineDelayQueue {
+		return w.consume(ctx, key, ack)
+	}
+	// 时间轮模式：一次性加载分片的 task，由时间轮在执行时间触发
+	if w.config.Engine == conf.TriggerEngineTimeWheel {
+		return w.schedule(ctx, key, ack)
+	}
+
 	// 进行为时一分钟的 zrange 处理
-	// startTime 是该时间片的开始时间
-	startTime, err := getStartMinute(minuteBucketKey)
-	if err != nil {
+	var tracker fireTracker
+	if err := w.poll(ctx, key, key.Minute, key.Minute.Add(time.Minute), &tracker); err != nil {
 		return err
 	}
+	logger.InfoContextf(ctx, "fire delay of key: %s, %s", key, &tracker)
 
-	// 每一秒轮询一次（轮询该分片的任务）
-	ticker := time.NewTicker(time.Duration(w.config.ZRangeGapSeconds) * time.Second)
-	defer ticker.Stop()
+	// 增加分布式锁的过期时间，那么就代表不会有人能抢到这个锁，证明完成成功
+	ack()
+	logger.InfoContextf(ctx, "ack success, key: %s", key)
+	return nil
+}
 
-	endTime := startTime.Add(time.Minute)
+// poll 按轮询间隔拉取 [startTime, endTime) 内的 task，返回时其中的 task 都已经触发
+func (w *Worker) poll(ctx context.Context, key utils.SliceKey, startTime, endTime time.Time, tracker *fireTracker) error {
+	// startTime 是该时间片的开始时间，每个轮询间隔拉取一次（轮询该分片的任务），间隔可以配置到毫秒级
+	gap := w.config.GetZRangeGap()
 
-	// chan size = 1 分钟除 1 秒 +1 = 61
-	// 用来保存 err，有任一一个 1 秒内的任务执行出错，结束该分钟的处理
-	notifier := concurrency.NewSafeChan(int(time.Minute/(time.Duration(w.config.ZRangeGapSeconds)*time.Second)) + 1)
+	// chan size = 时间段除轮询间隔 +1
+	// 用来保存 err，有任一一个时间片内的任务执行出错，结束该时间段的处理
+	notifier := concurrency.NewSafeChan(int(endTime.Sub(startTime)/gap) + 1)
 	defer notifier.Close()
 
 	var wg sync.WaitGroup
-	wg.Add(1)
-
-	// 异步协程轮询该时间片的第一秒，因为 Ticker 是一秒后才会有，所有也就是说丧失了第一秒的时间
-	go func() {
-		// log.InfoContextf(ctx, "trigger_2 start: %v", time.Now())
-		// defer func() {
-		// 	log.InfoContextf(ctx, "trigger_2 end: %v", time.Now())
-		// }()
-		defer wg.Done()
-		// 该一分钟时间片的第一秒
-		if err := w.handleBatch(ctx, minuteBucketKey, startTime, startTime.Add(time.Duration(w.config.ZRangeGapSeconds)*time.Second)); err != nil {
-			notifier.Put(err)
+	// 按时间片的开始时间对齐拉取，而不是相对于开始处理的时间；已经过去的时间片立即拉取补上
+	// 每个时间片提前 preload 拉取，时间片开始前任务已经按执行时间准备好触发
+	preload := time.Duration(w.config.PreloadMilliSeconds) * time.Millisecond
+	for ; startTime.Before(endTime); startTime = startTime.Add(gap) {
+		if wait := time.Until(startTime.Add(-preload)); wait > 0 {
+			select {
+			case <-ctx.Done():
+				wg.Wait()
+				return ctx.Err()
+			case <-time.After(wait):
+			}
 		}
-	}()
 
-	for range ticker.C {
 		select {
 		case e := <-notifier.GetChan():
-			err, _ = e.(error)
+			err, _ := e.(error)
+			wg.Wait()
 			return err
 		default:
 		}
 
-		// startTime 增加 1 秒，超过 end time 就停止
-		if startTime = startTime.Add(time.Duration(w.config.ZRangeGapSeconds) * time.Second); startTime.Equal(endTime) || startTime.After(endTime) {
-			break
-		}
-
-		// log.InfoContextf(ctx, "start time: %v", startTime)
-
 		wg.Add(1)
 		go func(startTime time.Time) {
-			// log.InfoContextf(ctx, "trigger_2 start: %v", time.Now())
-			// defer func() {
-			// 	log.InfoContextf(ctx, "trigger_2 end: %v", time.Now())
-			// }()
 			defer wg.Done()
-			if err := w.handleBatch(ctx, minuteBucketKey, startTime, startTime.Add(time.Duration(w.config.ZRangeGapSeconds)*time.Second)); err != nil {
+			if err := w.handleBatch(ctx, key, startTime, startTime.Add(gap), tracker); err != nil {
 				notifier.Put(err)
 			}
 		}(startTime)
 	}
 
-	// 等待全部时间片处理完
+	// 等待全部时间片处理完，并且其中的任务都已经触发
 	wg.Wait()
+	tracker.wait()
 	select {
 	case e := <-notifier.GetChan():
-		err, _ = e.(error)
+		err, _ := e.(error)
 		return err
 	default:
 	}
-
-	// 增加分布式锁的过期时间，那么就代表不会有人能抢到这个锁，证明完成成功
-	ack()
-	logger.InfoContextf(ctx, "ack success, key: %s", minuteBucketKey)
 	return nil
 }
 
-// handleBatch 处理时间片内（start，end）秒的 task
-func (w *Worker) handleBatch(ctx context.Context, key string, start, end time.Time) error {
-	// start，end 1 秒中内
-	bucket, err := getBucket(key)
-	if err != nil {
-		return err
-	}
-
+// handleBatch 处理时间片内 [start，end) 的 task
+func (w *Worker) handleBatch(ctx context.Context, key utils.SliceKey, start, end time.Time, tracker *fireTracker) error {
+	// start，end 相差一个轮询间隔
 	// key 是时间片分桶的整个 key，也就是该分片的 key
 	// 利用 zrange 获取该时间片里（1分钟）为该 秒 执行的 task 的 timerID，taskRunTime
-	tasks, err := w.task.GetTasksByTime(ctx, key, bucket, start, end)
+	tasks, err := w.task.GetTasksByTime(ctx, key, start, end)
 	if err != nil {
 		return err
 	}
@@ -144,16 +149,9 @@ func (w *Worker) handleBatch(ctx context.Context, key string, start, end time.Ti
 	// log.InfoContextf(ctx, "key: %s, get tasks: %+v, start: %v, end: %v", key, timerIDs, start, end)
 	for _, task := range tasks {
 		task := task
-		// 对于该秒内的每一个任务对应一个 G 执行
-		if err := w.pool.Submit(func() {
-			// log.InfoContextf(ctx, "trigger_3 start: %v", time.Now())
-			// defer func() {
-			// 	log.InfoContextf(ctx, "trigger_3 end: %v", time.Now())
-			// }()
-
-			if err := w.executor.Work(ctx, utils.UnionTimerIDUnix(task.TimerID, task.RunTimer.UnixMilli())); err != nil {
-				logger.ErrorContextf(ctx, "executor work failed, err: %v", err)
-			}
+		// 对于该时间片内的每一个任务对应一个 G 执行，按分片整体 ack，只需要处理被暂缓的 task
+		if err := w.submit(ctx, task, task.RunTimer, tracker, func(err error) {
+			w.deferTask(ctx, key, task, err)
 		}); err != nil {
 			return err
 		}
@@ -161,23 +159,85 @@ func (w *Worker) handleBatch(ctx context.Context, key string, start, end time.Ti
 	return nil
 }
 
-func getStartMinute(slice string) (time.Time, error) {
-	timeBucket := strings.Split(slice, "_")
-	if len(timeBucket) != 2 {
-		return time.Time{}, fmt.Errorf("invalid format of msg key: %s", slice)
-	}
+// submit 提交 task 到协程池，等到触发时间 at 再触发，精确到毫秒，at 通常为 task 的执行时间，被暂缓的 task 为释放时间
+// 提交成功之后 done 一定会被调用一次，参数为执行的结果，没有触发就退出时为 ctx 的错误
+func (w *Worker) submit(ctx context.Context, task *vo.Task, at time.Time, tracker *fireTracker, done func(err error)) error {
+	tracker.add()
+	if err := w.pool.Submit(func() {
+		// log.InfoContextf(ctx, "trigger_3 start: %v", time.Now())
+		// defer func() {
+		// 	log.InfoContextf(ctx, "trigger_3 end: %v", time.Now())
+		// }()
 
-	return utils.GetStartMinute(timeBucket[0])
+		// task 在触发时间之前就已经拉取到，等到触发时间再触发
+		if wait := time.Until(at); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				tracker.fired(-1)
+				if done != nil {
+					done(ctx.Err())
+				}
+				return
+			case <-timer.C:
+			}
+		}
+		tracker.fired(time.Since(at))
+
+		err := w.executor.Work(ctx, utils.UnionTimerIDUnix(task.TimerID, task.RunTimer.UnixMilli()))
+		if err != nil && !errors.As(err, new(*executor.DeferredError)) {
+			logger.ErrorContextf(ctx, "executor work failed, err: %v", err)
+		}
+		if done != nil {
+			done(err)
+		}
+	}); err != nil {
+		tracker.fired(-1)
+		return err
+	}
+	return nil
 }
 
-func getBucket(slice string) (int, error) {
-	timeBucket := strings.Split(slice, "_")
-	if len(timeBucket) != 2 {
-		return -1, fmt.Errorf("invalid format of msg key: %s", slice)
+// deferTask 被维护窗口或者限流暂缓的 task 移动到释放时间所在的分片，返回释放时间
+// 原来的 member 已经移除，不能再 ack；移动失败时不处理，task 重新投递后再次被暂缓
+func (w *Worker) deferTask(ctx context.Context, key utils.SliceKey, task *vo.Task, err error) (time.Time, bool) {
+	var deferred *executor.DeferredError
+	if !errors.As(err, &deferred) {
+		return time.Time{}, false
+	}
+
+	// zrange 不会重新读取已经拉取过的时间段，时间轮只会重新调度当前分片内的释放，释放时间早于触发器还会读取的时间时推迟到该时间，
+	// 否则 task 移动之后不会再被触发；延迟队列会弹出任意时间到期的 task，不需要推迟
+	releaseAt := deferred.ReleaseAt
+	switch {
+	case w.config.Engine == conf.TriggerEngineDelayQueue:
+	case w.config.Engine == conf.TriggerEngineTimeWheel && releaseAt.Before(key.Minute.Add(time.Minute)):
+	default:
+		lookahead := time.Duration(w.schedulerConfig.LookaheadMilliSeconds) * time.Millisecond
+		if unread := w.config.GetUnreadAfter(time.Now(), lookahead); releaseAt.Before(unread) {
+			releaseAt = unread
+		}
 	}
-	return strconv.Atoi(timeBucket[1])
+
+	if err := w.task.DeferTask(ctx, key, task, releaseAt); err != nil {
+		logger.ErrorContextf(ctx, "defer task failed, key: %s, timerID: %d, runTimer: %v, releaseAt: %v, err: %v", key, task.TimerID, task.RunTimer, releaseAt, err)
+		return time.Time{}, false
+	}
+	return releaseAt, true
+}
+
+var _ taskExecutor = &executor.Worker{}
+
+type taskExecutor interface {
+	Start(ctx context.Context)
+	Work(ctx context.Context, timerIDUnixKey string) error
 }
 
 type taskService interface {
-	GetTasksByTime(ctx context.Context, key string, bucket int, start, end time.Time) ([]*vo.Task, error)
+	LoadMinute(ctx context.Context, key utils.SliceKey) error
+	GetTasksByTime(ctx context.Context, key utils.SliceKey, start, end time.Time) ([]*vo.Task, error)
+	PopDueTasks(ctx context.Context, key utils.SliceKey, dueBefore time.Time, visibility time.Duration, limit int) ([]*vo.Task, time.Time, int64, error)
+	AckTask(ctx context.Context, key utils.SliceKey, task *vo.Task) error
+	DeferTask(ctx context.Context, key utils.SliceKey, task *vo.Task, releaseAt time.Time) error
 }
@@ -0,0 +1,220 @@
+package trigger
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+	"timer/common/conf"
+	"timer/common/model/po"
+	"timer/common/model/vo"
+	"timer/common/utils"
+	"timer/dao/task"
+	"timer/pkg/pool"
+	"timer/pkg/testenv"
+	"timer/service/executor"
+)
+
+// recordExecutor 记录每个 task 的实际触发时间相对于执行时间的延迟，以及触发的先后顺序
+type recordExecutor struct {
+	mu     sync.Mutex
+	delays []time.Duration
+	// 按触发顺序记录 task 的执行时间
+	fired []time.Time
+}
+
+func (e *recordExecutor) Start(ctx context.Context) {}
+
+func (e *recordExecutor) Work(ctx context.Context, timerIDUnixKey string) error {
+	now := time.Now()
+	_, unix, err := utils.SplitTimerIDUnix(timerIDUnixKey)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.delays = append(e.delays, now.Sub(time.UnixMilli(unix)))
+	e.fired = append(e.fired, time.UnixMilli(unix))
+	e.mu.Unlock()
+	return nil
+}
+
+// percentile 延迟的 p 分位数，p 取 0~100
+func (e *recordExecutor) percentile(p int) time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sort.Slice(e.delays, func(i, j int) bool {
+		return e.delays[i] < e.delays[j]
+	})
+	return e.delays[(len(e.delays)-1)*p/100]
+}
+
+// sliceTasks 内存中的分片，按执行时间范围返回 task
+type sliceTasks struct {
+	tasks []*vo.Task
+	// DeferTask 收到的释放时间
+	deferred []time.Time
+}
+
+func (s *sliceTasks) LoadMinute(ctx context.Context, key utils.SliceKey) error {
+	return nil
+}
+
+func (s *sliceTasks) GetTasksByTime(ctx context.Context, key utils.SliceKey, start, end time.Time) ([]*vo.Task, error) {
+	var res []*vo.Task
+	for _, task := range s.tasks {
+		if !task.RunTimer.Before(start) && task.RunTimer.Before(end) {
+			res = append(res, task)
+		}
+	}
+	return res, nil
+}
+
+func (s *sliceTasks) PopDueTasks(ctx context.Context, key utils.SliceKey, dueBefore time.Time, visibility time.Duration, limit int) ([]*vo.Task, time.Time, int64, error) {
+	return nil, time.Time{}, 0, nil
+}
+
+func (s *sliceTasks) AckTask(ctx context.Context, key utils.SliceKey, task *vo.Task) error {
+	return nil
+}
+
+func (s *sliceTasks) DeferTask(ctx context.Context, key utils.SliceKey, task *vo.Task, releaseAt time.Time) error {
+	s.deferred = append(s.deferred, releaseAt)
+	return nil
+}
+
+func TestDeferTaskAfterUnread(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	key := utils.NewSliceKey("timer", now, 0)
+	task := &vo.Task{TimerID: 1, RunTimer: key.Minute}
+	zrange := &conf.TriggerAppConfig{Engine: conf.TriggerEngineZRange, ZRangeGapMilliSeconds: 100, PreloadMilliSeconds: 200}
+
+	cases := []struct {
+		name      string
+		config    *conf.TriggerAppConfig
+		releaseAt time.Time
+		notBefore time.Time
+		moved     bool
+	}{
+		// zrange 已经拉取过当前时间之后 gap + preload 内的 task
+		{name: "zrange read range", config: zrange, releaseAt: now, notBefore: now.Add(300 * time.Millisecond), moved: true},
+		{name: "zrange unread", config: zrange, releaseAt: now.Add(10 * time.Second), notBefore: now.Add(10 * time.Second)},
+		// 延迟队列会弹出任意时间到期的 task
+		{name: "delay queue", config: &conf.TriggerAppConfig{Engine: conf.TriggerEngineDelayQueue}, releaseAt: now.Add(-time.Second), notBefore: now.Add(-time.Second)},
+		// 时间轮自己重新调度当前分片内的释放
+		{name: "time wheel in slice", config: &conf.TriggerAppConfig{Engine: conf.TriggerEngineTimeWheel}, releaseAt: key.Minute, notBefore: key.Minute},
+		// 下一个分片在认领之前就已经加载，不能移动到已经认领的分片
+		{name: "time wheel claimed slice", config: &conf.TriggerAppConfig{Engine: conf.TriggerEngineTimeWheel}, releaseAt: key.Minute.Add(time.Minute),
+			notBefore: (&conf.TriggerAppConfig{Engine: conf.TriggerEngineTimeWheel}).GetUnreadAfter(now, 2*time.Second), moved: true},
+	}
+	for _, c := range cases {
+		tasks := &sliceTasks{}
+		w := &Worker{task: tasks, config: c.config, schedulerConfig: &conf.SchedulerAppConfig{LookaheadMilliSeconds: 2000}}
+		releaseAt, ok := w.deferTask(ctx, key, task, &executor.DeferredError{ReleaseAt: c.releaseAt})
+		if !ok || len(tasks.deferred) != 1 || !tasks.deferred[0].Equal(releaseAt) {
+			t.Fatalf("%s: deferred %t to %v, moved to %v", c.name, ok, releaseAt, tasks.deferred)
+		}
+		if releaseAt.Before(c.notBefore) || !c.moved && !releaseAt.Equal(c.releaseAt) {
+			t.Errorf("%s: release at %v, want not before %v", c.name, releaseAt, c.notBefore)
+		}
+	}
+}
+
+func TestHandleBatchFiresAtRunTimer(t *testing.T) {
+	ctx := context.Background()
+	exec := &recordExecutor{}
+	// 执行时间在接下来的 500ms 内每 5ms 一个，不在轮询间隔的边界上
+	start := time.Now().Truncate(100 * time.Millisecond).Add(200 * time.Millisecond)
+	tasks := &sliceTasks{}
+	for i := 0; i < 100; i++ {
+		tasks.tasks = append(tasks.tasks, &vo.Task{TimerID: uint(i + 1), RunTimer: start.Add(time.Duration(i*5+3) * time.Millisecond)})
+	}
+	w := &Worker{
+		task:     tasks,
+		executor: exec,
+		pool:     pool.NewGoWorkerPool(100),
+		config:   &conf.TriggerAppConfig{ZRangeGapMilliSeconds: 100},
+	}
+
+	// 每个轮询间隔开始前拉取，与 Work 中的处理方式一致
+	var tracker fireTracker
+	key := utils.NewSliceKey("test", start, 0)
+	for batch := start; batch.Before(start.Add(500 * time.Millisecond)); batch = batch.Add(100 * time.Millisecond) {
+		time.Sleep(time.Until(batch.Add(-10 * time.Millisecond)))
+		if err := w.handleBatch(ctx, key, batch, batch.Add(100*time.Millisecond), &tracker); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tracker.wait()
+
+	if len(exec.delays) != len(tasks.tasks) {
+		t.Fatalf("fired %d tasks, want %d", len(exec.delays), len(tasks.tasks))
+	}
+	fired := make(map[int64]bool, len(exec.fired))
+	for _, runTimer := range exec.fired {
+		if fired[runTimer.UnixMilli()] {
+			t.Errorf("task at %v fired more than once", runTimer)
+		}
+		fired[runTimer.UnixMilli()] = true
+	}
+	// 不能早于执行时间触发，否则就是对齐到了轮询间隔
+	if min := exec.percentile(0); min < 0 {
+		t.Errorf("task fired %s before its run time", -min)
+	}
+	// 按执行时间的先后触发，相邻 task 只差 5ms，协程调度可能交换顺序，只要求不晚于执行时间晚一个轮询间隔的 task
+	var latest time.Time
+	for _, runTimer := range exec.fired {
+		if latest.Sub(runTimer) >= 100*time.Millisecond {
+			t.Errorf("task at %v fired after task at %v", runTimer.Format("15:04:05.000"), latest.Format("15:04:05.000"))
+		}
+		if runTimer.After(latest) {
+			latest = runTimer
+		}
+	}
+}
+
+// writeSlice 向分片写入 n 个 task，执行时间从 start 开始在 span 内均匀分布
+func writeSlice(tb testing.TB, cache *task.TaskCache, start time.Time, span time.Duration, n int) {
+	tb.Helper()
+	tasks := make([]*po.Task, 0, n)
+	for i := 0; i < n; i++ {
+		tasks = append(tasks, &po.Task{TimerID: uint(i + 1), RunTimer: start.Add(time.Duration(i) * span / time.Duration(n))})
+	}
+	if err := cache.BatchCreateTasks(context.Background(), tasks); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// BenchmarkHandleBatch 一个分钟分片 10 万个 task，每次处理其中一个轮询间隔的 task
+// 分片在上一分钟，task 拉取后立即触发，测量 ZRANGEBYSCORE 以及提交到协程池的开销
+// go test -run ^$ -bench HandleBatch ./service/trigger
+func BenchmarkHandleBatch(b *testing.B) {
+	ctx := context.Background()
+	_, rdb := testenv.NewRedis(b)
+	tasks, cache := newRedisTaskService(b, rdb)
+	minute := time.Now().Truncate(time.Minute).Add(-time.Minute)
+	writeSlice(b, cache, minute, time.Minute, 100000)
+	key := utils.NewSliceKey("timer", minute, 0)
+
+	for _, gap := range []time.Duration{time.Second, 100 * time.Millisecond} {
+		b.Run(gap.String(), func(b *testing.B) {
+			w := &Worker{
+				task:     tasks,
+				executor: &recordExecutor{},
+				pool:     pool.NewGoWorkerPool(10000),
+				config:   &conf.TriggerAppConfig{},
+			}
+			batches := int(time.Minute / gap)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var tracker fireTracker
+				start := minute.Add(time.Duration(i%batches) * gap)
+				if err := w.handleBatch(ctx, key, start, start.Add(gap), &tracker); err != nil {
+					b.Fatal(err)
+				}
+				tracker.wait()
+			}
+		})
+	}
+}
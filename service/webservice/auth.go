@@ -0,0 +1,136 @@
+package webservice
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+	"timer/common/conf"
+	"timer/common/consts"
+	"timer/common/model/po"
+	"timer/common/model/vo"
+	"timer/dao/apikey"
+)
+
+const (
+	apiKeyPrefix    = "tk_"
+	apiKeyRandBytes = 32
+)
+
+type AuthServer struct {
+	apiKeyDao apiKeyDao
+	config    *conf.AuthConfig
+}
+
+func NewAuthServer(dao *apikey.APIKeyDao, config *conf.AuthConfig) *AuthServer {
+	return &AuthServer{
+		apiKeyDao: dao,
+		config:    config,
+	}
+}
+
+// Enabled 是否开启鉴权
+func (server *AuthServer) Enabled() bool {
+	return server.config.Enabled
+}
+
+// Authenticate 根据明文 key 查找调用方
+func (server *AuthServer) Authenticate(ctx context.Context, rawKey string) (*vo.Principal, error) {
+	if rawKey == "" {
+		return nil, vo.ErrUnauthorized
+	}
+
+	key, err := server.apiKeyDao.GetAPIKey(ctx, apikey.WithKeyHash(hashAPIKey(rawKey)))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, vo.ErrUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return vo.NewPrincipal(key), nil
+}
+
+// IsAdmin 校验管理员 token，没有配置管理员 token 时拒绝全部管理请求
+func (server *AuthServer) IsAdmin(token string) bool {
+	if server.config.AdminToken == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(server.config.AdminToken)) == 1
+}
+
+// CreateAPIKey 生成新的 API Key，数据库只保存 hash，明文只返回这一次
+func (server *AuthServer) CreateAPIKey(ctx context.Context, req *vo.CreateAPIKeyReq) (*vo.CreateAPIKeyRespData, error) {
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if scope != consts.ScopeRead && scope != consts.ScopeWrite {
+			return nil, fmt.Errorf("invalid scope: %s", scope)
+		}
+		scopes = append(scopes, string(scope))
+	}
+
+	for _, app := range req.Apps {
+		if app == "" || strings.Contains(app, ",") {
+			return nil, fmt.Errorf("invalid app: %q", app)
+		}
+	}
+
+	rawKey, err := newRawAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := server.apiKeyDao.CreateAPIKey(ctx, &po.APIKey{
+		Name:    req.Name,
+		KeyHash: hashAPIKey(rawKey),
+		Apps:    strings.Join(req.Apps, ","),
+		Scopes:  strings.Join(scopes, ","),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &vo.CreateAPIKeyRespData{
+		ID:  id,
+		Key: rawKey,
+	}, nil
+}
+
+func (server *AuthServer) DeleteAPIKey(ctx context.Context, id uint) error {
+	return server.apiKeyDao.DeleteAPIKey(ctx, id)
+}
+
+func (server *AuthServer) GetAPIKeys(ctx context.Context) ([]*vo.APIKey, error) {
+	keys, err := server.apiKeyDao.GetAPIKeys(ctx, apikey.WithDesc())
+	if err != nil {
+		return nil, err
+	}
+	return vo.NewAPIKeys(keys), nil
+}
+
+func newRawAPIKey() (string, error) {
+	buf := make([]byte, apiKeyRandBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + hex.EncodeToString(buf), nil
+}
+
+func hashAPIKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
+
+var _ apiKeyDao = &apikey.APIKeyDao{}
+
+type apiKeyDao interface {
+	CreateAPIKey(ctx context.Context, key *po.APIKey) (uint, error)
+	DeleteAPIKey(ctx context.Context, id uint) error
+	GetAPIKey(ctx context.Context, opts ...apikey.Option) (*po.APIKey, error)
+	GetAPIKeys(ctx context.Context, opts ...apikey.Option) ([]*po.APIKey, error)
+}
@@ -0,0 +1,117 @@
+package webservice
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"timer/common/conf"
+	"timer/common/consts"
+	"timer/common/model/po"
+	"timer/common/model/vo"
+	"timer/common/utils"
+	"timer/dao/bucket"
+	"timer/dao/task"
+	"timer/pkg/logger"
+)
+
+type BucketServer struct {
+	bucketDao       bucketDao
+	taskDao         bucketTaskDao
+	taskCache       taskCache
+	schedulerConfig *conf.SchedulerAppConfig
+	migratorConfig  *conf.MigratorAppConfig
+}
+
+func NewBucketServer(bucketDao *bucket.BucketDao, taskDao task.Repository, taskCache *task.TaskCache,
+	schedulerConfig *conf.SchedulerAppConfig, migratorConfig *conf.MigratorAppConfig) *BucketServer {
+	return &BucketServer{
+		bucketDao:       bucketDao,
+		taskDao:         taskDao,
+		taskCache:       taskCache,
+		schedulerConfig: schedulerConfig,
+		migratorConfig:  migratorConfig,
+	}
+}
+
+// GetLayouts 查看当前生效的分桶数量以及全部分桶变更记录
+func (server *BucketServer) GetLayouts(ctx context.Context) (*vo.BucketLayoutsRespData, error) {
+	layouts, err := server.bucketDao.GetLayouts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	vLayouts := make([]*vo.BucketLayout, 0, len(layouts))
+	for _, layout := range layouts {
+		vLayouts = append(vLayouts, &vo.BucketLayout{
+			EffectiveFrom: layout.EffectiveFrom,
+			BucketsNum:    layout.BucketsNum,
+		})
+	}
+	return &vo.BucketLayoutsRespData{
+		Current: layouts.BucketsNumAt(time.Now(), server.schedulerConfig.BucketsNum),
+		Layouts: vLayouts,
+	}, nil
+}
+
+// SetBucketsNum 修改分桶数量，从指定的分钟开始生效
+// 生效时间之后已经写入缓存的 task 按新的分桶数量重新写入，旧分桶中的数据不再被读取，随过期时间自动清理
+func (server *BucketServer) SetBucketsNum(ctx context.Context, req *vo.SetBucketsReq) error {
+	effectiveFrom, err := time.Parse(time.RFC3339, req.EffectiveFrom)
+	if err != nil {
+		return fmt.Errorf("invalid effective from: %s", req.EffectiveFrom)
+	}
+	effectiveFrom = effectiveFrom.In(time.Local)
+	if !effectiveFrom.Equal(effectiveFrom.Truncate(time.Minute)) {
+		return fmt.Errorf("effective from must be a whole minute: %s", req.EffectiveFrom)
+	}
+	// 当前分钟和下一分钟可能已经在调度中，最早从下下分钟开始生效
+	if earliest := time.Now().Truncate(time.Minute).Add(2 * time.Minute); effectiveFrom.Before(earliest) {
+		return fmt.Errorf("effective from must not be earlier than %s", earliest.Format(consts.MinuteFormat))
+	}
+
+	layouts, err := server.bucketDao.GetLayouts(ctx)
+	if err != nil {
+		return err
+	}
+	// 只能在最后一次变更之后追加，不能改写已经生效的历史
+	last := layouts.Last()
+	if last != nil && !effectiveFrom.After(last.EffectiveFrom) {
+		return fmt.Errorf("effective from must be later than the last layout: %s",
+			last.EffectiveFrom.Format(consts.MinuteFormat))
+	}
+
+	// 写入时原子地检查最后一条记录没有变化，并发的修改只有一个能成功
+	if err := server.bucketDao.AddLayout(ctx, &bucket.Layout{EffectiveFrom: effectiveFrom, BucketsNum: req.BucketsNum}, last); err != nil {
+		return err
+	}
+	logger.InfoContextf(ctx, "set buckets num to %d, effective from: %s", req.BucketsNum, effectiveFrom.Format(consts.MinuteFormat))
+
+	return server.rebucket(ctx, effectiveFrom)
+}
+
+// rebucket 迁移器和激活定时器最多提前两个迁移步长写入缓存，将其中生效时间之后的 task 按新的分桶重新写入
+// 与此同时按旧分桶写入缓存的 task，由写入方在写入之后发现分桶变化并重新写入，见 TaskCache.BatchCreateTasks
+func (server *BucketServer) rebucket(ctx context.Context, effectiveFrom time.Time) error {
+	end := utils.GetForwardTwoMigrateStepEnd(time.Now(), time.Duration(server.migratorConfig.MigrateStepMinutes)*time.Minute)
+	if !effectiveFrom.Before(end) {
+		return nil
+	}
+
+	tasks, err := server.taskDao.GetTasks(ctx, task.WithStartTime(effectiveFrom), task.WithEndTime(end),
+		task.WithStatus(int32(consts.NotRunned.ToInt())))
+	if err != nil {
+		return err
+	}
+	return server.taskCache.BatchCreateTasks(ctx, tasks)
+}
+
+var _ bucketDao = &bucket.BucketDao{}
+
+type bucketDao interface {
+	GetLayouts(ctx context.Context) (bucket.Layouts, error)
+	AddLayout(ctx context.Context, layout *bucket.Layout, last *bucket.Layout) error
+}
+
+type bucketTaskDao interface {
+	GetTasks(ctx context.Context, opts ...task.Option) ([]*po.Task, error)
+}
@@ -0,0 +1,136 @@
+package webservice
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+	"timer/common/model/po"
+	"timer/common/model/vo"
+	calendarD "timer/dao/calendar"
+	"timer/pkg/calendar"
+	"timer/pkg/database"
+)
+
+// icsRecurrenceYears 导入 .ics 时，没有截止时间的重复事件展开的年数
+const icsRecurrenceYears = 5
+
+// CalendarServer 节假日日历的管理
+// 日历的变更只对之后生成的 task 生效，已经迁移到缓存中的 task 不受影响
+type CalendarServer struct {
+	calendarDao calendarDao
+}
+
+func NewCalendarServer(dao *calendarD.CalendarDao) *CalendarServer {
+	return &CalendarServer{
+		calendarDao: dao,
+	}
+}
+
+func (server *CalendarServer) CreateCalendar(ctx context.Context, req *vo.CreateCalendarReq) (uint, error) {
+	rule, err := vo.NewCalendarRule(req.Weekdays, req.Dates)
+	if err != nil {
+		return 0, err
+	}
+
+	// 同一个 app 下未删除的日历名称唯一，由唯一索引保证，并发创建同名日历只有一个能成功
+	id, err := server.calendarDao.CreateCalendar(ctx, &po.Calendar{
+		App:      req.App,
+		Name:     req.Name,
+		Weekdays: calendar.FormatWeekdays(rule.Weekdays),
+		Dates:    calendar.FormatRanges(rule.Ranges),
+	})
+	if database.IsDuplicatedKey(err) {
+		return 0, fmt.Errorf("%w: calendar %q already exists", vo.ErrCalendarUnValid, req.Name)
+	}
+	return id, err
+}
+
+// UpdateCalendar 整体覆盖日历的排除规则
+func (server *CalendarServer) UpdateCalendar(ctx context.Context, req *vo.UpdateCalendarReq) error {
+	rule, err := vo.NewCalendarRule(req.Weekdays, req.Dates)
+	if err != nil {
+		return err
+	}
+
+	c, err := server.getCalendar(ctx, req.App, req.ID)
+	if err != nil {
+		return err
+	}
+	c.Weekdays = calendar.FormatWeekdays(rule.Weekdays)
+	c.Dates = calendar.FormatRanges(rule.Ranges)
+	return server.calendarDao.UpdateCalendar(ctx, c)
+}
+
+// DeleteCalendar 删除日历，引用该日历的定时器之后不再按该日历排除
+func (server *CalendarServer) DeleteCalendar(ctx context.Context, app string, id uint) error {
+	if _, err := server.getCalendar(ctx, app, id); err != nil {
+		return err
+	}
+	return server.calendarDao.DeleteCalendar(ctx, id)
+}
+
+func (server *CalendarServer) GetCalendars(ctx context.Context, app string) ([]*vo.Calendar, error) {
+	calendars, err := server.calendarDao.GetCalendars(ctx, calendarD.WithApp(app), calendarD.WithDesc())
+	if err != nil {
+		return nil, err
+	}
+	return vo.NewCalendars(calendars)
+}
+
+// ImportICS 从 iCalendar 文件导入排除日期，默认与已有的日期合并
+func (server *CalendarServer) ImportICS(ctx context.Context, req *vo.ImportCalendarReq, r io.Reader) (*vo.ImportCalendarRespData, error) {
+	imported, err := calendar.ParseICS(r, time.Now().AddDate(icsRecurrenceYears, 0, 0))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", vo.ErrCalendarUnValid, err)
+	}
+
+	c, err := server.getCalendar(ctx, req.App, req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	ranges := imported
+	if !req.Replace {
+		existed, err := calendar.ParseRanges(c.Dates)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(existed, imported...)
+	}
+	c.Dates = calendar.FormatRanges(ranges)
+	if err = server.calendarDao.UpdateCalendar(ctx, c); err != nil {
+		return nil, err
+	}
+
+	vCalendar, err := vo.NewCalendar(c)
+	if err != nil {
+		return nil, err
+	}
+	return &vo.ImportCalendarRespData{
+		Imported: len(imported),
+		Dates:    vCalendar.Dates,
+	}, nil
+}
+
+// getCalendar 获取日历，只能操作自己 app 下的日历
+func (server *CalendarServer) getCalendar(ctx context.Context, app string, id uint) (*po.Calendar, error) {
+	c, err := server.calendarDao.GetCalendar(ctx, calendarD.WithID(id))
+	if err != nil {
+		return nil, err
+	}
+	if c.App != app {
+		return nil, vo.ErrForbidden
+	}
+	return c, nil
+}
+
+var _ calendarDao = &calendarD.CalendarDao{}
+
+type calendarDao interface {
+	CreateCalendar(ctx context.Context, calendar *po.Calendar) (uint, error)
+	UpdateCalendar(ctx context.Context, calendar *po.Calendar) error
+	DeleteCalendar(ctx context.Context, id uint) error
+	GetCalendar(ctx context.Context, opts ...calendarD.Option) (*po.Calendar, error)
+	GetCalendars(ctx context.Context, opts ...calendarD.Option) ([]*po.Calendar, error)
+}
@@ -0,0 +1,39 @@
+package webservice
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"timer/common/model/vo"
+	calendarD "timer/dao/calendar"
+	"timer/pkg/testenv"
+)
+
+func TestCreateCalendarUniqueName(t *testing.T) {
+	ctx := context.Background()
+	server := NewCalendarServer(calendarD.NewCalendarDao(testenv.NewDB(t)))
+	req := &vo.CreateCalendarReq{App: "app", Name: "holidays", Dates: []string{"2026-10-01~2026-10-07"}}
+
+	id, err := server.CreateCalendar(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.CreateCalendar(ctx, req); !errors.Is(err, vo.ErrCalendarUnValid) {
+		t.Fatalf("create duplicated calendar: %v, want %v", err, vo.ErrCalendarUnValid)
+	}
+
+	// 其他 app 可以使用相同的名称
+	if _, err := server.CreateCalendar(ctx, &vo.CreateCalendarReq{App: "other", Name: "holidays"}); err != nil {
+		t.Fatalf("create calendar with the same name in another app: %v", err)
+	}
+
+	// 删除之后名称可以重新使用，已经删除的日历不会再次冲突
+	for i := 0; i < 2; i++ {
+		if err := server.DeleteCalendar(ctx, req.App, id); err != nil {
+			t.Fatal(err)
+		}
+		if id, err = server.CreateCalendar(ctx, req); err != nil {
+			t.Fatalf("recreate deleted calendar: %v", err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package webservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+	"timer/common/model/po"
+	"timer/common/model/vo"
+	"timer/pkg/cron"
+)
+
+const (
+	defaultPreviewNum = 5
+	maxPreviewNum     = 100
+)
+
+type CronServer struct {
+	cronParser cronExplainer
+}
+
+func NewCronServer(parser *cron.Parser) *CronServer {
+	return &CronServer{
+		cronParser: parser,
+	}
+}
+
+// Preview 校验 cron 表达式，并给出描述和接下来的触发时间
+// 表达式不合法不算错误，而是通过返回值中的 Error 告知调用方；时区、开始时间等参数不合法才返回 error
+func (server *CronServer) Preview(ctx context.Context, req *vo.CronPreviewReq) (*vo.CronPreviewRespData, error) {
+	loc := time.Local
+	if req.TimeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(req.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid time zone: %s", req.TimeZone)
+		}
+	}
+
+	start := time.Now().In(loc)
+	if req.Start != "" {
+		t, err := time.Parse(time.RFC3339, req.Start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start time: %s, should be RFC3339", req.Start)
+		}
+		start = t.In(loc)
+	}
+
+	num := req.Num
+	if num <= 0 {
+		num = defaultPreviewNum
+	}
+	if num > maxPreviewNum {
+		num = maxPreviewNum
+	}
+
+	description, err := server.cronParser.Explain(req.Expr)
+	if err != nil {
+		var exprErr *cron.ExprError
+		if !errors.As(err, &exprErr) {
+			return nil, err
+		}
+		return &vo.CronPreviewRespData{
+			Error: &vo.CronExprError{
+				Field:    exprErr.Field,
+				Position: exprErr.Position,
+				Message:  exprErr.Message,
+			},
+		}, nil
+	}
+
+	nexts, err := server.cronParser.NextN(req.Expr, start, num)
+	if err != nil {
+		return nil, err
+	}
+
+	res := vo.CronPreviewRespData{
+		Valid:       true,
+		Description: description,
+		NextFires:   nexts,
+	}
+	if req.JitterSeconds > 0 {
+		timer := po.Timer{Model: gorm.Model{ID: req.TimerID}}
+		res.JitteredFires = timer.JitterTimes(nexts, time.Duration(req.JitterSeconds)*time.Second)
+	}
+	return &res, nil
+}
+
+var _ cronExplainer = &cron.Parser{}
+
+type cronExplainer interface {
+	Explain(cron string) (string, error)
+	NextN(cron string, start time.Time, n int) ([]time.Time, error)
+}
@@ -0,0 +1,215 @@
+package webservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"sort"
+	"strconv"
+	"time"
+	"timer/common/conf"
+	"timer/common/model/vo"
+	"timer/common/utils"
+	"timer/dao/bucket"
+	"timer/pkg/membership"
+	"timer/pkg/redis"
+	migratorservice "timer/service/migrator"
+	schedulerservice "timer/service/scheduler"
+)
+
+const (
+	// 依赖检查的超时时间
+	healthCheckTimeout = 2 * time.Second
+	// 调度循环超过多少个 tick 间隔没有触发，视为不存活
+	schedulerTickTolerance = 50
+	// 迁移循环超过多少个迁移步长没有触发，视为不存活
+	migratorTickTolerance = 2
+)
+
+type HealthServer struct {
+	db              *gorm.DB
+	redisClient     healthRedisClient
+	scheduler       tickReporter
+	migrator        tickReporter
+	registry        nodeRegistry
+	buckets         bucketNumGetter
+	schedulerConfig *conf.SchedulerAppConfig
+	migratorConfig  *conf.MigratorAppConfig
+	databaseConfig  *conf.DatabaseConfig
+}
+
+func NewHealthServer(db *gorm.DB, redisClient *redis.Client, scheduler *schedulerservice.Worker, migrator *migratorservice.Worker,
+	registry *membership.Registry, buckets *bucket.BucketDao, schedulerConfig *conf.SchedulerAppConfig, migratorConfig *conf.MigratorAppConfig,
+	databaseConfig *conf.DatabaseConfig) *HealthServer {
+	return &HealthServer{
+		db:              db,
+		redisClient:     redisClient,
+		scheduler:       scheduler,
+		migrator:        migrator,
+		registry:        registry,
+		buckets:         buckets,
+		schedulerConfig: schedulerConfig,
+		migratorConfig:  migratorConfig,
+		databaseConfig:  databaseConfig,
+	}
+}
+
+// Liveness 存活检查：只检查进程内的调度循环和迁移循环是否还在运行
+func (server *HealthServer) Liveness(ctx context.Context) *vo.HealthRespData {
+	return newHealthRespData(
+		server.checkScheduler(),
+		server.checkMigrator(),
+	)
+}
+
+// Readiness 就绪检查：在存活检查的基础上，检查数据库和 Redis 是否可用
+func (server *HealthServer) Readiness(ctx context.Context) *vo.HealthRespData {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	return newHealthRespData(
+		server.checkDatabase(ctx),
+		server.checkRedis(ctx),
+		server.checkScheduler(),
+		server.checkMigrator(),
+	)
+}
+
+// Cluster 查看存活的调度节点、各分桶的负责节点，以及各调度分片锁和迁移锁当前由哪个节点持有以及何时过期
+func (server *HealthServer) Cluster(ctx context.Context) (*vo.ClusterRespData, error) {
+	bucketsNum, err := server.buckets.GetBucketsNum(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	bucketOwners := make([]*vo.BucketOwner, 0, bucketsNum)
+	for i := 0; i < bucketsNum; i++ {
+		bucketOwners = append(bucketOwners, &vo.BucketOwner{
+			Bucket: i,
+			Node:   server.registry.Owner(strconv.Itoa(i)),
+		})
+	}
+
+	bucketLocks, err := server.listLocks(ctx, utils.TimeBucketLockKeyPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	migratorLocks, err := server.listLocks(ctx, utils.MigratorLockKeyPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vo.ClusterRespData{
+		Nodes:         server.registry.Nodes(),
+		BucketOwners:  bucketOwners,
+		BucketLocks:   bucketLocks,
+		MigratorLocks: migratorLocks,
+	}, nil
+}
+
+func (server *HealthServer) listLocks(ctx context.Context, pattern string) ([]*vo.ClusterLock, error) {
+	locks, err := server.redisClient.ListDistributionLocks(ctx, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterLocks := make([]*vo.ClusterLock, 0, len(locks))
+	for _, lock := range locks {
+		clusterLocks = append(clusterLocks, &vo.ClusterLock{
+			Key:      lock.Key,
+			Node:     utils.GetNodeIDFromToken(lock.Token),
+			Token:    lock.Token,
+			ExpireAt: lock.ExpireAt,
+		})
+	}
+
+	// scan 返回的 key 是无序的，按 key 排序方便查看
+	sort.Slice(clusterLocks, func(i, j int) bool {
+		return clusterLocks[i].Key < clusterLocks[j].Key
+	})
+	return clusterLocks, nil
+}
+
+// checkDatabase 组件名为配置的数据库驱动，例如 mysql、postgres、sqlite
+func (server *HealthServer) checkDatabase(ctx context.Context) *vo.ComponentStatus {
+	sqlDB, err := server.db.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	return newComponentStatus(server.databaseConfig.Driver, err)
+}
+
+func (server *HealthServer) checkRedis(ctx context.Context) *vo.ComponentStatus {
+	return newComponentStatus("redis", server.redisClient.Ping(ctx))
+}
+
+func (server *HealthServer) checkScheduler() *vo.ComponentStatus {
+	tolerance := schedulerTickTolerance * time.Duration(server.schedulerConfig.TryLockGapMilliSeconds) * time.Millisecond
+	return newComponentStatus("scheduler", checkTick(server.scheduler.LastTick(), tolerance))
+}
+
+func (server *HealthServer) checkMigrator() *vo.ComponentStatus {
+	tolerance := migratorTickTolerance * time.Duration(server.migratorConfig.MigrateStepMinutes) * time.Minute
+	return newComponentStatus("migrator", checkTick(server.migrator.LastTick(), tolerance))
+}
+
+func checkTick(lastTick time.Time, tolerance time.Duration) error {
+	if lastTick.IsZero() {
+		return errors.New("loop not started")
+	}
+
+	if since := time.Since(lastTick); since > tolerance {
+		return fmt.Errorf("loop stalled, last tick: %s ago", since.Truncate(time.Millisecond))
+	}
+	return nil
+}
+
+func newComponentStatus(name string, err error) *vo.ComponentStatus {
+	status := vo.ComponentStatus{
+		Name:    name,
+		Healthy: err == nil,
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	return &status
+}
+
+func newHealthRespData(components ...*vo.ComponentStatus) *vo.HealthRespData {
+	healthy := true
+	for _, component := range components {
+		healthy = healthy && component.Healthy
+	}
+	return &vo.HealthRespData{
+		Healthy:    healthy,
+		Components: components,
+	}
+}
+
+var _ healthRedisClient = &redis.Client{}
+var _ tickReporter = &schedulerservice.Worker{}
+var _ tickReporter = &migratorservice.Worker{}
+
+type healthRedisClient interface {
+	Ping(ctx context.Context) error
+	ListDistributionLocks(ctx context.Context, pattern string) ([]*redis.LockInfo, error)
+}
+
+type tickReporter interface {
+	LastTick() time.Time
+}
+
+var _ nodeRegistry = &membership.Registry{}
+
+type nodeRegistry interface {
+	Nodes() []string
+	Owner(resource string) string
+}
+
+var _ bucketNumGetter = &bucket.BucketDao{}
+
+type bucketNumGetter interface {
+	GetBucketsNum(ctx context.Context, t time.Time) (int, error)
+}
@@ -0,0 +1,42 @@
+package webservice
+
+import (
+	"context"
+	"testing"
+	"time"
+	"timer/common/conf"
+	"timer/pkg/testenv"
+)
+
+// fixedTick 最近一次 tick 固定为创建时的时间
+type fixedTick time.Time
+
+func (t fixedTick) LastTick() time.Time {
+	return time.Time(t)
+}
+
+func TestReadinessNamesDatabaseByDriver(t *testing.T) {
+	_, rdb := testenv.NewRedis(t)
+	now := fixedTick(time.Now())
+	server := &HealthServer{
+		db:              testenv.NewDB(t),
+		redisClient:     rdb,
+		scheduler:       now,
+		migrator:        now,
+		schedulerConfig: &conf.SchedulerAppConfig{TryLockGapMilliSeconds: 100},
+		migratorConfig:  &conf.MigratorAppConfig{MigrateStepMinutes: 60},
+		databaseConfig:  &conf.DatabaseConfig{Driver: conf.DriverSQLite},
+	}
+
+	resp := server.Readiness(context.Background())
+	if !resp.Healthy {
+		t.Errorf("readiness unhealthy: %+v", resp.Components)
+	}
+	names := make(map[string]bool)
+	for _, component := range resp.Components {
+		names[component.Name] = true
+	}
+	if !names[conf.DriverSQLite] || names[conf.DriverMySQL] {
+		t.Errorf("components %v, want database named %s", names, conf.DriverSQLite)
+	}
+}
@@ -0,0 +1,70 @@
+package webservice
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"timer/common/consts"
+	"timer/common/model/po"
+	"timer/common/model/vo"
+	"timer/dao/maintenance"
+)
+
+// MaintenanceServer 维护窗口的管理，执行器定期刷新窗口，新建、删除的窗口最多延迟一个刷新间隔生效
+type MaintenanceServer struct {
+	maintenanceDao maintenanceDao
+}
+
+func NewMaintenanceServer(dao *maintenance.MaintenanceDao) *MaintenanceServer {
+	return &MaintenanceServer{
+		maintenanceDao: dao,
+	}
+}
+
+func (server *MaintenanceServer) CreateWindow(ctx context.Context, req *vo.CreateMaintenanceWindowReq) (uint, error) {
+	if req.Policy != consts.MaintenancePolicySkip && req.Policy != consts.MaintenancePolicyDefer {
+		return 0, fmt.Errorf("invalid policy: %s, should be skip or defer", req.Policy)
+	}
+	if !req.EndAt.After(req.StartAt) {
+		return 0, fmt.Errorf("end at %v should be later than start at %v", req.EndAt, req.StartAt)
+	}
+	if !req.EndAt.After(time.Now()) {
+		return 0, fmt.Errorf("end at %v has already passed", req.EndAt)
+	}
+	for _, app := range req.Apps {
+		if app == "" || strings.Contains(app, ",") {
+			return 0, fmt.Errorf("invalid app: %q", app)
+		}
+	}
+
+	return server.maintenanceDao.CreateWindow(ctx, &po.MaintenanceWindow{
+		Apps:    strings.Join(req.Apps, ","),
+		StartAt: req.StartAt,
+		EndAt:   req.EndAt,
+		Policy:  string(req.Policy),
+		Reason:  req.Reason,
+	})
+}
+
+// DeleteWindow 删除窗口，已经暂缓的 task 仍然在原定的结束时间释放
+func (server *MaintenanceServer) DeleteWindow(ctx context.Context, id uint) error {
+	return server.maintenanceDao.DeleteWindow(ctx, id)
+}
+
+// GetWindows 查看尚未结束的窗口
+func (server *MaintenanceServer) GetWindows(ctx context.Context) ([]*vo.MaintenanceWindow, error) {
+	windows, err := server.maintenanceDao.GetWindows(ctx, maintenance.WithEndAfter(time.Now()), maintenance.WithStartAsc())
+	if err != nil {
+		return nil, err
+	}
+	return vo.NewMaintenanceWindows(windows), nil
+}
+
+var _ maintenanceDao = &maintenance.MaintenanceDao{}
+
+type maintenanceDao interface {
+	CreateWindow(ctx context.Context, window *po.MaintenanceWindow) (uint, error)
+	DeleteWindow(ctx context.Context, id uint) error
+	GetWindows(ctx context.Context, opts ...maintenance.Option) ([]*po.MaintenanceWindow, error)
+}
@@ -0,0 +1,126 @@
+package webservice
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+	"timer/common/consts"
+	"timer/common/model/po"
+	"timer/common/model/vo"
+	timerD "timer/dao/timer"
+	"timer/pkg/cron"
+)
+
+// 估算每分钟触发次数时观察的天数，覆盖按周、月、年重复以及只在闰年 2 月 29 日触发的 cron
+const quotaEstimateDays = 4 * 366
+
+// checkCreateQuota 创建定时器时校验 app 的定时器数量，以及 app 已激活的定时器加上该定时器后每分钟的触发次数
+func (server *TimerServer) checkCreateQuota(ctx context.Context, timer *po.Timer) error {
+	quota := server.quotaConfig.GetAppQuota(timer.App)
+
+	if quota.MaxTimers > 0 {
+		cnt, err := server.timerDao.CountTimers(ctx, timerD.WithApp(timer.App))
+		if err != nil {
+			return err
+		}
+		if cnt >= int64(quota.MaxTimers) {
+			return fmt.Errorf("%w: app %s already has %d timers, max timers: %d", vo.ErrQuotaExceeded, timer.App, cnt, quota.MaxTimers)
+		}
+	}
+
+	return server.checkEnableQuota(ctx, server.timerDao, timer)
+}
+
+// checkEnableQuota 激活定时器时校验整个 app 已激活的定时器加上该定时器后每分钟的触发次数
+func (server *TimerServer) checkEnableQuota(ctx context.Context, dao timerDao, timer *po.Timer) error {
+	if server.quotaConfig.GetAppQuota(timer.App).MaxFiresPerMinute <= 0 {
+		return nil
+	}
+
+	enabled, err := dao.GetTimers(ctx, timerD.WithApp(timer.App), timerD.WithStatus(int32(consts.Enabled.ToInt())))
+	if err != nil {
+		return err
+	}
+	return server.checkFiresQuota(timer, enabled)
+}
+
+// checkFiresQuota 按日期叠加全部定时器一天内每分钟的触发次数，任意一天任意一分钟的峰值不能超过 app 每分钟的触发上限
+// 观察 quotaEstimateDays 天，每天零点、每月 1 号这类不在最近一段时间内的峰值也会被统计
+func (server *TimerServer) checkFiresQuota(timer *po.Timer, others []*po.Timer) error {
+	quota := server.quotaConfig.GetAppQuota(timer.App)
+	if quota.MaxFiresPerMinute <= 0 {
+		return nil
+	}
+
+	// 相同 cron 的定时器触发分布相同，合并后只分析一次
+	timers := make([]*po.Timer, 0, len(others)+1)
+	for _, t := range others {
+		// 定时器自身已经激活时不重复统计
+		if timer.ID == 0 || t.ID != timer.ID {
+			timers = append(timers, t)
+		}
+	}
+	timers = append(timers, timer)
+	counts := make(map[string]int)
+	var crons []string
+	for _, t := range timers {
+		// 软删除的定时器不会被 Scan 过滤，这里手动过滤；只作为后继执行的定时器不按时间触发
+		if t.DeletedAt.Valid || t.IsSuccessorOnly() {
+			continue
+		}
+		if counts[t.Cron] == 0 {
+			crons = append(crons, t.Cron)
+		}
+		counts[t.Cron]++
+	}
+
+	now := time.Now()
+	profiles := make([]*cron.DailyProfile, len(crons))
+	// 日期 -> 当天会触发的 cron 在 crons 中的下标
+	firing := make(map[int64][]int)
+	var days []time.Time
+	for i, expr := range crons {
+		profile, err := server.cronParser.Profile(expr, now, quotaEstimateDays)
+		if err != nil {
+			return err
+		}
+		profiles[i] = profile
+		for _, day := range profile.Days {
+			if _, ok := firing[day.Unix()]; !ok {
+				days = append(days, day)
+			}
+			firing[day.Unix()] = append(firing[day.Unix()], i)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	// 当天会触发的 cron 相同的日期，每分钟的触发次数也相同，只统计第一次出现的日期
+	checked := make(map[string]bool)
+	for _, day := range days {
+		indexes := firing[day.Unix()]
+		key := fmt.Sprint(indexes)
+		if checked[key] {
+			continue
+		}
+		checked[key] = true
+
+		firesPerMinute := make(map[int]int)
+		for _, i := range indexes {
+			for minute, fires := range profiles[i].Minutes {
+				firesPerMinute[minute] += fires * counts[crons[i]]
+			}
+		}
+		peak := -1
+		for minute, fires := range firesPerMinute {
+			if fires > quota.MaxFiresPerMinute && (peak < 0 || minute < peak) {
+				peak = minute
+			}
+		}
+		if peak >= 0 {
+			return fmt.Errorf("%w: app %s would fire more than %d times at %s", vo.ErrQuotaExceeded,
+				timer.App, quota.MaxFiresPerMinute, day.Add(time.Duration(peak)*time.Minute).Format(consts.MinuteFormat))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package webservice
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"timer/common/conf"
+	"timer/common/consts"
+	"timer/common/model/po"
+	"timer/common/model/vo"
+	timerD "timer/dao/timer"
+	"timer/pkg/cron"
+	"timer/pkg/testenv"
+)
+
+func TestCheckCreateQuotaCountsPeaks(t *testing.T) {
+	ctx := context.Background()
+	db := testenv.NewDB(t)
+	server := &TimerServer{
+		timerDao:    timerD.NewTimerDao(db),
+		cronParser:  cron.NewCronParser(),
+		quotaConfig: &conf.QuotaConfig{Default: &conf.AppQuota{MaxFiresPerMinute: 2}},
+	}
+
+	// 每月 1 号零点已经有 2 次触发，未激活的定时器和其他 app 的定时器不计入
+	monthly := "0 0 0 1 * * *"
+	timers := []*po.Timer{
+		{App: "app", Name: "monthly-1", Status: consts.Enabled.ToInt(), Cron: monthly, NotifyHTTPParam: "{}"},
+		{App: "app", Name: "monthly-2", Status: consts.Enabled.ToInt(), Cron: monthly, NotifyHTTPParam: "{}"},
+		{App: "app", Name: "unabled", Status: consts.Unabled.ToInt(), Cron: monthly, NotifyHTTPParam: "{}"},
+		{App: "other", Name: "monthly", Status: consts.Enabled.ToInt(), Cron: monthly, NotifyHTTPParam: "{}"},
+	}
+	if err := db.Table(po.TimerTable).Create(timers).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		cron     string
+		exceeded bool
+	}{
+		{name: "same monthly peak", cron: monthly, exceeded: true},
+		{name: "daily overlaps the monthly peak", cron: "0 0 0 * * * *", exceeded: true},
+		{name: "another day of month", cron: "0 0 0 2 * * *"},
+		{name: "another minute", cron: "0 1 0 * * * *"},
+		{name: "successor only", cron: ""},
+	}
+	for _, c := range cases {
+		err := server.checkCreateQuota(ctx, &po.Timer{App: "app", Cron: c.cron})
+		if got := errors.Is(err, vo.ErrQuotaExceeded); got != c.exceeded || err != nil && !got {
+			t.Errorf("%s: %v, exceeded: %t", c.name, err, c.exceeded)
+		}
+	}
+}
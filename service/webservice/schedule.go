@@ -0,0 +1,49 @@
+package webservice
+
+import (
+	"fmt"
+	"time"
+	"timer/common/model/po"
+	"timer/common/model/vo"
+)
+
+// analyzeSchedule 分析 cron 表达式的调度密度，过于密集的表达式直接拒绝，永远不会触发的表达式给出告警
+func (server *TimerServer) analyzeSchedule(cron string) (*vo.ScheduleAnalysis, error) {
+	analysis, err := server.cronParser.Analyze(cron, time.Now(), server.scheduleConfig.PreviewNum)
+	if err != nil {
+		return nil, err
+	}
+
+	res := vo.ScheduleAnalysis{
+		MinIntervalSeconds: int64(analysis.MinInterval / time.Second),
+		FiresPerDay:        analysis.FiresPerDay,
+		NextFires:          analysis.Nexts,
+	}
+
+	if analysis.NeverFires {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("cron expression %q never fires", cron))
+		return &res, nil
+	}
+
+	if floor := time.Duration(server.scheduleConfig.MinIntervalSeconds) * time.Second; floor > 0 && analysis.MinInterval > 0 && analysis.MinInterval < floor {
+		return nil, fmt.Errorf("%w: min interval %v is less than %v", vo.ErrScheduleTooDense, analysis.MinInterval, floor)
+	}
+
+	if limit := server.scheduleConfig.MaxFiresPerDay; limit > 0 && analysis.FiresPerDay > limit {
+		return nil, fmt.Errorf("%w: fires %d times per day, max: %d", vo.ErrScheduleTooDense, analysis.FiresPerDay, limit)
+	}
+
+	return &res, nil
+}
+
+// previewJitter 在调度分析结果中补充抖动窗口以及实际的执行时间
+// fires 为日历过滤之前的触发时间，先偏移再按日历过滤，与迁移器生成 task 时一致
+func (server *TimerServer) previewJitter(schedule *vo.ScheduleAnalysis, timer *po.Timer, rules po.CalendarRules, fires []time.Time) {
+	window := timer.JitterWindow(server.quotaConfig.GetAppQuota(timer.App).GetSpread())
+	if window <= 0 {
+		return
+	}
+
+	schedule.JitterSeconds = int(window / time.Second)
+	schedule.JitteredFires = rules.Filter(timer, timer.JitterTimes(fires, window))
+}
@@ -0,0 +1,153 @@
+package webservice
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"timer/common/model/po"
+	"timer/common/model/vo"
+	timerD "timer/dao/timer"
+)
+
+// 后继 id 列的长度，与 timer 表 on_success_ids、on_failure_ids 的定义一致
+const maxSuccessorIDsLen = 255
+
+// UpdateSuccessors 覆盖定时器的后继，修改前校验不能形成环
+// 校验与修改在锁住定时器的事务中执行，检查环时对遍历到的定时器加锁读：并发修改（例如 A -> B 与 B -> A）时，
+// 后执行的一方会读到先提交的后继从而发现环，或者因为互相等待被数据库回滚，两者不会同时成功
+func (server *TimerServer) UpdateSuccessors(ctx context.Context, req *vo.UpdateSuccessorsReq) error {
+	return server.timerDao.DoWithTransactionAndLock(ctx, req.ID, func(ctx context.Context, dao timerD.Repository, timer *po.Timer) error {
+		if timer.App != req.App {
+			return vo.ErrForbidden
+		}
+
+		timer.SetOnSuccessIDs(req.OnSuccess)
+		timer.SetOnFailureIDs(req.OnFailure)
+		if req.PassOutput != nil {
+			timer.PassOutput = *req.PassOutput
+		}
+		if err := checkSuccessors(ctx, dao, timer); err != nil {
+			return err
+		}
+		return dao.UpdateSuccessors(ctx, timer)
+	})
+}
+
+// checkSuccessors 校验后继定时器必须存在且属于同一个 app，并且从后继出发不能回到定时器自身
+func checkSuccessors(ctx context.Context, dao timerDao, timer *po.Timer) error {
+	if len(timer.OnSuccessIDs) > maxSuccessorIDsLen || len(timer.OnFailureIDs) > maxSuccessorIDsLen {
+		return fmt.Errorf("%w: too many successors", vo.ErrSuccessorUnValid)
+	}
+
+	onSuccess, onFailure := timer.GetOnSuccessIDs(), timer.GetOnFailureIDs()
+	if err := checkDuplicated(onSuccess); err != nil {
+		return err
+	}
+	if err := checkDuplicated(onFailure); err != nil {
+		return err
+	}
+
+	ids := make([]uint, 0, len(onSuccess)+len(onFailure))
+	ids = append(append(ids, onSuccess...), onFailure...)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	timers, err := dao.GetTimers(ctx, timerD.WithIDs(ids))
+	if err != nil {
+		return err
+	}
+	found := make(map[uint]bool, len(timers))
+	for _, t := range timers {
+		// 软删除的定时器不会被 Scan 过滤，这里手动过滤
+		if t.DeletedAt.Valid {
+			continue
+		}
+		if t.App != timer.App {
+			return fmt.Errorf("%w: timer %d not belongs to app %s", vo.ErrSuccessorUnValid, t.ID, timer.App)
+		}
+		found[t.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return fmt.Errorf("%w: timer %d not found", vo.ErrSuccessorUnValid, id)
+		}
+	}
+
+	return checkCycle(ctx, dao, timer, ids)
+}
+
+// checkCycle 从后继出发按层遍历整个后继图，遍历到定时器自身说明形成了环
+// 新建的定时器还没有 id，后继只能引用已经存在的定时器，这时不会形成环，环只会在修改后继时出现
+func checkCycle(ctx context.Context, dao timerDao, timer *po.Timer, ids []uint) error {
+	if timer.ID == 0 {
+		return nil
+	}
+
+	// 遍历到的定时器由哪个定时器引用，用于还原环的路径
+	from := make(map[uint]uint, len(ids))
+	frontier := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if id == timer.ID {
+			return fmt.Errorf("%w: cycle detected: %s", vo.ErrSuccessorUnValid, successorPath(from, timer.ID, timer.ID))
+		}
+		if _, ok := from[id]; !ok {
+			from[id] = timer.ID
+			frontier = append(frontier, id)
+		}
+	}
+
+	for len(frontier) > 0 {
+		// 加锁读，并发修改后继的事务不能在本事务提交之前改变遍历到的定时器
+		timers, err := dao.GetTimers(ctx, timerD.WithIDs(frontier), timerD.WithForUpdate())
+		if err != nil {
+			return err
+		}
+
+		var next []uint
+		for _, t := range timers {
+			if t.DeletedAt.Valid {
+				continue
+			}
+			for _, id := range append(t.GetOnSuccessIDs(), t.GetOnFailureIDs()...) {
+				if id == timer.ID {
+					return fmt.Errorf("%w: cycle detected: %s", vo.ErrSuccessorUnValid, successorPath(from, timer.ID, t.ID))
+				}
+				if _, ok := from[id]; ok {
+					continue
+				}
+				from[id] = t.ID
+				next = append(next, id)
+			}
+		}
+		frontier = next
+	}
+	return nil
+}
+
+// successorPath 还原从 root 出发经过 last 再回到 root 的路径，例如 1 -> 2 -> 1
+func successorPath(from map[uint]uint, root, last uint) string {
+	path := []string{strconv.FormatUint(uint64(root), 10)}
+	for cur := last; cur != root; cur = from[cur] {
+		path = append(path, strconv.FormatUint(uint64(cur), 10))
+	}
+	path = append(path, strconv.FormatUint(uint64(root), 10))
+
+	// 上面是从 last 倒推的，反转成正向
+	for i, j := 1, len(path)-2; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return strings.Join(path, " -> ")
+}
+
+func checkDuplicated(ids []uint) error {
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return fmt.Errorf("%w: timer %d duplicated", vo.ErrSuccessorUnValid, id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package webservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"timer/common/consts"
+	"timer/common/model/po"
+	"timer/common/model/vo"
+	timerD "timer/dao/timer"
+	"timer/pkg/testenv"
+)
+
+func TestUpdateSuccessorsRejectsCycle(t *testing.T) {
+	ctx := context.Background()
+	db := testenv.NewDB(t)
+	server := &TimerServer{timerDao: timerD.NewTimerDao(db)}
+
+	// 1 -> 2 -> 3，4 属于其他 app
+	timers := make([]*po.Timer, 4)
+	for i := range timers {
+		timers[i] = &po.Timer{App: "app", Name: fmt.Sprintf("timer-%d", i+1), Status: consts.Enabled.ToInt(), NotifyHTTPParam: "{}"}
+	}
+	timers[3].App = "other"
+	if err := db.Table(po.TimerTable).Create(timers).Error; err != nil {
+		t.Fatal(err)
+	}
+	id := func(i int) uint { return timers[i-1].ID }
+	for _, edge := range [][2]int{{1, 2}, {2, 3}} {
+		if err := server.UpdateSuccessors(ctx, &vo.UpdateSuccessorsReq{App: "app", ID: id(edge[0]), OnSuccess: []uint{id(edge[1])}}); err != nil {
+			t.Fatalf("add successor %d -> %d: %v", edge[0], edge[1], err)
+		}
+	}
+
+	cases := []struct {
+		name      string
+		timer     int
+		onSuccess []uint
+		onFailure []uint
+		wantPath  string // 为空时不应该报错
+	}{
+		{name: "self", timer: 1, onFailure: []uint{id(1)}, wantPath: fmt.Sprintf("%d -> %d", id(1), id(1))},
+		{name: "direct", timer: 2, onFailure: []uint{id(1)}, wantPath: fmt.Sprintf("%d -> %d -> %d", id(2), id(1), id(2))},
+		{name: "indirect", timer: 3, onSuccess: []uint{id(1)}, wantPath: fmt.Sprintf("%d -> %d -> %d -> %d", id(3), id(1), id(2), id(3))},
+		{name: "diamond", timer: 1, onSuccess: []uint{id(2)}, onFailure: []uint{id(3)}},
+	}
+	for _, c := range cases {
+		err := server.UpdateSuccessors(ctx, &vo.UpdateSuccessorsReq{App: "app", ID: id(c.timer), OnSuccess: c.onSuccess, OnFailure: c.onFailure})
+		if c.wantPath == "" {
+			if err != nil {
+				t.Errorf("%s: %v", c.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, vo.ErrSuccessorUnValid) || !strings.HasSuffix(err.Error(), c.wantPath) {
+			t.Errorf("%s: %v, want cycle %s", c.name, err, c.wantPath)
+		}
+	}
+
+	// 被拒绝的修改不会落库
+	got, err := server.timerDao.GetTimer(ctx, timerD.WithID(id(3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := got.GetOnSuccessIDs(); len(ids) != 0 {
+		t.Errorf("timer 3 successors %v after rejected update", ids)
+	}
+
+	// 后继必须属于同一个 app
+	if err := server.UpdateSuccessors(ctx, &vo.UpdateSuccessorsReq{App: "app", ID: id(3), OnSuccess: []uint{id(4)}}); !errors.Is(err, vo.ErrSuccessorUnValid) {
+		t.Errorf("successor of another app: %v, want %v", err, vo.ErrSuccessorUnValid)
+	}
+}
+
+// rendezvous 让两个协程的写入两两配对执行，等不到对方时超时后单独执行
+type rendezvous struct {
+	mu      sync.Mutex
+	waiting chan struct{}
+}
+
+func (r *rendezvous) meet(timeout time.Duration) {
+	r.mu.Lock()
+	if r.waiting != nil {
+		close(r.waiting)
+		r.waiting = nil
+		r.mu.Unlock()
+		return
+	}
+	ch := make(chan struct{})
+	r.waiting = ch
+	r.mu.Unlock()
+
+	select {
+	case <-ch:
+	case <-time.After(timeout):
+		r.mu.Lock()
+		if r.waiting == ch {
+			r.waiting = nil
+		}
+		r.mu.Unlock()
+	}
+}
+
+func TestUpdateSuccessorsConcurrently(t *testing.T) {
+	ctx := context.Background()
+	db := testenv.NewDB(t)
+	server := &TimerServer{timerDao: timerD.NewTimerDao(db)}
+
+	timers := []*po.Timer{
+		{App: "app", Name: "a", Status: consts.Enabled.ToInt(), NotifyHTTPParam: "{}"},
+		{App: "app", Name: "b", Status: consts.Enabled.ToInt(), NotifyHTTPParam: "{}"},
+	}
+	if err := db.Table(po.TimerTable).Create(timers).Error; err != nil {
+		t.Fatal(err)
+	}
+	a, b := timers[0].ID, timers[1].ID
+
+	// 两个修改都完成检查之后才写入，没有串行化时两者都会在对方写入之前完成环的检查
+	var r rendezvous
+	if err := db.Callback().Update().Before("gorm:begin_transaction").Register("test:meet", func(tx *gorm.DB) {
+		if tx.Statement.Table == po.TimerTable {
+			r.meet(100 * time.Millisecond)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 同时修改 a -> b 与 b -> a，最多只有一个成功
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, 2)
+	)
+	for i, edge := range [][2]uint{{a, b}, {b, a}} {
+		wg.Add(1)
+		go func(i int, edge [2]uint) {
+			defer wg.Done()
+			errs[i] = server.UpdateSuccessors(ctx, &vo.UpdateSuccessorsReq{App: "app", ID: edge[0], OnSuccess: []uint{edge[1]}})
+		}(i, edge)
+	}
+	wg.Wait()
+
+	if errs[0] == nil && errs[1] == nil {
+		t.Fatal("both a -> b and b -> a persisted")
+	}
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, vo.ErrSuccessorUnValid) {
+			t.Errorf("concurrent update: %v, want %v", err, vo.ErrSuccessorUnValid)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package webservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+	"timer/common/model/po"
+	"timer/common/model/vo"
+	"timer/dao/task"
+)
+
+const (
+	defaultTaskPageSize = 20
+	maxTaskPageSize     = 500
+	// 一条执行链最多返回的 task 数量，防止扇出很大的后继把整张表查出来
+	maxChainTasks = 500
+)
+
+type TaskServer struct {
+	taskDao taskQueryDao
+}
+
+func NewTaskServer(taskDao task.Repository) *TaskServer {
+	return &TaskServer{
+		taskDao: taskDao,
+	}
+}
+
+// GetTasks 按执行时间倒序查询 app 下的 task，可以按定时器、状态以及执行时间过滤
+func (server *TaskServer) GetTasks(ctx context.Context, req *vo.GetTasksReq) ([]*vo.Task, error) {
+	opts := []task.Option{task.WithApp(req.App)}
+	if req.TimerID > 0 {
+		opts = append(opts, task.WithTimerID(req.TimerID))
+	}
+	if req.Status != nil {
+		opts = append(opts, task.WithStatus(int32(*req.Status)))
+	}
+	if req.Start != "" {
+		start, err := time.Parse(time.RFC3339, req.Start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start time: %s, should be RFC3339", req.Start)
+		}
+		opts = append(opts, task.WithStartTime(start))
+	}
+	if req.End != "" {
+		end, err := time.Parse(time.RFC3339, req.End)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end time: %s, should be RFC3339", req.End)
+		}
+		opts = append(opts, task.WithEndTime(end))
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultTaskPageSize
+	}
+	if limit > maxTaskPageSize {
+		limit = maxTaskPageSize
+	}
+	offset := req.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	opts = append(opts, task.WithDesc(), task.WithPageLimit(offset, limit))
+
+	tasks, err := server.taskDao.GetTasks(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return vo.NewTasks(tasks), nil
+}
+
+// GetTaskChain 查询 task 所在的整条执行链：先沿前驱找到按时间触发的根 task，再按层找出根 task 触发的全部后继
+func (server *TaskServer) GetTaskChain(ctx context.Context, app string, id uint) (*vo.TaskChain, error) {
+	root, err := server.taskDao.GetTask(ctx, task.WithTaskID(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %d", vo.ErrTaskNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if root.App != app {
+		return nil, vo.ErrForbidden
+	}
+	for depth := 0; root.ParentTaskID != 0 && depth < maxChainTasks; depth++ {
+		parent, err := server.taskDao.GetTask(ctx, task.WithTaskID(root.ParentTaskID))
+		if err != nil {
+			return nil, fmt.Errorf("get parent task failed, id: %d, err: %w", root.ParentTaskID, err)
+		}
+		root = parent
+	}
+
+	tasks := []*po.Task{root}
+	for level := []uint{root.ID}; len(level) > 0 && len(tasks) < maxChainTasks; {
+		children, err := server.taskDao.GetTasks(ctx, task.WithParentTaskIDs(level), task.WithIDAsc(),
+			task.WithPageLimit(0, maxChainTasks-len(tasks)))
+		if err != nil {
+			return nil, err
+		}
+		level = level[:0]
+		for _, child := range children {
+			tasks = append(tasks, child)
+			level = append(level, child.ID)
+		}
+	}
+
+	return &vo.TaskChain{
+		RootID: root.ID,
+		Tasks:  vo.NewTasks(tasks),
+	}, nil
+}
+
+var _ taskQueryDao = task.Repository(nil)
+
+type taskQueryDao interface {
+	GetTask(ctx context.Context, opts ...task.Option) (*po.Task, error)
+	GetTasks(ctx context.Context, opts ...task.Option) ([]*po.Task, error)
+}
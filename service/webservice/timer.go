@@ -10,54 +10,117 @@ import (
 	"timer/common/model/po"
 	"timer/common/model/vo"
 	timerUtil "timer/common/utils"
+	calendarD "timer/dao/calendar"
 	"timer/dao/task"
 	timerD "timer/dao/timer"
 	"timer/pkg/cron"
 )
 
 type TimerServer struct {
-	timerDao      timerDao
-	taskDao       taskDao
-	taskCache     taskCache
-	cronParser    cronParser
-	migrateConfig *conf.MigratorAppConfig
+	timerDao       timerDao
+	taskDao        taskDao
+	taskCache      taskCache
+	calendarDao    calendarDao
+	cronParser     cronParser
+	migrateConfig  *conf.MigratorAppConfig
+	quotaConfig    *conf.QuotaConfig
+	scheduleConfig *conf.ScheduleConfig
 }
 
-func NewTimerServer(timer *timerD.TimerDao, task *task.TaskDao, taskCache *task.TaskCache, parser *cron.Parser, config *conf.MigratorAppConfig) *TimerServer {
+func NewTimerServer(timer timerD.Repository, task task.Repository, taskCache *task.TaskCache, calendar *calendarD.CalendarDao, parser *cron.Parser,
+	config *conf.MigratorAppConfig, quotaConfig *conf.QuotaConfig, scheduleConfig *conf.ScheduleConfig) *TimerServer {
 	return &TimerServer{
-		timerDao:      timer,
-		taskDao:       task,
-		cronParser:    parser,
-		migrateConfig: config,
-		taskCache:     taskCache,
+		timerDao:       timer,
+		taskDao:        task,
+		calendarDao:    calendar,
+		cronParser:     parser,
+		migrateConfig:  config,
+		taskCache:      taskCache,
+		quotaConfig:    quotaConfig,
+		scheduleConfig: scheduleConfig,
 	}
 }
 
-func (server *TimerServer) CreateTimer(ctx context.Context, timer *vo.Timer) (uint, error) {
-	// 判断 cron 表达式是否有效
-	if !server.cronParser.IsValidCronExpr(timer.Cron) {
-		return 0, vo.ErrCronExprUnValid
+func (server *TimerServer) CreateTimer(ctx context.Context, timer *vo.Timer) (*vo.CreateTimerRespData, error) {
+	var (
+		schedule *vo.ScheduleAnalysis
+		err      error
+	)
+	// cron 为空的定时器只作为其他定时器的后继执行，没有调度分析
+	if timer.Cron != "" {
+		// 判断 cron 表达式是否有效
+		if !server.cronParser.IsValidCronExpr(timer.Cron) {
+			return nil, vo.ErrCronExprUnValid
+		}
+
+		// 分析调度密度，并生成触发时间预览
+		if schedule, err = server.analyzeSchedule(timer.Cron); err != nil {
+			return nil, err
+		}
+	}
+
+	// 校验抖动窗口，-1 代表不抖动
+	if max := server.scheduleConfig.MaxJitterSeconds; timer.JitterSeconds < po.JitterDisabled || (max > 0 && timer.JitterSeconds > max) {
+		return nil, fmt.Errorf("%w: %d, should be between %d and %d", vo.ErrJitterUnValid, timer.JitterSeconds, po.JitterDisabled, max)
 	}
 
 	// 转换成数据库映射 struct
 	poTimer, err := timer.ToPo()
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+
+	// 校验引用的日历，预览中去掉被日历排除的触发时间
+	rules, err := server.checkCalendars(ctx, poTimer)
+	if err != nil {
+		return nil, err
+	}
+	var fires []time.Time
+	if schedule != nil {
+		fires = schedule.NextFires
+		schedule.NextFires = rules.Filter(poTimer, fires)
+	}
+
+	// 校验后继定时器，不能形成环
+	if err = checkSuccessors(ctx, server.timerDao, poTimer); err != nil {
+		return nil, err
+	}
+
+	// 校验 app 配额
+	if err = server.checkCreateQuota(ctx, poTimer); err != nil {
+		return nil, err
+	}
+
+	id, err := server.timerDao.CreateTimer(ctx, poTimer)
+	if err != nil {
+		return nil, err
+	}
+
+	// 抖动偏移由定时器 id 决定，创建成功之后才能给出实际的执行时间
+	if schedule != nil {
+		server.previewJitter(schedule, poTimer, rules, fires)
 	}
 
-	return server.timerDao.CreateTimer(ctx, poTimer)
+	return &vo.CreateTimerRespData{
+		Id:       id,
+		Success:  true,
+		Schedule: schedule,
+	}, nil
 }
 
-func (server *TimerServer) DeleteTimer(ctx context.Context, id uint) error {
+func (server *TimerServer) DeleteTimer(ctx context.Context, app string, id uint) error {
+	if err := server.checkTimerApp(ctx, app, id); err != nil {
+		return err
+	}
 	return server.timerDao.DeleteTimer(ctx, id)
 }
 
-func (server *TimerServer) EnableTimer(ctx context.Context, id uint) error {
+func (server *TimerServer) EnableTimer(ctx context.Context, app string, id uint) error {
 	timer := &po.Timer{}
 	timer.ID = id
 
 	// 整个 MySQL 操作是事务+独占锁
-	do := func(ctx context.Context, dao *timerD.TimerDao, timer *po.Timer) error {
+	do := func(ctx context.Context, dao timerD.Repository, timer *po.Timer) error {
 		var err error
 
 		// 获取 timer 完整定义
@@ -66,11 +129,25 @@ func (server *TimerServer) EnableTimer(ctx context.Context, id uint) error {
 			fmt.Print(err)
 			return err
 		}
+		// 只能操作自己 app 下的定时器
+		if timer.App != app {
+			return vo.ErrForbidden
+		}
 		// 校验是否处于非激活状态
 		if timer.Status != consts.Unabled.ToInt() {
 			return fmt.Errorf("not unabled status, enable failed, timer id: %d", timer.ID)
 		}
 
+		// 只作为后继执行的定时器不按时间生成 task，激活后才能被前驱触发
+		if timer.IsSuccessorOnly() {
+			return dao.UpdateTimerStatus(ctx, timer.ID, int(consts.Enabled))
+		}
+
+		// 校验激活后整个 app 每分钟的触发次数是否超过配额
+		if err = server.checkEnableQuota(ctx, dao, timer); err != nil {
+			return err
+		}
+
 		start := time.Now()
 		// 获取两倍一级迁移的时间
 		end := timerUtil.GetForwardTwoMigrateStepEnd(start, time.Duration(server.migrateConfig.MigrateStepMinutes)*time.Minute)
@@ -81,11 +158,19 @@ func (server *TimerServer) EnableTimer(ctx context.Context, id uint) error {
 			return err
 		}
 
+		// 执行时间在抖动窗口内偏移，再去掉被引用的日历排除的部分，与迁移器生成 task 时一致
+		rules, err := server.getCalendarRules(ctx, timer)
+		if err != nil {
+			return err
+		}
+		runTimers := timer.JitterTimes(executeTimers, timer.JitterWindow(server.quotaConfig.GetAppQuota(timer.App).GetSpread()))
+		runTimers = rules.Filter(timer, runTimers)
+
 		// 根据执行时间批量生成定时任务
-		tasks := timer.BatchTasksFromTimer(executeTimers)
+		tasks := timer.BatchTasksFromTimer(runTimers)
 
 		// task 插入 mysql 中
-		err = server.taskDao.BatchCreateTasks(tasks)
+		err = server.taskDao.BatchCreateTasks(ctx, tasks)
 		if err != nil {
 			return err
 		}
@@ -97,31 +182,95 @@ func (server *TimerServer) EnableTimer(ctx context.Context, id uint) error {
 			return err
 		}
 
-		// 修改数据库中 timer 状态为激活态
-		return server.timerDao.UpdateTimerStatus(ctx, timer.ID, int(consts.Enabled))
+		// 记录已经生成 task 的截止时间，迁移器从该时间之后继续生成
+		if err = dao.UpdateGeneratedThrough(ctx, []uint{timer.ID}, end); err != nil {
+			return err
+		}
+
+		// 修改数据库中 timer 状态为激活态，需要在同一个事务中，否则会被事务持有的行锁阻塞
+		return dao.UpdateTimerStatus(ctx, timer.ID, int(consts.Enabled))
 	}
 
 	return server.timerDao.DoWithTransactionAndLock(ctx, id, do)
 }
 
-func (server *TimerServer) UnableTimer(ctx *gin.Context, id uint) error {
+func (server *TimerServer) UnableTimer(ctx *gin.Context, app string, id uint) error {
+	if err := server.checkTimerApp(ctx, app, id); err != nil {
+		return err
+	}
 	// 其实应该先检验是否处于非激活状态，这里简单就不检验了
 	return server.timerDao.UpdateTimerStatus(ctx, id, consts.Unabled.ToInt())
 }
 
-var _ timerDao = &timerD.TimerDao{}
+// checkTimerApp 校验定时器是否属于该 app，防止通过 id 操作其他 app 的定时器
+func (server *TimerServer) checkTimerApp(ctx context.Context, app string, id uint) error {
+	timer, err := server.timerDao.GetTimer(ctx, timerD.WithID(id))
+	if err != nil {
+		return err
+	}
+	if timer.App != app {
+		return vo.ErrForbidden
+	}
+	return nil
+}
+
+// checkCalendars 校验引用的日历必须存在且属于同一个 app，并加载排除规则
+func (server *TimerServer) checkCalendars(ctx context.Context, timer *po.Timer) (po.CalendarRules, error) {
+	ids := timer.GetCalendarIDs()
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	calendars, err := server.calendarDao.GetCalendars(ctx, calendarD.WithIDs(ids))
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[uint]bool, len(calendars))
+	for _, c := range calendars {
+		if c.App != timer.App {
+			return nil, fmt.Errorf("%w: calendar %d not belongs to app %s", vo.ErrCalendarUnValid, c.ID, timer.App)
+		}
+		found[c.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return nil, fmt.Errorf("%w: calendar %d not found", vo.ErrCalendarUnValid, id)
+		}
+	}
+	return po.NewCalendarRules(calendars)
+}
+
+// getCalendarRules 加载定时器引用的日历的排除规则，已经删除的日历忽略
+func (server *TimerServer) getCalendarRules(ctx context.Context, timer *po.Timer) (po.CalendarRules, error) {
+	ids := timer.GetCalendarIDs()
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	calendars, err := server.calendarDao.GetCalendars(ctx, calendarD.WithIDs(ids), calendarD.WithApp(timer.App))
+	if err != nil {
+		return nil, err
+	}
+	return po.NewCalendarRules(calendars)
+}
+
+var _ timerDao = timerD.Repository(nil)
 var _ cronParser = &cron.Parser{}
 
 type timerDao interface {
 	CreateTimer(context.Context, *po.Timer) (uint, error)
 	DeleteTimer(ctx context.Context, in uint) error
 	GetTimerByID(context.Context, *po.Timer) error
-	DoWithTransactionAndLock(ctx context.Context, uid uint, do func(context.Context, *timerD.TimerDao, *po.Timer) error) error
+	DoWithTransactionAndLock(ctx context.Context, uid uint, do func(context.Context, timerD.Repository, *po.Timer) error) error
 	UpdateTimerStatus(ctx context.Context, id uint, timerStatus int) error
+	UpdateSuccessors(ctx context.Context, timer *po.Timer) error
+	GetTimer(ctx context.Context, opts ...timerD.Option) (*po.Timer, error)
+	GetTimers(ctx context.Context, opts ...timerD.Option) ([]*po.Timer, error)
+	CountTimers(ctx context.Context, opts ...timerD.Option) (int64, error)
 }
 
 type taskDao interface {
-	BatchCreateTasks(task []*po.Task) error
+	BatchCreateTasks(ctx context.Context, tasks []*po.Task) error
 }
 
 type taskCache interface {
@@ -131,4 +280,7 @@ type taskCache interface {
 type cronParser interface {
 	IsValidCronExpr(string) bool
 	NextsBefore(cron string, end time.Time) ([]time.Time, error)
+	NextsBetween(cron string, start, end time.Time) ([]time.Time, error)
+	Analyze(cron string, from time.Time, previewNum int) (*cron.Analysis, error)
+	Profile(cron string, from time.Time, days int) (*cron.DailyProfile, error)
 }